@@ -227,6 +227,25 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 		config.Options.GlobalValues["runtime"]["buildVersion"] = version
 	}
 
+	// Set the USB identity of the device, unless the same globals are already
+	// set with -ldflags=-X.
+	usbIdentity, err := config.USBIdentity()
+	if err != nil {
+		return err
+	}
+	for name, value := range usbIdentity {
+		if config.Options.GlobalValues["machine"][name] != "" {
+			continue
+		}
+		if config.Options.GlobalValues == nil {
+			config.Options.GlobalValues = make(map[string]map[string]string)
+		}
+		if config.Options.GlobalValues["machine"] == nil {
+			config.Options.GlobalValues["machine"] = make(map[string]string)
+		}
+		config.Options.GlobalValues["machine"][name] = value
+	}
+
 	var embedFileObjects []*compileJob
 	for _, pkg := range lprogram.Sorted() {
 		pkg := pkg // necessary to avoid a race condition
@@ -563,6 +582,12 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 				return err
 			}
 
+			// Add coverage counters for the fuzzing engine. This is done after
+			// optimization so that only the remaining basic blocks are counted.
+			if config.FuzzCoverage() {
+				transform.AddFuzzCoverage(mod)
+			}
+
 			// Make sure stack sizes are loaded from a separate section so they can be
 			// modified after linking.
 			if config.AutomaticStackSize() {
@@ -838,7 +863,7 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 			}
 
 			// Print code size if requested.
-			if config.Options.PrintSizes == "short" || config.Options.PrintSizes == "full" {
+			if config.Options.PrintSizes == "short" || config.Options.PrintSizes == "full" || config.Options.PrintSizes == "json" {
 				packagePathMap := make(map[string]string, len(lprogram.Packages))
 				for _, pkg := range lprogram.Sorted() {
 					packagePathMap[pkg.OriginalDir()] = pkg.Pkg.Path()
@@ -847,7 +872,16 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 				if err != nil {
 					return err
 				}
-				if config.Options.PrintSizes == "short" {
+				if config.Options.PrintSizes == "json" {
+					err := sizes.writeJSON(os.Stdout)
+					if err != nil {
+						return err
+					}
+					if sizes.NoSymbols {
+						// Print to stderr to keep the JSON output valid.
+						fmt.Fprintln(os.Stderr, "warning: per-symbol sizes are only available for ELF files")
+					}
+				} else if config.Options.PrintSizes == "short" {
 					fmt.Printf("   code    data     bss |   flash     ram\n")
 					fmt.Printf("%7d %7d %7d | %7d %7d\n", sizes.Code+sizes.ROData, sizes.Data, sizes.BSS, sizes.Flash(), sizes.RAM())
 				} else {
@@ -870,6 +904,14 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 				printStacks(calculatedStacks, stackSizes)
 			}
 
+			// Explain why a symbol or package is part of the binary.
+			if config.Options.Why != "" {
+				err := printWhy(os.Stdout, mod, executable, config.Options.Why)
+				if err != nil {
+					return err
+				}
+			}
+
 			return nil
 		},
 	}
@@ -1052,10 +1094,13 @@ func createEmbedObjectFile(data, hexSum, sourceFile, sourceDir, tmpdir string, c
 // needed to convert a program to its final form. Some transformations are not
 // optional and must be run as the compiler expects them to run.
 func optimizeProgram(mod llvm.Module, config *compileopts.Config) error {
-	err := interp.Run(mod, config.Options.InterpTimeout, config.DumpSSA())
+	fallbacks, err := interp.Run(mod, config.Options.InterpTimeout, config.DumpSSA())
 	if err != nil {
 		return err
 	}
+	if config.Options.PrintInterp {
+		printInterpFallbacks(fallbacks)
+	}
 	if config.VerifyIR() {
 		// Only verify if we really need it.
 		// The IR has already been verified before writing the bitcode to disk
@@ -1286,6 +1331,20 @@ func determineStackSizes(mod llvm.Module, executable string) ([]string, map[stri
 		}
 	}
 
+	// On RISC-V, interrupts run on the stack of the interrupted goroutine so
+	// they need to be included in the goroutine stack size.
+	if f.Machine == elf.EM_RISCV {
+		if funcs := functions["handleInterruptASM"]; len(funcs) == 1 {
+			stackSize, stackSizeType, missingStackSize := funcs[0].StackSize()
+			sizes["handleInterruptASM"] = functionStackSize{
+				stackSize:        stackSize,
+				stackSizeType:    stackSizeType,
+				missingStackSize: missingStackSize,
+				humanName:        "interrupt",
+			}
+		}
+	}
+
 	if resetFunction != "" {
 		return append([]string{resetFunction}, gowrappers...), sizes, nil
 	}
@@ -1301,11 +1360,15 @@ func modifyStackSizes(executable string, stackSizeLoads []string, stackSizes map
 		return err
 	}
 
-	if len(stackSizeLoads)*4 != len(data) {
-		// Note: while AVR should use 2 byte stack sizes, even 64-bit platforms
-		// should probably stick to 4 byte stack sizes as a larger than 4GB
-		// stack doesn't make much sense.
-		return errors.New("expected 4 byte stack sizes")
+	// The stack sizes are stored as uintptr values.
+	wordSize := 4
+	if fileHeader.Class == elf.ELFCLASS64 {
+		wordSize = 8
+	}
+	if len(stackSizeLoads)*wordSize != len(data) {
+		// Note: AVR should use 2 byte stack sizes, but it doesn't support
+		// goroutines with separate stacks yet.
+		return fmt.Errorf("expected %d byte stack sizes", wordSize)
 	}
 
 	// Modify goroutine stack sizes with a compile-time known worst case stack
@@ -1344,12 +1407,29 @@ func modifyStackSizes(executable string, stackSizeLoads []string, stackSizes map
 				// determined, stack overflow checking is still important as the
 				// stack size cannot be determined for all goroutines.
 				stackSize += 8
+			case elf.EM_RISCV:
+				// Interrupts are handled on the current stack, so the stack
+				// needs to be big enough for the deepest interrupt handler as
+				// well. If that isn't known, keep the default stack size.
+				interrupt := stackSizes["handleInterruptASM"]
+				if interrupt.stackSizeType != stacksize.Bounded {
+					continue
+				}
+				stackSize += uint32(interrupt.stackSize)
+
+				// Adding a word for the stack canary, and rounding up to keep
+				// the stack 16-byte aligned as required by the ABI.
+				stackSize = (stackSize + uint32(wordSize) + 15) &^ 15
 			default:
 				return fmt.Errorf("unknown architecture: %s", fileHeader.Machine.String())
 			}
 
 			// Finally write the stack size to the binary.
-			binary.LittleEndian.PutUint32(data[i*4:], stackSize)
+			if wordSize == 8 {
+				binary.LittleEndian.PutUint64(data[i*8:], uint64(stackSize))
+			} else {
+				binary.LittleEndian.PutUint32(data[i*4:], stackSize)
+			}
 		}
 	}
 
@@ -1386,6 +1466,24 @@ func printStacks(calculatedStacks []string, stackSizes map[string]functionStackS
 	}
 }
 
+// printInterpFallbacks prints the package initializers that interp could not
+// run at compile time, why, and how much RAM the globals they modify take.
+func printInterpFallbacks(fallbacks []interp.Fallback) {
+	var total uint64
+	for _, fallback := range fallbacks {
+		pos := fallback.Err.Pos.String()
+		if pos == "" || pos == "-" {
+			pos = fallback.ImportPath
+		}
+		fmt.Fprintf(os.Stderr, "%s: package %s initializer runs at runtime: %s\n", pos, fallback.ImportPath, fallback.Err.Err)
+		for _, global := range fallback.Globals {
+			fmt.Fprintf(os.Stderr, "\t%s (%d bytes)\n", global.Name, global.Size)
+		}
+		total += fallback.Size()
+	}
+	fmt.Fprintf(os.Stderr, "%d package initializers run at runtime, modifying %d bytes of RAM\n", len(fallbacks), total)
+}
+
 // RP2040 second stage bootloader CRC32 calculation
 //
 // Spec: https://datasheets.raspberrypi.org/rp2040/rp2040-datasheet.pdf
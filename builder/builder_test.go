@@ -145,6 +145,34 @@ func testClangAttributes(t *testing.T, options *compileopts.Options) {
 	}
 }
 
+// Test that a program using the threads scheduler builds. This scheduler is
+// partially implemented in C, in a file that is added by compileopts.
+func TestBuildThreadsScheduler(t *testing.T) {
+	if goenv.Get("GOOS") != "linux" {
+		t.Skip("the threads scheduler is only supported on Linux")
+	}
+	t.Parallel()
+
+	config, err := NewConfig(&compileopts.Options{
+		GOOS:      goenv.Get("GOOS"),
+		GOARCH:    goenv.Get("GOARCH"),
+		GOARM:     goenv.Get("GOARM"),
+		Scheduler: "threads",
+		Opt:       "z",
+		Semaphore: make(chan struct{}, runtime.NumCPU()),
+	})
+	if err != nil {
+		t.Fatal("failed to create config:", err)
+	}
+	err = Build("../testdata/threads.go", "", config, func(result BuildResult) error {
+		_, err := os.Stat(result.Binary)
+		return err
+	})
+	if err != nil {
+		t.Error("failed to build:", err)
+	}
+}
+
 // This TestMain is necessary because TinyGo may also be invoked to run certain
 // LLVM tools in a separate process. Not capturing these invocations would lead
 // to recursive tests.
@@ -39,11 +39,24 @@ func NewConfig(options *compileopts.Options) (*compileopts.Config, error) {
 
 	clangHeaderPath := getClangHeaderPath(goenv.Get("TINYGOROOT"))
 
-	return &compileopts.Config{
+	config := &compileopts.Config{
 		Options:        options,
 		Target:         spec,
 		GoMinorVersion: minor,
 		ClangHeaders:   clangHeaderPath,
 		TestConfig:     options.TestConfig,
-	}, nil
+	}
+
+	if config.Scheduler() == "threads" {
+		// The threads scheduler needs pthreads and futexes, and only the
+		// conservative GC knows how to stop other threads.
+		if config.GOOS() != "linux" || config.GOARCH() == "wasm" || spec.Libc != "musl" {
+			return nil, fmt.Errorf("scheduler=threads is only supported on Linux, not on %s/%s", config.GOOS(), config.GOARCH())
+		}
+		if config.GC() != "conservative" {
+			return nil, fmt.Errorf("scheduler=threads requires gc=conservative, not gc=%s", config.GC())
+		}
+	}
+
+	return config, nil
 }
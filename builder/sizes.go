@@ -7,9 +7,11 @@ import (
 	"debug/macho"
 	"debug/pe"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -24,11 +26,13 @@ const sizesDebug = false
 
 // programSize contains size statistics per package of a compiled program.
 type programSize struct {
-	Packages map[string]packageSize
-	Code     uint64
-	ROData   uint64
-	Data     uint64
-	BSS      uint64
+	Packages  map[string]packageSize
+	Symbols   []symbolSize // sorted by name
+	NoSymbols bool         // per-symbol sizes are not supported for this file format
+	Code      uint64
+	ROData    uint64
+	Data      uint64
+	BSS       uint64
 }
 
 // sortedPackageNames returns the list of package names (ProgramSize.Packages)
@@ -55,10 +59,10 @@ func (ps *programSize) RAM() uint64 {
 // packageSize contains the size of a package, calculated from the linked object
 // file.
 type packageSize struct {
-	Code   uint64
-	ROData uint64
-	Data   uint64
-	BSS    uint64
+	Code   uint64 `json:"code"`
+	ROData uint64 `json:"rodata"`
+	Data   uint64 `json:"data"`
+	BSS    uint64 `json:"bss"`
 }
 
 // Flash usage in regular microcontrollers.
@@ -71,6 +75,15 @@ func (ps *packageSize) RAM() uint64 {
 	return ps.Data + ps.BSS
 }
 
+// symbolSize contains the size of a single function or global, as found in the
+// symbol table of the linked file.
+type symbolSize struct {
+	Name    string     `json:"name"`
+	Package string     `json:"package"`
+	Type    memoryType `json:"type"`
+	Size    uint64     `json:"size"`
+}
+
 // A mapping of a single chunk of code or data to a file path.
 type addressLine struct {
 	Address    uint64
@@ -98,6 +111,10 @@ const (
 	memoryStack
 )
 
+func (t memoryType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
 func (t memoryType) String() string {
 	return [...]string{
 		0:            "-",
@@ -335,9 +352,17 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 	// This stores all chunks of addresses found in the binary.
 	var addresses []addressLine
 
+	// Functions and globals from the symbol table, with the address stored in
+	// the Address field and the name in the File field.
+	var symbols []addressLine
+
 	// Load the binary file, which could be in a number of file formats.
+	// Only ELF files are currently read for per-symbol sizes.
 	var sections []memorySection
+	noSymbols := true
 	if file, err := elf.NewFile(f); err == nil {
+		noSymbols = false
+
 		// Read DWARF information. The error is intentionally ignored.
 		data, _ := file.DWARF()
 		if data != nil {
@@ -375,6 +400,14 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 			if section.Flags&elf.SHF_ALLOC == 0 {
 				continue
 			}
+			if symType != elf.STT_NOTYPE {
+				symbols = append(symbols, addressLine{
+					Address:    symbol.Value,
+					Length:     symbol.Size,
+					File:       symbol.Name,
+					IsVariable: symType == elf.STT_OBJECT,
+				})
+			}
 			if packageSymbolRegexp.MatchString(symbol.Name) || reflectDataRegexp.MatchString(symbol.Name) {
 				addresses = append(addresses, addressLine{
 					Address:    symbol.Value,
@@ -759,7 +792,9 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 
 	// ...and summarize the results.
 	program := &programSize{
-		Packages: sizes,
+		Packages:  sizes,
+		Symbols:   readSymbolSizes(symbols, sections, addresses, packagePathMap),
+		NoSymbols: noSymbols,
 	}
 	for _, pkg := range sizes {
 		program.Code += pkg.Code
@@ -770,6 +805,51 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 	return program, nil
 }
 
+// readSymbolSizes determines the memory type and package of each symbol, using
+// the section it is in and the address chunk at its start address.
+func readSymbolSizes(symbols []addressLine, sections []memorySection, addresses []addressLine, packagePathMap map[string]string) []symbolSize {
+	var result []symbolSize
+	for _, symbol := range symbols {
+		var typ memoryType
+		for _, section := range sections {
+			if symbol.Address >= section.Address && symbol.Address+symbol.Length <= section.Address+section.Size {
+				typ = section.Type
+				break
+			}
+		}
+		if typ == 0 || typ == memoryStack {
+			continue
+		}
+		if typ == memoryCode && symbol.IsVariable {
+			// Constant data in a code section, counted as rodata just like in
+			// the package sizes.
+			typ = memoryROData
+		}
+		pkg := "(unknown)"
+		if packageSymbolRegexp.MatchString(symbol.File) || reflectDataRegexp.MatchString(symbol.File) {
+			pkg = findPackagePath(symbol.File, packagePathMap)
+		} else {
+			// Find the last chunk that starts at or before the symbol.
+			i := sort.Search(len(addresses), func(i int) bool {
+				return addresses[i].Address > symbol.Address
+			}) - 1
+			if i >= 0 && symbol.Address < addresses[i].Address+addresses[i].Length {
+				pkg = findPackagePath(addresses[i].File, packagePathMap)
+			}
+		}
+		result = append(result, symbolSize{
+			Name:    symbol.File,
+			Package: pkg,
+			Type:    typ,
+			Size:    symbol.Length,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
 // readSection determines for each byte in this section to which package it
 // belongs. It reports this usage through the addSize callback.
 func readSection(section memorySection, addresses []addressLine, addSize func(string, uint64, bool), packagePathMap map[string]string) {
@@ -854,9 +934,211 @@ func findPackagePath(path string, packagePathMap map[string]string) string {
 			// fixed in the compiler.
 			packagePath = "-"
 		} else {
-			// This is some other path. Not sure what it is, so just emit its directory.
-			packagePath = filepath.Dir(path) // fallback
+			// This is some other path, for example a package that isn't in
+			// packagePathMap (see SizeDiff). Try to find its import path from
+			// its location and otherwise just emit its directory.
+			dir := filepath.Dir(path)
+			packagePath = guessImportPath(dir)
+			if packagePathMap != nil {
+				// Cache the result, guessImportPath may need to read files.
+				packagePathMap[dir] = packagePath
+			}
 		}
 	}
 	return packagePath
 }
+
+// guessImportPath returns the import path of the package in the given
+// directory, if it is part of GOROOT, TinyGo or a module. Otherwise it returns
+// the directory itself.
+func guessImportPath(dir string) string {
+	for _, root := range []string{goenv.Get("TINYGOROOT"), goenv.Get("GOROOT")} {
+		src := filepath.Join(root, "src") + string(os.PathSeparator)
+		if strings.HasPrefix(dir, src) {
+			return filepath.ToSlash(strings.TrimPrefix(dir, src))
+		}
+	}
+
+	// Look for the go.mod file of the module this directory is part of.
+	for modDir := dir; ; modDir = filepath.Dir(modDir) {
+		data, err := os.ReadFile(filepath.Join(modDir, "go.mod"))
+		if err == nil {
+			modPath := modulePath(data)
+			if modPath == "" {
+				break
+			}
+			rel, err := filepath.Rel(modDir, dir)
+			if err != nil {
+				break
+			}
+			return path.Join(modPath, filepath.ToSlash(rel))
+		}
+		if filepath.Dir(modDir) == modDir {
+			break // reached the root directory
+		}
+	}
+	return dir
+}
+
+// modulePath returns the module path from the module directive of a go.mod
+// file, or the empty string if there is none.
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// packageSizeReport is the JSON format of the size of a single package.
+type packageSizeReport struct {
+	Name string `json:"name"`
+	packageSize
+	Flash uint64 `json:"flash"`
+	RAM   uint64 `json:"ram"`
+}
+
+// writeJSON writes the program size, the size of each package and the size of
+// each symbol as JSON, for -size=json.
+func (ps *programSize) writeJSON(w io.Writer) error {
+	report := struct {
+		packageSize
+		Flash    uint64              `json:"flash"`
+		RAM      uint64              `json:"ram"`
+		Packages []packageSizeReport `json:"packages"`
+		Symbols  []symbolSize        `json:"symbols"`
+	}{
+		packageSize: packageSize{Code: ps.Code, ROData: ps.ROData, Data: ps.Data, BSS: ps.BSS},
+		Flash:       ps.Flash(),
+		RAM:         ps.RAM(),
+		Packages:    []packageSizeReport{},
+		Symbols:     ps.Symbols,
+	}
+	if report.Symbols == nil {
+		report.Symbols = []symbolSize{}
+	}
+	for _, name := range ps.sortedPackageNames() {
+		pkg := ps.Packages[name]
+		report.Packages = append(report.Packages, packageSizeReport{
+			Name:        name,
+			packageSize: pkg,
+			Flash:       pkg.Flash(),
+			RAM:         pkg.RAM(),
+		})
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// SizeDiff loads the sizes of two builds of a program and prints how the flash
+// and RAM usage of each package changed, followed by each function or global
+// that grew, shrank, was added or was removed, with the largest change first.
+//
+// The import path isn't stored in the binary, so packages are identified by
+// the import path derived from the location of their source files (see
+// guessImportPath).
+func SizeDiff(w io.Writer, oldPath, newPath string) error {
+	oldSizes, err := loadProgramSize(oldPath, map[string]string{})
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", oldPath, err)
+	}
+	newSizes, err := loadProgramSize(newPath, map[string]string{})
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", newPath, err)
+	}
+	writeSizeDiff(w, oldSizes, newSizes)
+	return nil
+}
+
+// writeSizeDiff prints the difference between two program sizes, as described
+// in SizeDiff.
+func writeSizeDiff(w io.Writer, oldSizes, newSizes *programSize) {
+	names := map[string]struct{}{}
+	for name := range oldSizes.Packages {
+		names[name] = struct{}{}
+	}
+	for name := range newSizes.Packages {
+		names[name] = struct{}{}
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	fmt.Fprintf(w, "   flash    diff |     ram    diff | package\n")
+	fmt.Fprintf(w, "--------------- | --------------- | -------\n")
+	for _, name := range sortedNames {
+		oldPkg := oldSizes.Packages[name]
+		newPkg := newSizes.Packages[name]
+		if oldPkg == newPkg {
+			continue
+		}
+		fmt.Fprintf(w, "%7d %+7d | %7d %+7d | %s\n", newPkg.Flash(), int64(newPkg.Flash()-oldPkg.Flash()), newPkg.RAM(), int64(newPkg.RAM()-oldPkg.RAM()), name)
+	}
+	fmt.Fprintf(w, "--------------- | --------------- | -------\n")
+	fmt.Fprintf(w, "%7d %+7d | %7d %+7d | total\n", newSizes.Flash(), int64(newSizes.Flash()-oldSizes.Flash()), newSizes.RAM(), int64(newSizes.RAM()-oldSizes.RAM()))
+
+	// Compare symbols by name and memory type. Names are not always unique
+	// (for example, static C functions), so sum their sizes.
+	type symbolKey struct {
+		name string
+		typ  memoryType
+	}
+	type symbolDiff struct {
+		symbolKey
+		oldSize, newSize uint64
+	}
+	symbols := map[symbolKey]*symbolDiff{}
+	getSymbol := func(symbol symbolSize) *symbolDiff {
+		key := symbolKey{symbol.Name, symbol.Type}
+		if symbols[key] == nil {
+			symbols[key] = &symbolDiff{symbolKey: key}
+		}
+		return symbols[key]
+	}
+	for _, symbol := range oldSizes.Symbols {
+		getSymbol(symbol).oldSize += symbol.Size
+	}
+	for _, symbol := range newSizes.Symbols {
+		getSymbol(symbol).newSize += symbol.Size
+	}
+	var changed []*symbolDiff
+	for _, symbol := range symbols {
+		if symbol.oldSize != symbol.newSize {
+			changed = append(changed, symbol)
+		}
+	}
+	if oldSizes.NoSymbols || newSizes.NoSymbols {
+		fmt.Fprintf(w, "\nwarning: per-symbol sizes are only available for ELF files\n")
+	}
+	if len(changed) == 0 {
+		return
+	}
+	absDiff := func(symbol *symbolDiff) uint64 {
+		if symbol.newSize > symbol.oldSize {
+			return symbol.newSize - symbol.oldSize
+		}
+		return symbol.oldSize - symbol.newSize
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		if absDiff(changed[i]) != absDiff(changed[j]) {
+			return absDiff(changed[i]) > absDiff(changed[j])
+		}
+		if changed[i].name != changed[j].name {
+			return changed[i].name < changed[j].name
+		}
+		return changed[i].typ < changed[j].typ
+	})
+	fmt.Fprintf(w, "\n    old     new    diff | type   | symbol\n")
+	fmt.Fprintf(w, "----------------------- | ------ | ------\n")
+	for _, symbol := range changed {
+		fmt.Fprintf(w, "%7d %7d %+7d | %-6s | %s\n", symbol.oldSize, symbol.newSize, int64(symbol.newSize-symbol.oldSize), symbol.typ, symbol.name)
+	}
+}
@@ -0,0 +1,147 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/goenv"
+)
+
+func TestSizesJSON(t *testing.T) {
+	sizes := &programSize{
+		Packages: map[string]packageSize{
+			"runtime": {Code: 100, ROData: 20, Data: 4, BSS: 8},
+			"main":    {Code: 10, BSS: 2},
+		},
+		Symbols: []symbolSize{
+			{Name: "main.main", Package: "main", Type: memoryCode, Size: 10},
+		},
+		Code:   110,
+		ROData: 20,
+		Data:   4,
+		BSS:    10,
+	}
+	buf := &bytes.Buffer{}
+	if err := sizes.writeJSON(buf); err != nil {
+		t.Fatal("could not write JSON:", err)
+	}
+
+	// Decode into generic values, to check the actual field names.
+	var report map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal("could not parse JSON:", err)
+	}
+	expected := map[string]interface{}{
+		"code":   110.0,
+		"rodata": 20.0,
+		"data":   4.0,
+		"bss":    10.0,
+		"flash":  134.0,
+		"ram":    14.0,
+		"packages": []interface{}{
+			map[string]interface{}{"name": "main", "code": 10.0, "rodata": 0.0, "data": 0.0, "bss": 2.0, "flash": 10.0, "ram": 2.0},
+			map[string]interface{}{"name": "runtime", "code": 100.0, "rodata": 20.0, "data": 4.0, "bss": 8.0, "flash": 124.0, "ram": 12.0},
+		},
+		"symbols": []interface{}{
+			map[string]interface{}{"name": "main.main", "package": "main", "type": "code", "size": 10.0},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected JSON output:\n%s", buf.String())
+	}
+
+	// Symbols must be an empty list, not null, when there are none.
+	sizes.Symbols = nil
+	buf.Reset()
+	if err := sizes.writeJSON(buf); err != nil {
+		t.Fatal("could not write JSON:", err)
+	}
+	if !strings.Contains(buf.String(), `"symbols": []`) {
+		t.Errorf("expected an empty symbols list:\n%s", buf.String())
+	}
+}
+
+func TestSizeDiff(t *testing.T) {
+	oldSizes := &programSize{
+		Packages: map[string]packageSize{
+			"fmt":     {Code: 200, ROData: 50},
+			"main":    {Code: 10, BSS: 2},
+			"runtime": {Code: 100, Data: 4, BSS: 8},
+		},
+		Symbols: []symbolSize{
+			{Name: "fmt.Println", Package: "fmt", Type: memoryCode, Size: 200},
+			{Name: "main.main", Package: "main", Type: memoryCode, Size: 10},
+			{Name: "runtime.alloc", Package: "runtime", Type: memoryCode, Size: 100},
+		},
+		Code: 310, ROData: 50, Data: 4, BSS: 10,
+	}
+	newSizes := &programSize{
+		Packages: map[string]packageSize{
+			"main":    {Code: 14, BSS: 2},
+			"runtime": {Code: 100, Data: 4, BSS: 8},
+		},
+		Symbols: []symbolSize{
+			{Name: "main.main", Package: "main", Type: memoryCode, Size: 12},
+			{Name: "main.print", Package: "main", Type: memoryCode, Size: 2},
+			{Name: "runtime.alloc", Package: "runtime", Type: memoryCode, Size: 100},
+		},
+		Code: 114, Data: 4, BSS: 10,
+	}
+	buf := &bytes.Buffer{}
+	writeSizeDiff(buf, oldSizes, newSizes)
+	expected := `   flash    diff |     ram    diff | package
+--------------- | --------------- | -------
+      0    -250 |       0      +0 | fmt
+     14      +4 |       2      +0 | main
+--------------- | --------------- | -------
+    118    -246 |      14      +0 | total
+
+    old     new    diff | type   | symbol
+----------------------- | ------ | ------
+    200       0    -200 | code   | fmt.Println
+     10      12      +2 | code   | main.main
+      0       2      +2 | code   | main.print
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected size diff:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	// Formats without per-symbol sizes should not silently print nothing.
+	oldSizes.Symbols, oldSizes.NoSymbols = nil, true
+	newSizes.Symbols, newSizes.NoSymbols = nil, true
+	buf.Reset()
+	writeSizeDiff(buf, oldSizes, newSizes)
+	if !strings.Contains(buf.String(), "warning: per-symbol sizes are only available for ELF files") {
+		t.Errorf("expected a warning about missing symbols:\n%s", buf.String())
+	}
+}
+
+func TestGuessImportPath(t *testing.T) {
+	// Packages in TinyGo and in GOROOT.
+	runtimeDir := filepath.Join(goenv.Get("TINYGOROOT"), "src", "runtime")
+	if path := guessImportPath(runtimeDir); path != "runtime" {
+		t.Errorf("expected runtime for %s, got %s", runtimeDir, path)
+	}
+	fmtDir := filepath.Join(goenv.Get("GOROOT"), "src", "fmt")
+	if path := guessImportPath(fmtDir); path != "fmt" {
+		t.Errorf("expected fmt for %s, got %s", fmtDir, path)
+	}
+
+	// Packages in a module.
+	modDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("// comment\nmodule \"example.com/foo\"\n\ngo 1.18\n"), 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := guessImportPath(modDir); path != "example.com/foo" {
+		t.Errorf("expected example.com/foo for the module root, got %s", path)
+	}
+	if path := guessImportPath(filepath.Join(modDir, "bar", "baz")); path != "example.com/foo/bar/baz" {
+		t.Errorf("expected example.com/foo/bar/baz for a package in the module, got %s", path)
+	}
+}
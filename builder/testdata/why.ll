@@ -0,0 +1,21 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32-S128"
+target triple = "riscv32-unknown-none"
+
+@main.message = internal global [5 x i8] c"hello"
+
+declare void @putchar(i32)
+
+define void @main.main() {
+  call void @main.greet()
+  ret void
+}
+
+define internal void @main.greet() {
+  call void @runtime.printstring(i8* getelementptr inbounds ([5 x i8], [5 x i8]* @main.message, i32 0, i32 0), i32 5)
+  ret void
+}
+
+define internal void @runtime.printstring(i8* %ptr, i32 %len) {
+  call void @putchar(i32 104)
+  ret void
+}
@@ -0,0 +1,289 @@
+package builder
+
+// This file implements -why, which explains why a symbol or package is linked
+// into the program by printing the shortest chain of references to it.
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/stacksize"
+	"tinygo.org/x/go-llvm"
+)
+
+// whyEdge is a reference from one symbol to another.
+type whyEdge struct {
+	to   string
+	pos  string // source location of the reference, if known
+	note string // kind of reference, if it is not obvious from the source
+}
+
+// whyGraph is a reference graph between all symbols in a program, built from
+// the optimized LLVM module and (if possible) the call graph of the linked
+// executable.
+type whyGraph struct {
+	roots []string
+	edges map[string][]whyEdge
+	names map[string]struct{} // all symbols that are part of the graph
+}
+
+// printWhy prints the shortest chain of references from a root of the program
+// (an exported function like main or an interrupt handler, or the entry point)
+// to the given symbol, or to any symbol in the given package. This explains why
+// the symbol or package was not removed as dead code.
+func printWhy(w io.Writer, mod llvm.Module, executable, query string) error {
+	graph := &whyGraph{
+		edges: make(map[string][]whyEdge),
+		names: make(map[string]struct{}),
+	}
+	graph.addModule(mod)
+
+	// The linker does another round of dead code elimination (and links in C
+	// libraries), so use the symbols of the executable when available.
+	var linked map[string]struct{}
+	if f, err := elf.Open(executable); err == nil {
+		defer f.Close()
+		linked, err = graph.addExecutable(f)
+		if err != nil {
+			return err
+		}
+
+		// The call graph can only be read when the executable was linked with
+		// --emit-relocs. Without it, only references from the LLVM module are
+		// known so mention that the path may be incomplete.
+		err = graph.addCallGraph(f)
+		if err != nil {
+			fmt.Fprintf(w, "note: calls in linked code are not shown: %v\n", err)
+		}
+	} else {
+		fmt.Fprintf(w, "note: calls in linked code are not shown: the executable is not an ELF file\n")
+	}
+
+	isTarget := func(name string) bool {
+		if linked != nil {
+			if _, ok := linked[name]; !ok {
+				return false
+			}
+		}
+		return name == query
+	}
+	if _, ok := graph.names[query]; !ok {
+		// Not a symbol name, so it must be a package.
+		isTarget = func(name string) bool {
+			if linked != nil {
+				if _, ok := linked[name]; !ok {
+					return false
+				}
+			}
+			return symbolInPackage(name, query)
+		}
+	}
+
+	path := graph.shortestPath(isTarget)
+	if path == nil {
+		fmt.Fprintf(w, "%s is not linked in\n", query)
+		return nil
+	}
+	fmt.Fprintf(w, "%s is linked in through:\n", path[len(path)-1].to)
+	fmt.Fprintf(w, "    %s\n", path[0].to)
+	for _, edge := range path[1:] {
+		var details []string
+		if edge.pos != "" {
+			details = append(details, edge.pos)
+		}
+		if edge.note != "" {
+			details = append(details, edge.note)
+		}
+		if len(details) != 0 {
+			fmt.Fprintf(w, " -> %s (%s)\n", edge.to, strings.Join(details, ", "))
+		} else {
+			fmt.Fprintf(w, " -> %s\n", edge.to)
+		}
+	}
+	return nil
+}
+
+// symbolInPackage returns whether the given symbol name (such as fmt.Println,
+// (*fmt.pp).printArg or main$string.3) belongs to the given Go package.
+func symbolInPackage(name, pkg string) bool {
+	name = strings.TrimPrefix(name, "(")
+	name = strings.TrimPrefix(name, "*")
+	return strings.HasPrefix(name, pkg+".") || strings.HasPrefix(name, pkg+"$")
+}
+
+// addModule adds all references between functions and globals in the LLVM
+// module to the graph. All externally visible definitions are roots.
+func (g *whyGraph) addModule(mod llvm.Module) {
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		g.addDefinition(fn)
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				pos := ""
+				if loc := inst.InstructionDebugLoc(); !loc.IsNil() {
+					file := loc.LocationScope().ScopeFile()
+					pos = filepath.Base(file.FileFilename()) + ":" + strconv.Itoa(int(loc.LocationLine()))
+				}
+				for i := 0; i < inst.OperandsCount(); i++ {
+					g.addReferences(fn, inst.Operand(i), pos, map[llvm.Value]struct{}{})
+				}
+			}
+		}
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if global.IsDeclaration() {
+			continue
+		}
+		g.addDefinition(global)
+		g.addReferences(global, global.Initializer(), "", map[llvm.Value]struct{}{})
+	}
+}
+
+// addDefinition adds a defined function or global to the graph, as a root if
+// it is externally visible (or otherwise must be kept, like llvm.used).
+func (g *whyGraph) addDefinition(value llvm.Value) {
+	g.names[value.Name()] = struct{}{}
+	switch value.Linkage() {
+	case llvm.InternalLinkage, llvm.PrivateLinkage:
+	default:
+		g.roots = append(g.roots, value.Name())
+	}
+}
+
+// addReferences adds an edge from the function or global from to each
+// function or global referenced by value, looking through constant
+// expressions and aggregates.
+func (g *whyGraph) addReferences(from, value llvm.Value, pos string, visited map[llvm.Value]struct{}) {
+	if value.IsNil() || value.IsAConstant().IsNil() {
+		// Not a constant (for example an instruction, basic block or
+		// metadata), so it can't refer to a global.
+		return
+	}
+	if _, ok := visited[value]; ok {
+		return
+	}
+	visited[value] = struct{}{}
+	if !value.IsAGlobalValue().IsNil() {
+		if value.Name() != "" && value != from {
+			g.edges[from.Name()] = append(g.edges[from.Name()], whyEdge{
+				to:   value.Name(),
+				pos:  pos,
+				note: whyNote(from.Name()),
+			})
+		}
+		return
+	}
+	for i := 0; i < value.OperandsCount(); i++ {
+		g.addReferences(from, value.Operand(i), pos, visited)
+	}
+}
+
+// whyNote returns a description of references made by the given symbol when
+// they were created by the compiler instead of written in the source code:
+// see transform/interface-lowering.go and src/reflect/sidetables.go.
+func whyNote(from string) string {
+	switch {
+	case strings.HasPrefix(from, "interface:") && strings.HasSuffix(from, "$invoke"):
+		return "interface method call"
+	case strings.HasSuffix(from, ".$typeassert"):
+		return "interface type assert"
+	case strings.HasPrefix(from, "reflect/types.type:"), reflectDataRegexp.MatchString(from):
+		return "type information for reflect"
+	}
+	return ""
+}
+
+// addExecutable adds the symbols in the linked executable to the graph, and
+// returns the set of these symbols.
+func (g *whyGraph) addExecutable(f *elf.File) (map[string]struct{}, error) {
+	symbols, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	linked := make(map[string]struct{}, len(symbols))
+	for _, symbol := range symbols {
+		if symbol.Section == elf.SHN_UNDEF {
+			continue
+		}
+		linked[symbol.Name] = struct{}{}
+		g.names[symbol.Name] = struct{}{}
+		// Clear the Thumb bit on ARM.
+		if symbol.Value&^1 == f.Entry&^1 && elf.ST_TYPE(symbol.Info) == elf.STT_FUNC {
+			g.roots = append(g.roots, symbol.Name)
+		}
+	}
+	return linked, nil
+}
+
+// addCallGraph adds the calls between functions in the linked executable to
+// the graph, which includes calls from and to C libraries.
+func (g *whyGraph) addCallGraph(f *elf.File) error {
+	callGraph, err := stacksize.CallGraph(f, nil)
+	if err != nil {
+		return err
+	}
+	for name, nodes := range callGraph {
+		for _, node := range nodes {
+			for _, child := range node.Children {
+				for _, childName := range child.Names {
+					g.edges[name] = append(g.edges[name], whyEdge{
+						to:   childName,
+						note: "call in linked code",
+					})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shortestPath does a breadth-first search from the roots of the graph and
+// returns the shortest path to a symbol for which isTarget returns true. The
+// first edge in the path only holds the root. It returns nil if no target is
+// reachable.
+func (g *whyGraph) shortestPath(isTarget func(string) bool) []whyEdge {
+	// For each visited symbol, the edge that first reached it and the symbol
+	// that edge came from.
+	type reached struct {
+		edge whyEdge
+		from string
+	}
+	visited := make(map[string]reached)
+	var worklist []string
+	for _, root := range g.roots {
+		if _, ok := visited[root]; ok {
+			continue
+		}
+		visited[root] = reached{edge: whyEdge{to: root}}
+		worklist = append(worklist, root)
+	}
+	for len(worklist) != 0 {
+		name := worklist[0]
+		worklist = worklist[1:]
+		if isTarget(name) {
+			var path []whyEdge
+			for {
+				r := visited[name]
+				path = append([]whyEdge{r.edge}, path...)
+				if r.from == "" {
+					return path
+				}
+				name = r.from
+			}
+		}
+		for _, edge := range g.edges[name] {
+			if _, ok := visited[edge.to]; ok {
+				continue
+			}
+			visited[edge.to] = reached{edge: edge, from: name}
+			worklist = append(worklist, edge.to)
+		}
+	}
+	return nil
+}
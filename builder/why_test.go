@@ -0,0 +1,65 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestWhy(t *testing.T) {
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/why.ll")
+	os.Stat("testdata/why.ll") // make sure this file is tracked by `go test` caching
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+	defer mod.Dispose()
+
+	for _, tc := range []struct {
+		executable string
+		query      string
+		output     string
+	}{
+		// The path continues into linked C code using the call graph.
+		{"testdata/why.elf", "uart_write", `uart_write is linked in through:
+    main.main
+ -> main.greet
+ -> runtime.printstring
+ -> putchar
+ -> uart_write (call in linked code)
+`},
+		// Packages match any symbol in them.
+		{"testdata/why.elf", "runtime", `runtime.printstring is linked in through:
+    main.main
+ -> main.greet
+ -> runtime.printstring
+`},
+		// Without relocations, only references in the module can be used.
+		{"testdata/why-norelocs.elf", "main.message", `note: calls in linked code are not shown: no relocations found in code sections, binary was linked without --emit-relocs
+main.message is linked in through:
+    main.main
+ -> main.greet
+ -> main.message
+`},
+		{"testdata/why.ll", "uart_write", `note: calls in linked code are not shown: the executable is not an ELF file
+uart_write is not linked in
+`},
+	} {
+		out := &bytes.Buffer{}
+		err := printWhy(out, mod, tc.executable, tc.query)
+		if err != nil {
+			t.Errorf("%s in %s: %v", tc.query, tc.executable, err)
+			continue
+		}
+		if out.String() != tc.output {
+			t.Errorf("%s in %s: unexpected output:\n%s\nexpected:\n%s", tc.query, tc.executable, out.String(), tc.output)
+		}
+	}
+}
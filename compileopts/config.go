@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/shlex"
 	"github.com/tinygo-org/tinygo/goenv"
@@ -73,6 +74,14 @@ func (c *Config) BuildTags() []string {
 	for i := 1; i <= c.GoMinorVersion; i++ {
 		tags = append(tags, fmt.Sprintf("go1.%d", i))
 	}
+	if c.FuzzCoverage() {
+		// Only include the fuzzing engine in test binaries built for fuzzing.
+		tags = append(tags, "tinygo.fuzz")
+	}
+	if c.TestConfig.CompileTestBinary {
+		// Only include the randomized scheduler in test binaries.
+		tags = append(tags, "tinygo.test")
+	}
 	tags = append(tags, c.Options.Tags...)
 	return tags
 }
@@ -113,7 +122,7 @@ func (c *Config) NeedsStackObjects() bool {
 }
 
 // Scheduler returns the scheduler implementation. Valid values are "none",
-// "asyncify" and "tasks".
+// "asyncify", "tasks" and "threads".
 func (c *Config) Scheduler() string {
 	if c.Options.Scheduler != "" {
 		return c.Options.Scheduler
@@ -137,6 +146,40 @@ func (c *Config) Serial() string {
 	return "none"
 }
 
+// USBIdentity returns the USB vendor and product ID, manufacturer, product
+// and serial number of the USB device, as a map from the name of the global in
+// the machine package to its value. Values set in the options override those
+// of the target, and values that are set in neither are left out so that the
+// defaults of the board are used.
+func (c *Config) USBIdentity() (map[string]string, error) {
+	identity := make(map[string]string)
+	for _, v := range []struct {
+		name, option, target string
+	}{
+		{"usbVendorID", c.Options.USBVendorID, c.Target.USBVendorID},
+		{"usbProductID", c.Options.USBProductID, c.Target.USBProductID},
+		{"usbManufacturer", c.Options.USBManufacturer, c.Target.USBManufacturer},
+		{"usbProduct", c.Options.USBProduct, c.Target.USBProduct},
+		{"usbSerialNumber", c.Options.USBSerialNumber, c.Target.USBSerialNumber},
+	} {
+		value := v.option
+		if value == "" {
+			value = v.target
+		}
+		if value != "" {
+			identity[v.name] = value
+		}
+	}
+	for _, name := range []string{"usbVendorID", "usbProductID"} {
+		if id, ok := identity[name]; ok {
+			if err := verifyUSBID(id); err != nil {
+				return nil, fmt.Errorf("invalid USB ID %#v in target %s: %w", id, c.Options.Target, err)
+			}
+		}
+	}
+	return identity, nil
+}
+
 // OptLevels returns the optimization level (0-2), size level (0-2), and inliner
 // threshold as used in the LLVM optimization pipeline.
 func (c *Config) OptLevels() (optLevel, sizeLevel int, inlinerThreshold uint) {
@@ -361,6 +404,11 @@ func (c *Config) LDFlags() []string {
 // ExtraFiles returns the list of extra files to be built and linked with the
 // executable. This can include extra C and assembly files.
 func (c *Config) ExtraFiles() []string {
+	if c.Scheduler() == "threads" {
+		// The threads scheduler is partially implemented in C.
+		files := append([]string{}, c.Target.ExtraFiles...)
+		return append(files, "lib/task-threads.c")
+	}
 	return c.Target.ExtraFiles
 }
 
@@ -376,6 +424,12 @@ func (c *Config) VerifyIR() bool {
 	return c.Options.VerifyIR
 }
 
+// FuzzCoverage returns whether the test binary should be instrumented with
+// coverage counters for the fuzzing engine (tinygo test -fuzz).
+func (c *Config) FuzzCoverage() bool {
+	return c.TestConfig.CompileTestBinary && c.TestConfig.Fuzz != ""
+}
+
 // Debug returns whether debug (DWARF) information should be retained by the
 // linker. By default, debug information is retained, but it can be removed
 // with the -no-debug flag.
@@ -548,5 +602,23 @@ func (c *Config) Emulator(format, binary string) ([]string, error) {
 
 type TestConfig struct {
 	CompileTestBinary bool
-	// TODO: Filter the test functions to run, include verbose flag, etc
+	CompileOnly       bool
+	Verbose           bool
+	Short             bool
+	RunRegexp         string
+	BenchRegexp       string
+	BenchTime         string
+	BenchMem          bool
+	CoverMode         string // set or count, or empty when not measuring coverage
+	CoverProfile      string // file to write the coverage profile to
+	Count             int
+	Timeout           time.Duration // zero means no timeout
+	FailFast          bool
+	Shuffle           string // off, on, or a seed
+	ListRegexp        string
+	SkipRegexp        string
+	Parallel          int    // zero means the default of the test binary
+	Fuzz              string // regexp of the fuzz test to fuzz, or empty when not fuzzing
+	FuzzTime          string // duration or count (like 1000x), empty for no limit
+	FuzzMinimizeTime  string // duration or count, empty for the default
 }
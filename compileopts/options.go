@@ -3,17 +3,19 @@ package compileopts
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var (
 	validGCOptions            = []string{"none", "leaking", "conservative"}
-	validSchedulerOptions     = []string{"none", "tasks", "asyncify"}
+	validSchedulerOptions     = []string{"none", "tasks", "asyncify", "threads"}
 	validSerialOptions        = []string{"none", "uart", "usb"}
-	validPrintSizeOptions     = []string{"none", "short", "full"}
+	validPrintSizeOptions     = []string{"none", "short", "full", "json"}
 	validPanicStrategyOptions = []string{"print", "trap"}
 	validOptOptions           = []string{"none", "0", "1", "2", "s", "z"}
+	validCoverModeOptions     = []string{"set", "count"}
 )
 
 // Options contains extra options to give to the compiler. These options are
@@ -41,6 +43,8 @@ type Options struct {
 	PrintSizes      string
 	PrintAllocs     *regexp.Regexp // regexp string
 	PrintStacks     bool
+	PrintInterp     bool
+	Why             string
 	Tags            []string
 	WasmAbi         string
 	GlobalValues    map[string]map[string]string // map[pkgpath]map[varname]value
@@ -52,6 +56,11 @@ type Options struct {
 	PrintJSON       bool
 	Monitor         bool
 	BaudRate        int
+	USBVendorID     string
+	USBProductID    string
+	USBManufacturer string
+	USBProduct      string
+	USBSerialNumber string
 }
 
 // Verify performs a validation on the given options, raising an error if options are not valid.
@@ -107,9 +116,39 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.TestConfig.CoverMode != "" {
+		if !isInArray(validCoverModeOptions, o.TestConfig.CoverMode) {
+			return fmt.Errorf("invalid -covermode=%s: valid values are %s", o.TestConfig.CoverMode, strings.Join(validCoverModeOptions, ", "))
+		}
+	}
+
+	if o.USBVendorID != "" {
+		if err := verifyUSBID(o.USBVendorID); err != nil {
+			return fmt.Errorf("invalid -usb-vid=%s: %w", o.USBVendorID, err)
+		}
+	}
+
+	if o.USBProductID != "" {
+		if err := verifyUSBID(o.USBProductID); err != nil {
+			return fmt.Errorf("invalid -usb-pid=%s: %w", o.USBProductID, err)
+		}
+	}
+
 	return nil
 }
 
+// verifyUSBID checks that a USB vendor or product ID is a 16-bit number, in
+// the same notation as the machine package parses it: hexadecimal with a 0x
+// prefix, or decimal.
+func verifyUSBID(id string) error {
+	if strings.HasPrefix(id, "0x") || strings.HasPrefix(id, "0X") {
+		_, err := strconv.ParseUint(id[2:], 16, 16)
+		return err
+	}
+	_, err := strconv.ParseUint(id, 10, 16)
+	return err
+}
+
 func isInArray(arr []string, item string) bool {
 	for _, i := range arr {
 		if i == item {
@@ -10,9 +10,12 @@ import (
 func TestVerifyOptions(t *testing.T) {
 
 	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, conservative`)
-	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, asyncify`)
-	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full`)
+	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, asyncify, threads`)
+	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full, json`)
 	expectedPanicStrategyError := errors.New(`invalid panic option 'incorrect': valid values are print, trap`)
+	expectedUSBVendorIDError := errors.New(`invalid -usb-vid=0x12345: strconv.ParseUint: parsing "12345": value out of range`)
+	expectedUSBProductIDError := errors.New(`invalid -usb-pid=12ab: strconv.ParseUint: parsing "12ab": invalid syntax`)
+	expectedCoverModeError := errors.New(`invalid -covermode=atomic: valid values are set, count`)
 
 	testCases := []struct {
 		name          string
@@ -92,6 +95,12 @@ func TestVerifyOptions(t *testing.T) {
 				PrintSizes: "full",
 			},
 		},
+		{
+			name: "PrintSizeOptionJSON",
+			opts: compileopts.Options{
+				PrintSizes: "json",
+			},
+		},
 		{
 			name: "InvalidPanicOption",
 			opts: compileopts.Options{
@@ -111,6 +120,40 @@ func TestVerifyOptions(t *testing.T) {
 				PanicStrategy: "trap",
 			},
 		},
+		{
+			name: "USBIDs",
+			opts: compileopts.Options{
+				USBVendorID:  "0x2e8a",
+				USBProductID: "10",
+			},
+		},
+		{
+			name: "InvalidUSBVendorID",
+			opts: compileopts.Options{
+				USBVendorID: "0x12345",
+			},
+			expectedError: expectedUSBVendorIDError,
+		},
+		{
+			name: "InvalidUSBProductID",
+			opts: compileopts.Options{
+				USBProductID: "12ab",
+			},
+			expectedError: expectedUSBProductIDError,
+		},
+		{
+			name: "CoverModeCount",
+			opts: compileopts.Options{
+				TestConfig: compileopts.TestConfig{CoverMode: "count"},
+			},
+		},
+		{
+			name: "InvalidCoverMode",
+			opts: compileopts.Options{
+				TestConfig: compileopts.TestConfig{CoverMode: "atomic"},
+			},
+			expectedError: expectedCoverModeError,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -62,6 +62,11 @@ type TargetSpec struct {
 	CodeModel        string   `json:"code-model"`
 	RelocationModel  string   `json:"relocation-model"`
 	WasmAbi          string   `json:"wasm-abi"`
+	USBVendorID      string   `json:"usb-vendor-id"`  // USB vendor ID, such as "0x2e8a"
+	USBProductID     string   `json:"usb-product-id"` // USB product ID, such as "0x000a"
+	USBManufacturer  string   `json:"usb-manufacturer"`
+	USBProduct       string   `json:"usb-product"`
+	USBSerialNumber  string   `json:"usb-serial-number"` // fixed serial number, instead of the unique ID of the chip
 }
 
 // overrideProperties overrides all properties that are set in child into itself using reflection.
@@ -290,6 +295,7 @@ func defaultTarget(goos, goarch, triple string) (*TargetSpec, error) {
 		spec.RTLib = "compiler-rt"
 		spec.Libc = "musl"
 		spec.LDFlags = append(spec.LDFlags, "--gc-sections")
+		spec.ExtraFiles = append(spec.ExtraFiles, "lib/runtime-signal.c")
 	} else if goos == "windows" {
 		spec.Linker = "ld.lld"
 		spec.Libc = "mingw-w64"
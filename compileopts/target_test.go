@@ -81,3 +81,35 @@ func TestOverrideProperties(t *testing.T) {
 	}
 
 }
+
+func TestUSBIdentity(t *testing.T) {
+	config := &Config{
+		Options: &Options{
+			USBProductID:    "0x0042",
+			USBSerialNumber: "0001",
+		},
+		Target: &TargetSpec{
+			USBVendorID:  "0x2e8a",
+			USBProductID: "0x000a",
+			USBProduct:   "Widget",
+		},
+	}
+	identity, err := config.USBIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"usbVendorID":     "0x2e8a",
+		"usbProductID":    "0x0042",
+		"usbProduct":      "Widget",
+		"usbSerialNumber": "0001",
+	}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Errorf("expected %v, got %v", expected, identity)
+	}
+
+	config.Target.USBVendorID = "vendor"
+	if _, err := config.USBIdentity(); err == nil {
+		t.Error("expected an error for an invalid vendor ID")
+	}
+}
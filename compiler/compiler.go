@@ -780,6 +780,7 @@ func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package
 	})
 
 	// Define all functions.
+	var constinitGlobals []llvm.Value
 	for _, name := range members {
 		member := pkg.Members[name]
 		switch member := member.(type) {
@@ -855,9 +856,22 @@ func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package
 					global.SetSection(info.section)
 				}
 			}
+			if info.constinit {
+				constinitGlobals = append(constinitGlobals, llvm.ConstBitCast(global, c.i8ptrType))
+			}
 		}
 	}
 
+	// Store the list of //go:constinit globals, for interp to check that they
+	// are initialized at compile time.
+	if len(constinitGlobals) != 0 {
+		list := llvm.ConstArray(c.i8ptrType, constinitGlobals)
+		global := llvm.AddGlobal(c.mod, list.Type(), pkg.Pkg.Path()+"$constinit")
+		global.SetInitializer(list)
+		global.SetGlobalConstant(true)
+		global.SetLinkage(llvm.InternalLinkage)
+	}
+
 	// Add forwarding functions for functions that would otherwise be
 	// implemented in assembly.
 	for _, name := range members {
@@ -1078,6 +1092,11 @@ func (b *builder) createFunctionStart(intrinsic bool) {
 		b.llvmFn.AddFunctionAttr(noinline)
 	}
 
+	if b.info.noalloc {
+		// Checked by transform.CheckNoAlloc after optimizations.
+		b.llvmFn.AddFunctionAttr(b.ctx.CreateStringAttribute("tinygo-noalloc", ""))
+	}
+
 	if b.info.interrupt {
 		// Mark this function as an interrupt.
 		// This is necessary on MCUs that don't push caller saved registers when
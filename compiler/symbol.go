@@ -28,6 +28,7 @@ type functionInfo struct {
 	exported   bool       // go:export, CGo
 	interrupt  bool       // go:interrupt
 	nobounds   bool       // go:nobounds
+	noalloc    bool       // go:noalloc
 	variadic   bool       // go:variadic (CGo only)
 	inline     inlineType // go:inline
 }
@@ -262,6 +263,10 @@ func (info *functionInfo) parsePragmas(f *ssa.Function) {
 				info.inline = inlineHint
 			case "//go:noinline":
 				info.inline = inlineNone
+			case "//go:noalloc":
+				// Check that this function doesn't allocate memory or block,
+				// for use from interrupts.
+				info.noalloc = true
 			case "//go:linkname":
 				if len(parts) != 3 || parts[1] != f.Name() {
 					continue
@@ -376,10 +381,11 @@ func (c *compilerContext) addStandardAttributes(llvmFn llvm.Value) {
 // linkName is equal to .RelString(nil) on a global and extern is false, but for
 // some symbols this is different (due to //go:extern for example).
 type globalInfo struct {
-	linkName string // go:extern
-	extern   bool   // go:extern
-	align    int    // go:align
-	section  string // go:section
+	linkName  string // go:extern
+	extern    bool   // go:extern
+	align     int    // go:align
+	section   string // go:section
+	constinit bool   // go:constinit
 }
 
 // loadASTComments loads comments on globals from the AST, for use later in the
@@ -491,6 +497,10 @@ func (info *globalInfo) parsePragmas(doc *ast.CommentGroup) {
 			if len(parts) == 2 {
 				info.section = parts[1]
 			}
+		case "//go:constinit":
+			// The global must be initialized at compile time, it is an
+			// error if interp can't do that.
+			info.constinit = true
 		}
 	}
 }
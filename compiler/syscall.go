@@ -5,6 +5,7 @@ package compiler
 
 import (
 	"strconv"
+	"strings"
 
 	"golang.org/x/tools/go/ssa"
 	"tinygo.org/x/go-llvm"
@@ -145,10 +146,20 @@ func (b *builder) createRawSyscall(call *ssa.CallCommon) (llvm.Value, error) {
 func (b *builder) createSyscall(call *ssa.CallCommon) (llvm.Value, error) {
 	switch b.GOOS {
 	case "linux":
+		// With the threads scheduler, other goroutines may run while this
+		// goroutine is blocked in the system call. This is not needed for raw
+		// system calls, which don't block.
+		blocking := b.Scheduler == "threads" && strings.HasPrefix(call.StaticCallee().Name(), "Syscall")
+		if blocking {
+			b.createRuntimeCall("entersyscall", nil, "")
+		}
 		syscallResult, err := b.createRawSyscall(call)
 		if err != nil {
 			return syscallResult, err
 		}
+		if blocking {
+			b.createRuntimeCall("exitsyscall", nil, "")
+		}
 		// Return values: r0, r1 uintptr, err Errno
 		// Pseudocode:
 		//     var err uintptr
@@ -48,6 +48,12 @@ func inlineFunc() {
 func noinlineFunc() {
 }
 
+// Function must not allocate or block, which is checked after optimizing.
+//
+//go:noalloc
+func noallocFunc() {
+}
+
 // This function should have the specified section.
 //
 //go:section .special_function_section
@@ -74,3 +80,8 @@ var undefinedGlobalNotInSection uint32
 //go:align 1024
 //go:section .global_section
 var multipleGlobalPragmas uint32
+
+// This global must be initialized at compile time, which is checked by interp.
+//
+//go:constinit
+var constinitGlobal uint32
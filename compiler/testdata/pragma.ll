@@ -9,6 +9,8 @@ target triple = "wasm32-unknown-wasi"
 @main.globalInSection = hidden global i32 0, section ".special_global_section", align 4
 @undefinedGlobalNotInSection = external global i32, align 4
 @main.multipleGlobalPragmas = hidden global i32 0, section ".global_section", align 1024
+@main.constinitGlobal = hidden global i32 0, align 4
+@"main$constinit" = internal constant [1 x i8*] [i8* bitcast (i32* @main.constinitGlobal to i8*)]
 
 declare noalias nonnull i8* @runtime.alloc(i32, i8*, i8*) #0
 
@@ -46,6 +48,12 @@ entry:
   ret void
 }
 
+; Function Attrs: nounwind
+define hidden void @main.noallocFunc(i8* %context) unnamed_addr #5 {
+entry:
+  ret void
+}
+
 ; Function Attrs: nounwind
 define hidden void @main.functionInSection(i8* %context) unnamed_addr #1 section ".special_function_section" {
 entry:
@@ -53,7 +61,7 @@ entry:
 }
 
 ; Function Attrs: nounwind
-define void @exportedFunctionInSection() #5 section ".special_function_section" {
+define void @exportedFunctionInSection() #6 section ".special_function_section" {
 entry:
   ret void
 }
@@ -65,4 +73,5 @@ attributes #1 = { nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,
 attributes #2 = { nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" "wasm-export-name"="extern_func" "wasm-import-module"="env" "wasm-import-name"="extern_func" }
 attributes #3 = { inlinehint nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" }
 attributes #4 = { noinline nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" }
-attributes #5 = { nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" "wasm-export-name"="exportedFunctionInSection" "wasm-import-module"="env" "wasm-import-name"="exportedFunctionInSection" }
+attributes #5 = { nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" "tinygo-noalloc" }
+attributes #6 = { nounwind "target-features"="+bulk-memory,+nontrapping-fptoint,+sign-ext" "wasm-export-name"="exportedFunctionInSection" "wasm-import-module"="env" "wasm-import-name"="exportedFunctionInSection" }
@@ -5,6 +5,7 @@ package interp
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -58,9 +59,40 @@ func (r *runner) dispose() {
 	r.targetData = llvm.TargetData{}
 }
 
+// Fallback describes a package initializer that could not be run at compile
+// time, and is therefore run at runtime. The globals it touches are then
+// initialized at runtime, so they need to be stored in RAM instead of flash.
+type Fallback struct {
+	ImportPath string
+	Err        *Error // the reason, and the instruction that caused it
+	Globals    []FallbackGlobal
+}
+
+// FallbackGlobal is a global variable that is touched by a package initializer
+// that runs at runtime.
+type FallbackGlobal struct {
+	Name string
+	Size uint64 // size in bytes
+}
+
+// Size returns the total size in bytes of the globals that are touched by the
+// package initializer.
+func (f *Fallback) Size() uint64 {
+	var size uint64
+	for _, global := range f.Globals {
+		size += global.Size
+	}
+	return size
+}
+
 // Run evaluates runtime.initAll function as much as possible at compile time.
-// Set debug to true if it should print output while running.
-func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
+// Set debug to true if it should print output while running. It returns the
+// package initializers that could not be run at compile time.
+//
+// Globals marked //go:constinit must be initialized at compile time: Run
+// returns an error if a package initializer that touches them can only be run
+// at runtime.
+func Run(mod llvm.Module, timeout time.Duration, debug bool) ([]Fallback, error) {
 	r := newRunner(mod, timeout, debug)
 	defer r.dispose()
 
@@ -97,17 +129,18 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			break // ret void
 		}
 		if inst.IsACallInst().IsNil() || inst.CalledValue().IsAFunction().IsNil() {
-			return errorAt(inst, "interp: expected all instructions in "+initAll.Name()+" to be direct calls")
+			return nil, errorAt(inst, "interp: expected all instructions in "+initAll.Name()+" to be direct calls")
 		}
 		initCalls = append(initCalls, inst)
 	}
 
 	// Run initializers for each package. Once the package initializer is
 	// finished, the call to the package initializer can be removed.
+	var fallbacks []Fallback
 	for _, call := range initCalls {
 		initName := call.CalledValue().Name()
 		if !strings.HasSuffix(initName, ".init") {
-			return errorAt(call, "interp: expected all instructions in "+initAll.Name()+" to be *.init() calls")
+			return nil, errorAt(call, "interp: expected all instructions in "+initAll.Name()+" to be *.init() calls")
 		}
 		r.pkgName = initName[:len(initName)-len(".init")]
 		fn := call.CalledValue()
@@ -130,13 +163,18 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 				r.builder.CreateCall(fn, []llvm.Value{i8undef}, "")
 				// Make sure that any globals touched by the package
 				// initializer, won't be accessed by later package initializers.
-				err := r.markExternalLoad(fn)
+				marked, err := r.markExternalLoad(fn)
 				if err != nil {
-					return fmt.Errorf("failed to interpret package %s: %w", r.pkgName, err)
+					return nil, fmt.Errorf("failed to interpret package %s: %w", r.pkgName, err)
 				}
+				fallbacks = append(fallbacks, Fallback{
+					ImportPath: r.pkgName,
+					Err:        callErr,
+					Globals:    r.fallbackGlobals(marked),
+				})
 				continue
 			}
-			return callErr
+			return nil, callErr
 		}
 		for index, obj := range mem.objects {
 			r.objects[index] = obj
@@ -144,6 +182,11 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 	}
 	r.pkgName = ""
 
+	err := r.checkConstInit(fallbacks)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update all global variables in the LLVM module.
 	mem := memoryView{r: r}
 	for i, obj := range r.objects {
@@ -166,7 +209,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			// memory layout.
 			initializer, err := obj.buffer.asRawValue(r).rawLLVMValue(&mem)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			initializerType := initializer.Type()
 			newGlobal := llvm.AddGlobal(mod, initializerType, obj.llvmGlobal.Name()+".tmp")
@@ -188,7 +231,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			continue
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if checks && initializer.Type() != obj.llvmGlobal.Type().ElementType() {
 			panic("initializer type mismatch")
@@ -196,6 +239,66 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 		obj.llvmGlobal.SetInitializer(initializer)
 	}
 
+	return fallbacks, nil
+}
+
+// fallbackGlobals returns the global variables in the given objects, sorted by
+// name.
+func (r *runner) fallbackGlobals(indices []uint32) []FallbackGlobal {
+	var globals []FallbackGlobal
+	for _, index := range indices {
+		obj := r.objects[index]
+		if obj.llvmGlobal.IsNil() || obj.llvmGlobal.IsAGlobalVariable().IsNil() || obj.constant || obj.llvmGlobal.IsDeclaration() {
+			// Not a global variable, or one that can't be written to.
+			continue
+		}
+		globals = append(globals, FallbackGlobal{
+			Name: obj.llvmGlobal.Name(),
+			Size: r.targetData.TypeAllocSize(obj.llvmGlobal.Type().ElementType()),
+		})
+	}
+	sort.Slice(globals, func(i, j int) bool {
+		return globals[i].Name < globals[j].Name
+	})
+	return globals
+}
+
+// checkConstInit checks that globals marked //go:constinit are not modified at
+// runtime by package initializers, and removes the list of these globals from
+// the module. The compiler stores this list for each package in a global named
+// "<package>$constinit".
+func (r *runner) checkConstInit(fallbacks []Fallback) error {
+	var lists []llvm.Value
+	for global := r.mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if strings.HasSuffix(global.Name(), "$constinit") {
+			lists = append(lists, global)
+		}
+	}
+	for _, list := range lists {
+		initializer := list.Initializer()
+		for i := 0; i < initializer.OperandsCount(); i++ {
+			global := initializer.Operand(i)
+			if !global.IsAConstantExpr().IsNil() {
+				global = global.Operand(0) // bitcast
+			}
+			index, ok := r.globals[global]
+			if !ok || r.objects[index].marked < 2 {
+				// Not touched by code that runs at runtime.
+				continue
+			}
+			for _, fallback := range fallbacks {
+				for _, g := range fallback.Globals {
+					if g.Name == global.Name() {
+						err := *fallback.Err
+						err.Err = fmt.Errorf("global %s is marked //go:constinit but the initializer of package %s runs at runtime: %w", global.Name(), fallback.ImportPath, fallback.Err.Err)
+						return &err
+					}
+				}
+			}
+			return errorAt(list, fmt.Sprintf("interp: global %s is marked //go:constinit but is modified by code that runs at runtime", global.Name()))
+		}
+		list.EraseFromParentAsGlobal()
+	}
 	return nil
 }
 
@@ -301,16 +404,23 @@ func (r *runner) getFunction(llvmFn llvm.Value) *function {
 // variable. Another package initializer might read from the same global
 // variable. By marking this function as being run at runtime, that load
 // instruction will need to be run at runtime instead of at compile time.
-func (r *runner) markExternalLoad(llvmValue llvm.Value) error {
+//
+// It returns the indices of the objects that were newly marked, sorted.
+func (r *runner) markExternalLoad(llvmValue llvm.Value) ([]uint32, error) {
 	mem := memoryView{r: r}
 	err := mem.markExternalLoad(llvmValue)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var marked []uint32
 	for index, obj := range mem.objects {
 		if obj.marked > r.objects[index].marked {
+			marked = append(marked, index)
 			r.objects[index].marked = obj.marked
 		}
 	}
-	return nil
+	sort.Slice(marked, func(i, j int) bool {
+		return marked[i] < marked[j]
+	})
+	return marked, nil
 }
@@ -53,7 +53,7 @@ func runTest(t *testing.T, pathPrefix string) {
 	defer mod.Dispose()
 
 	// Perform the transform.
-	err = Run(mod, 10*time.Minute, false)
+	_, err = Run(mod, 10*time.Minute, false)
 	if err != nil {
 		if err, match := err.(*Error); match {
 			println(err.Error())
@@ -101,6 +101,71 @@ func runTest(t *testing.T, pathPrefix string) {
 	}
 }
 
+// loadTestModule parses the LLVM IR file at path.
+func loadTestModule(t *testing.T, ctx llvm.Context, path string) llvm.Module {
+	buf, err := llvm.NewMemoryBufferFromFile(path)
+	os.Stat(path) // make sure this file is tracked by `go test` caching
+	if err != nil {
+		t.Fatalf("could not read file %s: %v", path, err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+	return mod
+}
+
+func TestFallbacks(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	mod := loadTestModule(t, ctx, "testdata/fallback.ll")
+	defer mod.Dispose()
+
+	fallbacks, err := Run(mod, 10*time.Minute, false)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(fallbacks) != 1 || fallbacks[0].ImportPath != "foo" {
+		t.Fatalf("expected a fallback for package foo, got %#v", fallbacks)
+	}
+	fallback := fallbacks[0]
+	if fallback.Err.Err != errUnsupportedInst {
+		t.Errorf("unexpected fallback reason: %v", fallback.Err.Err)
+	}
+	expected := []FallbackGlobal{{"foo.other", 8}, {"foo.table", 64}}
+	if len(fallback.Globals) != len(expected) {
+		t.Fatalf("expected globals %v, got %v", expected, fallback.Globals)
+	}
+	for i, global := range fallback.Globals {
+		if global != expected[i] {
+			t.Errorf("expected global %v, got %v", expected[i], global)
+		}
+	}
+	if size := fallback.Size(); size != 72 {
+		t.Errorf("expected a size of 72 bytes, got %d", size)
+	}
+	if !mod.NamedGlobal("bar$constinit").IsNil() {
+		t.Error("list of //go:constinit globals was not removed")
+	}
+}
+
+func TestConstInit(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	mod := loadTestModule(t, ctx, "testdata/constinit.ll")
+	defer mod.Dispose()
+
+	_, err := Run(mod, 10*time.Minute, false)
+	if err == nil {
+		t.Fatal("expected an error for a //go:constinit global initialized at runtime")
+	}
+	if !strings.Contains(err.Error(), "global foo.value is marked //go:constinit") {
+		t.Error("unexpected error:", err)
+	}
+}
+
 // fuzzyEqualIR returns true if the two LLVM IR strings passed in are roughly
 // equal. That means, only relevant lines are compared (excluding comments
 // etc.).
@@ -0,0 +1,18 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@foo.value = global i32 0
+@"foo$constinit" = internal constant [1 x i8*] [i8* bitcast (i32* @foo.value to i8*)]
+
+define void @runtime.initAll() unnamed_addr {
+entry:
+  call void @foo.init(i8* undef)
+  ret void
+}
+
+define internal void @foo.init(i8* %context) unnamed_addr {
+  ; This global is marked //go:constinit, but can only be initialized at
+  ; runtime.
+  store i32 3, i32* @foo.value
+  unreachable
+}
@@ -0,0 +1,28 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@foo.table = global [16 x i32] zeroinitializer
+@foo.other = global i64 0
+@bar.value = global i32 0
+@"bar$constinit" = internal constant [1 x i8*] [i8* bitcast (i32* @bar.value to i8*)]
+
+define void @runtime.initAll() unnamed_addr {
+entry:
+  call void @foo.init(i8* undef)
+  call void @bar.init(i8* undef)
+  ret void
+}
+
+define internal void @foo.init(i8* %context) unnamed_addr {
+  store i64 1, i64* @foo.other
+  %elem = getelementptr [16 x i32], [16 x i32]* @foo.table, i32 0, i32 3
+  store i32 5, i32* %elem
+  unreachable ; this triggers a revert of @foo.init
+}
+
+define internal void @bar.init(i8* %context) unnamed_addr {
+  ; This global is marked //go:constinit, and can be initialized at compile
+  ; time.
+  store i32 3, i32* @bar.value
+  ret void
+}
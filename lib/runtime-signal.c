@@ -0,0 +1,47 @@
+// This file implements the parts of signal handling that are easier to write in
+// C than in Go, because they need libc types like struct sigaction.
+// It is only used on Linux, with musl.
+
+#include <signal.h>
+#include <stdint.h>
+
+// Implemented in signal_linux.go.
+void tinygo_signal_handler(int sig);
+
+static void tinygo_signal_set(uint32_t sig, void (*handler)(int)) {
+    struct sigaction act = { 0 };
+    act.sa_handler = handler;
+    act.sa_flags = SA_RESTART;
+    sigaction(sig, &act, NULL);
+}
+
+void tinygo_signal_enable(uint32_t sig) {
+    tinygo_signal_set(sig, &tinygo_signal_handler);
+}
+
+void tinygo_signal_disable(uint32_t sig) {
+    tinygo_signal_set(sig, SIG_DFL);
+}
+
+void tinygo_signal_ignore(uint32_t sig) {
+    tinygo_signal_set(sig, SIG_IGN);
+}
+
+// Wait until at least one bit in the pending bitmask is set. Signals are
+// blocked while checking the mask, so that a signal that arrives right after
+// the check will still wake up sigsuspend.
+void tinygo_signal_wait(volatile uint32_t *pending, uint32_t n) {
+    sigset_t all, old;
+    sigfillset(&all);
+    sigprocmask(SIG_BLOCK, &all, &old);
+    int found = 0;
+    for (uint32_t i = 0; i < n; i++) {
+        if (__atomic_load_n(&pending[i], __ATOMIC_SEQ_CST) != 0) {
+            found = 1;
+        }
+    }
+    if (!found) {
+        sigsuspend(&old);
+    }
+    sigprocmask(SIG_SETMASK, &old, NULL);
+}
@@ -0,0 +1,203 @@
+// This file implements the parts of the threads scheduler that are easier to
+// write in C than in Go: creating threads, thread-local storage, futexes and
+// stopping all threads for the garbage collector.
+// It is only used on Linux, with musl.
+
+#define _GNU_SOURCE
+#include <limits.h>
+#include <pthread.h>
+#include <signal.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <sys/syscall.h>
+#include <time.h>
+#include <unistd.h>
+
+// Linux kernel headers are not available, so define the futex operations here.
+#define FUTEX_WAIT_PRIVATE 128
+#define FUTEX_WAKE_PRIVATE 129
+
+// Signal used to stop all threads during a GC cycle.
+#define TINYGO_GC_SIGNAL (SIGRTMIN + 3)
+
+// Implemented in src/internal/task/task_threads.go.
+void tinygo_task_started(void *task);
+void tinygo_task_exited(void *task);
+
+// The current goroutine (*task.Task) and a pointer to the place where its
+// stack pointer should be stored when the world is stopped.
+static __thread void *current_task;
+static __thread uintptr_t *current_sp;
+
+void *tinygo_task_current(void) {
+    return current_task;
+}
+
+void tinygo_task_set_current(void *task, uintptr_t *sp) {
+    current_task = task;
+    current_sp = sp;
+}
+
+void tinygo_futex_wait(uint32_t *addr, uint32_t cmp) {
+    syscall(SYS_futex, addr, FUTEX_WAIT_PRIVATE, cmp, NULL, NULL, 0);
+}
+
+// Like tinygo_futex_wait, but with a timeout in nanoseconds.
+void tinygo_futex_wait_timeout(uint32_t *addr, uint32_t cmp, int64_t timeout) {
+    struct timespec ts;
+    ts.tv_sec = timeout / 1000000000;
+    ts.tv_nsec = timeout % 1000000000;
+    syscall(SYS_futex, addr, FUTEX_WAIT_PRIVATE, cmp, &ts, NULL, 0);
+}
+
+void tinygo_futex_wake(uint32_t *addr, uint32_t n) {
+    syscall(SYS_futex, addr, FUTEX_WAKE_PRIVATE, n, NULL, NULL, 0);
+}
+
+// A simple futex based mutex. The state is 0 when unlocked, 1 when locked and
+// 2 when locked with possible waiters.
+void tinygo_mutex_lock(uint32_t *state) {
+    uint32_t c = 0;
+    if (__atomic_compare_exchange_n(state, &c, 1, 0, __ATOMIC_ACQUIRE, __ATOMIC_RELAXED)) {
+        return;
+    }
+    if (c != 2) {
+        c = __atomic_exchange_n(state, 2, __ATOMIC_ACQUIRE);
+    }
+    while (c != 0) {
+        tinygo_futex_wait(state, 2);
+        c = __atomic_exchange_n(state, 2, __ATOMIC_ACQUIRE);
+    }
+}
+
+void tinygo_mutex_unlock(uint32_t *state) {
+    if (__atomic_fetch_sub(state, 1, __ATOMIC_RELEASE) != 1) {
+        __atomic_store_n(state, 0, __ATOMIC_RELEASE);
+        tinygo_futex_wake(state, 1);
+    }
+}
+
+// The scheduler lock protects all runtime data structures (channels, timers,
+// etc). It replaces disabling interrupts on baremetal systems and may be
+// locked recursively by the same thread.
+static uint32_t scheduler_lock;
+static __thread uint32_t scheduler_lock_depth;
+
+void tinygo_scheduler_lock(void) {
+    if (scheduler_lock_depth++ == 0) {
+        tinygo_mutex_lock(&scheduler_lock);
+    }
+}
+
+void tinygo_scheduler_unlock(void) {
+    if (--scheduler_lock_depth == 0) {
+        tinygo_mutex_unlock(&scheduler_lock);
+    }
+}
+
+struct task_start {
+    void (*fn)(void *);
+    void *args;
+    void *task;
+    uintptr_t *sp;
+    uintptr_t *stack_top;
+};
+
+static void *tinygo_task_entry(void *arg) {
+    struct task_start start = *(struct task_start *)arg;
+    free(arg);
+    tinygo_task_set_current(start.task, start.sp);
+
+    // Store the top of the stack, for the GC.
+    pthread_attr_t attr;
+    void *stack_addr;
+    size_t stack_size;
+    pthread_getattr_np(pthread_self(), &attr);
+    pthread_attr_getstack(&attr, &stack_addr, &stack_size);
+    pthread_attr_destroy(&attr);
+    *start.stack_top = (uintptr_t)stack_addr + stack_size;
+
+    tinygo_task_started(start.task);
+    start.fn(start.args);
+    tinygo_task_exited(start.task);
+    return NULL;
+}
+
+// Start a new thread that runs fn(args). The thread ID is stored in *thread.
+// Returns 0 on success or an error number.
+int tinygo_task_start(uintptr_t fn, void *args, uintptr_t stack_size, void *task, uintptr_t *sp, uintptr_t *stack_top, uintptr_t *thread) {
+    struct task_start *start = malloc(sizeof(struct task_start));
+    if (start == NULL) {
+        return -1;
+    }
+    start->fn = (void (*)(void *))fn;
+    start->args = args;
+    start->task = task;
+    start->sp = sp;
+    start->stack_top = stack_top;
+
+    pthread_attr_t attr;
+    pthread_attr_init(&attr);
+    pthread_attr_setdetachstate(&attr, PTHREAD_CREATE_DETACHED);
+    pthread_attr_setstacksize(&attr, stack_size);
+    pthread_t id;
+    int err = pthread_create(&id, &attr, tinygo_task_entry, start);
+    pthread_attr_destroy(&attr);
+    if (err != 0) {
+        free(start);
+        return err;
+    }
+    *thread = (uintptr_t)id;
+    return 0;
+}
+
+// Number of threads that have stopped for the GC, and a counter that is
+// incremented each time the world is started again.
+static uint32_t gc_stopped;
+static uint32_t gc_generation;
+
+// Signal handler that stops the current thread until the GC has finished. All
+// registers have been saved on the stack by the kernel, so the GC only needs to
+// scan the stack from the current stack pointer.
+static void tinygo_task_gc_pause(int sig) {
+    (void)sig;
+    volatile uintptr_t sp = 0;
+    *current_sp = (uintptr_t)&sp;
+    uint32_t generation = __atomic_load_n(&gc_generation, __ATOMIC_SEQ_CST);
+    __atomic_fetch_add(&gc_stopped, 1, __ATOMIC_SEQ_CST);
+    tinygo_futex_wake(&gc_stopped, 1);
+    while (__atomic_load_n(&gc_generation, __ATOMIC_SEQ_CST) == generation) {
+        tinygo_futex_wait(&gc_generation, generation);
+    }
+}
+
+void tinygo_task_init(void) {
+    struct sigaction act = { 0 };
+    act.sa_handler = tinygo_task_gc_pause;
+    act.sa_flags = SA_RESTART;
+    sigfillset(&act.sa_mask);
+    sigaction(TINYGO_GC_SIGNAL, &act, NULL);
+}
+
+// Stop the given threads. It returns once all of them are paused in the signal
+// handler.
+void tinygo_task_gc_stop(uintptr_t *threads, uint32_t n) {
+    __atomic_store_n(&gc_stopped, 0, __ATOMIC_SEQ_CST);
+    for (uint32_t i = 0; i < n; i++) {
+        pthread_kill((pthread_t)threads[i], TINYGO_GC_SIGNAL);
+    }
+    uint32_t stopped;
+    while ((stopped = __atomic_load_n(&gc_stopped, __ATOMIC_SEQ_CST)) < n) {
+        tinygo_futex_wait(&gc_stopped, stopped);
+    }
+}
+
+// Resume all threads stopped by tinygo_task_gc_stop.
+void tinygo_task_gc_resume(void) {
+    __atomic_fetch_add(&gc_generation, 1, __ATOMIC_SEQ_CST);
+    tinygo_futex_wake(&gc_generation, INT_MAX);
+}
+
+long tinygo_task_num_cpu(void) {
+    return sysconf(_SC_NPROCESSORS_ONLN);
+}
@@ -0,0 +1,333 @@
+package loader
+
+// This file instruments the package under test for code coverage (tinygo test
+// -cover), using the same approach as `go tool cover`: the source code is split
+// into basic blocks and a statement that sets or increments a counter is
+// inserted at the start of each of them. This is done on the AST before type
+// checking, so it works the same on every target.
+// The counters and the source position of each block are registered with the
+// testing package (from an init function in an extra file added to the
+// package), which writes them out as a standard coverage profile.
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Name of the global with the coverage counters in an instrumented package.
+const coverCountersName = "_tinygoCoverCounters"
+
+// coverBlock is a block of code with a single coverage counter.
+type coverBlock struct {
+	file       int // index in the list of files
+	start, end token.Position
+	numStmt    int
+}
+
+// coverer adds coverage counters to the files of a package.
+type coverer struct {
+	fset   *token.FileSet
+	mode   string // "set" or "count"
+	file   int
+	blocks []coverBlock
+}
+
+// isCoverageTarget returns whether this package should be instrumented for
+// code coverage. Like with go test, only the package under test is.
+func (p *Package) isCoverageTarget() bool {
+	config := p.program.config
+	if config.TestConfig.CoverMode == "" || !config.TestConfig.CompileTestBinary {
+		return false
+	}
+	return p.ImportPath+".test" == p.program.MainPkg().ImportPath
+}
+
+// addCoverage adds coverage counters to all non-test Go files of the package,
+// and adds a file that registers them with the testing package.
+func (p *Package) addCoverage(mode string) error {
+	sourceFiles := make(map[string]bool)
+	for _, name := range append(p.GoFiles, p.CgoFiles...) {
+		if !strings.HasSuffix(name, "_test.go") {
+			sourceFiles[filepath.Base(name)] = true
+		}
+	}
+
+	c := &coverer{
+		fset: p.program.fset,
+		mode: mode,
+	}
+	var files []string
+	for _, file := range p.Files {
+		name := filepath.Base(c.fset.File(file.Pos()).Name())
+		if !sourceFiles[name] {
+			continue
+		}
+		c.file = len(files)
+		files = append(files, p.ImportPath+"/"+name)
+		ast.Inspect(file, c.visit)
+	}
+	if len(c.blocks) == 0 {
+		return nil
+	}
+
+	// Create a file that declares the counters and registers them, together
+	// with the position and number of statements of each block.
+	var blocks, fileNames strings.Builder
+	for _, block := range c.blocks {
+		fmt.Fprintf(&blocks, "\n\t\t%d, %d, %d, %d, %d, %d,", block.file, block.start.Line, block.start.Column, block.end.Line, block.end.Column, block.numStmt)
+	}
+	for _, name := range files {
+		fmt.Fprintf(&fileNames, "\n\t\t%q,", name)
+	}
+	src := fmt.Sprintf(`package %s
+
+import _ "unsafe"
+
+var %s [%d]uint32
+
+//go:linkname _tinygoCoverRegister testing.registerCover
+func _tinygoCoverRegister(mode string, counters []uint32, blocks []uint32, files []string)
+
+func init() {
+	_tinygoCoverRegister(%q, %s[:], []uint32{%s
+	}, []string{%s
+	})
+}
+`, p.Name, coverCountersName, len(c.blocks), mode, coverCountersName, blocks.String(), fileNames.String())
+	path := filepath.Join(p.Dir, "_tinygo_cover.go")
+	f, err := parser.ParseFile(p.program.fset, path, src, parser.ParseComments)
+	if err != nil {
+		return err // shouldn't happen
+	}
+	p.Files = append(p.Files, f)
+	// Make sure the instrumented package is cached separately.
+	sum := sha512.Sum512_224([]byte(src))
+	p.FileHashes[path] = sum[:]
+	return nil
+}
+
+// visit adds counters to the statement lists in the given node. It is called
+// by ast.Inspect for each node in a file.
+func (c *coverer) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.FuncDecl:
+		// Functions with a blank name can't be called.
+		return node.Name.Name != "_"
+	case *ast.BlockStmt:
+		if len(node.List) != 0 {
+			switch node.List[0].(type) {
+			case *ast.CaseClause, *ast.CommClause:
+				// The body of a switch or select statement: the clauses get
+				// a counter instead.
+				return true
+			}
+		}
+		node.List = c.addCounters(node.Lbrace, node.Rbrace+1, node.List, true)
+	case *ast.CaseClause:
+		node.Body = c.addCounters(node.Colon+1, node.End(), node.Body, false)
+	case *ast.CommClause:
+		node.Body = c.addCounters(node.Colon+1, node.End(), node.Body, false)
+	case *ast.IfStmt:
+		if elseIf, ok := node.Else.(*ast.IfStmt); ok {
+			// Wrap an "else if" in a block, so that there is a place to put
+			// the counter for its condition.
+			node.Else = &ast.BlockStmt{
+				Lbrace: node.Body.End(),
+				List:   []ast.Stmt{elseIf},
+				Rbrace: elseIf.End(),
+			}
+		}
+	case *ast.SelectStmt:
+		// A counter can't be put in an empty select statement.
+		return len(node.Body.List) != 0
+	case *ast.SwitchStmt:
+		if len(node.Body.List) == 0 {
+			// Same for an empty switch statement.
+			c.inspect(node.Init)
+			c.inspect(node.Tag)
+			return false
+		}
+	case *ast.TypeSwitchStmt:
+		if len(node.Body.List) == 0 {
+			c.inspect(node.Init)
+			c.inspect(node.Assign)
+			return false
+		}
+	}
+	return true
+}
+
+// inspect adds counters to function literals in the given (possibly nil)
+// node.
+func (c *coverer) inspect(node ast.Node) {
+	if node != nil {
+		ast.Inspect(node, c.visit)
+	}
+}
+
+// addCounters splits the list of statements into basic blocks and puts a
+// counter at the start of each. The first block starts at pos, and the last
+// block ends at blockEnd if extendToClosingBrace is set.
+func (c *coverer) addCounters(pos, blockEnd token.Pos, list []ast.Stmt, extendToClosingBrace bool) []ast.Stmt {
+	if len(list) == 0 {
+		// An empty block is counted too.
+		return []ast.Stmt{c.newCounter(pos, blockEnd, 0)}
+	}
+	var newList []ast.Stmt
+	for len(list) != 0 {
+		// Find the first statement that affects control flow. It ends the
+		// basic block.
+		var last int
+		end := blockEnd
+		for last = 0; last < len(list); last++ {
+			stmt := list[last]
+			end = statementBoundary(stmt)
+			if endsBasicBlock(stmt) {
+				if label, ok := stmt.(*ast.LabeledStmt); ok && !isControl(label.Stmt) {
+					// The label may be the target of a goto, which starts a
+					// new basic block. So split the label from its statement
+					// and put the statement in the next block.
+					newLabel := *label
+					newLabel.Stmt = &ast.EmptyStmt{Semicolon: label.Stmt.Pos(), Implicit: true}
+					end = label.Pos()
+					list = append(list[:last:last], append([]ast.Stmt{&newLabel, label.Stmt}, list[last+1:]...)...)
+				}
+				last++
+				extendToClosingBrace = false
+				break
+			}
+		}
+		if extendToClosingBrace {
+			end = blockEnd
+		}
+		if pos != end {
+			// Blocks may abut, in which case there is nothing to cover.
+			newList = append(newList, c.newCounter(pos, end, last))
+		}
+		newList = append(newList, list[:last]...)
+		list = list[last:]
+		if len(list) != 0 {
+			pos = list[0].Pos()
+		}
+	}
+	return newList
+}
+
+// newCounter returns a statement that sets or increments a new counter for the
+// block from start to end.
+func (c *coverer) newCounter(start, end token.Pos, numStmt int) ast.Stmt {
+	index := len(c.blocks)
+	c.blocks = append(c.blocks, coverBlock{
+		file:    c.file,
+		start:   c.fset.Position(start),
+		end:     c.fset.Position(end),
+		numStmt: numStmt,
+	})
+	counter := &ast.IndexExpr{
+		X:      &ast.Ident{NamePos: start, Name: coverCountersName},
+		Lbrack: start,
+		Index:  &ast.BasicLit{ValuePos: start, Kind: token.INT, Value: strconv.Itoa(index)},
+		Rbrack: start,
+	}
+	if c.mode == "count" {
+		return &ast.IncDecStmt{X: counter, TokPos: start, Tok: token.INC}
+	}
+	return &ast.AssignStmt{
+		Lhs:    []ast.Expr{counter},
+		TokPos: start,
+		Tok:    token.ASSIGN,
+		Rhs:    []ast.Expr{&ast.BasicLit{ValuePos: start, Kind: token.INT, Value: "1"}},
+	}
+}
+
+// statementBoundary returns where the basic block containing stmt ends when
+// stmt is the last statement in it: before the body of a control flow
+// statement or function literal.
+func statementBoundary(stmt ast.Stmt) token.Pos {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStmt:
+		// Treat blocks like basic blocks, to avoid overlapping counters.
+		return stmt.Lbrace
+	case *ast.IfStmt:
+		return funcLiteralOr(stmt.Body.Lbrace, stmt.Init, stmt.Cond)
+	case *ast.ForStmt:
+		return funcLiteralOr(stmt.Body.Lbrace, stmt.Init, stmt.Cond, stmt.Post)
+	case *ast.LabeledStmt:
+		return statementBoundary(stmt.Stmt)
+	case *ast.RangeStmt:
+		return funcLiteralOr(stmt.Body.Lbrace, stmt.X)
+	case *ast.SwitchStmt:
+		return funcLiteralOr(stmt.Body.Lbrace, stmt.Init, stmt.Tag)
+	case *ast.SelectStmt:
+		return stmt.Body.Lbrace
+	case *ast.TypeSwitchStmt:
+		return funcLiteralOr(stmt.Body.Lbrace, stmt.Init)
+	}
+	// Any other statement may contain a function literal, whose body isn't
+	// part of this block.
+	return funcLiteralOr(stmt.End(), stmt)
+}
+
+// funcLiteralOr returns the start of the body of the first function literal
+// in the given nodes, or pos if there is none.
+func funcLiteralOr(pos token.Pos, nodes ...ast.Node) token.Pos {
+	for _, node := range nodes {
+		if found := findFuncLiteral(node); found.IsValid() {
+			return found
+		}
+	}
+	return pos
+}
+
+// findFuncLiteral returns the start of the body of the first function literal
+// in node, or token.NoPos if there is none.
+func findFuncLiteral(node ast.Node) token.Pos {
+	found := token.NoPos
+	if node == nil {
+		return found
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && !found.IsValid() {
+			found = lit.Body.Lbrace
+		}
+		return !found.IsValid()
+	})
+	return found
+}
+
+// endsBasicBlock returns whether the statement ends a basic block of source
+// code, because it is or contains control flow.
+func endsBasicBlock(stmt ast.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStmt, *ast.BranchStmt, *ast.ForStmt, *ast.IfStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	case *ast.LabeledStmt:
+		// A goto may jump here, starting a new basic block.
+		return true
+	case *ast.ExprStmt:
+		// A call to panic doesn't return. Without type information this could
+		// be a different function named panic, but that's very unlikely.
+		if call, ok := stmt.X.(*ast.CallExpr); ok {
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "panic" && len(call.Args) == 1 {
+				return true
+			}
+		}
+	}
+	return findFuncLiteral(stmt).IsValid()
+}
+
+// isControl returns whether the statement can be the target of a labeled break
+// or continue, which means the label can't be separated from it.
+func isControl(stmt ast.Stmt) bool {
+	switch stmt.(type) {
+	case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Pass -update to go test to update the output of the test files.
+var flagUpdate = flag.Bool("update", false, "update tests based on test output")
+
+// Test the coverage instrumentation: the counters that are inserted and the
+// blocks of source code they belong to.
+func TestCoverage(t *testing.T) {
+	for _, mode := range []string{"set", "count"} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			path := filepath.Join("testdata", "cover.go")
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatal("could not parse Go source file:", err)
+			}
+
+			c := &coverer{
+				fset: fset,
+				mode: mode,
+			}
+			ast.Inspect(f, c.visit)
+
+			// Print the instrumented source code, followed by the blocks in
+			// the format of a coverage profile.
+			buf := &bytes.Buffer{}
+			err = format.Node(buf, fset, f)
+			if err != nil {
+				t.Fatal("could not format instrumented source:", err)
+			}
+			buf.WriteString("\n// Blocks:\n")
+			for i, block := range c.blocks {
+				fmt.Fprintf(buf, "// %d: %d.%d,%d.%d %d\n", i, block.start.Line, block.start.Column, block.end.Line, block.end.Column, block.numStmt)
+			}
+			actual := strings.ReplaceAll(buf.String(), "\r\n", "\n")
+
+			outPath := filepath.Join("testdata", "cover."+mode+".go")
+			if *flagUpdate {
+				err := os.WriteFile(outPath, []byte(actual), 0666)
+				if err != nil {
+					t.Fatal("could not write output file:", err)
+				}
+				return
+			}
+			expected, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal("could not read expected output:", err)
+			}
+			if actual != strings.ReplaceAll(string(expected), "\r\n", "\n") {
+				t.Errorf("output did not match %s:\n%s", outPath, actual)
+			}
+		})
+	}
+}
@@ -361,6 +361,14 @@ func (p *Package) Parse() error {
 	}
 	p.Files = files
 
+	// Instrument the package under test for code coverage, if requested.
+	if p.isCoverageTarget() {
+		err := p.addCoverage(p.program.config.TestConfig.CoverMode)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
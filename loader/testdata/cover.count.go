@@ -0,0 +1,87 @@
+package cover
+
+func simple(a int) int {
+	_tinygoCoverCounters[0]++
+	b := a * 2
+	return b
+}
+
+func branches(a int) string {
+	_tinygoCoverCounters[1]++
+	if a < 0 {
+		_tinygoCoverCounters[5]++
+		return "negative"
+	} else {
+		_tinygoCoverCounters[6]++
+		if a == 0 {
+			_tinygoCoverCounters[7]++
+			return "zero"
+		}
+	}
+	_tinygoCoverCounters[2]++
+	for i := 0; i < a; i++ {
+		_tinygoCoverCounters[8]++
+		if i == 5 {
+			_tinygoCoverCounters[9]++
+			break
+		}
+	}
+	_tinygoCoverCounters[3]++
+	switch a {
+	case 1:
+		_tinygoCoverCounters[10]++
+		return "one"
+	default:
+		_tinygoCoverCounters[11]++
+	}
+	_tinygoCoverCounters[4]++
+	return "many"
+}
+
+func closure() func() int {
+	_tinygoCoverCounters[12]++
+	x := 1
+	return func() int {
+		_tinygoCoverCounters[13]++
+		return x
+	}
+}
+
+func retry(n int) {
+	_tinygoCoverCounters[14]++
+	i := 0
+again:
+	;
+	_tinygoCoverCounters[15]++
+	i++
+	if i < n {
+		_tinygoCoverCounters[17]++
+		goto again
+	}
+	_tinygoCoverCounters[16]++
+	panic("done")
+}
+
+func _() {
+	// Not instrumented, as it can't be called.
+}
+
+// Blocks:
+// 0: 3.24,6.2 2
+// 1: 8.29,9.11 1
+// 2: 14.2,14.25 1
+// 3: 19.2,19.11 1
+// 4: 24.2,24.15 1
+// 5: 9.11,11.3 1
+// 6: 11.3,11.19 1
+// 7: 11.19,13.3 1
+// 8: 14.25,15.13 1
+// 9: 15.13,16.9 1
+// 10: 20.9,21.15 1
+// 11: 22.10,22.10 0
+// 12: 27.27,29.20 2
+// 13: 29.20,31.3 1
+// 14: 34.19,36.1 2
+// 15: 37.2,38.11 2
+// 16: 41.2,41.15 1
+// 17: 38.11,39.13 1
@@ -0,0 +1,46 @@
+package cover
+
+func simple(a int) int {
+	b := a * 2
+	return b
+}
+
+func branches(a int) string {
+	if a < 0 {
+		return "negative"
+	} else if a == 0 {
+		return "zero"
+	}
+	for i := 0; i < a; i++ {
+		if i == 5 {
+			break
+		}
+	}
+	switch a {
+	case 1:
+		return "one"
+	default:
+	}
+	return "many"
+}
+
+func closure() func() int {
+	x := 1
+	return func() int {
+		return x
+	}
+}
+
+func retry(n int) {
+	i := 0
+again:
+	i++
+	if i < n {
+		goto again
+	}
+	panic("done")
+}
+
+func _() {
+	// Not instrumented, as it can't be called.
+}
@@ -196,37 +196,143 @@ func Build(pkgName, outpath string, options *compileopts.Options) error {
 
 // Test runs the tests in the given package. Returns whether the test passed and
 // possibly an error if the test failed to run.
-func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options, testCompileOnly, testVerbose, testShort bool, testRunRegexp string, testBenchRegexp string, testBenchTime string, testBenchMem bool, outpath string) (bool, error) {
+func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options, outpath string) (bool, error) {
 	options.TestConfig.CompileTestBinary = true
 	config, err := builder.NewConfig(options)
 	if err != nil {
 		return false, err
 	}
+	testConfig := &options.TestConfig
+
+	// With -json, the verbose output of the test binary is converted to test
+	// events.
+	var jsonOut *testJSONWriter
+	if options.PrintJSON {
+		jsonOut = &testJSONWriter{out: stdout, pkg: pkgName}
+	}
 
 	// Pass test flags to the test binary.
 	var flags []string
-	if testVerbose {
+	if testConfig.Verbose || jsonOut != nil {
 		flags = append(flags, "-test.v")
 	}
-	if testShort {
+	if testConfig.Short {
 		flags = append(flags, "-test.short")
 	}
-	if testRunRegexp != "" {
-		flags = append(flags, "-test.run="+testRunRegexp)
+	if testConfig.RunRegexp != "" {
+		flags = append(flags, "-test.run="+testConfig.RunRegexp)
 	}
-	if testBenchRegexp != "" {
-		flags = append(flags, "-test.bench="+testBenchRegexp)
+	if testConfig.BenchRegexp != "" {
+		flags = append(flags, "-test.bench="+testConfig.BenchRegexp)
 	}
-	if testBenchTime != "" {
-		flags = append(flags, "-test.benchtime="+testBenchTime)
+	if testConfig.BenchTime != "" {
+		flags = append(flags, "-test.benchtime="+testConfig.BenchTime)
 	}
-	if testBenchMem {
+	if testConfig.BenchMem {
 		flags = append(flags, "-test.benchmem")
 	}
+	if testConfig.Count > 1 {
+		flags = append(flags, "-test.count="+strconv.Itoa(testConfig.Count))
+	}
+	if testConfig.Timeout != 0 {
+		flags = append(flags, "-test.timeout="+testConfig.Timeout.String())
+	}
+	if testConfig.FailFast {
+		flags = append(flags, "-test.failfast")
+	}
+	if testConfig.Shuffle != "" && testConfig.Shuffle != "off" {
+		flags = append(flags, "-test.shuffle="+testConfig.Shuffle)
+	}
+	if testConfig.ListRegexp != "" {
+		flags = append(flags, "-test.list="+testConfig.ListRegexp)
+	}
+	if testConfig.SkipRegexp != "" {
+		flags = append(flags, "-test.skip="+testConfig.SkipRegexp)
+	}
+	if testConfig.Parallel > 0 {
+		flags = append(flags, "-test.parallel="+strconv.Itoa(testConfig.Parallel))
+	}
+
+	// Fuzzing needs a test binary that can write files and is instrumented
+	// with coverage counters, which is only supported when running natively
+	// on Linux for now.
+	var fuzzWorkDir string
+	if testConfig.Fuzz != "" && !testConfig.CompileOnly {
+		supported := config.GOOS() == "linux" && config.Target.Emulator == ""
+		for _, tag := range config.BuildTags() {
+			if tag == "baremetal" || tag == "wasi" {
+				supported = false
+			}
+		}
+		if !supported {
+			return false, fmt.Errorf("-fuzz is only supported when running natively on Linux, not on %s", config.Triple())
+		}
+		flags = append(flags, "-test.fuzz="+testConfig.Fuzz)
+		if testConfig.FuzzTime != "" {
+			flags = append(flags, "-test.fuzztime="+testConfig.FuzzTime)
+		}
+		if testConfig.FuzzMinimizeTime != "" {
+			flags = append(flags, "-test.fuzzminimizetime="+testConfig.FuzzMinimizeTime)
+		}
+
+		// A crashing input is written to this directory by the test binary,
+		// after which the test binary is started again to minimize it.
+		fuzzWorkDir, err = os.MkdirTemp("", "tinygofuzz")
+		if err != nil {
+			return false, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(fuzzWorkDir)
+		flags = append(flags, "-test.fuzzworkdir="+fuzzWorkDir)
+	}
+
+	// The test binary enforces the timeout itself, but that needs working
+	// timers which may not be available in an emulator (for example on
+	// baremetal targets). So kill the emulator when the timeout is exceeded.
+	var timeout time.Duration
+	if config.Target.Emulator != "" {
+		timeout = testConfig.Timeout
+	}
+
+	// The test binary writes its coverage profile to a temporary directory,
+	// from where it is appended to the profile of all tested packages.
+	var coverDir string
+	if testConfig.CoverProfile != "" && !testConfig.CompileOnly {
+		// Baremetal targets can only write the profile to the host using
+		// semihosting, which is only implemented for Cortex-M in QEMU.
+		tags := make(map[string]bool)
+		for _, tag := range config.BuildTags() {
+			tags[tag] = true
+		}
+		if tags["baremetal"] && !(tags["cortexm"] && tags["qemu"]) {
+			return false, fmt.Errorf("-coverprofile is not supported on %s: the test binary can't write files", config.Triple())
+		}
+		coverDir, err = os.MkdirTemp("", "tinygocover")
+		if err != nil {
+			return false, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(coverDir)
+		flags = append(flags, "-test.coverprofile="+filepath.Join(coverDir, "cover.out"))
+	}
 
-	passed := false
-	err = buildAndRun(pkgName, config, os.Stdout, flags, nil, 0, func(cmd *exec.Cmd, result builder.BuildResult) error {
-		if testCompileOnly || outpath != "" {
+	// Run the test with the randomized scheduler if requested. The variable
+	// is passed explicitly, as emulators and baremetal targets don't see the
+	// environment of the host.
+	var environmentVars []string
+	schedSeed, err := randomSchedulerSeed(config)
+	if err != nil {
+		return false, err
+	}
+	if schedSeed != "" {
+		environmentVars = append(environmentVars, "TINYGO_SCHEDSEED="+schedSeed)
+	}
+
+	var testOut io.Writer = os.Stdout
+	if jsonOut != nil {
+		testOut = jsonOut
+	}
+	passed, reported := false, false
+	err = buildAndRun(pkgName, config, testOut, flags, environmentVars, timeout, func(cmd *exec.Cmd, result builder.BuildResult) error {
+		if testConfig.CompileOnly || outpath != "" {
 			// Write test binary to the specified file name.
 			if outpath == "" {
 				// No -o path was given, so create one now.
@@ -235,7 +341,7 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 			}
 			copyFile(result.Binary, outpath)
 		}
-		if testCompileOnly {
+		if testConfig.CompileOnly {
 			// Do not run the test.
 			passed = true
 			return nil
@@ -244,6 +350,15 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 		// Tests are always run in the package directory.
 		cmd.Dir = result.MainDir
 
+		importPath := strings.TrimSuffix(result.ImportPath, ".test")
+		if jsonOut != nil {
+			// Messages like panics are part of the output of a test too.
+			jsonOut.pkg = importPath
+			if cmd.Stdout == jsonOut {
+				cmd.Stderr = jsonOut
+			}
+		}
+
 		// wasmtime is the default emulator used for `-target=wasi`. wasmtime
 		// is a WebAssembly runtime CLI with WASI enabled by default. However,
 		// only stdio are allowed by default. For example, while STDOUT routes
@@ -279,6 +394,10 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 				return fmt.Errorf("failed to create temporary directory: %w", err)
 			}
 			args = append(args, "--dir="+tmpdir, "--env=TMPDIR="+tmpdir)
+			if coverDir != "" {
+				// Allow writing the coverage profile.
+				args = append(args, "--dir="+coverDir)
+			}
 			// TODO: add option to not delete temp dir for debugging?
 			defer os.RemoveAll(tmpdir)
 
@@ -288,18 +407,52 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 			cmd.Args = append(cmd.Args[:1:1], args...)
 		}
 
+		if fuzzWorkDir != "" {
+			// Inputs that increase coverage are kept between runs.
+			cmd.Args = append(cmd.Args, "-test.fuzzcachedir="+filepath.Join(goenv.Get("GOCACHE"), "fuzz", importPath))
+		}
+
 		// Run the test.
 		start := time.Now()
 		err = cmd.Run()
+		if fuzzWorkDir != "" {
+			err = minimizeFuzzCrasher(cmd, err, fuzzWorkDir, testConfig.FuzzMinimizeTime, testOut)
+		}
 		duration := time.Since(start)
+		if err != nil && timeout != 0 && duration >= timeout {
+			// The emulator was killed by buildAndRun.
+			fmt.Fprintf(testOut, "*** Test killed: ran too long (%s).\n", timeout)
+		}
+
+		// Add the coverage profile of this package to the combined profile.
+		if coverDir != "" {
+			if coverErr := appendCoverProfile(testConfig.CoverProfile, filepath.Join(coverDir, "cover.out")); coverErr != nil {
+				fmt.Fprintln(stderr, "could not write coverage profile:", coverErr)
+			}
+		}
 
 		// Print the result.
-		importPath := strings.TrimSuffix(result.ImportPath, ".test")
 		passed = err == nil
+		if !passed && schedSeed != "" && !testConfig.CompileOnly {
+			fmt.Fprintf(testOut, "scheduler seed: %s (replay with TINYGO_SCHEDSEED=%s)\n", schedSeed, schedSeed)
+		}
+		var summary string
 		if passed {
-			fmt.Fprintf(stdout, "ok  \t%s\t%.3fs\n", importPath, duration.Seconds())
+			summary = fmt.Sprintf("ok  \t%s\t%.3fs\n", importPath, duration.Seconds())
 		} else {
-			fmt.Fprintf(stdout, "FAIL\t%s\t%.3fs\n", importPath, duration.Seconds())
+			summary = fmt.Sprintf("FAIL\t%s\t%.3fs\n", importPath, duration.Seconds())
+		}
+		if jsonOut != nil {
+			action := "fail"
+			if passed {
+				action = "pass"
+			}
+			reported = true
+			if jsonErr := jsonOut.finish(action, summary, duration); jsonErr != nil {
+				return jsonErr
+			}
+		} else {
+			io.WriteString(stdout, summary)
 		}
 		if _, ok := err.(*exec.ExitError); ok {
 			// Binary exited with a non-zero exit code, which means the test
@@ -309,13 +462,134 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 		return err
 	})
 	if err, ok := err.(loader.NoTestFilesError); ok {
-		fmt.Fprintf(stdout, "?   \t%s\t[no test files]\n", err.ImportPath)
+		summary := fmt.Sprintf("?   \t%s\t[no test files]\n", err.ImportPath)
+		if jsonOut != nil {
+			jsonOut.pkg = err.ImportPath
+			return true, jsonOut.finish("skip", summary, 0)
+		}
+		fmt.Fprint(stdout, summary)
 		// Pretend the test passed - it at least didn't fail.
 		return true, nil
 	}
+	if jsonOut != nil && err != nil && !reported {
+		// The test failed to build or run, so report the errors as output
+		// of the package.
+		printCompilerError(func(args ...interface{}) {
+			fmt.Fprintln(jsonOut, args...)
+		}, err)
+		return false, jsonOut.finish("fail", "FAIL\t"+pkgName+" [build failed]\n", 0)
+	}
 	return passed, err
 }
 
+// randomSchedulerSeed returns the seed for the randomized scheduler as set in
+// the TINYGO_SCHEDSEED environment variable, or the empty string if it isn't
+// set. The special value "random" picks a new seed, which is printed when the
+// test fails so that the failure can be replayed.
+func randomSchedulerSeed(config *compileopts.Config) (string, error) {
+	seed := os.Getenv("TINYGO_SCHEDSEED")
+	if seed == "" {
+		return "", nil
+	}
+	if seed == "random" {
+		seed = strconv.FormatUint(uint64(time.Now().UnixNano()%(1<<53))|1, 10)
+	} else if n, err := strconv.ParseUint(seed, 10, 64); err != nil || n == 0 {
+		return "", fmt.Errorf("invalid TINYGO_SCHEDSEED %q: must be a positive integer or \"random\"", seed)
+	}
+	switch config.Scheduler() {
+	case "tasks", "asyncify":
+	default:
+		return "", fmt.Errorf("TINYGO_SCHEDSEED is only supported with -scheduler=tasks or -scheduler=asyncify, not with -scheduler=%s", config.Scheduler())
+	}
+	return seed, nil
+}
+
+// coverProfileLock serializes writes to the coverage profile, as tests of
+// multiple packages run in parallel.
+var coverProfileLock sync.Mutex
+
+// createCoverProfile creates (or truncates) the coverage profile that the
+// profiles of all tested packages are appended to.
+func createCoverProfile(path, mode string) error {
+	return os.WriteFile(path, []byte("mode: "+mode+"\n"), 0666)
+}
+
+// appendCoverProfile appends the blocks in the coverage profile written by a
+// test binary to the combined coverage profile. Nothing is appended if the
+// test binary didn't write a profile (for example, because it crashed).
+func appendCoverProfile(path, packageProfile string) error {
+	data, err := os.ReadFile(packageProfile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Strip the "mode: set" line, the combined profile has it already.
+	if i := bytes.IndexByte(data, '\n'); i >= 0 && bytes.HasPrefix(data, []byte("mode: ")) {
+		data = data[i+1:]
+	}
+
+	coverProfileLock.Lock()
+	defer coverProfileLock.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// minimizeFuzzCrasher restarts the test binary for as long as the fuzzing
+// engine left a crashing input in the work directory. A crash aborts the test
+// process, so every smaller input that crashes too needs a new process to
+// continue minimizing. Only the output of the last run, which reports the
+// failure, is shown. It returns the error of the last run.
+func minimizeFuzzCrasher(cmd *exec.Cmd, err error, workDir, minimizeTime string, stdout io.Writer) error {
+	crasher := filepath.Join(workDir, "crasher")
+	input, readErr := os.ReadFile(crasher)
+	if readErr != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "fuzz: minimizing crashing input")
+
+	// The time limit (if it is a duration and not a count) applies to all
+	// runs together.
+	isCount := strings.HasSuffix(minimizeTime, "x")
+	limit := 60 * time.Second
+	if minimizeTime != "" && !isCount {
+		limit, _ = time.ParseDuration(minimizeTime)
+	}
+	start := time.Now()
+	for {
+		args := cmd.Args[1:len(cmd.Args):len(cmd.Args)]
+		if !isCount {
+			remaining := limit - time.Since(start)
+			if remaining < 0 {
+				remaining = 0
+			}
+			args = append(args, "-test.fuzzminimizetime="+remaining.String())
+		}
+		var output bytes.Buffer
+		rerun := exec.Command(cmd.Path, args...)
+		rerun.Dir = cmd.Dir
+		rerun.Env = cmd.Env
+		rerun.Stdout = &output
+		rerun.Stderr = &output
+		err = rerun.Run()
+
+		// Continue while a smaller input crashed the test binary.
+		newInput, readErr := os.ReadFile(crasher)
+		if readErr != nil || bytes.Equal(newInput, input) {
+			stdout.Write(output.Bytes())
+			return err
+		}
+		input = newInput
+	}
+}
+
 func dirsToModuleRoot(maindir, modroot string) []string {
 	var dirs = []string{"."}
 	last := ".."
@@ -764,7 +1038,24 @@ func buildAndRun(pkgName string, config *compileopts.Config, stdout io.Writer, c
 		// Pass environment variables and command line parameters as usual.
 		// This also works on qemu-aarch64 etc.
 		args = cmdArgs
-		env = environmentVars
+		if len(environmentVars) != 0 {
+			// Add to the environment of the host, which the program would
+			// normally inherit. Variables that are set explicitly replace
+			// those of the host.
+			for _, v := range os.Environ() {
+				name, _, _ := strings.Cut(v, "=")
+				overridden := false
+				for _, e := range environmentVars {
+					if strings.HasPrefix(e, name+"=") {
+						overridden = true
+					}
+				}
+				if !overridden {
+					env = append(env, v)
+				}
+			}
+			env = append(env, environmentVars...)
+		}
 	}
 
 	format, fileExt := config.EmulatorFormat()
@@ -1125,6 +1416,7 @@ func usage(command string) {
 		fmt.Fprintln(os.Stderr, "  clean:   empty cache directory ("+goenv.Get("GOCACHE")+")")
 		fmt.Fprintln(os.Stderr, "  targets: list targets")
 		fmt.Fprintln(os.Stderr, "  info:    show info for specified target")
+		fmt.Fprintln(os.Stderr, "  size-diff: compare code and data size of two builds")
 		fmt.Fprintln(os.Stderr, "  version: show version")
 		fmt.Fprintln(os.Stderr, "  help:    print this help text")
 
@@ -1306,7 +1598,7 @@ func main() {
 	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z")
 	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative)")
 	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
-	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify)")
+	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify, threads)")
 	serial := flag.String("serial", "", "which serial output to use (none, uart, usb)")
 	work := flag.Bool("work", false, "print the name of the temporary build directory and do not delete this directory on exit")
 	interpTimeout := flag.Duration("interp-timeout", 180*time.Second, "interp optimization pass timeout")
@@ -1322,8 +1614,10 @@ func main() {
 		stackSize = uint64(size)
 		return err
 	})
-	printSize := flag.String("size", "", "print sizes (none, short, full)")
+	printSize := flag.String("size", "", "print sizes (none, short, full, json)")
 	printStacks := flag.Bool("print-stacks", false, "print stack sizes of goroutines")
+	why := flag.String("why", "", "print why the given symbol or package is linked into the binary")
+	printInterp := flag.Bool("print-interp", false, "print package initializers that could not be run at compile time")
 	printAllocsString := flag.String("print-allocs", "", "regular expression of functions for which heap allocations should be printed")
 	printCommands := flag.Bool("x", false, "Print commands")
 	parallelism := flag.Int("p", runtime.GOMAXPROCS(0), "the number of build jobs that can run in parallel")
@@ -1338,11 +1632,19 @@ func main() {
 	cpuprofile := flag.String("cpuprofile", "", "cpuprofile output")
 	monitor := flag.Bool("monitor", false, "enable serial monitor")
 	baudrate := flag.Int("baudrate", 115200, "baudrate of serial monitor")
+	usbVID := flag.String("usb-vid", "", "USB vendor ID, overriding the target spec")
+	usbPID := flag.String("usb-pid", "", "USB product ID, overriding the target spec")
+	usbManufacturer := flag.String("usb-manufacturer", "", "USB manufacturer string, overriding the target spec")
+	usbProduct := flag.String("usb-product", "", "USB product string, overriding the target spec")
+	usbSerial := flag.String("usb-serial", "", "USB serial number (default: unique ID of the chip, when available)")
 
 	var flagJSON, flagDeps, flagTest bool
 	if command == "help" || command == "list" || command == "info" || command == "build" {
 		flag.BoolVar(&flagJSON, "json", false, "print data in JSON format")
 	}
+	if command == "test" {
+		flag.BoolVar(&flagJSON, "json", false, "convert test output to JSON events")
+	}
 	if command == "help" || command == "list" {
 		flag.BoolVar(&flagDeps, "deps", false, "supply -deps flag to go list")
 		flag.BoolVar(&flagTest, "test", false, "supply -test flag to go list")
@@ -1351,19 +1653,29 @@ func main() {
 	if command == "help" || command == "build" || command == "build-library" || command == "test" {
 		flag.StringVar(&outpath, "o", "", "output filename")
 	}
-	var testCompileOnlyFlag, testVerboseFlag, testShortFlag *bool
-	var testBenchRegexp *string
-	var testBenchTime *string
-	var testRunRegexp *string
-	var testBenchMem *bool
+	var testConfig compileopts.TestConfig
+	var testCover bool
 	if command == "help" || command == "test" {
-		testCompileOnlyFlag = flag.Bool("c", false, "compile the test binary but do not run it")
-		testVerboseFlag = flag.Bool("v", false, "verbose: print additional output")
-		testShortFlag = flag.Bool("short", false, "short: run smaller test suite to save time")
-		testRunRegexp = flag.String("run", "", "run: regexp of tests to run")
-		testBenchRegexp = flag.String("bench", "", "run: regexp of benchmarks to run")
-		testBenchTime = flag.String("benchtime", "", "run each benchmark for duration `d`")
-		testBenchMem = flag.Bool("benchmem", false, "show memory stats for benchmarks")
+		flag.BoolVar(&testConfig.CompileOnly, "c", false, "compile the test binary but do not run it")
+		flag.BoolVar(&testConfig.Verbose, "v", false, "verbose: print additional output")
+		flag.BoolVar(&testConfig.Short, "short", false, "short: run smaller test suite to save time")
+		flag.StringVar(&testConfig.RunRegexp, "run", "", "run: regexp of tests to run")
+		flag.StringVar(&testConfig.BenchRegexp, "bench", "", "run: regexp of benchmarks to run")
+		flag.StringVar(&testConfig.BenchTime, "benchtime", "", "run each benchmark for duration `d`")
+		flag.BoolVar(&testConfig.BenchMem, "benchmem", false, "show memory stats for benchmarks")
+		flag.IntVar(&testConfig.Count, "count", 1, "run tests and benchmarks `n` times")
+		flag.DurationVar(&testConfig.Timeout, "timeout", 0, "panic the test binary after duration `d` (default 0, timeout disabled)")
+		flag.BoolVar(&testConfig.FailFast, "failfast", false, "do not start new tests after the first test failure")
+		flag.StringVar(&testConfig.Shuffle, "shuffle", "off", "randomize the execution order of tests and benchmarks: off, on, or a seed")
+		flag.StringVar(&testConfig.ListRegexp, "list", "", "list tests and benchmarks matching `regexp` instead of running them")
+		flag.StringVar(&testConfig.SkipRegexp, "skip", "", "do not list or run tests matching `regexp`")
+		flag.IntVar(&testConfig.Parallel, "parallel", 0, "run at most `n` tests in parallel (default GOMAXPROCS of the test binary)")
+		flag.StringVar(&testConfig.Fuzz, "fuzz", "", "run the fuzz test matching `regexp` with coverage-guided fuzzing (Linux only)")
+		flag.StringVar(&testConfig.FuzzTime, "fuzztime", "", "time to spend fuzzing, or a number of executions like 1000x (default unlimited)")
+		flag.StringVar(&testConfig.FuzzMinimizeTime, "fuzzminimizetime", "", "time to spend minimizing a failing input, or a number of executions (default 60s)")
+		flag.BoolVar(&testCover, "cover", false, "enable coverage analysis")
+		flag.StringVar(&testConfig.CoverMode, "covermode", "", "coverage mode: set, count")
+		flag.StringVar(&testConfig.CoverProfile, "coverprofile", "", "write a coverage profile to `file` (implies -cover)")
 	}
 
 	// Early command processing, before commands are interpreted by the Go flag
@@ -1419,6 +1731,8 @@ func main() {
 		Debug:           !*nodebug,
 		PrintSizes:      *printSize,
 		PrintStacks:     *printStacks,
+		PrintInterp:     *printInterp,
+		Why:             *why,
 		PrintAllocs:     printAllocs,
 		Tags:            []string(tags),
 		GlobalValues:    globalVarValues,
@@ -1429,10 +1743,21 @@ func main() {
 		PrintJSON:       flagJSON,
 		Monitor:         *monitor,
 		BaudRate:        *baudrate,
+		USBVendorID:     *usbVID,
+		USBProductID:    *usbPID,
+		USBManufacturer: *usbManufacturer,
+		USBProduct:      *usbProduct,
+		USBSerialNumber: *usbSerial,
 	}
 	if *printCommands {
 		options.PrintCommands = printCommand
 	}
+	if command == "test" {
+		if (testCover || testConfig.CoverProfile != "") && testConfig.CoverMode == "" {
+			testConfig.CoverMode = "set"
+		}
+		options.TestConfig = testConfig
+	}
 
 	err = options.Verify()
 	if err != nil {
@@ -1553,11 +1878,24 @@ func main() {
 			os.Exit(1)
 		}
 
+		if options.TestConfig.CoverProfile != "" && !options.TestConfig.CompileOnly {
+			err := createCoverProfile(options.TestConfig.CoverProfile, options.TestConfig.CoverMode)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
 		if outpath != "" && len(explicitPkgNames) > 1 {
 			fmt.Println("cannot use -o flag with multiple packages")
 			os.Exit(1)
 		}
 
+		if options.TestConfig.Fuzz != "" && len(explicitPkgNames) > 1 {
+			fmt.Println("cannot use -fuzz flag with multiple packages")
+			os.Exit(1)
+		}
+
 		fail := make(chan struct{}, 1)
 		var wg sync.WaitGroup
 		bufs := make([]testOutputBuf, len(explicitPkgNames))
@@ -1597,7 +1935,7 @@ func main() {
 				defer close(buf.done)
 				stdout := (*testStdout)(buf)
 				stderr := (*testStderr)(buf)
-				passed, err := Test(pkgName, stdout, stderr, options, *testCompileOnlyFlag, *testVerboseFlag, *testShortFlag, *testRunRegexp, *testBenchRegexp, *testBenchTime, *testBenchMem, outpath)
+				passed, err := Test(pkgName, stdout, stderr, options, outpath)
 				if err != nil {
 					printCompilerError(func(args ...interface{}) {
 						fmt.Fprintln(stderr, args...)
@@ -1616,7 +1954,9 @@ func main() {
 		wg.Wait()
 		close(fail)
 		if _, fail := <-fail; fail {
-			fmt.Println("FAIL")
+			if !flagJSON {
+				fmt.Println("FAIL")
+			}
 			os.Exit(1)
 		}
 	case "monitor":
@@ -1737,6 +2077,16 @@ func main() {
 			fmt.Fprintln(os.Stderr, "failed to run `go list`:", err)
 			os.Exit(1)
 		}
+	case "size-diff":
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "usage: tinygo size-diff old.elf new.elf")
+			os.Exit(1)
+		}
+		err := builder.SizeDiff(os.Stdout, flag.Arg(0), flag.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	case "clean":
 		// remove cache directory
 		err := os.RemoveAll(goenv.Get("GOCACHE"))
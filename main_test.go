@@ -12,6 +12,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
@@ -115,6 +116,22 @@ func TestBuild(t *testing.T) {
 			}
 			runTestWithConfig("ldflags.go", t, opts, nil, nil)
 		})
+
+		// Test the threads scheduler, which is only supported on Linux.
+		if goenv.Get("GOOS") == "linux" {
+			t.Run("scheduler=threads", func(t *testing.T) {
+				t.Parallel()
+				opts := optionsFromTarget("", sema)
+				opts.Scheduler = "threads"
+				for _, name := range []string{"atomic.go", "channel.go", "gc.go", "goroutines.go", "threads.go", "timers.go"} {
+					name := name // redefine to avoid race condition
+					t.Run(name, func(t *testing.T) {
+						t.Parallel()
+						runTestWithConfig(name, t, opts, nil, nil)
+					})
+				}
+			})
+		}
 	})
 
 	if testing.Short() {
@@ -141,6 +158,15 @@ func TestBuild(t *testing.T) {
 		runPlatTests(optionsFromTarget("simavr", sema), tests, t)
 	})
 
+	t.Run("AVRSleep", func(t *testing.T) {
+		// The scheduler puts the CPU in idle mode while sleeping. Check that
+		// the timer interrupt wakes it up again.
+		t.Parallel()
+		options := optionsFromTarget("simavr", sema)
+		emuCheck(t, options)
+		runTest("sleep.go", options, t, nil, nil)
+	})
+
 	if runtime.GOOS == "linux" {
 		for name, osArch := range supportedLinuxArches {
 			options := optionsFromOSARCH(osArch, sema)
@@ -161,6 +187,32 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+// Test that boards with USB support build. The USB stack runs in an interrupt
+// and is compiled with the check for allocations in //go:noalloc functions, so
+// this catches it if that check rejects the USB code.
+func TestBuildUSB(t *testing.T) {
+	t.Parallel()
+
+	for _, target := range []string{"pico", "feather-m0"} {
+		target := target
+		t.Run(target, func(t *testing.T) {
+			t.Parallel()
+			options := optionsFromTarget(target, sema)
+			config, err := builder.NewConfig(&options)
+			if err != nil {
+				t.Fatal("failed to create config:", err)
+			}
+			// This example uses both USB CDC and USB mass storage.
+			err = builder.Build("examples/usb-storage", "", config, func(result builder.BuildResult) error {
+				return nil
+			})
+			if err != nil {
+				t.Error("failed to build:", err)
+			}
+		})
+	}
+}
+
 func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
 	emuCheck(t, options)
 
@@ -240,6 +292,12 @@ func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
 			runTest("filesystem.go", options, t, nil, nil)
 		})
 	}
+	if options.Target == "" && options.GOOS == "linux" {
+		t.Run("signal.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("signal.go", options, t, nil, nil)
+		})
+	}
 	if options.Target == "" || options.Target == "wasi" || options.Target == "wasm" {
 		t.Run("rand.go", func(t *testing.T) {
 			t.Parallel()
@@ -435,7 +493,7 @@ func TestTest(t *testing.T) {
 				defer out.Close()
 
 				opts := targ.opts
-				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/pass", out, out, &opts, false, false, false, "", "", "", false, "")
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/pass", out, out, &opts, "")
 				if err != nil {
 					t.Errorf("test error: %v", err)
 				}
@@ -456,7 +514,7 @@ func TestTest(t *testing.T) {
 				defer out.Close()
 
 				opts := targ.opts
-				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/fail", out, out, &opts, false, false, false, "", "", "", false, "")
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/fail", out, out, &opts, "")
 				if err != nil {
 					t.Errorf("test error: %v", err)
 				}
@@ -483,7 +541,7 @@ func TestTest(t *testing.T) {
 
 				var output bytes.Buffer
 				opts := targ.opts
-				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/nothing", io.MultiWriter(&output, out), out, &opts, false, false, false, "", "", "", false, "")
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/nothing", io.MultiWriter(&output, out), out, &opts, "")
 				if err != nil {
 					t.Errorf("test error: %v", err)
 				}
@@ -507,7 +565,7 @@ func TestTest(t *testing.T) {
 				defer out.Close()
 
 				opts := targ.opts
-				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/builderr", out, out, &opts, false, false, false, "", "", "", false, "")
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/builderr", out, out, &opts, "")
 				if err == nil {
 					t.Error("test did not error")
 				}
@@ -515,6 +573,56 @@ func TestTest(t *testing.T) {
 					t.Error("test passed")
 				}
 			})
+
+			t.Run("SchedSeed", func(t *testing.T) {
+				t.Parallel()
+
+				// Test that the randomized scheduler replays the same
+				// interleaving of goroutines with the same seed, and that
+				// different seeds lead to different interleavings.
+
+				var wg sync.WaitGroup
+				defer wg.Wait()
+
+				out := ioLogger(t, &wg)
+				defer out.Close()
+
+				opts := targ.opts
+				opts.Scheduler = "tasks"
+				opts.TestConfig.CompileOnly = true
+				binary := filepath.Join(t.TempDir(), "schedseed.test")
+				if runtime.GOOS == "windows" {
+					binary += ".exe"
+				}
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/schedseed", out, out, &opts, binary)
+				if err != nil {
+					t.Fatalf("test error: %v", err)
+				}
+				if !passed {
+					t.Fatal("test failed")
+				}
+
+				run := func(seed string) string {
+					cmd := exec.Command(binary)
+					cmd.Env = append(os.Environ(), "TINYGO_SCHEDSEED="+seed)
+					output, err := cmd.CombinedOutput()
+					if err != nil {
+						t.Fatalf("failed to run test binary with seed %s: %v\n%s", seed, err, output)
+					}
+					return string(output)
+				}
+				orders := make(map[string]bool)
+				for _, seed := range []string{"1", "2", "3", "4"} {
+					output := run(seed)
+					if replay := run(seed); replay != output {
+						t.Errorf("seed %s did not replay the same interleaving:\n%s\n%s", seed, output, replay)
+					}
+					orders[output] = true
+				}
+				if len(orders) == 1 {
+					t.Error("all seeds resulted in the same interleaving")
+				}
+			})
 		})
 	}
 }
@@ -0,0 +1,30 @@
+package main
+
+// This example blinks the LED, and then sleeps for ten seconds or until the
+// button is pressed.
+
+import (
+	"machine"
+	"time"
+)
+
+const (
+	led    = machine.LED
+	button = machine.BUTTON
+)
+
+func main() {
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+
+	for {
+		led.High()
+		time.Sleep(time.Millisecond * 100)
+		led.Low()
+
+		err := machine.DeepSleep(int64(10*time.Second), machine.WakeOnPin(button, false))
+		if err != nil {
+			println("could not sleep:", err.Error())
+			time.Sleep(time.Second)
+		}
+	}
+}
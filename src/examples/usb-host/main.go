@@ -0,0 +1,53 @@
+package main
+
+// This example uses the USB port in host mode: connect a USB keyboard (with
+// an adapter) and the characters that are typed are printed on the serial
+// port, or connect another board with a USB serial port to print what it
+// sends.
+
+import (
+	"machine"
+	"machine/usb/host"
+	"time"
+)
+
+func main() {
+	machine.USBHost.Configure()
+	h := host.New(machine.USBHost)
+	for {
+		dev, err := h.Enumerate()
+		if err != nil {
+			if err != host.ErrNoDevice {
+				println("enumeration failed:", err.Error())
+			}
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		product, _ := h.String(dev.Descriptor.Product)
+		println("connected:", product)
+
+		if kb, err := host.NewKeyboard(h); err == nil {
+			for h.Poll() == host.StateConfigured {
+				kb.Poll(func(e host.KeyEvent) {
+					if r := e.Rune(); e.Pressed && r != 0 {
+						print(string(r))
+					}
+				})
+				time.Sleep(5 * time.Millisecond)
+			}
+		} else if s, err := host.NewSerial(h); err == nil {
+			var buf [64]byte
+			for h.Poll() == host.StateConfigured {
+				n, _ := s.Read(buf[:])
+				print(string(buf[:n]))
+				time.Sleep(5 * time.Millisecond)
+			}
+		} else {
+			println("unsupported device")
+			for h.Poll() == host.StateConfigured {
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+		println("disconnected")
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+// This example exposes the unused part of the flash memory as a USB drive,
+// next to the USB serial port. The host will offer to format the drive the
+// first time it is connected.
+
+import (
+	"machine"
+	"machine/usb/msc"
+	"time"
+)
+
+func init() {
+	msc.New(machine.Flash)
+}
+
+func main() {
+	for {
+		println("flash size:", machine.Flash.Size())
+		time.Sleep(time.Second)
+	}
+}
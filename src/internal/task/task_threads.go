@@ -0,0 +1,351 @@
+//go:build scheduler.threads
+// +build scheduler.threads
+
+package task
+
+// This file implements goroutines as OS threads: every goroutine runs on its
+// own thread, created with pthread_create. This makes it possible to run
+// goroutines in parallel on multiple cores.
+// A goroutine must hold one of the processors to run Go code, and gives it up
+// while it is blocked (in Pause, sleeping or in a system call). The number of
+// processors is set by GOMAXPROCS, so at most that many goroutines run at the
+// same time. Goroutines are not preempted: a goroutine that never blocks keeps
+// its processor.
+// Most of the low-level work is done in lib/task-threads.c.
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+//go:linkname runtimePanic runtime.runtimePanic
+func runtimePanic(str string)
+
+// state is the thread state of a goroutine.
+type state struct {
+	// thread is the pthread_t of the thread running this goroutine.
+	thread uintptr
+
+	// stackTop is the highest address of the stack of this goroutine. It is
+	// set by the new thread before the goroutine starts running.
+	stackTop uintptr
+
+	// sp is the stack pointer of this goroutine while it is stopped for the
+	// GC.
+	sp uintptr
+
+	// args is the argument bundle of the goroutine, kept here until the thread
+	// has started so that the GC can find it.
+	args unsafe.Pointer
+
+	// wakeups is the number of times Resume was called without a matching
+	// Pause. It is used as a futex.
+	wakeups uint32
+
+	// running is true once the thread has started and its stack can be
+	// scanned by the GC.
+	running bool
+
+	// nextActive links all goroutines that have not yet exited.
+	nextActive *Task
+}
+
+// The goroutine running on the main thread.
+var mainTask Task
+
+// Processors that goroutines need to hold while running. procsIdle is the
+// number of processors that are not held by a goroutine and is used as a
+// futex. It is an int32 stored in a uint32, as it is negative after
+// GOMAXPROCS was lowered while all processors were in use.
+var (
+	procsIdle uint32
+	procsMax  int32
+	procsLock Mutex
+)
+
+// List of all goroutines that have not exited, protected by activeLock.
+var (
+	activeTasks *Task
+	activeCount uint32
+	activeLock  Mutex
+)
+
+//export tinygo_task_current
+func tinygo_task_current() unsafe.Pointer
+
+//export tinygo_task_set_current
+func tinygo_task_set_current(t unsafe.Pointer, sp *uintptr)
+
+//export tinygo_task_start
+func tinygo_task_start(fn uintptr, args unsafe.Pointer, stackSize uintptr, t unsafe.Pointer, sp *uintptr, stackTop *uintptr, thread *uintptr) int32
+
+//export tinygo_task_init
+func tinygo_task_init()
+
+//export tinygo_task_gc_stop
+func tinygo_task_gc_stop(threads *uintptr, n uint32)
+
+//export tinygo_task_gc_resume
+func tinygo_task_gc_resume()
+
+//export tinygo_task_num_cpu
+func tinygo_task_num_cpu() int
+
+//export tinygo_futex_wait
+func futexWait(addr *uint32, cmp uint32)
+
+//export tinygo_futex_wait_timeout
+func futexWaitTimeout(addr *uint32, cmp uint32, timeout int64)
+
+//export tinygo_futex_wake
+func futexWake(addr *uint32, n uint32)
+
+//export tinygo_mutex_lock
+func mutexLock(state *uint32)
+
+//export tinygo_mutex_unlock
+func mutexUnlock(state *uint32)
+
+// Current returns the current active task.
+func Current() *Task {
+	return (*Task)(tinygo_task_current())
+}
+
+// Pause suspends the current goroutine until Resume is called on it. If
+// Resume was already called, it returns immediately.
+func Pause() {
+	s := &Current().state
+	released := false
+	for {
+		n := atomic.LoadUint32(&s.wakeups)
+		if n != 0 {
+			if atomic.CompareAndSwapUint32(&s.wakeups, n, n-1) {
+				if released {
+					AcquireProc()
+				}
+				return
+			}
+			continue
+		}
+		if !released {
+			// Let another goroutine run while this one is blocked.
+			ReleaseProc()
+			released = true
+		}
+		futexWait(&s.wakeups, 0)
+	}
+}
+
+// Resume wakes up the given goroutine, which is (or will soon be) waiting in
+// Pause. It may be called from any thread.
+func (t *Task) Resume() {
+	atomic.AddUint32(&t.state.wakeups, 1)
+	futexWake(&t.state.wakeups, 1)
+}
+
+// OnSystemStack returns whether the caller is running on the system stack.
+func OnSystemStack() bool {
+	// Every goroutine has its own thread stack.
+	return false
+}
+
+// start creates and starts a new goroutine with the given function and arguments.
+// The new goroutine runs in a new thread.
+func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
+	t := &Task{}
+	t.state.args = args
+	activeLock.Lock()
+	t.state.nextActive = activeTasks
+	activeTasks = t
+	activeCount++
+	err := tinygo_task_start(fn, args, stackSize, unsafe.Pointer(t), &t.state.sp, &t.state.stackTop, &t.state.thread)
+	activeLock.Unlock()
+	if err != 0 {
+		runtimePanic("could not start thread")
+	}
+}
+
+// Called by the new thread, right before the goroutine starts running.
+//
+//export tinygo_task_started
+func taskStarted(t *Task) {
+	activeLock.Lock()
+	t.state.running = true
+	t.state.args = nil
+	activeLock.Unlock()
+	AcquireProc()
+}
+
+// Called by the thread of a goroutine after it returned.
+//
+//export tinygo_task_exited
+func taskExited(t *Task) {
+	ReleaseProc()
+	activeLock.Lock()
+	for p := &activeTasks; *p != nil; p = &(*p).state.nextActive {
+		if *p == t {
+			*p = t.state.nextActive
+			break
+		}
+	}
+	activeCount--
+	activeLock.Unlock()
+}
+
+// Init registers the main goroutine, which runs on the thread that started
+// the program. It must be called once, before any other goroutine is started.
+func Init(stackTop uintptr) {
+	tinygo_task_init()
+	t := &mainTask
+	t.state.stackTop = stackTop
+	t.state.running = true
+	tinygo_task_set_current(unsafe.Pointer(t), &t.state.sp)
+	activeTasks = t
+	activeCount = 1
+
+	// The main goroutine holds a processor.
+	procsMax = int32(NumCPU())
+	procsIdle = uint32(procsMax - 1)
+}
+
+// AcquireProc blocks until a processor is available and takes it. It must be
+// called before running Go code after a call to ReleaseProc.
+func AcquireProc() {
+	for {
+		n := atomic.LoadUint32(&procsIdle)
+		if int32(n) > 0 {
+			if atomic.CompareAndSwapUint32(&procsIdle, n, n-1) {
+				return
+			}
+			continue
+		}
+		futexWait(&procsIdle, n)
+	}
+}
+
+// ReleaseProc gives up the processor of the current goroutine, so that another
+// goroutine can run. It must be called before blocking for a long time, while
+// not holding any runtime lock.
+func ReleaseProc() {
+	if int32(atomic.AddUint32(&procsIdle, 1)) > 0 {
+		futexWake(&procsIdle, 1)
+	}
+}
+
+// SetMaxProcs changes the number of processors and returns the previous
+// number. Goroutines that are running keep their processor: the new limit
+// applies once they block.
+func SetMaxProcs(n int) int {
+	procsLock.Lock()
+	old := procsMax
+	procsMax = int32(n)
+	if delta := int32(n) - old; delta != 0 {
+		if int32(atomic.AddUint32(&procsIdle, uint32(delta))) > 0 && delta > 0 {
+			futexWake(&procsIdle, uint32(delta))
+		}
+	}
+	procsLock.Unlock()
+	return int(old)
+}
+
+// MaxProcs returns the number of processors.
+func MaxProcs() int {
+	procsLock.Lock()
+	n := procsMax
+	procsLock.Unlock()
+	return int(n)
+}
+
+// NumCPU returns the number of CPUs that are currently online.
+func NumCPU() int {
+	return tinygo_task_num_cpu()
+}
+
+// NumGoroutine returns the number of goroutines that have not exited.
+func NumGoroutine() int {
+	activeLock.Lock()
+	n := activeCount
+	activeLock.Unlock()
+	return int(n)
+}
+
+// StackTop returns the top of the stack of the current goroutine.
+func StackTop() uintptr {
+	return Current().state.stackTop
+}
+
+// GCStopWorld stops all goroutines except the current one. It returns once
+// they are all stopped. The world must be started again with GCResumeWorld.
+func GCStopWorld() {
+	activeLock.Lock()
+	current := Current()
+	// Collect the threads in a stack allocated buffer (the heap cannot be
+	// used while the GC is running), in batches if needed.
+	var threads [64]uintptr
+	i := uint32(0)
+	for t := activeTasks; t != nil; t = t.state.nextActive {
+		if t == current || !t.state.running {
+			continue
+		}
+		threads[i] = t.state.thread
+		i++
+		if i == uint32(len(threads)) {
+			tinygo_task_gc_stop(&threads[0], i)
+			i = 0
+		}
+	}
+	if i != 0 {
+		tinygo_task_gc_stop(&threads[0], i)
+	}
+}
+
+// GCScan calls markRoots for the stacks of all goroutines that were stopped
+// by GCStopWorld.
+func GCScan(markRoots func(start, end uintptr)) {
+	current := Current()
+	for t := activeTasks; t != nil; t = t.state.nextActive {
+		if t != current && t.state.running {
+			markRoots(t.state.sp, t.state.stackTop)
+		}
+	}
+}
+
+// GCResumeWorld resumes all goroutines stopped by GCStopWorld.
+func GCResumeWorld() {
+	tinygo_task_gc_resume()
+	activeLock.Unlock()
+}
+
+// Mutex is a simple mutex based on a futex, for use inside the runtime. It is
+// not reentrant and it does not interact with the scheduler: a goroutine
+// waiting on this mutex blocks its thread.
+type Mutex struct {
+	state uint32
+}
+
+func (m *Mutex) Lock() {
+	mutexLock(&m.state)
+}
+
+func (m *Mutex) Unlock() {
+	mutexUnlock(&m.state)
+}
+
+// Futex operations, for use by the runtime.
+
+// FutexWait blocks while *addr equals cmp, or until woken up by FutexWake.
+// It may return spuriously.
+func FutexWait(addr *uint32, cmp uint32) {
+	futexWait(addr, cmp)
+}
+
+// FutexWaitTimeout is like FutexWait, but returns after at most timeout
+// nanoseconds.
+func FutexWaitTimeout(addr *uint32, cmp uint32, timeout int64) {
+	futexWaitTimeout(addr, cmp, timeout)
+}
+
+// FutexWake wakes up at most n threads waiting in FutexWait on addr.
+func FutexWake(addr *uint32, n uint32) {
+	futexWake(addr, n)
+}
@@ -0,0 +1,95 @@
+//go:build !baremetal || rp2040 || (sam && atsamd51) || (sam && atsame5x)
+// +build !baremetal rp2040 sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"errors"
+	"internal/task"
+	"runtime/interrupt"
+)
+
+// Asynchronous transfers.
+//
+// Buses that support it have TxAsync and Wait methods next to the usual
+// blocking methods. TxAsync starts a transfer that is done in the background
+// (usually by DMA) and returns immediately, so that other goroutines can run
+// while the data is moved. Wait parks the calling goroutine until the transfer
+// has finished and returns its result:
+//
+//	spi.TxAsync(framebuffer, nil)
+//	prepareNextFrame()
+//	err := spi.Wait()
+//
+// The buffers passed to TxAsync must stay untouched until Wait returns. Only
+// one transfer can be in progress on a bus at a time.
+
+var errTxInProgress = errors.New("transfer already in progress")
+
+// asyncTransfer keeps track of the asynchronous transfer on a single bus.
+type asyncTransfer struct {
+	busy   bool
+	err    error
+	waiter *task.Task
+	bufs   [2][]byte // keep the buffers alive while the hardware uses them
+}
+
+// begin marks a new transfer using the given buffers as started. It fails when
+// the previous transfer has not finished yet.
+func (t *asyncTransfer) begin(w, r []byte) error {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	if t.busy {
+		return errTxInProgress
+	}
+	t.busy = true
+	t.err = nil
+	t.bufs = [2][]byte{w, r}
+	return nil
+}
+
+// complete marks the transfer as finished with the given result and wakes up
+// the goroutine waiting for it. It is normally called from an interrupt.
+func (t *asyncTransfer) complete(err error) {
+	mask := interrupt.Disable()
+	if t.busy {
+		t.busy = false
+		t.err = err
+		t.bufs = [2][]byte{}
+		if w := t.waiter; w != nil {
+			t.waiter = nil
+			resumeTask(w)
+		}
+	}
+	interrupt.Restore(mask)
+}
+
+// inProgress returns whether the transfer has started but not yet finished.
+func (t *asyncTransfer) inProgress() bool {
+	mask := interrupt.Disable()
+	busy := t.busy
+	interrupt.Restore(mask)
+	return busy
+}
+
+// wait blocks until the transfer has finished and returns its result. The
+// calling goroutine is paused if the scheduler allows it, otherwise this is a
+// busy loop.
+func (t *asyncTransfer) wait() error {
+	for {
+		mask := interrupt.Disable()
+		if !t.busy {
+			err := t.err
+			t.err = nil
+			interrupt.Restore(mask)
+			return err
+		}
+		if asyncCanPause {
+			t.waiter = task.Current()
+			interrupt.Restore(mask)
+			task.Pause()
+		} else {
+			interrupt.Restore(mask)
+		}
+	}
+}
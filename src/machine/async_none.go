@@ -0,0 +1,14 @@
+//go:build (!baremetal || rp2040 || (sam && atsamd51) || (sam && atsame5x)) && scheduler.none
+// +build !baremetal rp2040 sam,atsamd51 sam,atsame5x
+// +build scheduler.none
+
+package machine
+
+// Without a scheduler, waiting for a transfer is a busy loop.
+const asyncCanPause = false
+
+// completeLater completes the transfer immediately, as there are no other
+// goroutines to do it.
+func (t *asyncTransfer) completeLater(err error) {
+	t.complete(err)
+}
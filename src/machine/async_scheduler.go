@@ -0,0 +1,14 @@
+//go:build (!baremetal || rp2040 || (sam && atsamd51) || (sam && atsame5x)) && !scheduler.none
+// +build !baremetal rp2040 sam,atsamd51 sam,atsame5x
+// +build !scheduler.none
+
+package machine
+
+// Goroutines waiting for a transfer can be paused.
+const asyncCanPause = true
+
+// completeLater completes the transfer from a new goroutine, like an interrupt
+// would on real hardware.
+func (t *asyncTransfer) completeLater(err error) {
+	go t.complete(err)
+}
@@ -0,0 +1,190 @@
+//go:build (sam && atsame51) || (sam && atsame54) || stm32f4 || (linux && !baremetal)
+// +build sam,atsame51 sam,atsame54 stm32f4 linux,!baremetal
+
+package machine
+
+import "errors"
+
+// CAN bus.
+//
+// The CAN type is implemented by the CAN FD controller of the SAM E5x, the
+// bxCAN controller of the STM32F4 and on Linux by SocketCAN, which also works
+// with a virtual CAN interface for testing:
+//
+//	ip link add dev vcan0 type vcan
+//	ip link set up vcan0
+//
+// Besides the chip specific configuration in CANConfig, these all support the
+// methods of the CANBus interface:
+//
+//	can := &machine.CAN1
+//	can.Configure(machine.CANConfig{TransferRate: machine.CANTransferRate500kbps})
+//	can.SetFilters([]machine.CANFilter{{ID: 0x100, Mask: 0x700}})
+//	can.SetReceiveHandler(func(f *machine.CANFrame) {
+//		println("received", f.ID, len(f.Payload()))
+//	})
+//	can.Send(&machine.CANFrame{ID: 0x123, Length: 2, Data: [64]byte{1, 2}})
+
+var (
+	ErrCANTxFull              = errors.New("CAN: transmit queue full")
+	errCANInvalidFrame        = errors.New("CAN: invalid frame")
+	errCANFDNotSupported      = errors.New("CAN: CAN FD not supported")
+	errCANTooManyFilters      = errors.New("CAN: too many filters")
+	errCANInvalidTransferRate = errors.New("CAN: invalid TransferRate")
+)
+
+// CANBus is the interface of a CAN bus, implemented by *CAN.
+type CANBus interface {
+	// Send queues a frame for transmission. It doesn't wait for the frame
+	// to be sent, and returns ErrCANTxFull if there is no room for it.
+	Send(frame *CANFrame) error
+
+	// Receive copies the next received frame into frame. It returns false if
+	// no frame has been received.
+	Receive(frame *CANFrame) bool
+
+	// SetReceiveHandler sets a function that is called for each received
+	// frame, instead of queuing it for Receive. On microcontrollers it is
+	// called from an interrupt, so it must not block or allocate memory. The
+	// frame is only valid during the call. A nil handler goes back to
+	// Receive.
+	SetReceiveHandler(handler func(frame *CANFrame))
+
+	// SetFilters sets the frames to receive: those that match any of the
+	// filters. With no filters, all frames are received.
+	SetFilters(filters []CANFilter) error
+
+	// Status returns the error state and error counters of the controller.
+	Status() CANStatus
+}
+
+// CANTransferRate is the bit rate of a CAN bus, in bits per second.
+type CANTransferRate uint32
+
+// CAN transfer rates for CANConfig
+const (
+	CANTransferRate125kbps  CANTransferRate = 125000
+	CANTransferRate250kbps  CANTransferRate = 250000
+	CANTransferRate500kbps  CANTransferRate = 500000
+	CANTransferRate1000kbps CANTransferRate = 1000000
+	CANTransferRate2000kbps CANTransferRate = 2000000
+	CANTransferRate4000kbps CANTransferRate = 4000000
+)
+
+// CANFrame is a CAN or CAN FD frame.
+type CANFrame struct {
+	ID       uint32 // 11-bit standard or 29-bit extended identifier
+	Extended bool   // the ID is an extended identifier
+	Remote   bool   // remote transmission request, without data
+	FD       bool   // CAN FD frame, with up to 64 bytes of data
+	BRS      bool   // CAN FD bit rate switch: the data is sent at TransferRateFD
+	Length   uint8  // number of bytes in Data
+	Data     [64]byte
+}
+
+// Payload returns the data of the frame.
+func (f *CANFrame) Payload() []byte {
+	return f.Data[:f.Length]
+}
+
+// valid returns whether the frame can be sent: the ID fits and the length is
+// possible for the kind of frame.
+func (f *CANFrame) valid() bool {
+	if f.Extended && f.ID > 0x1fffffff || !f.Extended && f.ID > 0x7ff {
+		return false
+	}
+	if f.FD {
+		return f.Length <= 64 && CANDlcToLength(CANLengthToDlc(f.Length, true), true) == f.Length
+	}
+	return f.Length <= 8
+}
+
+// CANFilter selects received frames by their identifier: a frame matches if
+// its ID is equal to the ID of the filter in the bits that are set in Mask,
+// and it is of the same kind (standard or extended).
+type CANFilter struct {
+	ID       uint32
+	Mask     uint32
+	Extended bool
+}
+
+// Match returns whether the frame matches the filter.
+func (filter CANFilter) Match(frame *CANFrame) bool {
+	return frame.Extended == filter.Extended && (frame.ID^filter.ID)&filter.Mask == 0
+}
+
+// CANState is the error state of a CAN controller. The controller goes from
+// error active to error passive and then to bus off as it sees more errors.
+type CANState uint8
+
+const (
+	CANErrorActive  CANState = iota // normal operation
+	CANErrorPassive                 // an error counter is above 127
+	CANBusOff                       // the transmit error counter is above 255, the controller doesn't take part in the bus
+)
+
+// CANStatus is the error state of a CAN controller, with its error counters.
+type CANStatus struct {
+	State    CANState
+	TxErrors uint8 // transmit error counter
+	RxErrors uint8 // receive error counter
+}
+
+// CANDlcToLength() converts a DLC value to its actual length.
+func CANDlcToLength(dlc byte, isFD bool) byte {
+	length := dlc
+	if dlc == 0x09 {
+		length = 12
+	} else if dlc == 0x0A {
+		length = 16
+	} else if dlc == 0x0B {
+		length = 20
+	} else if dlc == 0x0C {
+		length = 24
+	} else if dlc == 0x0D {
+		length = 32
+	} else if dlc == 0x0E {
+		length = 48
+	} else if dlc == 0x0F {
+		length = 64
+	}
+	return length
+
+}
+
+// CANLengthToDlc() converts its actual length to a DLC value.
+func CANLengthToDlc(length byte, isFD bool) byte {
+	dlc := length
+	if length <= 0x08 {
+	} else if length <= 12 {
+		dlc = 0x09
+	} else if length <= 16 {
+		dlc = 0x0A
+	} else if length <= 20 {
+		dlc = 0x0B
+	} else if length <= 24 {
+		dlc = 0x0C
+	} else if length <= 32 {
+		dlc = 0x0D
+	} else if length <= 48 {
+		dlc = 0x0E
+	} else if length <= 64 {
+		dlc = 0x0F
+	}
+	return dlc
+}
+
+// canDecodeMCANR0 decodes the first word (R0) of a receive buffer element of
+// the M_CAN controller in the SAM E5x. It is here instead of in the chip
+// specific file so that it can be tested on the host.
+func canDecodeMCANR0(r0 uint32) (id uint32, extended, remote, errorPassive bool) {
+	errorPassive = r0&(1<<31) != 0
+	extended = r0&(1<<30) != 0
+	remote = r0&(1<<29) != 0
+	id = r0 & 0x1FFFFFFF
+	if !extended {
+		// Standard IDs are stored in bits 28:18.
+		id = (id >> 18) & 0x7FF
+	}
+	return
+}
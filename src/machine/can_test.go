@@ -0,0 +1,28 @@
+//go:build linux && !baremetal
+// +build linux,!baremetal
+
+package machine
+
+import "testing"
+
+func TestCANDecodeMCANR0(t *testing.T) {
+	for _, tc := range []struct {
+		r0       uint32
+		id       uint32
+		extended bool
+		remote   bool
+	}{
+		{0x123 << 18, 0x123, false, false},
+		{0x7ff << 18, 0x7ff, false, false},
+		{1<<30 | 0x18daf110, 0x18daf110, true, false},
+		// The RTR bit must not be mistaken for the XTD bit.
+		{1<<29 | 0x123<<18, 0x123, false, true},
+		{1<<30 | 1<<29 | 0x1fffffff, 0x1fffffff, true, true},
+	} {
+		id, extended, remote, _ := canDecodeMCANR0(tc.r0)
+		if id != tc.id || extended != tc.extended || remote != tc.remote {
+			t.Errorf("R0 %#08x: expected id=%#x extended=%v remote=%v, got id=%#x extended=%v remote=%v",
+				tc.r0, tc.id, tc.extended, tc.remote, id, extended, remote)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+//go:build (nrf52 && !softdevice) || (nrf52833 && !softdevice) || (nrf52840 && !softdevice) || rp2040 || atsamd21 || atsamd51 || atsame5x || avr
+// +build nrf52,!softdevice nrf52833,!softdevice nrf52840,!softdevice rp2040 atsamd21 atsamd51 atsame5x avr
+
+package machine
+
+// Hardware abstraction layer for deep sleep.
+//
+// DeepSleep blocks the calling goroutine, and while it does the scheduler
+// puts the chip in a deeper sleep mode than usual whenever all goroutines are
+// sleeping. Peripherals that need a fast clock (UART, USB, PWM) stop working
+// in that mode. For example, to sleep for a minute or until a button is
+// pressed:
+//
+//	err := machine.DeepSleep(int64(time.Minute), machine.WakeOnPin(button, false))
+//
+// The wake sources use pin interrupts, so other goroutines keep running until
+// they sleep as well. On SAMD chips, the scheduler also uses standby outside
+// of DeepSleep when no peripheral that needs the main clock is in use.
+
+import (
+	"errors"
+	"internal/task"
+	"runtime/interrupt"
+	"unsafe"
+)
+
+var (
+	errDeepSleepNoWakeSource  = errors.New("machine: DeepSleep without duration or wake source")
+	ErrWakeSourceNotSupported = errors.New("machine: wake source not supported")
+)
+
+// State of the current DeepSleep call. The wake source interrupts set
+// deepSleepWoken and resume the goroutine in deepSleepWaiter.
+var (
+	deepSleepWoken  bool
+	deepSleepWaiter *task.Task
+)
+
+// WakeSource is a pin that wakes the chip from DeepSleep when it reads Level.
+type WakeSource struct {
+	Pin   Pin
+	Level bool
+}
+
+// WakeOnPin returns a WakeSource that wakes the chip when pin reads level.
+// The pin is configured as an input, with a pull-up or pull-down resistor
+// keeping it at the opposite level.
+func WakeOnPin(pin Pin, level bool) WakeSource {
+	return WakeSource{Pin: pin, Level: level}
+}
+
+// DeepSleep puts the chip in the lowest power mode from which it can wake up
+// after duration nanoseconds (using the real-time clock) or when one of the
+// wake sources triggers. A duration of zero sleeps until a wake source
+// triggers.
+//
+// How deep the sleep is depends on the chip:
+//   - nRF52: System ON with only the RTC running, or System OFF without a
+//     duration. The chip resets when it wakes from System OFF, so DeepSleep
+//     doesn't return and ResetReason returns ResetCauseWakeup.
+//   - RP2040: the clocks of all peripherals except the timer are gated, or
+//     the chip is dormant (with the crystal oscillator stopped) without a
+//     duration. Time doesn't advance while dormant.
+//   - SAMD21 and SAMD51: standby, with only the RTC running.
+//   - AVR: power-down, waking up with the watchdog timer. The watchdog is not
+//     very accurate, so the duration can be off by 10% or more.
+func DeepSleep(duration int64, wakeSources ...WakeSource) error {
+	if duration <= 0 && len(wakeSources) == 0 {
+		return errDeepSleepNoWakeSource
+	}
+	mask := interrupt.Disable()
+	deepSleepWoken = false
+	interrupt.Restore(mask)
+	for i, src := range wakeSources {
+		if err := src.Pin.configureWake(src.Level); err != nil {
+			disableWake(wakeSources[:i])
+			return err
+		}
+	}
+	deepSleep(duration, wakeSources)
+	disableWake(wakeSources)
+	return nil
+}
+
+// disableWake stops the wake sources from triggering after DeepSleep.
+func disableWake(wakeSources []WakeSource) {
+	for _, src := range wakeSources {
+		src.Pin.disableWake()
+	}
+}
+
+// wakeDeepSleep ends the current DeepSleep. It is called from the interrupt
+// of a wake source, or by the timer when the duration has passed.
+func wakeDeepSleep() {
+	mask := interrupt.Disable()
+	deepSleepWoken = true
+	if t := deepSleepWaiter; t != nil {
+		deepSleepWaiter = nil
+		resumeTask(t)
+	}
+	interrupt.Restore(mask)
+}
+
+// isDeepSleepWoken returns whether a wake source triggered since DeepSleep was
+// called.
+func isDeepSleepWoken() bool {
+	mask := interrupt.Disable()
+	woken := deepSleepWoken
+	interrupt.Restore(mask)
+	return woken
+}
+
+// woken returns whether a wake pin reads its level.
+func woken(wakeSources []WakeSource) bool {
+	for _, src := range wakeSources {
+		if src.Pin.Get() == src.Level {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepUntilWoken sleeps (through the scheduler) until the duration has
+// passed or a wake source triggers. A duration of zero means no timeout. The
+// wake sources must have been configured already, so that a pin that changes
+// after the check below still ends the sleep.
+func sleepUntilWoken(duration int64, wakeSources []WakeSource) {
+	if woken(wakeSources) {
+		return
+	}
+	waitForWake(duration)
+}
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+//go:linkname timeSleep time.Sleep
+func timeSleep(duration int64)
+
+// linked from runtime.startCallbackTimer (the time package can't be imported
+// here)
+func startCallbackTimer(ns int64, callback func()) unsafe.Pointer
+
+// linked from runtime.stopCallbackTimer
+func stopCallbackTimer(timer unsafe.Pointer) bool
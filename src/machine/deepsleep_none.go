@@ -0,0 +1,30 @@
+//go:build ((nrf52 && !softdevice) || (nrf52833 && !softdevice) || (nrf52840 && !softdevice) || rp2040 || atsamd21 || atsamd51 || atsame5x || avr) && scheduler.none
+// +build nrf52,!softdevice nrf52833,!softdevice nrf52840,!softdevice rp2040 atsamd21 atsamd51 atsame5x avr
+// +build scheduler.none
+
+package machine
+
+// Without a scheduler there is no goroutine to pause and no timer to end the
+// sleep, and sleeping with time.Sleep doesn't return before the time has
+// passed. So waitForWake sleeps in short steps of wakeCheckInterval
+// nanoseconds, and checks between them whether a wake source triggered.
+const wakeCheckInterval = 10e6
+
+// waitForWake sleeps until a wake source triggers or the duration has passed.
+// A duration of zero means no timeout.
+func waitForWake(duration int64) {
+	deadline := nanotime() + duration
+	for !isDeepSleepWoken() {
+		d := int64(wakeCheckInterval)
+		if duration > 0 {
+			left := deadline - nanotime()
+			if left <= 0 {
+				return
+			}
+			if left < d {
+				d = left
+			}
+		}
+		timeSleep(d)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build ((nrf52 && !softdevice) || (nrf52833 && !softdevice) || (nrf52840 && !softdevice) || rp2040 || atsamd21 || atsamd51 || atsame5x || avr) && !scheduler.none
+// +build nrf52,!softdevice nrf52833,!softdevice nrf52840,!softdevice rp2040 atsamd21 atsamd51 atsame5x avr
+// +build !scheduler.none
+
+package machine
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+)
+
+// waitForWake pauses the calling goroutine until a wake source triggers or the
+// duration has passed. A duration of zero means no timeout. Other goroutines
+// keep running in the meantime.
+func waitForWake(duration int64) {
+	if duration > 0 {
+		timer := startCallbackTimer(duration, wakeDeepSleep)
+		defer stopCallbackTimer(timer)
+	}
+	mask := interrupt.Disable()
+	if deepSleepWoken {
+		interrupt.Restore(mask)
+		return
+	}
+	deepSleepWaiter = task.Current()
+	interrupt.Restore(mask)
+	task.Pause()
+}
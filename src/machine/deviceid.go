@@ -0,0 +1,30 @@
+//go:build sam || nrf || rp2040 || stm32f4 || stm32l4 || stm32wlx
+// +build sam nrf rp2040 stm32f4 stm32l4 stm32wlx
+
+package machine
+
+var deviceIDRead bool
+
+// DeviceID returns the unique ID of the chip, which is programmed during
+// manufacturing. No two chips of the same vendor have the same ID, but chips of
+// different vendors might. The length of the ID depends on the chip: 16 bytes
+// on the SAMD21 and SAMD51, 12 bytes on the STM32 and 8 bytes on the nRF. The
+// RP2040 has no unique ID, so the 8-byte unique ID of the flash chip is used
+// instead.
+//
+// This ID is also used as the USB serial number, unless another serial number
+// is set at build time. The returned slice must not be modified.
+func DeviceID() []byte {
+	if !deviceIDRead {
+		readDeviceID()
+		deviceIDRead = true
+	}
+	return deviceID[:]
+}
+
+// putDeviceIDWord stores a 32-bit word of the unique ID in big-endian order,
+// so that the ID reads the same as in the documentation of the vendor when
+// printed in hexadecimal.
+func putDeviceIDWord(b []byte, w uint32) {
+	b[0], b[1], b[2], b[3] = byte(w>>24), byte(w>>16), byte(w>>8), byte(w)
+}
@@ -0,0 +1,81 @@
+//go:build !baremetal || nrf || sam || rp2040 || stm32f4 || stm32l4 || stm32wlx
+// +build !baremetal nrf sam rp2040 stm32f4 stm32l4 stm32wlx
+
+package machine
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	errFlashCannotReadPastEOF  = errors.New("cannot read beyond end of flash data")
+	errFlashCannotWritePastEOF = errors.New("cannot write beyond end of flash data")
+	errFlashCannotErasePastEOF = errors.New("cannot erase beyond end of flash data")
+	errFlashNegativeOffset     = errors.New("negative flash offset")
+)
+
+// BlockDevice is the raw device that is meant to store flash data, such as
+// machine.Flash.
+type BlockDevice interface {
+	// ReadAt reads the given number of bytes from the block device.
+	io.ReaderAt
+
+	// WriteAt writes the given number of bytes to the block device. The
+	// written area must have been erased before with EraseBlocks.
+	io.WriterAt
+
+	// Size returns the number of bytes in this block device.
+	Size() int64
+
+	// WriteBlockSize returns the block size in which data can be written to
+	// memory. It can be used by a client to optimize writes, non-aligned writes
+	// should always work correctly.
+	WriteBlockSize() int64
+
+	// EraseBlockSize returns the smallest erasable area on this particular chip
+	// in bytes. This is used for the block size in EraseBlocks.
+	// It must be a power of two, and may be as small as 1. A typical size is
+	// 4096.
+	EraseBlockSize() int64
+
+	// EraseBlocks erases the given number of blocks. An implementation may
+	// transparently coalesce ranges of blocks into larger bundles if the chip
+	// supports this. The start and len parameters are in block numbers, use
+	// EraseBlockSize to map addresses to blocks.
+	EraseBlocks(start, len int64) error
+}
+
+var _ BlockDevice = Flash
+
+// checkFlashRange returns an error when the range [off, off+n) does not lie
+// within a block device of the given size.
+func checkFlashRange(off, n, size int64, errPastEOF error) error {
+	if off < 0 {
+		return errFlashNegativeOffset
+	}
+	if off+n > size {
+		return errPastEOF
+	}
+	return nil
+}
+
+// flashAlign pads p with 0xff bytes at the start and end, so that the returned
+// data starts and ends at a multiple of blockSize. Writing 0xff to erased or
+// written flash leaves the existing contents unchanged, which makes it possible
+// to do writes that are not aligned to the write block size. This doesn't work
+// for flash with error correction, like on the STM32L4 and STM32WL, where a
+// block can only be written once after it was erased.
+func flashAlign(p []byte, off, blockSize int64) (data []byte, alignedOff int64) {
+	alignedOff = off &^ (blockSize - 1)
+	end := (off + int64(len(p)) + blockSize - 1) &^ (blockSize - 1)
+	if alignedOff == off && end == off+int64(len(p)) {
+		return p, off
+	}
+	data = make([]byte, end-alignedOff)
+	for i := range data {
+		data[i] = 0xff
+	}
+	copy(data[off-alignedOff:], p)
+	return data, alignedOff
+}
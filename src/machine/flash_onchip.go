@@ -0,0 +1,80 @@
+//go:build nrf || sam || rp2040 || stm32f4 || stm32l4 || stm32wlx
+// +build nrf sam rp2040 stm32f4 stm32l4 stm32wlx
+
+package machine
+
+import "unsafe"
+
+// The flash data area is at the end of the FLASH_TEXT region. Its size is set
+// with __flash_data_size in the linker script of the chip, and the symbols
+// below are defined in targets/arm.ld. The linker refuses programs that would
+// overlap it.
+
+//go:extern __flash_data_start
+var flashDataStart [0]byte
+
+//go:extern __flash_data_end
+var flashDataEnd [0]byte
+
+// FlashDataStart returns the start address of the writable flash area, aligned
+// on an erase block boundary.
+func FlashDataStart() uintptr {
+	blockSize := uintptr(Flash.EraseBlockSize())
+	return (uintptr(unsafe.Pointer(&flashDataStart)) + blockSize - 1) &^ (blockSize - 1)
+}
+
+// FlashDataEnd returns the end address of the writable flash area. Usually
+// this is the address one past the end of the on-chip flash.
+func FlashDataEnd() uintptr {
+	return uintptr(unsafe.Pointer(&flashDataEnd))
+}
+
+// Flash is the area at the end of the on-chip flash memory that is reserved
+// for data. Offsets are relative to FlashDataStart. The data stored here is
+// kept when a new program is flashed, unless the flasher erases the entire
+// chip.
+var Flash flashBlockDevice
+
+type flashBlockDevice struct{}
+
+// ReadAt reads the given number of bytes from the flash data area.
+func (f flashBlockDevice) ReadAt(p []byte, off int64) (n int, err error) {
+	if err := checkFlashRange(off, int64(len(p)), f.Size(), errFlashCannotReadPastEOF); err != nil {
+		return 0, err
+	}
+	data := unsafe.Slice((*byte)(unsafe.Pointer(FlashDataStart()+uintptr(off))), len(p))
+	copy(p, data)
+	return len(p), nil
+}
+
+// Size returns the number of bytes in the flash data area.
+func (f flashBlockDevice) Size() int64 {
+	start := FlashDataStart()
+	end := FlashDataEnd()
+	if start >= end {
+		// No flash data area was reserved in the linker script.
+		return 0
+	}
+	return int64(end - start)
+}
+
+// checkErase returns an error if the given range of erase blocks is not inside
+// the flash data area, and otherwise the address of the first block.
+func (f flashBlockDevice) checkErase(start, len int64) (uintptr, error) {
+	blockSize := f.EraseBlockSize()
+	if err := checkFlashRange(start*blockSize, len*blockSize, f.Size(), errFlashCannotErasePastEOF); err != nil {
+		return 0, err
+	}
+	return FlashDataStart() + uintptr(start*blockSize), nil
+}
+
+// prepareWrite returns an error if the write is not inside the flash data
+// area, and otherwise the data padded to the write block size and the address
+// to write it to.
+func (f flashBlockDevice) prepareWrite(p []byte, off int64) ([]byte, uintptr, error) {
+	if err := checkFlashRange(off, int64(len(p)), f.Size(), errFlashCannotWritePastEOF); err != nil {
+		return nil, 0, err
+	}
+	data, off := flashAlign(p, off, f.WriteBlockSize())
+	return data, FlashDataStart() + uintptr(off), nil
+}
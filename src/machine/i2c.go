@@ -1,5 +1,5 @@
-//go:build atmega || nrf || sam || stm32 || fe310 || k210 || rp2040
-// +build atmega nrf sam stm32 fe310 k210 rp2040
+//go:build !baremetal || atmega || nrf || sam || stm32 || fe310 || k210 || rp2040
+// +build !baremetal atmega nrf sam stm32 fe310 k210 rp2040
 
 package machine
 
@@ -0,0 +1,86 @@
+//go:build !baremetal || nrf || sam || rp2040
+// +build !baremetal nrf sam rp2040
+
+package machine
+
+import "errors"
+
+// I2C target mode.
+//
+// Besides being the controller of the bus, some I2C peripherals can act as a
+// target (also known as peripheral or slave device) that responds to
+// transactions started by another controller. This is useful for example to
+// build a co-processor that is controlled by a bigger system:
+//
+//	var regs [16]byte
+//	var pointer byte
+//	var first bool
+//	machine.I2C0.SetTargetHandler(func(event machine.I2CTargetEvent, b byte) byte {
+//		switch event {
+//		case machine.I2CAddressMatch:
+//			first = b&1 == 0 // a write starts with the register pointer
+//		case machine.I2CReceive:
+//			if first {
+//				pointer, first = b%16, false
+//			} else {
+//				regs[pointer], pointer = b, (pointer+1)%16
+//			}
+//		case machine.I2CRequest:
+//			b, pointer = regs[pointer], (pointer+1)%16
+//			return b
+//		}
+//		return 0
+//	})
+//	machine.I2C0.Configure(machine.I2CConfig{Mode: machine.I2CModeTarget, Address: 0x42})
+
+var (
+	errI2CTargetNotSupported   = errors.New("I2C target mode not supported")
+	errI2CInvalidTargetAddress = errors.New("invalid I2C target address")
+)
+
+// I2CMode is the role of an I2C bus, set in I2CConfig.
+type I2CMode uint8
+
+const (
+	// The bus is the controller and starts transactions with Tx. This is the
+	// default.
+	I2CModeController I2CMode = iota
+
+	// The bus is a target that responds to transactions of another
+	// controller at I2CConfig.Address. The events of those transactions are
+	// passed to the handler set with SetTargetHandler.
+	I2CModeTarget
+)
+
+// I2CTargetEvent is an event on an I2C bus in target mode.
+type I2CTargetEvent uint8
+
+const (
+	// The controller addressed this target, after a start or repeated start
+	// condition. The byte passed to the handler is the address byte: the
+	// 7-bit address shifted left by one, with the lowest bit set when the
+	// controller is going to read.
+	I2CAddressMatch I2CTargetEvent = iota
+
+	// The controller wrote a byte, which is passed to the handler.
+	I2CReceive
+
+	// The controller reads a byte. The byte returned by the handler is sent.
+	I2CRequest
+
+	// The controller ended the transaction with a stop condition.
+	I2CStop
+)
+
+// I2CTargetHandler handles the events of an I2C bus in target mode. It is
+// called from an interrupt, so it should return quickly and must not allocate
+// memory or block. The return value is only used for I2CRequest events.
+type I2CTargetHandler func(event I2CTargetEvent, b byte) byte
+
+// isReservedI2CAddr returns whether the 7-bit address is reserved by the I2C
+// specification and can't be used by a target.
+//
+//go:inline
+func isReservedI2CAddr(addr uint8) bool {
+	return (addr&0x78) == 0 || (addr&0x78) == 0x78
+}
@@ -303,3 +303,27 @@ func (s SPI) Transfer(b byte) (byte, error) {
 
 	return byte(s.spdr.Get()), nil
 }
+
+// powerDownWDT enters power-down mode until the watchdog interrupt fires after
+// the given period (0=16ms, 1=32ms, ..., 9=8s).
+func powerDownWDT(period uint8) {
+	avr.Asm("cli")
+	avr.Asm("wdr")
+	// Start the timed sequence, and set the watchdog to only interrupt.
+	avr.WDTCSR.SetBits(avr.WDTCSR_WDCE | avr.WDTCSR_WDE)
+	avr.WDTCSR.Set(avr.WDTCSR_WDIE | period&7 | (period&8)<<2)
+
+	// Enable power-down mode. The instruction after sei is executed before
+	// any interrupt, so the wakeup can't be missed.
+	avr.SMCR.Set((2 << 1) | avr.SMCR_SE)
+	avr.Asm("sei")
+	avr.Asm("sleep")
+	avr.SMCR.Set(0)
+
+	// Stop the watchdog.
+	avr.Asm("cli")
+	avr.MCUSR.ClearBits(avr.MCUSR_WDRF)
+	avr.WDTCSR.SetBits(avr.WDTCSR_WDCE | avr.WDTCSR_WDE)
+	avr.WDTCSR.Set(0)
+	avr.Asm("sei")
+}
@@ -170,14 +170,9 @@ func findPinPadMapping(sercom uint8, pin Pin) (pinMode PinMode, pad uint32, ok b
 	return
 }
 
-// SetInterrupt sets an interrupt to be executed when a particular pin changes
-// state. The pin should already be configured as an input, including a pull up
-// or down if no external pull is provided.
-//
-// This call will replace a previously set callback on this pin. You can pass a
-// nil func to unset the pin change interrupt. If you do so, the change
-// parameter is ignored and can be set to any value (such as 0).
-func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+// getEXTINT returns the external interrupt (EXTINT) number of the pin, or
+// false if the pin has no usable external interrupt.
+func (p Pin) getEXTINT() (uint8, bool) {
 	// Most pins follow a common pattern where the EXTINT value is the pin
 	// number modulo 16. However, there are a few exceptions, as you can see
 	// below.
@@ -185,7 +180,7 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 	switch p {
 	case PA08:
 		// Connected to NMI. This is not currently supported.
-		return ErrInvalidInputPin
+		return 0, false
 	case PA24:
 		extint = 12
 	case PA25:
@@ -203,6 +198,22 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 		extint = uint8(p) % 16
 	}
 
+	return extint, true
+}
+
+// SetInterrupt sets an interrupt to be executed when a particular pin changes
+// state. The pin should already be configured as an input, including a pull up
+// or down if no external pull is provided.
+//
+// This call will replace a previously set callback on this pin. You can pass a
+// nil func to unset the pin change interrupt. If you do so, the change
+// parameter is ignored and can be set to any value (such as 0).
+func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+	extint, ok := p.getEXTINT()
+	if !ok {
+		return ErrInvalidInputPin
+	}
+
 	if callback == nil {
 		// Disable this pin interrupt (if it was enabled).
 		sam.EIC.INTENCLR.Set(1 << extint)
@@ -312,6 +323,7 @@ func InitADC() {
 
 // Configure configures a ADC pin to be able to be used to read data.
 func (a ADC) Configure(config ADCConfig) {
+	standbyBlocked = true // runs from the main clock
 
 	// Wait for synchronization
 	waitADCSync()
@@ -512,6 +524,8 @@ const (
 
 // Configure the UART.
 func (uart *UART) Configure(config UARTConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
@@ -635,6 +649,11 @@ func (uart *UART) WriteByte(c byte) error {
 // handleInterrupt should be called from the appropriate interrupt handler for
 // this UART instance.
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
+	if i2c := sercomI2CTargets[uart.SERCOM]; i2c != nil {
+		// The SERCOM is used as I2C target instead.
+		i2c.handleTargetInterrupt()
+		return
+	}
 	// should reset IRQ
 	uart.Receive(byte((uart.Bus.DATA.Get() & 0xFF)))
 	uart.Bus.INTFLAG.SetBits(sam.SERCOM_USART_INTFLAG_RXC)
@@ -651,6 +670,8 @@ type I2CConfig struct {
 	Frequency uint32
 	SCL       Pin
 	SDA       Pin
+	Mode      I2CMode
+	Address   uint16 // target address, when Mode is I2CModeTarget
 }
 
 const (
@@ -674,6 +695,8 @@ const i2cTimeout = 1000
 
 // Configure is intended to setup the I2C interface.
 func (i2c *I2C) Configure(config I2CConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Default I2C bus speed is 100 kHz.
 	if config.Frequency == 0 {
 		config.Frequency = 100 * KHz
@@ -704,6 +727,16 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 		i2c.Bus.SYNCBUSY.HasBits(sam.SERCOM_I2CM_SYNCBUSY_SWRST) {
 	}
 
+	if config.Mode == I2CModeTarget {
+		if err := i2c.configureTarget(config.Address); err != nil {
+			return err
+		}
+		config.SDA.Configure(PinConfig{Mode: sdaPinMode})
+		config.SCL.Configure(PinConfig{Mode: sclPinMode})
+		return nil
+	}
+	sercomI2CTargets[i2c.SERCOM] = nil
+
 	// Set i2c controller mode
 	//SERCOM_I2CM_CTRLA_MODE( I2C_MASTER_OPERATION )
 	i2c.Bus.CTRLA.Set(sam.SERCOM_I2CM_CTRLA_MODE_I2C_MASTER << sam.SERCOM_I2CM_CTRLA_MODE_Pos) // |
@@ -728,6 +761,12 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 	return nil
 }
 
+// enableTargetInterrupt enables the SERCOM interrupt for target mode. The
+// handler is the one of the UART on the same SERCOM.
+func (i2c *I2C) enableTargetInterrupt() {
+	arm.EnableIRQ(sam.IRQ_SERCOM0 + uint32(i2c.SERCOM))
+}
+
 // SetBaudRate sets the communication speed for the I2C.
 func (i2c *I2C) SetBaudRate(br uint32) {
 	// Synchronous arithmetic baudrate, via Arduino SAMD implementation:
@@ -903,6 +942,8 @@ var I2S0 = I2S{Bus: sam.I2S}
 // Configure is used to configure the I2S interface. You must call this
 // before you can use the I2S bus.
 func (i2s I2S) Configure(config I2SConfig) {
+	standbyBlocked = true // runs from the main clock
+
 	// handle defaults
 	if config.SCK == 0 {
 		config.SCK = I2S_SCK_PIN
@@ -1140,6 +1181,8 @@ type SPIConfig struct {
 
 // Configure is intended to setup the SPI interface.
 func (spi SPI) Configure(config SPIConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Use default pins if not set.
 	if config.SCK == 0 && config.SDO == 0 && config.SDI == 0 {
 		config.SCK = SPI0_SCK_PIN
@@ -1378,6 +1421,8 @@ func (tcc *TCC) timer() *sam.TCC_Type {
 
 // Configure enables and configures this TCC.
 func (tcc *TCC) Configure(config PWMConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Enable the clock source for this timer.
 	switch tcc.timer() {
 	case sam.TCC0:
@@ -1755,6 +1800,8 @@ type DACConfig struct {
 // Configure the DAC.
 // output pin must already be configured.
 func (dac DAC) Configure(config DACConfig) {
+	standbyBlocked = true // runs from the main clock
+
 	// Turn on clock for DAC
 	sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_DAC_)
 
@@ -1789,3 +1836,15 @@ func syncDAC() {
 	for sam.DAC.STATUS.HasBits(sam.DAC_STATUS_SYNCBUSY) {
 	}
 }
+
+// The 128-bit serial number of the SAMD21 is spread over these addresses.
+var deviceIDWords = [4]uintptr{0x0080A00C, 0x0080A040, 0x0080A044, 0x0080A048}
+
+var deviceID [16]byte
+
+// readDeviceID reads the serial number of the chip into deviceID.
+func readDeviceID() {
+	for i, addr := range deviceIDWords {
+		putDeviceIDWord(deviceID[i*4:], *(*uint32)(unsafe.Pointer(addr)))
+	}
+}
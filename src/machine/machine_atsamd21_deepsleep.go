@@ -0,0 +1,71 @@
+//go:build sam && atsamd21
+// +build sam,atsamd21
+
+package machine
+
+import (
+	"device/sam"
+)
+
+// configureWake configures the pin as an input, with a pin interrupt that
+// detects the level. Unlike edge detection, level detection works without
+// GCLK_EIC, so with the WAKEUP bit set it wakes the chip from standby.
+func (p Pin) configureWake(level bool) error {
+	extint, ok := p.getEXTINT()
+	if !ok {
+		return ErrWakeSourceNotSupported
+	}
+	mode, sense := PinInputPullup, PinChange(sam.EIC_CONFIG_SENSE0_LOW)
+	if level {
+		mode, sense = PinInputPulldown, PinChange(sam.EIC_CONFIG_SENSE0_HIGH)
+	}
+	p.Configure(PinConfig{Mode: mode})
+	if err := p.SetInterrupt(sense, wakePinInterrupt); err != nil {
+		return err
+	}
+	sam.EIC.WAKEUP.SetBits(1 << extint)
+	return nil
+}
+
+// disableWake disables the pin interrupt set by configureWake.
+func (p Pin) disableWake() {
+	extint, _ := p.getEXTINT()
+	sam.EIC.WAKEUP.ClearBits(1 << extint)
+	p.SetInterrupt(0, nil)
+}
+
+// wakePinInterrupt is called when a wake pin reads its level. The interrupt is
+// disabled, as it would keep firing as long as the pin stays at that level.
+func wakePinInterrupt(p Pin) {
+	extint, _ := p.getEXTINT()
+	sam.EIC.INTENCLR.Set(1 << extint)
+	wakeDeepSleep()
+}
+
+// eicNeedsClock returns whether a pin interrupt uses edge detection, which
+// doesn't work in standby.
+func eicNeedsClock() bool {
+	enabled := sam.EIC.INTENSET.Get()
+	for extint := uint8(0); extint < 16; extint++ {
+		if enabled&(1<<extint) == 0 {
+			continue
+		}
+		config := sam.EIC.CONFIG0.Get()
+		if extint >= 8 {
+			config = sam.EIC.CONFIG1.Get()
+		}
+		switch (config >> ((extint % 8) * 4)) & 0x7 {
+		case sam.EIC_CONFIG_SENSE0_RISE, sam.EIC_CONFIG_SENSE0_FALL, sam.EIC_CONFIG_SENSE0_BOTH:
+			return true
+		}
+	}
+	return false
+}
+
+// The scheduler enters standby while deepSleeping is set. The 32kHz oscillator
+// of the RTC keeps running in standby.
+func deepSleep(duration int64, wakeSources []WakeSource) {
+	deepSleeping = true
+	sleepUntilWoken(duration, wakeSources)
+	deepSleeping = false
+}
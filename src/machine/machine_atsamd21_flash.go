@@ -0,0 +1,108 @@
+//go:build sam && atsamd21
+// +build sam,atsamd21
+
+package machine
+
+import (
+	"device/sam"
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+var (
+	errFlashProgrammingError = errors.New("flash programming error")
+	errFlashLockError        = errors.New("flash lock error")
+	errFlashNVMError         = errors.New("flash NVM error")
+)
+
+// WriteAt writes the given number of bytes to the flash data area.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	data, address, err := f.prepareWrite(p, off)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write pages manually, with the WP command.
+	sam.NVMCTRL.CTRLB.SetBits(sam.NVMCTRL_CTRLB_MANW)
+
+	pageSize := int(f.WriteBlockSize())
+	for i := 0; i < len(data); i += pageSize {
+		waitWhileFlashBusy()
+		nvmCommand(sam.NVMCTRL_CTRLA_CMD_PBC, address)
+		waitWhileFlashBusy()
+
+		// Fill the page buffer, which is mapped at the address of the page.
+		// It can only be written in 16-bit or 32-bit units.
+		for j := 0; j < pageSize; j += 4 {
+			*(*uint32)(unsafe.Pointer(address + uintptr(j))) = binary.LittleEndian.Uint32(data[i+j : i+j+4])
+		}
+
+		nvmCommand(sam.NVMCTRL_CTRLA_CMD_WP, address)
+		waitWhileFlashBusy()
+		if err := checkFlashError(); err != nil {
+			return 0, err
+		}
+		address += uintptr(pageSize)
+	}
+	return len(p), nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 64-byte page.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 64
+}
+
+// EraseBlockSize returns the size of a row, which consists of four pages.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return 256
+}
+
+// EraseBlocks erases the given number of rows.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	for i := int64(0); i < len; i++ {
+		waitWhileFlashBusy()
+		nvmCommand(sam.NVMCTRL_CTRLA_CMD_ER, address)
+		waitWhileFlashBusy()
+		if err := checkFlashError(); err != nil {
+			return err
+		}
+		address += uintptr(f.EraseBlockSize())
+	}
+	return nil
+}
+
+// nvmCommand executes the given NVM controller command on the given address.
+func nvmCommand(cmd uint16, address uintptr) {
+	// Clear errors of a previous command.
+	sam.NVMCTRL.STATUS.Set(sam.NVMCTRL_STATUS_PROGE | sam.NVMCTRL_STATUS_LOCKE | sam.NVMCTRL_STATUS_NVME)
+
+	// The ADDR register takes a 16-bit word address.
+	sam.NVMCTRL.ADDR.Set(uint32(address >> 1))
+	sam.NVMCTRL.CTRLA.Set((cmd << sam.NVMCTRL_CTRLA_CMD_Pos) |
+		(sam.NVMCTRL_CTRLA_CMDEX_KEY << sam.NVMCTRL_CTRLA_CMDEX_Pos))
+}
+
+func waitWhileFlashBusy() {
+	for !sam.NVMCTRL.INTFLAG.HasBits(sam.NVMCTRL_INTFLAG_READY) {
+	}
+}
+
+func checkFlashError() error {
+	status := sam.NVMCTRL.STATUS.Get()
+	switch {
+	case status&sam.NVMCTRL_STATUS_PROGE != 0:
+		return errFlashProgrammingError
+	case status&sam.NVMCTRL_STATUS_LOCKE != 0:
+		return errFlashLockError
+	case status&sam.NVMCTRL_STATUS_NVME != 0:
+		return errFlashNVMError
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build sam && atsamd21
+// +build sam,atsamd21
+
+package machine
+
+import (
+	"device/sam"
+)
+
+// watchdogSetPeriod sets up the watchdog clock and the watchdog period.
+func watchdogSetPeriod(period uint8) {
+	sam.PM.APBAMASK.SetBits(sam.PM_APBAMASK_WDT_)
+
+	// Use Generic Clock Generator 4 as a 1.024kHz clock for the watchdog:
+	// OSCULP32K/32 (division by 2^(DIV+1)). OSCULP32K is always running,
+	// also in standby mode.
+	sam.GCLK.GENDIV.Set((4 << sam.GCLK_GENDIV_ID_Pos) |
+		(4 << sam.GCLK_GENDIV_DIV_Pos))
+	waitForSync()
+
+	sam.GCLK.GENCTRL.Set((4 << sam.GCLK_GENCTRL_ID_Pos) |
+		(sam.GCLK_GENCTRL_SRC_OSCULP32K << sam.GCLK_GENCTRL_SRC_Pos) |
+		sam.GCLK_GENCTRL_DIVSEL |
+		sam.GCLK_GENCTRL_GENEN)
+	waitForSync()
+
+	sam.GCLK.CLKCTRL.Set((sam.GCLK_CLKCTRL_ID_WDT << sam.GCLK_CLKCTRL_ID_Pos) |
+		(sam.GCLK_CLKCTRL_GEN_GCLK4 << sam.GCLK_CLKCTRL_GEN_Pos) |
+		sam.GCLK_CLKCTRL_CLKEN)
+	waitForSync()
+
+	sam.WDT.CONFIG.Set(period << sam.WDT_CONFIG_PER_Pos)
+	for sam.WDT.STATUS.HasBits(sam.WDT_STATUS_SYNCBUSY) {
+	}
+}
+
+func watchdogStart() {
+	sam.WDT.CTRL.SetBits(sam.WDT_CTRL_ENABLE)
+	for sam.WDT.STATUS.HasBits(sam.WDT_STATUS_SYNCBUSY) {
+	}
+}
+
+func watchdogUpdate() {
+	// 0xA5 is the magic value to clear the watchdog (see datasheet).
+	sam.WDT.CLEAR.Set(0xA5)
+}
+
+func resetReason() ResetCause {
+	rcause := sam.PM.RCAUSE.Get()
+	switch {
+	case rcause&sam.PM_RCAUSE_WDT != 0:
+		return ResetCauseWatchdog
+	case rcause&sam.PM_RCAUSE_SYST != 0:
+		return ResetCauseSoftware
+	case rcause&sam.PM_RCAUSE_EXT != 0:
+		return ResetCauseExternal
+	case rcause&(sam.PM_RCAUSE_BOD12|sam.PM_RCAUSE_BOD33) != 0:
+		return ResetCauseBrownout
+	case rcause&sam.PM_RCAUSE_POR != 0:
+		return ResetCausePowerOn
+	}
+	return ResetCauseUnknown
+}
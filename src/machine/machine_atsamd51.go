@@ -361,14 +361,9 @@ func findPinPadMapping(sercom uint8, pin Pin) (pinMode PinMode, pad uint32, ok b
 	return
 }
 
-// SetInterrupt sets an interrupt to be executed when a particular pin changes
-// state. The pin should already be configured as an input, including a pull up
-// or down if no external pull is provided.
-//
-// This call will replace a previously set callback on this pin. You can pass a
-// nil func to unset the pin change interrupt. If you do so, the change
-// parameter is ignored and can be set to any value (such as 0).
-func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+// getEXTINT returns the external interrupt (EXTINT) number of the pin, or
+// false if the pin has no usable external interrupt.
+func (p Pin) getEXTINT() (uint8, bool) {
 	// Most pins follow a common pattern where the EXTINT value is the pin
 	// number modulo 16. However, there are a few exceptions, as you can see
 	// below.
@@ -377,7 +372,7 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 	switch p {
 	case PA08:
 		// Connected to NMI. This is not currently supported.
-		return ErrInvalidInputPin
+		return 0, false
 	case PB26:
 		extint = 12
 	case PB27:
@@ -407,6 +402,22 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 		extint = uint8(p) % 16
 	}
 
+	return extint, true
+}
+
+// SetInterrupt sets an interrupt to be executed when a particular pin changes
+// state. The pin should already be configured as an input, including a pull up
+// or down if no external pull is provided.
+//
+// This call will replace a previously set callback on this pin. You can pass a
+// nil func to unset the pin change interrupt. If you do so, the change
+// parameter is ignored and can be set to any value (such as 0).
+func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+	extint, ok := p.getEXTINT()
+	if !ok {
+		return ErrInvalidInputPin
+	}
+
 	if callback == nil {
 		// Disable this pin interrupt (if it was enabled).
 		sam.EIC.INTENCLR.Set(1 << extint)
@@ -741,6 +752,7 @@ func InitADC() {
 
 // Configure configures a ADCPin to be able to be used to read data.
 func (a ADC) Configure(config ADCConfig) {
+	standbyBlocked = true // runs from the main clock
 
 	for _, adc := range []*sam.ADC_Type{sam.ADC0, sam.ADC1} {
 
@@ -977,6 +989,8 @@ const (
 
 // Configure the UART.
 func (uart *UART) Configure(config UARTConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
@@ -1105,6 +1119,11 @@ func (uart *UART) WriteByte(c byte) error {
 }
 
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
+	if i2c := sercomI2CTargets[uart.SERCOM]; i2c != nil {
+		// The SERCOM is used as I2C target instead.
+		i2c.handleTargetInterrupt()
+		return
+	}
 	// should reset IRQ
 	uart.Receive(byte((uart.Bus.DATA.Get() & 0xFF)))
 	uart.Bus.INTFLAG.SetBits(sam.SERCOM_USART_INT_INTFLAG_RXC)
@@ -1121,6 +1140,8 @@ type I2CConfig struct {
 	Frequency uint32
 	SCL       Pin
 	SDA       Pin
+	Mode      I2CMode
+	Address   uint16 // target address, when Mode is I2CModeTarget
 }
 
 const (
@@ -1148,6 +1169,8 @@ const i2cTimeout = 1000
 
 // Configure is intended to setup the I2C interface.
 func (i2c *I2C) Configure(config I2CConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Default I2C bus speed is 100 kHz.
 	if config.Frequency == 0 {
 		config.Frequency = 100 * KHz
@@ -1183,6 +1206,16 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 	// set clock
 	setSERCOMClockGenerator(i2c.SERCOM, sam.GCLK_PCHCTRL_GEN_GCLK1)
 
+	if config.Mode == I2CModeTarget {
+		if err := i2c.configureTarget(config.Address); err != nil {
+			return err
+		}
+		config.SDA.Configure(PinConfig{Mode: sdaPinMode})
+		config.SCL.Configure(PinConfig{Mode: sclPinMode})
+		return nil
+	}
+	sercomI2CTargets[i2c.SERCOM] = nil
+
 	// Set i2c controller mode
 	//SERCOM_I2CM_CTRLA_MODE( I2C_MASTER_OPERATION )
 	// sam.SERCOM_I2CM_CTRLA_MODE_I2C_MASTER = 5?
@@ -1208,6 +1241,38 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 	return nil
 }
 
+// enableTargetInterrupt enables the SERCOM interrupts for target mode. The
+// SERCOM has a separate interrupt for each of the PREC, AMATCH and DRDY flags,
+// the one for DRDY is shared with the UART, whose handler passes it on.
+func (i2c *I2C) enableTargetInterrupt() {
+	switch i2c.SERCOM {
+	case 0:
+		interrupt.New(sam.IRQ_SERCOM0_0, func(interrupt.Interrupt) { sercomI2CTargets[0].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM0_1, func(interrupt.Interrupt) { sercomI2CTargets[0].handleTargetInterrupt() }).Enable()
+		sercomUSART0.Interrupt.Enable()
+	case 1:
+		interrupt.New(sam.IRQ_SERCOM1_0, func(interrupt.Interrupt) { sercomI2CTargets[1].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM1_1, func(interrupt.Interrupt) { sercomI2CTargets[1].handleTargetInterrupt() }).Enable()
+		sercomUSART1.Interrupt.Enable()
+	case 2:
+		interrupt.New(sam.IRQ_SERCOM2_0, func(interrupt.Interrupt) { sercomI2CTargets[2].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM2_1, func(interrupt.Interrupt) { sercomI2CTargets[2].handleTargetInterrupt() }).Enable()
+		sercomUSART2.Interrupt.Enable()
+	case 3:
+		interrupt.New(sam.IRQ_SERCOM3_0, func(interrupt.Interrupt) { sercomI2CTargets[3].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM3_1, func(interrupt.Interrupt) { sercomI2CTargets[3].handleTargetInterrupt() }).Enable()
+		sercomUSART3.Interrupt.Enable()
+	case 4:
+		interrupt.New(sam.IRQ_SERCOM4_0, func(interrupt.Interrupt) { sercomI2CTargets[4].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM4_1, func(interrupt.Interrupt) { sercomI2CTargets[4].handleTargetInterrupt() }).Enable()
+		sercomUSART4.Interrupt.Enable()
+	case 5:
+		interrupt.New(sam.IRQ_SERCOM5_0, func(interrupt.Interrupt) { sercomI2CTargets[5].handleTargetInterrupt() }).Enable()
+		interrupt.New(sam.IRQ_SERCOM5_1, func(interrupt.Interrupt) { sercomI2CTargets[5].handleTargetInterrupt() }).Enable()
+		sercomUSART5.Interrupt.Enable()
+	}
+}
+
 // SetBaudRate sets the communication speed for the I2C.
 func (i2c *I2C) SetBaudRate(br uint32) {
 	// Synchronous arithmetic baudrate, via Adafruit SAMD51 implementation:
@@ -1389,6 +1454,8 @@ type SPIConfig struct {
 
 // Configure is intended to setup the SPI interface.
 func (spi SPI) Configure(config SPIConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Use default pins if not set.
 	if config.SCK == 0 && config.SDO == 0 && config.SDI == 0 {
 		config.SCK = SPI0_SCK_PIN
@@ -1634,6 +1701,8 @@ func (tcc *TCC) timer() *sam.TCC_Type {
 
 // Configure enables and configures this TCC.
 func (tcc *TCC) Configure(config PWMConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	// Enable the TCC clock to be able to use the TCC.
 	tcc.configureClock()
 
@@ -1997,6 +2066,8 @@ type DACConfig struct {
 // Configure the DAC.
 // output pin must already be configured.
 func (dac DAC) Configure(config DACConfig) {
+	standbyBlocked = true // runs from the main clock
+
 	// Turn on clock for DAC
 	sam.MCLK.APBDMASK.SetBits(sam.MCLK_APBDMASK_DAC_)
 
@@ -2075,3 +2146,16 @@ func GetRNG() (uint32, error) {
 	ret := sam.TRNG.DATA.Get()
 	return ret, nil
 }
+
+// The 128-bit serial number of the SAMD51 and SAME5x is spread over these
+// addresses.
+var deviceIDWords = [4]uintptr{0x008061FC, 0x00806010, 0x00806014, 0x00806018}
+
+var deviceID [16]byte
+
+// readDeviceID reads the serial number of the chip into deviceID.
+func readDeviceID() {
+	for i, addr := range deviceIDWords {
+		putDeviceIDWord(deviceID[i*4:], *(*uint32)(unsafe.Pointer(addr)))
+	}
+}
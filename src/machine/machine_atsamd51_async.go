@@ -0,0 +1,170 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"device/sam"
+	"unsafe"
+)
+
+// spiDMA is the state of asynchronous transfers on a SPI bus. Transfers longer
+// than a single DMA block are split, the next block is started when the rx
+// channel has received the previous one.
+type spiDMA struct {
+	asyncTransfer
+	tx, rx  dmaChannel
+	claimed bool
+	spi     SPI
+	w, r    []byte
+	offset  int
+	length  int
+}
+
+var spiDMAs [8]spiDMA
+
+// TxAsync starts a SPI transfer using DMA and returns immediately. The w and r
+// buffers are used in the same way as in Tx. Call Wait to wait for the
+// transfer to finish, the buffers must not be used until then.
+func (spi SPI) TxAsync(w, r []byte) error {
+	if w != nil && r != nil && len(w) != len(r) {
+		return ErrTxInvalidSliceSize
+	}
+	d := &spiDMAs[spi.SERCOM]
+	if !d.claimed {
+		tx, err := claimDMAChannel(nil)
+		if err != nil {
+			return err
+		}
+		rx, err := claimDMAChannel(d.next)
+		if err != nil {
+			tx.release()
+			return err
+		}
+		d.tx, d.rx, d.claimed = tx, rx, true
+	}
+	if err := d.begin(w, r); err != nil {
+		return err
+	}
+	d.spi, d.w, d.r, d.offset, d.length = spi, w, r, 0, len(r)
+	if r == nil {
+		d.length = len(w)
+	}
+
+	// Throw away stale data, so that the received bytes line up.
+	for spi.Bus.INTFLAG.HasBits(sam.SERCOM_SPIM_INTFLAG_RXC) {
+		spi.Bus.DATA.Get()
+	}
+	d.next(nil)
+	return nil
+}
+
+// next starts the next block of the transfer, or completes the transfer when
+// all blocks are done or there was an error.
+func (d *spiDMA) next(err error) {
+	if err != nil || d.offset == d.length {
+		d.tx.abort()
+		d.w, d.r = nil, nil
+		d.complete(err)
+		return
+	}
+	n := d.length - d.offset
+	if n > dmaMaxBlock {
+		n = dmaMaxBlock
+	}
+	data := uintptr(unsafe.Pointer(&d.spi.Bus.DATA))
+	rxAddr, rxCtrl := uintptr(unsafe.Pointer(&dmaDummy)), uint16(0)
+	if d.r != nil {
+		rxAddr, rxCtrl = uintptr(unsafe.Pointer(&d.r[d.offset])), dmaBtctrlDSTINC
+	}
+	txAddr, txCtrl := uintptr(unsafe.Pointer(&dmaZero)), uint16(0)
+	if d.w != nil {
+		txAddr, txCtrl = uintptr(unsafe.Pointer(&d.w[d.offset])), dmaBtctrlSRCINC
+	}
+	d.offset += n
+	// Start receiving before sending, so that no received byte is lost.
+	d.rx.start(data, rxAddr, uint16(n), rxCtrl|dmaBtctrlBEATSIZEByte, dmaTriggerSERCOMRX+2*d.spi.SERCOM)
+	d.tx.start(txAddr, data, uint16(n), txCtrl|dmaBtctrlBEATSIZEByte, dmaTriggerSERCOMTX+2*d.spi.SERCOM)
+}
+
+// Wait waits until the transfer started with TxAsync has finished. The calling
+// goroutine is paused in the meantime, so that other goroutines can run.
+func (spi SPI) Wait() error {
+	return spiDMAs[spi.SERCOM].wait()
+}
+
+// uartDMA is the state of asynchronous writes to a UART.
+type uartDMA struct {
+	asyncTransfer
+	ch      dmaChannel
+	claimed bool
+	uart    *UART
+	data    []byte
+	offset  int
+}
+
+var uartDMAs [8]uartDMA
+
+// TxAsync starts writing data to the UART using DMA and returns immediately.
+// Call Wait to wait for the write to finish, data must not be modified until
+// then.
+func (uart *UART) TxAsync(data []byte) error {
+	d := &uartDMAs[uart.SERCOM]
+	if !d.claimed {
+		ch, err := claimDMAChannel(d.next)
+		if err != nil {
+			return err
+		}
+		d.ch, d.claimed = ch, true
+	}
+	if err := d.begin(data, nil); err != nil {
+		return err
+	}
+	d.uart, d.data, d.offset = uart, data, 0
+	d.next(nil)
+	return nil
+}
+
+// next starts writing the next block of data, or completes the write when all
+// data has been written or there was an error.
+func (d *uartDMA) next(err error) {
+	if err != nil || d.offset == len(d.data) {
+		d.data = nil
+		d.complete(err)
+		return
+	}
+	n := len(d.data) - d.offset
+	if n > dmaMaxBlock {
+		n = dmaMaxBlock
+	}
+	src := uintptr(unsafe.Pointer(&d.data[d.offset]))
+	d.offset += n
+	d.ch.start(src, uintptr(unsafe.Pointer(&d.uart.Bus.DATA)), uint16(n),
+		dmaBtctrlSRCINC|dmaBtctrlBEATSIZEByte, dmaTriggerSERCOMTX+2*d.uart.SERCOM)
+}
+
+// Wait waits until all data passed to TxAsync has been moved into the UART.
+// The last byte may still be in the process of being sent.
+func (uart *UART) Wait() error {
+	return uartDMAs[uart.SERCOM].wait()
+}
+
+var i2cAsync [8]asyncTransfer
+
+// TxAsync performs an I2C transaction, see Tx. The SERCOM I2C host needs help
+// from the CPU between the address, write and read phases of a transaction, so
+// unlike SPI and UART transfers this is not done with DMA: the transaction is
+// finished when TxAsync returns, Wait only returns its result.
+func (i2c *I2C) TxAsync(addr uint16, w, r []byte) error {
+	t := &i2cAsync[i2c.SERCOM]
+	if err := t.begin(w, r); err != nil {
+		return err
+	}
+	t.complete(i2c.Tx(addr, w, r))
+	return nil
+}
+
+// Wait returns the result of the transaction started with TxAsync.
+func (i2c *I2C) Wait() error {
+	return i2cAsync[i2c.SERCOM].wait()
+}
@@ -0,0 +1,82 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"device/sam"
+)
+
+// configureWake configures the pin as an input, with a pin interrupt that
+// detects the level. The interrupt uses asynchronous detection, which works
+// without GCLK_EIC and so wakes the chip from standby.
+func (p Pin) configureWake(level bool) error {
+	extint, ok := p.getEXTINT()
+	if !ok {
+		return ErrWakeSourceNotSupported
+	}
+	mode, sense := PinInputPullup, PinChange(sam.EIC_CONFIG_SENSE0_LOW)
+	if level {
+		mode, sense = PinInputPulldown, PinChange(sam.EIC_CONFIG_SENSE0_HIGH)
+	}
+	p.Configure(PinConfig{Mode: mode})
+	if err := p.SetInterrupt(sense, wakePinInterrupt); err != nil {
+		return err
+	}
+	setEICAsynch(extint, true)
+	return nil
+}
+
+// disableWake disables the pin interrupt set by configureWake.
+func (p Pin) disableWake() {
+	extint, _ := p.getEXTINT()
+	setEICAsynch(extint, false)
+	p.SetInterrupt(0, nil)
+}
+
+// setEICAsynch enables or disables asynchronous detection for an EXTINT. The
+// ASYNCH register is enable-protected, so the EIC is disabled meanwhile.
+func setEICAsynch(extint uint8, enable bool) {
+	sam.EIC.CTRLA.ClearBits(sam.EIC_CTRLA_ENABLE)
+	for sam.EIC.SYNCBUSY.HasBits(sam.EIC_SYNCBUSY_ENABLE) {
+	}
+	if enable {
+		sam.EIC.ASYNCH.SetBits(1 << extint)
+	} else {
+		sam.EIC.ASYNCH.ClearBits(1 << extint)
+	}
+	sam.EIC.CTRLA.SetBits(sam.EIC_CTRLA_ENABLE)
+	for sam.EIC.SYNCBUSY.HasBits(sam.EIC_SYNCBUSY_ENABLE) {
+	}
+}
+
+// wakePinInterrupt is called when a wake pin reads its level. The interrupt is
+// disabled, as it would keep firing as long as the pin stays at that level.
+func wakePinInterrupt(p Pin) {
+	extint, _ := p.getEXTINT()
+	sam.EIC.INTENCLR.Set(1 << extint)
+	wakeDeepSleep()
+}
+
+// eicNeedsClock returns whether a pin interrupt uses synchronous edge
+// detection, which doesn't work in standby.
+func eicNeedsClock() bool {
+	enabled := sam.EIC.INTENSET.Get() &^ sam.EIC.ASYNCH.Get()
+	for extint := uint8(0); extint < 16; extint++ {
+		if enabled&(1<<extint) == 0 {
+			continue
+		}
+		switch (sam.EIC.CONFIG[extint/8].Get() >> ((extint % 8) * 4)) & 0x7 {
+		case sam.EIC_CONFIG_SENSE0_RISE, sam.EIC_CONFIG_SENSE0_FALL, sam.EIC_CONFIG_SENSE0_BOTH:
+			return true
+		}
+	}
+	return false
+}
+
+// The scheduler enters standby while deepSleeping is set.
+func deepSleep(duration int64, wakeSources []WakeSource) {
+	deepSleeping = true
+	sleepUntilWoken(duration, wakeSources)
+	deepSleeping = false
+}
@@ -0,0 +1,228 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"device/sam"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// The DMAC of the SAMD51 has 32 channels, of which only the first few are used
+// here to keep the descriptor memory small. Each channel transfers a single
+// block of at most 65535 beats described by a transfer descriptor in RAM.
+// Drivers claim a channel for as long as they need it and may register a
+// callback that is called from the DMAC interrupt when the block is done.
+
+const dmaChannels = 8
+
+type dmacChannelType struct {
+	chctrla    volatile.Register32
+	chctrlb    volatile.Register8
+	chprilvl   volatile.Register8
+	chevctrl   volatile.Register8
+	_          [5]byte
+	chintenclr volatile.Register8
+	chintenset volatile.Register8
+	chintflag  volatile.Register8
+	chstatus   volatile.Register8
+}
+
+type dmacType struct {
+	ctrl       volatile.Register16
+	crcctrl    volatile.Register16
+	crcdatain  volatile.Register32
+	crcchksum  volatile.Register32
+	crcstatus  volatile.Register8
+	dbgctrl    volatile.Register8
+	_          [2]byte
+	swtrigctrl volatile.Register32
+	prictrl0   volatile.Register32
+	_          [8]byte
+	intpend    volatile.Register16
+	_          [2]byte
+	intstatus  volatile.Register32
+	busych     volatile.Register32
+	pendch     volatile.Register32
+	active     volatile.Register32
+	baseaddr   volatile.Register32
+	wrbaddr    volatile.Register32
+	_          [4]byte
+	channel    [32]dmacChannelType
+}
+
+var dmac = (*dmacType)(unsafe.Pointer(sam.DMAC))
+
+// Fields of the DMAC registers.
+const (
+	dmacCtrlSWRST     = 1 << 0
+	dmacCtrlDMAENABLE = 1 << 1
+	dmacCtrlLVLENAll  = 0xf << 8
+
+	dmacChctrlaENABLE       = 1 << 1
+	dmacChctrlaTRIGSRCPos   = 8
+	dmacChctrlaTRIGACTBurst = 2 << 20
+
+	dmacChintTERR  = 1 << 0
+	dmacChintTCMPL = 1 << 1
+)
+
+// Fields of the BTCTRL field of a transfer descriptor.
+const (
+	dmaBtctrlVALID        = 1 << 0
+	dmaBtctrlBLOCKACTInt  = 1 << 3
+	dmaBtctrlBEATSIZEByte = 0 << 8
+	dmaBtctrlSRCINC       = 1 << 10
+	dmaBtctrlDSTINC       = 1 << 11
+)
+
+// Peripheral triggers of the SERCOMs. Add twice the SERCOM number.
+const (
+	dmaTriggerSERCOMRX = 0x04
+	dmaTriggerSERCOMTX = 0x05
+)
+
+// The largest number of beats in a single block transfer.
+const dmaMaxBlock = 0xffff
+
+// dmaDescriptor is a transfer descriptor, as read by the DMAC.
+type dmaDescriptor struct {
+	btctrl   uint16
+	btcnt    uint16
+	srcaddr  uint32
+	dstaddr  uint32
+	descaddr uint32
+}
+
+// The descriptors must be 128-bit aligned.
+//
+//go:align 16
+var dmaDescriptors [dmaChannels]dmaDescriptor
+
+//go:align 16
+var dmaWriteBack [dmaChannels]dmaDescriptor
+
+var (
+	errNoDMAChannel = errors.New("no free DMA channel")
+	errDMATransfer  = errors.New("DMA transfer error")
+)
+
+// dmaChannel is a claimed DMA channel.
+type dmaChannel uint8
+
+var (
+	dmaClaimed     uint8
+	dmaCallbacks   [dmaChannels]func(err error)
+	dmaInitialized bool
+)
+
+// Transfers that only need to move a constant or throw data away use these
+// as their source or destination.
+var (
+	dmaZero  uint32
+	dmaDummy uint32
+)
+
+// claimDMAChannel reserves a free DMA channel. The callback, if not nil, is
+// called from an interrupt each time the channel finishes a block transfer,
+// with a non-nil error if there was a bus error.
+func claimDMAChannel(callback func(err error)) (dmaChannel, error) {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	if !dmaInitialized {
+		sam.MCLK.AHBMASK.SetBits(sam.MCLK_AHBMASK_DMAC_)
+		dmac.ctrl.Set(0)
+		dmac.ctrl.Set(dmacCtrlSWRST)
+		for dmac.ctrl.HasBits(dmacCtrlSWRST) {
+		}
+		dmac.baseaddr.Set(uint32(uintptr(unsafe.Pointer(&dmaDescriptors))))
+		dmac.wrbaddr.Set(uint32(uintptr(unsafe.Pointer(&dmaWriteBack))))
+		dmac.ctrl.Set(dmacCtrlDMAENABLE | dmacCtrlLVLENAll)
+
+		// Channels 0-3 have their own interrupt, the others share one.
+		interrupt.New(sam.IRQ_DMAC_0, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_1, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_2, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_3, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_OTHER, dmaHandleInterrupt).Enable()
+		dmaInitialized = true
+	}
+	for ch := dmaChannel(0); ch < dmaChannels; ch++ {
+		if dmaClaimed&(1<<ch) != 0 {
+			continue
+		}
+		dmaClaimed |= 1 << ch
+		dmaCallbacks[ch] = callback
+		regs := &dmac.channel[ch]
+		regs.chctrla.Set(0)
+		if callback != nil {
+			regs.chintenset.Set(dmacChintTCMPL | dmacChintTERR)
+		}
+		return ch, nil
+	}
+	return 0, errNoDMAChannel
+}
+
+// release stops any transfer in progress and frees the channel.
+func (ch dmaChannel) release() {
+	ch.abort()
+	mask := interrupt.Disable()
+	dmac.channel[ch].chintenclr.Set(dmacChintTCMPL | dmacChintTERR)
+	dmaCallbacks[ch] = nil
+	dmaClaimed &^= 1 << ch
+	interrupt.Restore(mask)
+}
+
+// start transfers count beats (at most dmaMaxBlock) from src to dst. The btctrl
+// value selects the beat size and address increments, trigger selects the
+// peripheral that paces the transfer.
+func (ch dmaChannel) start(src, dst uintptr, count uint16, btctrl uint16, trigger uint8) {
+	// With address increment enabled, the DMAC expects the end address of the
+	// block instead of the start address.
+	if btctrl&dmaBtctrlSRCINC != 0 {
+		src += uintptr(count)
+	}
+	if btctrl&dmaBtctrlDSTINC != 0 {
+		dst += uintptr(count)
+	}
+	desc := &dmaDescriptors[ch]
+	desc.btctrl = btctrl | dmaBtctrlVALID | dmaBtctrlBLOCKACTInt
+	desc.btcnt = count
+	desc.srcaddr = uint32(src)
+	desc.dstaddr = uint32(dst)
+	desc.descaddr = 0
+	regs := &dmac.channel[ch]
+	regs.chctrla.Set(uint32(trigger)<<dmacChctrlaTRIGSRCPos | dmacChctrlaTRIGACTBurst)
+	regs.chctrla.SetBits(dmacChctrlaENABLE)
+}
+
+// abort stops the transfer in progress, if any, without calling the callback.
+func (ch dmaChannel) abort() {
+	regs := &dmac.channel[ch]
+	regs.chctrla.ClearBits(dmacChctrlaENABLE)
+	for regs.chctrla.HasBits(dmacChctrlaENABLE) {
+	}
+	regs.chintflag.Set(dmacChintTCMPL | dmacChintTERR)
+}
+
+func dmaHandleInterrupt(interrupt.Interrupt) {
+	for ch := dmaChannel(0); ch < dmaChannels; ch++ {
+		regs := &dmac.channel[ch]
+		flags := regs.chintflag.Get() & (dmacChintTCMPL | dmacChintTERR)
+		if flags == 0 {
+			continue
+		}
+		regs.chintflag.Set(flags) // write 1 to clear
+		if dmaCallbacks[ch] == nil {
+			continue
+		}
+		var err error
+		if flags&dmacChintTERR != 0 {
+			err = errDMATransfer
+		}
+		dmaCallbacks[ch](err)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"device/sam"
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+var (
+	errFlashAddressError     = errors.New("flash address error")
+	errFlashProgrammingError = errors.New("flash programming error")
+	errFlashLockError        = errors.New("flash lock error")
+	errFlashNVMError         = errors.New("flash NVM error")
+)
+
+// WriteAt writes the given number of bytes to the flash data area.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	data, address, err := f.prepareWrite(p, off)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < len(data); i += 16 {
+		waitWhileFlashBusy()
+		nvmCommand(sam.NVMCTRL_CTRLB_CMD_PBC, address)
+		waitWhileFlashBusy()
+
+		// Fill the page buffer, which is mapped at the address of the page.
+		for j := 0; j < 16; j += 4 {
+			*(*uint32)(unsafe.Pointer(address + uintptr(j))) = binary.LittleEndian.Uint32(data[i+j : i+j+4])
+		}
+
+		nvmCommand(sam.NVMCTRL_CTRLB_CMD_WQW, address)
+		waitWhileFlashBusy()
+		if err := checkFlashError(); err != nil {
+			return 0, err
+		}
+		address += 16
+	}
+	return len(p), nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 128-bit quad-word.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 16
+}
+
+// EraseBlockSize returns the size of an erase block, which is 8kB.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return 8192
+}
+
+// EraseBlocks erases the given number of blocks.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	for i := int64(0); i < len; i++ {
+		waitWhileFlashBusy()
+		nvmCommand(sam.NVMCTRL_CTRLB_CMD_EB, address)
+		waitWhileFlashBusy()
+		if err := checkFlashError(); err != nil {
+			return err
+		}
+		address += uintptr(f.EraseBlockSize())
+	}
+	return nil
+}
+
+// nvmCommand executes the given NVM controller command on the given address.
+func nvmCommand(cmd uint16, address uintptr) {
+	// Clear errors of a previous command.
+	sam.NVMCTRL.INTFLAG.Set(sam.NVMCTRL_INTFLAG_ADDRE | sam.NVMCTRL_INTFLAG_PROGE | sam.NVMCTRL_INTFLAG_LOCKE | sam.NVMCTRL_INTFLAG_NVME)
+
+	sam.NVMCTRL.ADDR.Set(uint32(address))
+	sam.NVMCTRL.CTRLB.Set((cmd << sam.NVMCTRL_CTRLB_CMD_Pos) |
+		(sam.NVMCTRL_CTRLB_CMDEX_KEY << sam.NVMCTRL_CTRLB_CMDEX_Pos))
+}
+
+func waitWhileFlashBusy() {
+	for !sam.NVMCTRL.STATUS.HasBits(sam.NVMCTRL_STATUS_READY) {
+	}
+}
+
+func checkFlashError() error {
+	intflag := sam.NVMCTRL.INTFLAG.Get()
+	switch {
+	case intflag&sam.NVMCTRL_INTFLAG_ADDRE != 0:
+		return errFlashAddressError
+	case intflag&sam.NVMCTRL_INTFLAG_PROGE != 0:
+		return errFlashProgrammingError
+	case intflag&sam.NVMCTRL_INTFLAG_LOCKE != 0:
+		return errFlashLockError
+	case intflag&sam.NVMCTRL_INTFLAG_NVME != 0:
+		return errFlashNVMError
+	}
+	return nil
+}
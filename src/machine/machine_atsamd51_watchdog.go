@@ -0,0 +1,47 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd51 sam,atsame5x
+
+package machine
+
+import (
+	"device/sam"
+)
+
+// watchdogSetPeriod sets the watchdog period. The watchdog always runs from
+// the 1.024kHz output of OSCULP32K on these chips.
+func watchdogSetPeriod(period uint8) {
+	sam.MCLK.APBAMASK.SetBits(sam.MCLK_APBAMASK_WDT_)
+	sam.WDT.CONFIG.Set(period << sam.WDT_CONFIG_PER_Pos)
+}
+
+func watchdogStart() {
+	sam.WDT.CTRLA.SetBits(sam.WDT_CTRLA_ENABLE)
+	for sam.WDT.SYNCBUSY.HasBits(sam.WDT_SYNCBUSY_ENABLE) {
+	}
+}
+
+func watchdogUpdate() {
+	// 0xA5 is the magic value to clear the watchdog (see datasheet).
+	sam.WDT.CLEAR.Set(0xA5)
+	for sam.WDT.SYNCBUSY.HasBits(sam.WDT_SYNCBUSY_CLEAR) {
+	}
+}
+
+func resetReason() ResetCause {
+	rcause := sam.RSTC.RCAUSE.Get()
+	switch {
+	case rcause&sam.RSTC_RCAUSE_WDT != 0:
+		return ResetCauseWatchdog
+	case rcause&sam.RSTC_RCAUSE_SYST != 0:
+		return ResetCauseSoftware
+	case rcause&sam.RSTC_RCAUSE_EXT != 0:
+		return ResetCauseExternal
+	case rcause&sam.RSTC_RCAUSE_BACKUP != 0:
+		return ResetCauseWakeup
+	case rcause&(sam.RSTC_RCAUSE_BODCORE|sam.RSTC_RCAUSE_BODVDD) != 0:
+		return ResetCauseBrownout
+	case rcause&sam.RSTC_RCAUSE_POR != 0:
+		return ResetCausePowerOn
+	}
+	return ResetCauseUnknown
+}
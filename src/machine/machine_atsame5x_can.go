@@ -28,22 +28,20 @@ var CANTxFifo [2][(8 + 64) * CANTxFifoSize]byte
 //go:align 4
 var CANEvFifo [2][(8) * CANEvFifoSize]byte
 
+// The number of standard and of extended filters that can be set with
+// SetFilters.
+const canMaxFilters = 8
+
+//go:align 4
+var canStdFilters [2][canMaxFilters]uint32
+
+//go:align 4
+var canExtFilters [2][canMaxFilters * 2]uint32
+
 type CAN struct {
 	Bus *sam.CAN_Type
 }
 
-type CANTransferRate uint32
-
-// CAN transfer rates for CANConfig
-const (
-	CANTransferRate125kbps  CANTransferRate = 125000
-	CANTransferRate250kbps  CANTransferRate = 250000
-	CANTransferRate500kbps  CANTransferRate = 500000
-	CANTransferRate1000kbps CANTransferRate = 1000000
-	CANTransferRate2000kbps CANTransferRate = 2000000
-	CANTransferRate4000kbps CANTransferRate = 4000000
-)
-
 // CANConfig holds CAN configuration parameters. Tx and Rx need to be
 // specified with some pins. When the Standby Pin is specified, configure it
 // as an output pin and output Low in Configure(). If this operation is not
@@ -56,13 +54,12 @@ type CANConfig struct {
 	Standby        Pin
 }
 
-var (
-	errCANInvalidTransferRate   = errors.New("CAN: invalid TransferRate")
-	errCANInvalidTransferRateFD = errors.New("CAN: invalid TransferRateFD")
-)
+var errCANInvalidTransferRateFD = errors.New("CAN: invalid TransferRateFD")
 
 // Configure this CAN peripheral with the given configuration.
 func (can *CAN) Configure(config CANConfig) error {
+	standbyBlocked = true // runs from the main clock
+
 	if config.Standby != NoPin {
 		config.Standby.Configure(PinConfig{Mode: PinOutput})
 		config.Standby.Low()
@@ -321,27 +318,7 @@ func (can *CAN) RxRaw(e *CANRxBufferElement) {
 	idx := (can.Bus.RXF0S.Get() & sam.CAN_RXF0S_F0GI_Msk) >> sam.CAN_RXF0S_F0GI_Pos
 	f := CANRxFifo[can.instance()][idx*(8+64):]
 
-	e.ESI = false
-	if (f[3] & 0x80) != 0x00 {
-		e.ESI = true
-	}
-
-	e.XTD = false
-	if (f[3] & 0x40) != 0x00 {
-		e.XTD = true
-	}
-
-	e.RTR = false
-	if (f[3] & 0x20) != 0x00 {
-		e.RTR = true
-	}
-
-	id := ((uint32(f[3]) << 24) + (uint32(f[2]) << 16) + (uint32(f[1]) << 8) + uint32(f[0])) & 0x1FFFFFFF
-	if (f[3] & 0x20) == 0 {
-		id >>= 18
-		id &= 0x000007FF
-	}
-	e.ID = id
+	e.ID, e.XTD, e.RTR, e.ESI = canDecodeMCANR0(uint32(f[0]) | uint32(f[1])<<8 | uint32(f[2])<<16 | uint32(f[3])<<24)
 
 	e.ANMF = false
 	if (f[7] & 0x80) != 0x00 {
@@ -380,6 +357,136 @@ func (can *CAN) Rx() (id uint32, dlc byte, data []byte, isFd, isExtendedID bool)
 	return e.ID, length, e.DB[:length], e.FDF, e.XTD
 }
 
+// Send queues a frame for transmission, and returns ErrCANTxFull if the
+// transmit FIFO is full.
+func (can *CAN) Send(frame *CANFrame) error {
+	if !frame.valid() {
+		return errCANInvalidFrame
+	}
+	if can.TxFifoIsFull() {
+		return ErrCANTxFull
+	}
+	e := CANTxBufferElement{
+		XTD: frame.Extended,
+		RTR: frame.Remote,
+		ID:  frame.ID,
+		FDF: frame.FD,
+		BRS: frame.FD && frame.BRS,
+		DLC: CANLengthToDlc(frame.Length, frame.FD),
+	}
+	copy(e.DB[:], frame.Payload())
+	can.TxRaw(&e)
+	return nil
+}
+
+// Receive copies the next frame in the receive FIFO into frame. It returns
+// false if the FIFO is empty.
+func (can *CAN) Receive(frame *CANFrame) bool {
+	if can.RxFifoIsEmpty() {
+		return false
+	}
+	var e CANRxBufferElement
+	can.RxRaw(&e)
+	frame.ID = e.ID
+	frame.Extended = e.XTD
+	frame.Remote = e.RTR
+	frame.FD = e.FDF
+	frame.BRS = e.BRS
+	frame.Length = CANDlcToLength(e.DLC, e.FDF)
+	copy(frame.Data[:], e.DB[:frame.Length])
+	return true
+}
+
+var (
+	canRxHandlers [2]func(*CANFrame)
+	canRxFrames   [2]CANFrame
+)
+
+// SetReceiveHandler sets a function that is called from the CAN interrupt for
+// each received frame. A nil handler disables the interrupt, so that frames
+// can be read with Receive.
+func (can *CAN) SetReceiveHandler(handler func(frame *CANFrame)) {
+	canRxHandlers[can.instance()] = handler
+	if handler == nil {
+		can.SetInterrupt(sam.CAN_IE_RF0NE, nil)
+		return
+	}
+	can.SetInterrupt(sam.CAN_IE_RF0NE, func(can *CAN) {
+		i := can.instance()
+		for can.Receive(&canRxFrames[i]) {
+			canRxHandlers[i](&canRxFrames[i])
+		}
+	})
+}
+
+// SetFilters sets the frames to receive into the receive FIFO, using up to 8
+// standard and 8 extended filters. With no filters, all frames are received.
+// It must be called after Configure.
+func (can *CAN) SetFilters(filters []CANFilter) error {
+	std, ext := uint32(0), uint32(0)
+	for _, f := range filters {
+		if f.Extended {
+			ext++
+		} else {
+			std++
+		}
+	}
+	if std > canMaxFilters || ext > canMaxFilters {
+		return errCANTooManyFilters
+	}
+
+	can.Bus.CCCR.SetBits(sam.CAN_CCCR_INIT)
+	for !can.Bus.CCCR.HasBits(sam.CAN_CCCR_INIT) {
+	}
+	can.Bus.CCCR.SetBits(sam.CAN_CCCR_CCE)
+
+	i := can.instance()
+	std, ext = 0, 0
+	for _, f := range filters {
+		// Classic filters (ID and mask) that store matching frames in FIFO 0.
+		if f.Extended {
+			canExtFilters[i][ext*2] = 1<<29 | f.ID&0x1fffffff
+			canExtFilters[i][ext*2+1] = 2<<30 | f.Mask&0x1fffffff
+			ext++
+		} else {
+			canStdFilters[i][std] = 2<<30 | 1<<27 | (f.ID&0x7ff)<<16 | f.Mask&0x7ff
+			std++
+		}
+	}
+	can.Bus.SIDFC.Set(std<<sam.CAN_SIDFC_LSS_Pos | uint32(uintptr(unsafe.Pointer(&canStdFilters[i][0])))&0xFFFF)
+	can.Bus.XIDFC.Set(ext<<sam.CAN_XIDFC_LSE_Pos | uint32(uintptr(unsafe.Pointer(&canExtFilters[i][0])))&0xFFFF)
+	if len(filters) == 0 {
+		// Accept all frames.
+		can.Bus.GFC.Set(0<<sam.CAN_GFC_ANFS_Pos | 0<<sam.CAN_GFC_ANFE_Pos)
+	} else {
+		// Reject frames that don't match a filter.
+		can.Bus.GFC.Set(2<<sam.CAN_GFC_ANFS_Pos | 2<<sam.CAN_GFC_ANFE_Pos)
+	}
+
+	can.Bus.CCCR.ClearBits(sam.CAN_CCCR_CCE)
+	can.Bus.CCCR.ClearBits(sam.CAN_CCCR_INIT)
+	for can.Bus.CCCR.HasBits(sam.CAN_CCCR_INIT) {
+	}
+	return nil
+}
+
+// Status returns the error state and error counters of the controller.
+func (can *CAN) Status() CANStatus {
+	ecr := can.Bus.ECR.Get()
+	psr := can.Bus.PSR.Get()
+	status := CANStatus{
+		TxErrors: uint8((ecr & sam.CAN_ECR_TEC_Msk) >> sam.CAN_ECR_TEC_Pos),
+		RxErrors: uint8((ecr & sam.CAN_ECR_REC_Msk) >> sam.CAN_ECR_REC_Pos),
+	}
+	switch {
+	case psr&sam.CAN_PSR_BO != 0:
+		status.State = CANBusOff
+	case psr&sam.CAN_PSR_EP != 0:
+		status.State = CANErrorPassive
+	}
+	return status
+}
+
 func (can *CAN) instance() byte {
 	if can.Bus == sam.CAN0 {
 		return 0
@@ -423,47 +530,3 @@ type CANRxBufferElement struct {
 func (e CANRxBufferElement) Data() []byte {
 	return e.DB[:CANDlcToLength(e.DLC, e.FDF)]
 }
-
-// CANDlcToLength() converts a DLC value to its actual length.
-func CANDlcToLength(dlc byte, isFD bool) byte {
-	length := dlc
-	if dlc == 0x09 {
-		length = 12
-	} else if dlc == 0x0A {
-		length = 16
-	} else if dlc == 0x0B {
-		length = 20
-	} else if dlc == 0x0C {
-		length = 24
-	} else if dlc == 0x0D {
-		length = 32
-	} else if dlc == 0x0E {
-		length = 48
-	} else if dlc == 0x0F {
-		length = 64
-	}
-	return length
-
-}
-
-// CANLengthToDlc() converts its actual length to a DLC value.
-func CANLengthToDlc(length byte, isFD bool) byte {
-	dlc := length
-	if length <= 0x08 {
-	} else if length <= 12 {
-		dlc = 0x09
-	} else if length <= 16 {
-		dlc = 0x0A
-	} else if length <= 20 {
-		dlc = 0x0B
-	} else if length <= 24 {
-		dlc = 0x0C
-	} else if length <= 32 {
-		dlc = 0x0D
-	} else if length <= 48 {
-		dlc = 0x0E
-	} else if length <= 64 {
-		dlc = 0x0F
-	}
-	return dlc
-}
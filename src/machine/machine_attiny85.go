@@ -22,3 +22,27 @@ func (p Pin) getPortMask() (*volatile.Register8, uint8) {
 	// Very simple for the attiny85, which only has a single port.
 	return avr.PORTB, 1 << uint8(p)
 }
+
+// powerDownWDT enters power-down mode until the watchdog interrupt fires after
+// the given period (0=16ms, 1=32ms, ..., 9=8s).
+func powerDownWDT(period uint8) {
+	avr.Asm("cli")
+	avr.Asm("wdr")
+	// Start the timed sequence, and set the watchdog to only interrupt.
+	avr.WDTCR.SetBits(avr.WDTCR_WDCE | avr.WDTCR_WDE)
+	avr.WDTCR.Set(avr.WDTCR_WDIE | period&7 | (period&8)<<2)
+
+	// Enable power-down mode (SM=10). The instruction after sei is executed
+	// before any interrupt, so the wakeup can't be missed.
+	avr.MCUCR.ReplaceBits((2<<3)|avr.MCUCR_SE, 0x38, 0)
+	avr.Asm("sei")
+	avr.Asm("sleep")
+	avr.MCUCR.ClearBits(0x38)
+
+	// Stop the watchdog.
+	avr.Asm("cli")
+	avr.MCUSR.ClearBits(avr.MCUSR_WDRF)
+	avr.WDTCR.SetBits(avr.WDTCR_WDCE | avr.WDTCR_WDE)
+	avr.WDTCR.Set(0)
+	avr.Asm("sei")
+}
@@ -149,3 +149,6 @@ func adjustMonotonicTimer()
 
 // linked from runtime.initMonotonicTimer
 func initMonotonicTimer()
+
+// linked from runtime.advanceMonotonicTimer
+func advanceMonotonicTimer(ns int64)
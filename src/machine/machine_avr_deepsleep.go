@@ -0,0 +1,55 @@
+//go:build avr
+// +build avr
+
+package machine
+
+import (
+	"device/avr"
+	"runtime/interrupt"
+)
+
+// Number of watchdog periods: 16ms, 32ms, ..., 8s.
+const wdtPeriods = 10
+
+// configureWake configures the pin as an input. There is no pull-down
+// resistor, so a pin that wakes at a high level needs an external one.
+func (p Pin) configureWake(level bool) error {
+	mode := PinInputPullup
+	if level {
+		mode = PinInput
+	}
+	p.Configure(PinConfig{Mode: mode})
+	return nil
+}
+
+// disableWake does nothing, as there is no pin interrupt to disable: deepSleep
+// checks the wake pins every time the watchdog wakes up the chip.
+func (p Pin) disableWake() {}
+
+// wdtPeriod returns the watchdog period in nanoseconds.
+func wdtPeriod(period uint8) int64 {
+	return 16e6 << period
+}
+
+func deepSleep(duration int64, wakeSources []WakeSource) {
+	// The watchdog interrupt only wakes up the CPU.
+	interrupt.New(avr.IRQ_WDT, func(interrupt.Interrupt) {})
+
+	for !woken(wakeSources) {
+		if duration > 0 && duration < wdtPeriod(0)/2 {
+			break
+		}
+		// Sleep as long as possible, but check the wake pins every period.
+		period := uint8(0)
+		if len(wakeSources) == 0 {
+			for period+1 < wdtPeriods && wdtPeriod(period+1) <= duration {
+				period++
+			}
+		}
+		powerDownWDT(period)
+
+		// Timer 0, which keeps time for the runtime, stops in power-down.
+		advanceMonotonicTimer(wdtPeriod(period))
+		duration -= wdtPeriod(period)
+	}
+}
@@ -0,0 +1,172 @@
+//go:build cortexm && qemu
+// +build cortexm,qemu
+
+package machine
+
+// This file implements the GPIO pins of the Stellaris LM3S6965, as emulated by
+// QEMU (machine lm3s6965evb).
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+const deviceName = "LM3S6965"
+
+// System control registers.
+var (
+	sysctlRESC  = (*volatile.Register32)(unsafe.Pointer(uintptr(0x400FE05C)))
+	sysctlRCGC0 = (*volatile.Register32)(unsafe.Pointer(uintptr(0x400FE100)))
+	sysctlRCGC2 = (*volatile.Register32)(unsafe.Pointer(uintptr(0x400FE108)))
+)
+
+const (
+	PinInput PinMode = iota
+	PinOutput
+	PinInputPullup
+	PinInputPulldown
+)
+
+// Pins are numbered per port, eight pins per port: PA0 is pin 0, PB0 is pin 8,
+// etc. up to PG7.
+const (
+	PA0 Pin = 8*0 + iota
+	PA1
+	PA2
+	PA3
+	PA4
+	PA5
+	PA6
+	PA7
+)
+
+const (
+	PB0 Pin = 8*1 + iota
+	PB1
+	PB2
+	PB3
+	PB4
+	PB5
+	PB6
+	PB7
+)
+
+const (
+	PC0 Pin = 8*2 + iota
+	PC1
+	PC2
+	PC3
+	PC4
+	PC5
+	PC6
+	PC7
+)
+
+const (
+	PD0 Pin = 8*3 + iota
+	PD1
+	PD2
+	PD3
+	PD4
+	PD5
+	PD6
+	PD7
+)
+
+const (
+	PE0 Pin = 8*4 + iota
+	PE1
+	PE2
+	PE3
+	PE4
+	PE5
+	PE6
+	PE7
+)
+
+const (
+	PF0 Pin = 8*5 + iota
+	PF1
+	PF2
+	PF3
+	PF4
+	PF5
+	PF6
+	PF7
+)
+
+const (
+	PG0 Pin = 8*6 + iota
+	PG1
+	PG2
+	PG3
+	PG4
+	PG5
+	PG6
+	PG7
+)
+
+// gpioPortType is the register layout of a GPIO port.
+type gpioPortType struct {
+	data [256]volatile.Register32 // 0x000: masked data, address bits 9:2 select the pins
+	dir  volatile.Register32      // 0x400
+	_    [67]volatile.Register32
+	pur  volatile.Register32 // 0x510
+	pdr  volatile.Register32 // 0x514
+	_    [1]volatile.Register32
+	den  volatile.Register32 // 0x51C
+}
+
+var gpioPortAddresses = [...]uintptr{
+	0x40004000, // GPIOA
+	0x40005000, // GPIOB
+	0x40006000, // GPIOC
+	0x40007000, // GPIOD
+	0x40024000, // GPIOE
+	0x40025000, // GPIOF
+	0x40026000, // GPIOG
+}
+
+// getPort returns the GPIO port of this pin and the bit mask of the pin.
+func (p Pin) getPort() (*gpioPortType, uint32) {
+	return (*gpioPortType)(unsafe.Pointer(gpioPortAddresses[p/8])), 1 << (p % 8)
+}
+
+// Configure this pin with the given configuration.
+func (p Pin) Configure(config PinConfig) {
+	sysctlRCGC2.SetBits(1 << (p / 8))
+	port, mask := p.getPort()
+	port.den.SetBits(mask)
+	port.pur.ClearBits(mask)
+	port.pdr.ClearBits(mask)
+	switch config.Mode {
+	case PinOutput:
+		port.dir.SetBits(mask)
+	case PinInputPullup:
+		port.dir.ClearBits(mask)
+		port.pur.SetBits(mask)
+	case PinInputPulldown:
+		port.dir.ClearBits(mask)
+		port.pdr.SetBits(mask)
+	default:
+		port.dir.ClearBits(mask)
+	}
+}
+
+// Set the pin to high or low.
+func (p Pin) Set(high bool) {
+	port, mask := p.getPort()
+	// The address of the data register masks the bits that are written.
+	if high {
+		port.data[mask].Set(mask)
+	} else {
+		port.data[mask].Set(0)
+	}
+}
+
+// Get returns the current value of a GPIO pin when the pin is configured as an
+// input or as an output.
+func (p Pin) Get() bool {
+	port, mask := p.getPort()
+	return port.data[mask].Get() != 0
+}
@@ -0,0 +1,76 @@
+//go:build cortexm && qemu
+// +build cortexm,qemu
+
+package machine
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+// The watchdog is the one of the LM3S6965, which QEMU emulates. This makes it
+// possible to test watchdog handling in QEMU.
+
+// The watchdog runs from the system clock, which runs at 12.5MHz after reset
+// in QEMU.
+const watchdogFrequency = 12_500_000
+
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds. The watchdog must expire twice before resetting the chip, so
+// this is twice the time it takes for the 32-bit counter to count down.
+const WatchdogMaxTimeout = 0xffffffff * 2 / (watchdogFrequency / 1000)
+
+type watchdogRegs struct {
+	load  volatile.Register32 // 0x000
+	value volatile.Register32 // 0x004
+	ctl   volatile.Register32 // 0x008
+	icr   volatile.Register32 // 0x00C
+	ris   volatile.Register32 // 0x010
+	_     [763]volatile.Register32
+	lock  volatile.Register32 // 0xC00
+}
+
+var watchdogDevice = (*watchdogRegs)(unsafe.Pointer(uintptr(0x40000000)))
+
+const (
+	watchdogCtlINTEN  = 1 << 0 // enable the counter and interrupt
+	watchdogCtlRESEN  = 1 << 1 // reset on the second timeout
+	watchdogUnlockKey = 0x1ACCE551
+)
+
+func watchdogConfigure(timeoutMillis uint32) {
+	// The first timeout raises an interrupt (which is not enabled), the
+	// second one resets the chip. So count down for half the timeout.
+	load := uint64(timeoutMillis) * (watchdogFrequency / 1000) / 2
+
+	sysctlRCGC0.SetBits(1 << 3) // WDT clock
+	watchdogDevice.lock.Set(watchdogUnlockKey)
+	watchdogDevice.load.Set(uint32(load))
+}
+
+func watchdogStart() {
+	watchdogDevice.lock.Set(watchdogUnlockKey)
+	watchdogDevice.ctl.Set(watchdogCtlINTEN | watchdogCtlRESEN)
+}
+
+func watchdogUpdate() {
+	// Writing any value to the interrupt clear register reloads the counter.
+	watchdogDevice.icr.Set(0)
+}
+
+func resetReason() ResetCause {
+	resc := sysctlRESC.Get()
+	switch {
+	case resc&(1<<3) != 0:
+		return ResetCauseWatchdog
+	case resc&(1<<4) != 0:
+		return ResetCauseSoftware
+	case resc&(1<<2) != 0:
+		return ResetCauseBrownout
+	case resc&(1<<1) != 0:
+		return ResetCausePowerOn
+	case resc&(1<<0) != 0:
+		return ResetCauseExternal
+	}
+	return ResetCauseUnknown
+}
@@ -3,10 +3,18 @@
 
 package machine
 
+import "errors"
+
 // Dummy machine package that calls out to external functions.
+//
+// These functions (prefixed with __tinygo_) are normally implemented by the
+// environment, for example by JavaScript in a simulator. On Linux and other
+// hosted systems they can be implemented by importing the machine/sim package.
 
 const deviceName = "generic"
 
+var errUARTBufferEmpty = errors.New("UART buffer empty")
+
 var (
 	UART0 = &UART{0}
 	USB   = &UART{100}
@@ -46,6 +54,56 @@ func gpioSet(pin Pin, value bool)
 //export __tinygo_gpio_get
 func gpioGet(pin Pin) bool
 
+// PinChange is the type of pin change that triggers an interrupt.
+type PinChange uint8
+
+// Pin change interrupt constants for SetInterrupt.
+const (
+	PinRising PinChange = 1 << iota
+	PinFalling
+	PinToggle = PinRising | PinFalling
+)
+
+// pinInterrupt is a pin change interrupt registered with SetInterrupt.
+type pinInterrupt struct {
+	change   PinChange
+	callback func(Pin)
+}
+
+var pinInterrupts map[Pin]pinInterrupt
+
+// SetInterrupt sets an interrupt to be executed when a particular pin changes
+// state. The environment reports pin changes by calling the exported
+// __tinygo_gpio_interrupt function.
+//
+// You can pass a nil func to unset the pin change interrupt. If you do so,
+// the change parameter is ignored and can be set to any value (such as 0).
+func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+	if callback == nil {
+		delete(pinInterrupts, p)
+		return nil
+	}
+	if pinInterrupts == nil {
+		pinInterrupts = make(map[Pin]pinInterrupt)
+	}
+	pinInterrupts[p] = pinInterrupt{change, callback}
+	return nil
+}
+
+// gpioInterrupt is called by the environment when the input level of a pin
+// changed, to run the interrupt callback set with SetInterrupt.
+//
+//export __tinygo_gpio_interrupt
+func gpioInterrupt(pin Pin, level bool) {
+	intr, ok := pinInterrupts[pin]
+	if !ok {
+		return
+	}
+	if (level && intr.change&PinRising != 0) || (!level && intr.change&PinFalling != 0) {
+		intr.callback(pin)
+	}
+}
+
 type SPI struct {
 	Bus uint8
 }
@@ -100,26 +158,82 @@ type I2CConfig struct {
 	Frequency uint32
 	SCL       Pin
 	SDA       Pin
+	Mode      I2CMode
+	Address   uint16 // target address, when Mode is I2CModeTarget
 }
 
 // Configure is intended to setup the I2C interface.
 func (i2c *I2C) Configure(config I2CConfig) error {
+	if config.Mode == I2CModeTarget {
+		i2cTargetConfigure(i2c.Bus, config.Address)
+		return nil
+	}
 	i2cConfigure(i2c.Bus, config.SCL, config.SDA)
 	return nil
 }
 
+var i2cTargetHandlers map[uint8]I2CTargetHandler
+
+// SetTargetHandler sets the handler for the events of this bus in target mode.
+// The environment reports those events by calling the exported
+// __tinygo_i2c_target_event function.
+func (i2c *I2C) SetTargetHandler(handler I2CTargetHandler) {
+	if i2cTargetHandlers == nil {
+		i2cTargetHandlers = make(map[uint8]I2CTargetHandler)
+	}
+	i2cTargetHandlers[i2c.Bus] = handler
+}
+
+// i2cTargetEvent is called by the environment for each event on a bus in
+// target mode, to run the handler set with SetTargetHandler.
+//
+//export __tinygo_i2c_target_event
+func i2cTargetEvent(bus uint8, event I2CTargetEvent, b byte) byte {
+	handler := i2cTargetHandlers[bus]
+	if handler == nil {
+		return 0xff
+	}
+	return handler(event, b)
+}
+
 // Tx does a single I2C transaction at the specified address.
 func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
-	i2cTransfer(i2c.Bus, &w[0], len(w), &r[0], len(r))
-	// TODO: do something with the returned error code.
-	return nil
+	switch i2cTransfer(i2c.Bus, addr, bufferPointer(w), len(w), bufferPointer(r), len(r)) {
+	case 0:
+		return nil
+	case 1:
+		// No device responded at this address.
+		return errI2CAckExpected
+	default:
+		return errI2CBusError
+	}
 }
 
 //export __tinygo_i2c_configure
 func i2cConfigure(bus uint8, scl Pin, sda Pin)
 
-//export __tinygo_i2c_transfer
-func i2cTransfer(bus uint8, w *byte, wlen int, r *byte, rlen int) int
+//export __tinygo_i2c_target_configure
+func i2cTargetConfigure(bus uint8, addr uint16)
+
+// i2cTransfer returns 0 on success, 1 when there is no device at the given
+// address and another value for other errors.
+//
+// This hook replaces __tinygo_i2c_transfer, which didn't have the addr
+// parameter. It has a different name so that an environment that only
+// implements the old hook fails to link, instead of being called with the
+// wrong parameters.
+//
+//export __tinygo_i2c_tx
+func i2cTransfer(bus uint8, addr uint16, w *byte, wlen int, r *byte, rlen int) int
+
+// bufferPointer returns a pointer to the first byte of buf, or nil if buf is
+// empty.
+func bufferPointer(buf []byte) *byte {
+	if len(buf) == 0 {
+		return nil
+	}
+	return &buf[0]
+}
 
 type UART struct {
 	Bus uint8
@@ -132,12 +246,12 @@ func (uart *UART) Configure(config UARTConfig) {
 
 // Read from the UART.
 func (uart *UART) Read(data []byte) (n int, err error) {
-	return uartRead(uart.Bus, &data[0], len(data)), nil
+	return uartRead(uart.Bus, bufferPointer(data), len(data)), nil
 }
 
 // Write to the UART.
 func (uart *UART) Write(data []byte) (n int, err error) {
-	return uartWrite(uart.Bus, &data[0], len(data)), nil
+	return uartWrite(uart.Bus, bufferPointer(data), len(data)), nil
 }
 
 // Buffered returns the number of bytes currently stored in the RX buffer.
@@ -148,7 +262,9 @@ func (uart *UART) Buffered() int {
 // ReadByte reads a single byte from the UART.
 func (uart *UART) ReadByte() (byte, error) {
 	var b byte
-	uartRead(uart.Bus, &b, 1)
+	if uartRead(uart.Bus, &b, 1) == 0 {
+		return 0, errUARTBufferEmpty
+	}
 	return b, nil
 }
 
@@ -167,6 +283,101 @@ func uartRead(bus uint8, buf *byte, bufLen int) int
 //export __tinygo_uart_write
 func uartWrite(bus uint8, buf *byte, bufLen int) int
 
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds. What happens when the watchdog expires is up to the
+// environment.
+const WatchdogMaxTimeout = 0xffffffff
+
+func resetReason() ResetCause {
+	return ResetCause(readResetReason())
+}
+
+//export __tinygo_watchdog_configure
+func watchdogConfigure(timeoutMillis uint32)
+
+//export __tinygo_watchdog_start
+func watchdogStart()
+
+//export __tinygo_watchdog_update
+func watchdogUpdate()
+
+//export __tinygo_reset_reason
+func readResetReason() uint8
+
+// Flash is an in-memory fake of on-chip flash, for testing code that stores
+// data in flash. It behaves like NOR flash: erasing sets all bytes to 0xff and
+// writing can only clear bits, so that forgetting to erase is noticed.
+var Flash flashBlockDevice
+
+type flashBlockDevice struct{}
+
+const (
+	flashSize           = 64 * 1024
+	flashWriteBlockSize = 4
+	flashEraseBlockSize = 4096
+)
+
+// The contents of the fake flash. Allocated on first use.
+var flashData []byte
+
+func (f flashBlockDevice) data() []byte {
+	if flashData == nil {
+		flashData = make([]byte, flashSize)
+		for i := range flashData {
+			flashData[i] = 0xff
+		}
+	}
+	return flashData
+}
+
+// ReadAt reads the given number of bytes from the flash.
+func (f flashBlockDevice) ReadAt(p []byte, off int64) (n int, err error) {
+	if err := checkFlashRange(off, int64(len(p)), f.Size(), errFlashCannotReadPastEOF); err != nil {
+		return 0, err
+	}
+	return copy(p, f.data()[off:]), nil
+}
+
+// WriteAt writes the given number of bytes to the flash.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	if err := checkFlashRange(off, int64(len(p)), f.Size(), errFlashCannotWritePastEOF); err != nil {
+		return 0, err
+	}
+	data := f.data()[off:]
+	for i, b := range p {
+		data[i] &= b
+	}
+	return len(p), nil
+}
+
+// Size returns the size of the fake flash.
+func (f flashBlockDevice) Size() int64 {
+	return flashSize
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return flashWriteBlockSize
+}
+
+// EraseBlockSize returns the size of an erase block.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return flashEraseBlockSize
+}
+
+// EraseBlocks erases the given number of blocks.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	if err := checkFlashRange(start*flashEraseBlockSize, len*flashEraseBlockSize, f.Size(), errFlashCannotErasePastEOF); err != nil {
+		return err
+	}
+	data := f.data()[start*flashEraseBlockSize : (start+len)*flashEraseBlockSize]
+	for i := range data {
+		data[i] = 0xff
+	}
+	return nil
+}
+
 // Some objects used by Atmel SAM D chips (samd21, samd51).
 // Defined here (without build tag) for convenience.
 var (
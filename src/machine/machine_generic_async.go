@@ -0,0 +1,101 @@
+//go:build !baremetal
+// +build !baremetal
+
+package machine
+
+import "runtime/interrupt"
+
+// Asynchronous transfers in the generic machine package. The data is
+// transferred immediately using the same hooks as the blocking calls, but the
+// transfer is only marked as completed from another goroutine. This mimics the
+// DMA-complete interrupt of real hardware so that code using TxAsync and Wait
+// can be tested on the host.
+
+// asyncTransfers holds the asynchronous transfer state of each bus of one kind.
+type asyncTransfers map[uint8]*asyncTransfer
+
+var (
+	spiAsync  = asyncTransfers{}
+	i2cAsync  = asyncTransfers{}
+	uartAsync = asyncTransfers{}
+)
+
+// get returns the transfer state of the given bus.
+func (m asyncTransfers) get(bus uint8) *asyncTransfer {
+	mask := interrupt.Disable()
+	t := m[bus]
+	if t == nil {
+		t = &asyncTransfer{}
+		m[bus] = t
+	}
+	interrupt.Restore(mask)
+	return t
+}
+
+// TxAsync starts a SPI transfer in the background. The w and r buffers are
+// used in the same way as in Tx. Call Wait to wait for the transfer to finish.
+func (spi SPI) TxAsync(w, r []byte) error {
+	if w != nil && r != nil && len(w) != len(r) {
+		return ErrTxInvalidSliceSize
+	}
+	t := spiAsync.get(spi.Bus)
+	if err := t.begin(w, r); err != nil {
+		return err
+	}
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		var b byte
+		if w != nil {
+			b = w[i]
+		}
+		b = spiTransfer(spi.Bus, b)
+		if r != nil {
+			r[i] = b
+		}
+	}
+	t.completeLater(nil)
+	return nil
+}
+
+// Wait waits until the transfer started with TxAsync has finished and returns
+// its result.
+func (spi SPI) Wait() error {
+	return spiAsync.get(spi.Bus).wait()
+}
+
+// TxAsync starts an I2C transaction in the background, see Tx. Call Wait to
+// wait for the transaction to finish.
+func (i2c *I2C) TxAsync(addr uint16, w, r []byte) error {
+	t := i2cAsync.get(i2c.Bus)
+	if err := t.begin(w, r); err != nil {
+		return err
+	}
+	t.completeLater(i2c.Tx(addr, w, r))
+	return nil
+}
+
+// Wait waits until the transaction started with TxAsync has finished and
+// returns its result.
+func (i2c *I2C) Wait() error {
+	return i2cAsync.get(i2c.Bus).wait()
+}
+
+// TxAsync starts writing data to the UART in the background. Call Wait to wait
+// until all data has been sent.
+func (uart *UART) TxAsync(data []byte) error {
+	t := uartAsync.get(uart.Bus)
+	if err := t.begin(data, nil); err != nil {
+		return err
+	}
+	uartWrite(uart.Bus, bufferPointer(data), len(data))
+	t.completeLater(nil)
+	return nil
+}
+
+// Wait waits until the data passed to TxAsync has been sent.
+func (uart *UART) Wait() error {
+	return uartAsync.get(uart.Bus).wait()
+}
@@ -0,0 +1,294 @@
+//go:build linux && !baremetal
+// +build linux,!baremetal
+
+package machine
+
+// CAN bus support on Linux, using a SocketCAN raw socket.
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// CAN is a SocketCAN network interface, such as can0 or a virtual vcan0.
+type CAN struct {
+	name      string
+	fd        int
+	status    CANStatus
+	handler   atomic.Value // func(*CANFrame)
+	receiving uint32       // nonzero while the receive goroutine runs
+}
+
+var (
+	CAN0 = CAN{name: "can0", fd: -1}
+	CAN1 = CAN{name: "can1", fd: -1}
+)
+
+// CANConfig holds CAN configuration parameters. The bit rate of a SocketCAN
+// interface is set when it is brought up (ip link set can0 type can bitrate
+// 500000), so TransferRate is ignored.
+type CANConfig struct {
+	Interface    string // network interface name, overrides the default
+	TransferRate CANTransferRate
+}
+
+const (
+	afCAN           = 29
+	canRaw          = 1
+	solCANRaw       = 100 + canRaw
+	canRawFilter    = 1
+	canRawErrFilter = 2
+	canRawFDFrames  = 5
+	siocGIFINDEX    = 0x8933
+
+	canEFFFlag = 0x80000000
+	canRTRFlag = 0x40000000
+	canERRFlag = 0x20000000
+	canEFFMask = 0x1fffffff
+	canSFFMask = 0x000007ff
+
+	canFDBRS = 0x01
+	canFDFDF = 0x04
+
+	canMTU   = 16 // sizeof(struct can_frame)
+	canFDMTU = 72 // sizeof(struct canfd_frame)
+
+	// Error frame classes in the ID and controller status bits in data[1].
+	canErrCrtl          = 0x00000004
+	canErrBusOff        = 0x00000040
+	canErrRestarted     = 0x00000100
+	canErrCnt           = 0x00000200
+	canErrCrtlRxPassive = 0x10
+	canErrCrtlTxPassive = 0x20
+	canErrCrtlActive    = 0x40
+)
+
+// Configure opens a raw socket on the interface, receiving all frames.
+func (can *CAN) Configure(config CANConfig) error {
+	if config.Interface != "" {
+		can.name = config.Interface
+	}
+	if can.fd >= 0 {
+		syscall.Close(can.fd)
+		can.fd = -1
+	}
+
+	// The socket is blocking, for the receive goroutine. Send and Receive
+	// use MSG_DONTWAIT instead.
+	fd, err := syscall.Socket(afCAN, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, canRaw)
+	if err != nil {
+		return err
+	}
+
+	// struct ifreq, to look up the interface index.
+	var ifreq struct {
+		name  [16]byte
+		index int32
+		_     [20]byte
+	}
+	copy(ifreq.name[:len(ifreq.name)-1], can.name)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocGIFINDEX, uintptr(unsafe.Pointer(&ifreq))); errno != 0 {
+		syscall.Close(fd)
+		return errno
+	}
+
+	// struct sockaddr_can
+	var addr struct {
+		family  uint16
+		_       uint16
+		ifindex int32
+		_       [16]byte
+	}
+	addr.family = afCAN
+	addr.ifindex = ifreq.index
+	if _, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&addr)), unsafe.Sizeof(addr)); errno != 0 {
+		syscall.Close(fd)
+		return errno
+	}
+
+	// Receive CAN FD frames too. This fails on interfaces that only support
+	// classic CAN, which is fine.
+	syscall.SetsockoptInt(fd, solCANRaw, canRawFDFrames, 1)
+
+	// Receive error frames, to keep track of the controller state.
+	syscall.SetsockoptInt(fd, solCANRaw, canRawErrFilter, canErrCrtl|canErrBusOff|canErrRestarted|canErrCnt)
+
+	can.fd = fd
+	can.status = CANStatus{}
+	return nil
+}
+
+// Send writes a frame to the socket, and returns ErrCANTxFull if the
+// transmit queue of the interface is full.
+func (can *CAN) Send(frame *CANFrame) error {
+	if !frame.valid() {
+		return errCANInvalidFrame
+	}
+	var buf [canFDMTU]byte
+	id := frame.ID
+	if frame.Extended {
+		id |= canEFFFlag
+	}
+	if frame.Remote {
+		id |= canRTRFlag
+	}
+	*(*uint32)(unsafe.Pointer(&buf[0])) = id
+	buf[4] = frame.Length
+	size := canMTU
+	if frame.FD {
+		buf[5] = canFDFDF
+		if frame.BRS {
+			buf[5] |= canFDBRS
+		}
+		size = canFDMTU
+	}
+	copy(buf[8:], frame.Payload())
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_SENDTO, uintptr(can.fd), uintptr(unsafe.Pointer(&buf[0])), uintptr(size), syscall.MSG_DONTWAIT, 0, 0)
+	switch errno {
+	case 0:
+		return nil
+	case syscall.EAGAIN, syscall.ENOBUFS:
+		return ErrCANTxFull
+	default:
+		return errno
+	}
+}
+
+// Receive reads the next frame from the socket into frame. It returns false if
+// no frame is waiting.
+func (can *CAN) Receive(frame *CANFrame) bool {
+	return can.receive(frame, syscall.MSG_DONTWAIT)
+}
+
+// receive reads the next frame from the socket, with the given recv flags.
+// Without MSG_DONTWAIT, it blocks until a frame arrives.
+func (can *CAN) receive(frame *CANFrame, flags uintptr) bool {
+	var buf [canFDMTU]byte
+	for {
+		r, _, errno := syscall.Syscall6(syscall.SYS_RECVFROM, uintptr(can.fd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), flags, 0, 0)
+		n := int(r)
+		if errno != 0 || (n != canMTU && n != canFDMTU) {
+			return false
+		}
+		id := *(*uint32)(unsafe.Pointer(&buf[0]))
+		if id&canERRFlag != 0 {
+			can.handleErrorFrame(id, buf[8:16])
+			continue
+		}
+
+		frame.Extended = id&canEFFFlag != 0
+		frame.Remote = id&canRTRFlag != 0
+		if frame.Extended {
+			frame.ID = id & canEFFMask
+		} else {
+			frame.ID = id & canSFFMask
+		}
+		frame.FD = n == canFDMTU
+		frame.BRS = frame.FD && buf[5]&canFDBRS != 0
+		frame.Length = buf[4]
+		if max := uint8(n - 8); frame.Length > max {
+			frame.Length = max
+		}
+		copy(frame.Data[:], buf[8:8+frame.Length])
+		return true
+	}
+}
+
+func (can *CAN) handleErrorFrame(id uint32, data []byte) {
+	if id&canErrCnt != 0 {
+		can.status.TxErrors = data[6]
+		can.status.RxErrors = data[7]
+	}
+	switch {
+	case id&canErrBusOff != 0:
+		can.status.State = CANBusOff
+	case id&canErrRestarted != 0:
+		can.status.State = CANErrorActive
+	case id&canErrCrtl != 0:
+		if data[1]&(canErrCrtlRxPassive|canErrCrtlTxPassive) != 0 {
+			can.status.State = CANErrorPassive
+		} else if data[1]&canErrCrtlActive != 0 {
+			can.status.State = CANErrorActive
+		}
+	}
+}
+
+// SetReceiveHandler sets a function that is called for each received frame,
+// from a goroutine that waits for frames on the socket. With the threads
+// scheduler this goroutine blocks in recv. The other schedulers run all
+// goroutines on a single thread, so there it checks the socket every
+// millisecond instead. A nil handler stops the goroutine once the next frame
+// arrives (which is dropped), so that frames can be read with Receive.
+func (can *CAN) SetReceiveHandler(handler func(frame *CANFrame)) {
+	can.handler.Store(handler)
+	if handler == nil || !atomic.CompareAndSwapUint32(&can.receiving, 0, 1) {
+		return
+	}
+	go can.receiveLoop()
+}
+
+func (can *CAN) receiveLoop() {
+	var frame CANFrame
+	for {
+		var ok bool
+		if canBlockingReceive {
+			ok = can.receive(&frame, 0)
+		} else {
+			ok = can.Receive(&frame)
+		}
+		handler, _ := can.handler.Load().(func(*CANFrame))
+		if handler == nil {
+			atomic.StoreUint32(&can.receiving, 0)
+			// Keep running if a new handler was set in the meantime.
+			handler, _ = can.handler.Load().(func(*CANFrame))
+			if handler == nil || !atomic.CompareAndSwapUint32(&can.receiving, 0, 1) {
+				return
+			}
+			continue
+		}
+		if ok {
+			handler(&frame)
+		} else {
+			// No frame waiting, or recv was interrupted.
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// SetFilters sets the frames the socket receives. With no filters, all frames
+// are received.
+func (can *CAN) SetFilters(filters []CANFilter) error {
+	// struct can_filter
+	type canFilter struct {
+		id   uint32
+		mask uint32
+	}
+	raw := []canFilter{{0, 0}}
+	if len(filters) != 0 {
+		raw = make([]canFilter, len(filters))
+		for i, f := range filters {
+			// The EFF flag in the mask makes the filter match only frames of
+			// the same kind.
+			if f.Extended {
+				raw[i] = canFilter{f.ID&canEFFMask | canEFFFlag, f.Mask&canEFFMask | canEFFFlag}
+			} else {
+				raw[i] = canFilter{f.ID & canSFFMask, f.Mask&canSFFMask | canEFFFlag}
+			}
+		}
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(can.fd), solCANRaw, canRawFilter,
+		uintptr(unsafe.Pointer(&raw[0])), uintptr(len(raw))*unsafe.Sizeof(raw[0]), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Status returns the error state and error counters of the interface, as far
+// as they have been reported in error frames.
+func (can *CAN) Status() CANStatus {
+	return can.status
+}
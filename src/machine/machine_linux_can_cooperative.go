@@ -0,0 +1,8 @@
+//go:build linux && !baremetal && !scheduler.threads
+// +build linux,!baremetal,!scheduler.threads
+
+package machine
+
+// A blocking recv would stop all goroutines, as they share a single thread.
+// Therefore the receive goroutine checks for frames every millisecond.
+const canBlockingReceive = false
@@ -0,0 +1,8 @@
+//go:build linux && !baremetal && scheduler.threads
+// +build linux,!baremetal,scheduler.threads
+
+package machine
+
+// Every goroutine runs on its own thread, so the receive goroutine can block
+// in recv until a frame arrives.
+const canBlockingReceive = true
@@ -216,6 +216,8 @@ type I2CConfig struct {
 	Frequency uint32
 	SCL       Pin
 	SDA       Pin
+	Mode      I2CMode
+	Address   uint16 // target address, when Mode is I2CModeTarget
 }
 
 // Configure is intended to setup the I2C interface.
@@ -248,6 +250,11 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 		(nrf.GPIO_PIN_CNF_DRIVE_S0D1 << nrf.GPIO_PIN_CNF_DRIVE_Pos) |
 		(nrf.GPIO_PIN_CNF_SENSE_Disabled << nrf.GPIO_PIN_CNF_SENSE_Pos))
 
+	if config.Mode == I2CModeTarget {
+		return i2c.configureTarget(config.SCL, config.SDA, config.Address)
+	}
+	i2c.disableTarget()
+
 	if config.Frequency >= 400*KHz {
 		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K400)
 	} else {
@@ -383,3 +390,12 @@ func ReadTemperature() int32 {
 	nrf.TEMP.EVENTS_DATARDY.Set(0)
 	return temp
 }
+
+var deviceID [8]byte
+
+// readDeviceID reads the 64-bit device ID from the FICR into deviceID, high
+// word first.
+func readDeviceID() {
+	putDeviceIDWord(deviceID[0:], nrf.FICR.DEVICEID[1].Get())
+	putDeviceIDWord(deviceID[4:], nrf.FICR.DEVICEID[0].Get())
+}
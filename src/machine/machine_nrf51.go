@@ -22,6 +22,18 @@ func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSDA.Set(uint32(sda))
 }
 
+// SetTargetHandler sets the handler for the events of this bus in target mode.
+// Target mode is not supported on the nRF51.
+func (i2c *I2C) SetTargetHandler(handler I2CTargetHandler) {
+}
+
+func (i2c *I2C) configureTarget(scl, sda Pin, address uint16) error {
+	return errI2CTargetNotSupported
+}
+
+func (i2c *I2C) disableTarget() {
+}
+
 // SPI on the NRF.
 type SPI struct {
 	Bus *nrf.SPI_Type
@@ -46,6 +46,9 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 		return
 	}
 
+	// Must be done before the USB interrupt is enabled.
+	initUSBSerial()
+
 	state := interrupt.Disable()
 	defer interrupt.Restore(state)
 
@@ -171,7 +174,7 @@ func handleUSBIRQ(interrupt.Interrupt) {
 		epDataStatus := nrf.USBD.EPDATASTATUS.Get()
 		nrf.USBD.EPDATASTATUS.Set(epDataStatus)
 		var i uint32
-		for i = 1; i < uint32(len(endPoints)); i++ {
+		for i = 1; i < usb.NumberOfEndpoints; i++ {
 			// Check if endpoint has a pending interrupt
 			inDataDone := epDataStatus&(nrf.USBD_EPDATASTATUS_EPIN1<<(i-1)) > 0
 			outDataDone := epDataStatus&(nrf.USBD_EPDATASTATUS_EPOUT1<<(i-1)) > 0
@@ -179,7 +182,8 @@ func handleUSBIRQ(interrupt.Interrupt) {
 				if usbTxHandler[i] != nil {
 					usbTxHandler[i]()
 				}
-			} else if outDataDone {
+			}
+			if outDataDone {
 				enterCriticalSection()
 				nrf.USBD.EPOUT[i].PTR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_out_cache_buffer[i]))))
 				count := nrf.USBD.SIZE.EPOUT[i].Get()
@@ -190,7 +194,7 @@ func handleUSBIRQ(interrupt.Interrupt) {
 	}
 
 	// ENDEPOUT[n] events
-	for i := 0; i < len(endPoints); i++ {
+	for i := 0; i < usb.NumberOfEndpoints; i++ {
 		if nrf.USBD.EVENTS_ENDEPOUT[i].Get() > 0 {
 			nrf.USBD.EVENTS_ENDEPOUT[i].Set(0)
 			buf := handleEndpointRx(uint32(i))
@@ -246,10 +250,6 @@ func initEndpoint(ep, config uint32) {
 // SendUSBInPacket sends a packet for USBHID (interrupt in / bulk in).
 func SendUSBInPacket(ep uint32, data []byte) bool {
 	sendUSBPacket(ep, data, 0)
-
-	// clear transfer complete flag
-	nrf.USBD.INTENCLR.Set(nrf.USBD_INTENCLR_ENDEPOUT0 << 4)
-
 	return true
 }
 
@@ -0,0 +1,218 @@
+//go:build nrf52 || nrf52840 || nrf52833
+// +build nrf52 nrf52840 nrf52833
+
+package machine
+
+import (
+	"device/nrf"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// I2C target mode on the nRF52, using the TWIS peripheral that shares its
+// address with the TWI controller.
+//
+// The TWIS moves data with EasyDMA instead of byte by byte, so the events are
+// passed to the handler a bit differently than on other chips: received bytes
+// are passed on at the end of the write (at the stop or repeated start
+// condition), and when the controller starts reading, the handler is asked for
+// up to i2cTargetBufferSize bytes at once, whether the controller reads all of
+// them or not. Bytes read beyond those are 0xff.
+
+type twisType struct {
+	_               [5]uint32
+	tasksStop       volatile.Register32
+	_               [1]uint32
+	tasksSuspend    volatile.Register32
+	tasksResume     volatile.Register32
+	_               [3]uint32
+	tasksPrepareRX  volatile.Register32
+	tasksPrepareTX  volatile.Register32
+	_               [51]uint32
+	eventsStopped   volatile.Register32
+	_               [7]uint32
+	eventsError     volatile.Register32
+	_               [9]uint32
+	eventsRXStarted volatile.Register32
+	eventsTXStarted volatile.Register32
+	_               [4]uint32
+	eventsWrite     volatile.Register32
+	eventsRead      volatile.Register32
+	_               [37]uint32
+	shorts          volatile.Register32
+	_               [63]uint32
+	inten           volatile.Register32
+	intenset        volatile.Register32
+	intenclr        volatile.Register32
+	_               [113]uint32
+	errorsrc        volatile.Register32
+	match           volatile.Register32
+	_               [10]uint32
+	enable          volatile.Register32
+	_               [1]uint32
+	pselSCL         volatile.Register32
+	pselSDA         volatile.Register32
+	_               [9]uint32
+	rxdPtr          volatile.Register32
+	rxdMaxcnt       volatile.Register32
+	rxdAmount       volatile.Register32
+	_               [1]uint32
+	txdPtr          volatile.Register32
+	txdMaxcnt       volatile.Register32
+	txdAmount       volatile.Register32
+	_               [14]uint32
+	address         [2]volatile.Register32
+	_               [1]uint32
+	config          volatile.Register32
+	_               [10]uint32
+	orc             volatile.Register32
+}
+
+// Fields of the TWIS registers.
+const (
+	twisEnable = 9
+
+	twisShortsWriteSuspend = 1 << 13
+	twisShortsReadSuspend  = 1 << 14
+
+	twisIntStopped = 1 << 1
+	twisIntError   = 1 << 9
+	twisIntWrite   = 1 << 25
+	twisIntRead    = 1 << 26
+
+	twisConfigAddress0 = 1 << 0
+)
+
+// The number of bytes received or sent in one go.
+const i2cTargetBufferSize = 16
+
+type i2cTarget struct {
+	handler I2CTargetHandler
+	address byte
+	writing bool
+	rx, tx  [i2cTargetBufferSize]byte
+}
+
+var i2cTargets [2]i2cTarget
+
+func (i2c *I2C) twis() *twisType {
+	return (*twisType)(unsafe.Pointer(i2c))
+}
+
+func (i2c *I2C) target() *i2cTarget {
+	if i2c == I2C1 {
+		return &i2cTargets[1]
+	}
+	return &i2cTargets[0]
+}
+
+// SetTargetHandler sets the handler for the events of this bus in target mode.
+func (i2c *I2C) SetTargetHandler(handler I2CTargetHandler) {
+	mask := interrupt.Disable()
+	i2c.target().handler = handler
+	interrupt.Restore(mask)
+}
+
+// configureTarget enables the TWIS at the given address. The pins have already
+// been configured.
+func (i2c *I2C) configureTarget(scl, sda Pin, address uint16) error {
+	if address >= 0x80 || isReservedI2CAddr(uint8(address)) {
+		return errI2CInvalidTargetAddress
+	}
+	t := i2c.target()
+	t.address = byte(address)
+	t.writing = false
+
+	bus := i2c.twis()
+	bus.pselSCL.Set(uint32(scl))
+	bus.pselSDA.Set(uint32(sda))
+	bus.address[0].Set(uint32(address))
+	bus.config.Set(twisConfigAddress0)
+	bus.orc.Set(0xff)
+	bus.shorts.Set(twisShortsWriteSuspend | twisShortsReadSuspend)
+	bus.intenclr.Set(0xffffffff)
+	bus.intenset.Set(twisIntStopped | twisIntError | twisIntWrite | twisIntRead)
+	bus.enable.Set(twisEnable)
+
+	if i2c == I2C1 {
+		intr := interrupt.New(nrf.IRQ_SPIM1_SPIS1_TWIM1_TWIS1_SPI1_TWI1, func(interrupt.Interrupt) {
+			I2C1.handleTargetInterrupt()
+		})
+		intr.SetPriority(0xc0)
+		intr.Enable()
+	} else {
+		intr := interrupt.New(nrf.IRQ_SPIM0_SPIS0_TWIM0_TWIS0_SPI0_TWI0, func(interrupt.Interrupt) {
+			I2C0.handleTargetInterrupt()
+		})
+		intr.SetPriority(0xc0)
+		intr.Enable()
+	}
+	return nil
+}
+
+// disableTarget stops the interrupts of target mode, when the bus is
+// configured as controller.
+func (i2c *I2C) disableTarget() {
+	i2c.twis().intenclr.Set(0xffffffff)
+	i2c.twis().shorts.Set(0)
+}
+
+// event passes an event to the target handler.
+func (t *i2cTarget) event(event I2CTargetEvent, b byte) byte {
+	if t.handler == nil {
+		return 0xff
+	}
+	return t.handler(event, b)
+}
+
+// flush passes the bytes of the last write to the handler.
+func (t *i2cTarget) flush(bus *twisType) {
+	if !t.writing {
+		return
+	}
+	t.writing = false
+	n := bus.rxdAmount.Get()
+	for i := uint32(0); i < n && i < i2cTargetBufferSize; i++ {
+		t.event(I2CReceive, t.rx[i])
+	}
+}
+
+// handleTargetInterrupt turns the TWIS events into events for the target
+// handler. The TWIS is suspended after an address match, until the buffer for
+// the transfer has been prepared.
+func (i2c *I2C) handleTargetInterrupt() {
+	t := i2c.target()
+	bus := i2c.twis()
+	if bus.eventsError.Get() != 0 {
+		bus.eventsError.Set(0)
+		bus.errorsrc.Set(bus.errorsrc.Get()) // write 1 to clear
+	}
+	if bus.eventsWrite.Get() != 0 {
+		bus.eventsWrite.Set(0)
+		t.flush(bus)
+		t.event(I2CAddressMatch, t.address<<1)
+		bus.rxdPtr.Set(uint32(uintptr(unsafe.Pointer(&t.rx[0]))))
+		bus.rxdMaxcnt.Set(i2cTargetBufferSize)
+		bus.tasksPrepareRX.Set(1)
+		bus.tasksResume.Set(1)
+		t.writing = true
+	}
+	if bus.eventsRead.Get() != 0 {
+		bus.eventsRead.Set(0)
+		t.flush(bus)
+		t.event(I2CAddressMatch, t.address<<1|1)
+		for i := range t.tx {
+			t.tx[i] = t.event(I2CRequest, 0)
+		}
+		bus.txdPtr.Set(uint32(uintptr(unsafe.Pointer(&t.tx[0]))))
+		bus.txdMaxcnt.Set(i2cTargetBufferSize)
+		bus.tasksPrepareTX.Set(1)
+		bus.tasksResume.Set(1)
+	}
+	if bus.eventsStopped.Get() != 0 {
+		bus.eventsStopped.Set(0)
+		t.flush(bus)
+		t.event(I2CStop, 0)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build (nrf52 || nrf52833 || nrf52840) && !softdevice
+// +build nrf52 nrf52833 nrf52840
+// +build !softdevice
+
+package machine
+
+import (
+	"device/arm"
+	"device/nrf"
+)
+
+// configureWake configures the pin as an input that raises the DETECT signal
+// at the given level, which wakes the chip from System OFF. In System ON, a pin
+// interrupt on the edge towards the level wakes it instead.
+func (p Pin) configureWake(level bool) error {
+	mode, sense, change := PinInputPullup, uint32(nrf.GPIO_PIN_CNF_SENSE_Low), PinFalling
+	if level {
+		mode, sense, change = PinInputPulldown, nrf.GPIO_PIN_CNF_SENSE_High, PinRising
+	}
+	p.Configure(PinConfig{Mode: mode})
+	if err := p.SetInterrupt(change, wakePinInterrupt); err != nil {
+		return err
+	}
+	port, pin := p.getPortPin()
+	port.PIN_CNF[pin].ReplaceBits(sense, nrf.GPIO_PIN_CNF_SENSE_Msk>>nrf.GPIO_PIN_CNF_SENSE_Pos, nrf.GPIO_PIN_CNF_SENSE_Pos)
+	return nil
+}
+
+// disableWake disables the pin interrupt and the DETECT signal set by
+// configureWake.
+func (p Pin) disableWake() {
+	p.SetInterrupt(0, nil)
+	port, pin := p.getPortPin()
+	port.PIN_CNF[pin].ReplaceBits(nrf.GPIO_PIN_CNF_SENSE_Disabled, nrf.GPIO_PIN_CNF_SENSE_Msk>>nrf.GPIO_PIN_CNF_SENSE_Pos, nrf.GPIO_PIN_CNF_SENSE_Pos)
+}
+
+func wakePinInterrupt(Pin) {
+	wakeDeepSleep()
+}
+
+func deepSleep(duration int64, wakeSources []WakeSource) {
+	if duration <= 0 {
+		// System OFF: everything but the DETECT signal of the GPIO ports is
+		// powered down, and waking up resets the chip.
+		nrf.POWER.SYSTEMOFF.Set(nrf.POWER_SYSTEMOFF_SYSTEMOFF_Enter)
+		for {
+			arm.Asm("wfe")
+		}
+	}
+
+	// System ON: the scheduler already sleeps in the lowest power mode in
+	// which the RTC keeps running.
+	sleepUntilWoken(duration, wakeSources)
+}
@@ -0,0 +1,65 @@
+//go:build nrf
+// +build nrf
+
+package machine
+
+import (
+	"device/nrf"
+	"encoding/binary"
+	"unsafe"
+)
+
+// Note: the flash cannot be written this way while the SoftDevice is enabled.
+
+// WriteAt writes the given number of bytes to the flash data area.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	data, address, err := f.prepareWrite(p, off)
+	if err != nil {
+		return 0, err
+	}
+
+	waitWhileFlashBusy()
+	nrf.NVMC.CONFIG.Set(nrf.NVMC_CONFIG_WEN_Wen)
+	for i := 0; i < len(data); i += 4 {
+		*(*uint32)(unsafe.Pointer(address)) = binary.LittleEndian.Uint32(data[i : i+4])
+		address += 4
+		waitWhileFlashBusy()
+	}
+	nrf.NVMC.CONFIG.Set(nrf.NVMC_CONFIG_WEN_Ren)
+	return len(p), nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 32-bit word.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 4
+}
+
+// EraseBlockSize returns the size of a flash page: 1kB on the nRF51 and 4kB
+// on the nRF52.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return int64(nrf.FICR.CODEPAGESIZE.Get())
+}
+
+// EraseBlocks erases the given number of flash pages.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	waitWhileFlashBusy()
+	nrf.NVMC.CONFIG.Set(nrf.NVMC_CONFIG_WEN_Een)
+	for i := int64(0); i < len; i++ {
+		nrf.NVMC.ERASEPAGE.Set(uint32(address))
+		waitWhileFlashBusy()
+		address += uintptr(f.EraseBlockSize())
+	}
+	nrf.NVMC.CONFIG.Set(nrf.NVMC_CONFIG_WEN_Ren)
+	return nil
+}
+
+func waitWhileFlashBusy() {
+	for nrf.NVMC.READY.Get() != nrf.NVMC_READY_READY_Ready {
+	}
+}
@@ -0,0 +1,74 @@
+//go:build nrf
+// +build nrf
+
+package machine
+
+import (
+	"device/nrf"
+)
+
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds (about 36 hours).
+const WatchdogMaxTimeout = 0xffffffff * 1000 / 32768
+
+// The watchdog keeps running while the CPU sleeps, but is paused while a
+// debugger halts the CPU.
+func watchdogConfigure(timeoutMillis uint32) {
+	// The watchdog counts down at 32.768kHz.
+	crv := uint64(timeoutMillis) * 32768 / 1000
+	if crv < 0xf {
+		// Minimum value (see datasheet).
+		crv = 0xf
+	}
+	nrf.WDT.CRV.Set(uint32(crv))
+
+	// Use a single reload request register.
+	nrf.WDT.RREN.Set(nrf.WDT_RREN_RR0_Msk)
+
+	nrf.WDT.CONFIG.Set(nrf.WDT_CONFIG_SLEEP_Run<<nrf.WDT_CONFIG_SLEEP_Pos |
+		nrf.WDT_CONFIG_HALT_Pause<<nrf.WDT_CONFIG_HALT_Pos)
+}
+
+func watchdogStart() {
+	nrf.WDT.TASKS_START.Set(1)
+}
+
+func watchdogUpdate() {
+	// 0x6E524635 is the magic reload value (see datasheet).
+	nrf.WDT.RR[0].Set(0x6E524635)
+}
+
+// resetCause is the cause of the last reset, see readResetReason.
+var resetCause ResetCause
+
+// The reset reason register is kept until a power-on reset, so it is read once
+// at startup and then cleared by writing ones to the bits that are set.
+// Otherwise, a reset would also report the reasons of all resets before it.
+func init() {
+	resetreas := nrf.POWER.RESETREAS.Get()
+	resetCause = readResetReason(resetreas)
+	nrf.POWER.RESETREAS.Set(resetreas)
+}
+
+func resetReason() ResetCause {
+	return resetCause
+}
+
+// readResetReason returns the cause of the last reset from the value of the
+// reset reason register.
+func readResetReason(resetreas uint32) ResetCause {
+	switch {
+	case resetreas == 0:
+		// No other reason recorded, so this is a power-on or brown-out reset.
+		return ResetCausePowerOn
+	case resetreas&nrf.POWER_RESETREAS_DOG_Msk != 0:
+		return ResetCauseWatchdog
+	case resetreas&(nrf.POWER_RESETREAS_SREQ_Msk|nrf.POWER_RESETREAS_LOCKUP_Msk) != 0:
+		return ResetCauseSoftware
+	case resetreas&nrf.POWER_RESETREAS_RESETPIN_Msk != 0:
+		return ResetCauseExternal
+	case resetreas&nrf.POWER_RESETREAS_OFF_Msk != 0:
+		return ResetCauseWakeup
+	}
+	return ResetCauseUnknown
+}
@@ -0,0 +1,96 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/arm"
+	"device/rp"
+)
+
+// Value for the XOSC DORMANT register that stops the crystal oscillator.
+const xoscDormant = 0x636f6d61 // "coma"
+
+// Level interrupts, which keep firing as long as the pin is at the level.
+const (
+	pinLevelLow  PinChange = 1
+	pinLevelHigh PinChange = 2
+)
+
+// configureWake configures the pin as an input, with a pin interrupt that
+// detects the level. When going dormant in deepSleep, the level is used for
+// the dormant wake interrupt instead.
+func (p Pin) configureWake(level bool) error {
+	if p >= _NUMBANK0_GPIOS {
+		return ErrWakeSourceNotSupported
+	}
+	mode, change := PinInputPullup, pinLevelLow
+	if level {
+		mode, change = PinInputPulldown, pinLevelHigh
+	}
+	p.Configure(PinConfig{Mode: mode})
+	return p.SetInterrupt(change, wakePinInterrupt)
+}
+
+// disableWake disables the pin interrupt set by configureWake.
+func (p Pin) disableWake() {
+	p.SetInterrupt(pinLevelLow|pinLevelHigh, nil)
+}
+
+// wakePinInterrupt is called when a wake pin reads its level. The interrupt is
+// disabled, as it would keep firing as long as the pin stays at that level.
+func wakePinInterrupt(p Pin) {
+	p.setInterrupt(pinLevelLow|pinLevelHigh, false)
+	wakeDeepSleep()
+}
+
+func deepSleep(duration int64, wakeSources []WakeSource) {
+	if duration <= 0 {
+		dormantUntilWoken(wakeSources)
+		return
+	}
+
+	// Gate the clocks of everything but the timer (and the watchdog, which
+	// provides its tick) when both cores are in deep sleep. The GPIO and pad
+	// clocks keep running for the pin interrupts of the wake sources.
+	clocks.sleepEN0.Set(rp.CLOCKS_SLEEP_EN0_CLK_SYS_IO | rp.CLOCKS_SLEEP_EN0_CLK_SYS_PADS)
+	clocks.sleepEN1.Set(rp.CLOCKS_SLEEP_EN1_CLK_SYS_TIMER | rp.CLOCKS_SLEEP_EN1_CLK_SYS_WATCHDOG)
+	arm.SCB.SCR.SetBits(arm.SCB_SCR_SLEEPDEEP)
+
+	sleepUntilWoken(duration, wakeSources)
+
+	arm.SCB.SCR.ClearBits(arm.SCB_SCR_SLEEPDEEP)
+	clocks.sleepEN0.Set(0xffffffff)
+	clocks.sleepEN1.Set(0xffffffff)
+}
+
+// dormantUntilWoken stops all clocks until one of the wake pins reads its
+// level, and then configures the clocks again.
+func dormantUntilWoken(wakeSources []WakeSource) {
+	// Run clk_sys from clk_ref (the crystal oscillator), and stop the clocks
+	// that run from the PLLs.
+	clocks.clk[clkSys].ctrl.ClearBits(rp.CLOCKS_CLK_SYS_CTRL_SRC_Msk)
+	for !clocks.clk[clkSys].selected.HasBits(0x1) {
+	}
+	for _, cix := range []clockIndex{clkPeri, clkUSB, clkADC, clkRTC} {
+		clocks.clk[cix].ctrl.ClearBits(rp.CLOCKS_CLK_GPOUT0_CTRL_ENABLE)
+	}
+
+	for _, src := range wakeSources {
+		change := pinLevelLow
+		if src.Level {
+			change = pinLevelHigh
+		}
+		src.Pin.ctrlSetInterrupt(change, true, &ioBank0.dormantWakeIRQctrl)
+	}
+
+	// Execution stops here until a wake pin changes the level.
+	xosc.dormant.Set(xoscDormant)
+	for !xosc.status.HasBits(rp.XOSC_STATUS_STABLE) {
+	}
+
+	for _, src := range wakeSources {
+		src.Pin.ctrlSetInterrupt(pinLevelLow|pinLevelHigh, false, &ioBank0.dormantWakeIRQctrl)
+	}
+	clocks.init()
+}
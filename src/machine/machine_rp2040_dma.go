@@ -0,0 +1,173 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// The RP2040 has 12 DMA channels. Drivers claim a channel for as long as they
+// need it and may register a callback that is called from the DMA_IRQ_0
+// interrupt when the channel has finished its transfer.
+
+const dmaChannels = 12
+
+type dmaChannelType struct {
+	readAddr   volatile.Register32
+	writeAddr  volatile.Register32
+	transCount volatile.Register32
+	ctrlTrig   volatile.Register32
+	_          [12]volatile.Register32 // alias registers
+}
+
+type dmaType struct {
+	ch               [dmaChannels]dmaChannelType
+	_                [64]volatile.Register32
+	intR             volatile.Register32
+	intE0            volatile.Register32
+	intF0            volatile.Register32
+	intS0            volatile.Register32
+	_                volatile.Register32
+	intE1            volatile.Register32
+	intF1            volatile.Register32
+	intS1            volatile.Register32
+	timer            [4]volatile.Register32
+	multiChanTrigger volatile.Register32
+	sniffCtrl        volatile.Register32
+	sniffData        volatile.Register32
+	_                volatile.Register32
+	fifoLevels       volatile.Register32
+	chanAbort        volatile.Register32
+}
+
+var dma = (*dmaType)(unsafe.Pointer(rp.DMA))
+
+// Fields of the CTRL register of a DMA channel.
+const (
+	dmaCtrlEN             = 1 << 0
+	dmaCtrlDataSizePos    = 2
+	dmaCtrlIncrRead       = 1 << 4
+	dmaCtrlIncrWrite      = 1 << 5
+	dmaCtrlChainToPos     = 11
+	dmaCtrlTreqSelPos     = 15
+	dmaCtrlBusy           = 1 << 24
+	dmaCtrlDataSizeByte   = 0 << dmaCtrlDataSizePos
+	dmaCtrlDataSizeHalf   = 1 << dmaCtrlDataSizePos
+	dmaCtrlDataSizeWord   = 2 << dmaCtrlDataSizePos
+	dmaCtrlTreqSelUnpaced = 0x3f << dmaCtrlTreqSelPos
+)
+
+// Data request (DREQ) numbers of the peripherals, used to pace transfers.
+const (
+	dreqSPI0TX  = 16
+	dreqSPI0RX  = 17
+	dreqSPI1TX  = 18
+	dreqSPI1RX  = 19
+	dreqUART0TX = 20
+	dreqUART0RX = 21
+	dreqUART1TX = 22
+	dreqUART1RX = 23
+	dreqI2C0TX  = 32
+	dreqI2C0RX  = 33
+	dreqI2C1TX  = 34
+	dreqI2C1RX  = 35
+)
+
+var errNoDMAChannel = errors.New("no free DMA channel")
+
+// dmaChannel is a claimed DMA channel.
+type dmaChannel uint8
+
+var (
+	dmaClaimed     uint16
+	dmaCallbacks   [dmaChannels]func()
+	dmaInitialized bool
+)
+
+// Transfers that only need to move a constant or throw data away use these
+// as their source or destination.
+var (
+	dmaZero  uint32
+	dmaDummy uint32
+)
+
+// claimDMAChannel reserves a free DMA channel. The callback, if not nil, is
+// called from an interrupt each time the channel finishes a transfer.
+func claimDMAChannel(callback func()) (dmaChannel, error) {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	if !dmaInitialized {
+		unresetBlockWait(rp.RESETS_RESET_DMA)
+		interrupt.New(rp.IRQ_DMA_IRQ_0, dmaHandleInterrupt).Enable()
+		irqSet(rp.IRQ_DMA_IRQ_0, true)
+		dmaInitialized = true
+	}
+	for ch := dmaChannel(0); ch < dmaChannels; ch++ {
+		if dmaClaimed&(1<<ch) != 0 {
+			continue
+		}
+		dmaClaimed |= 1 << ch
+		dmaCallbacks[ch] = callback
+		if callback != nil {
+			dma.intE0.SetBits(1 << ch)
+		}
+		return ch, nil
+	}
+	return 0, errNoDMAChannel
+}
+
+// release aborts any transfer in progress and frees the channel.
+func (ch dmaChannel) release() {
+	ch.abort()
+	mask := interrupt.Disable()
+	dma.intE0.ClearBits(1 << ch)
+	dmaCallbacks[ch] = nil
+	dmaClaimed &^= 1 << ch
+	interrupt.Restore(mask)
+}
+
+// start transfers count items from read to write. The ctrl value selects the
+// data size, address increments and DREQ, the channel is enabled and not
+// chained to another channel.
+func (ch dmaChannel) start(read, write uintptr, count uint32, ctrl uint32) {
+	regs := &dma.ch[ch]
+	regs.readAddr.Set(uint32(read))
+	regs.writeAddr.Set(uint32(write))
+	regs.transCount.Set(count)
+	regs.ctrlTrig.Set(ctrl | uint32(ch)<<dmaCtrlChainToPos | dmaCtrlEN)
+}
+
+// busy returns whether the channel is still transferring data.
+func (ch dmaChannel) busy() bool {
+	return dma.ch[ch].ctrlTrig.HasBits(dmaCtrlBusy)
+}
+
+// abort stops the transfer in progress, if any, without calling the callback.
+func (ch dmaChannel) abort() {
+	// An aborted channel may still raise its completion interrupt (erratum
+	// RP2040-E13), so keep it masked until the abort has finished.
+	enabled := dma.intE0.HasBits(1 << ch)
+	dma.intE0.ClearBits(1 << ch)
+	dma.chanAbort.Set(1 << ch)
+	for dma.chanAbort.HasBits(1 << ch) {
+	}
+	dma.intS0.Set(1 << ch)
+	if enabled {
+		dma.intE0.SetBits(1 << ch)
+	}
+}
+
+func dmaHandleInterrupt(interrupt.Interrupt) {
+	status := dma.intS0.Get()
+	dma.intS0.Set(status) // write 1 to clear
+	for ch := dmaChannel(0); status != 0; ch, status = ch+1, status>>1 {
+		if status&1 != 0 && dmaCallbacks[ch] != nil {
+			dmaCallbacks[ch]()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+/*
+// Flash access using the boot ROM functions, based on the pico-sdk
+// (src/rp2_common/hardware_flash/flash.c).
+//
+// While the flash is being erased or programmed, it cannot be used to execute
+// code (XIP). Therefore, the functions that do the actual work are placed in
+// RAM (see the .ramfuncs section in targets/arm.ld) and the ROM functions they
+// call are looked up beforehand.
+
+typedef unsigned char uint8_t;
+typedef unsigned short uint16_t;
+typedef unsigned long uint32_t;
+typedef unsigned long size_t;
+typedef unsigned long uintptr_t;
+
+#define ROM_TABLE_CODE(c1, c2) ((c1) | ((c2) << 8))
+#define ROM_FUNC_CONNECT_INTERNAL_FLASH ROM_TABLE_CODE('I', 'F')
+#define ROM_FUNC_FLASH_EXIT_XIP         ROM_TABLE_CODE('E', 'X')
+#define ROM_FUNC_FLASH_RANGE_ERASE      ROM_TABLE_CODE('R', 'E')
+#define ROM_FUNC_FLASH_RANGE_PROGRAM    ROM_TABLE_CODE('R', 'P')
+#define ROM_FUNC_FLASH_FLUSH_CACHE      ROM_TABLE_CODE('F', 'C')
+
+#define XIP_BASE 0x10000000
+#define FLASH_BLOCK_SIZE (1u << 16)
+#define FLASH_BLOCK_ERASE_CMD 0xd8
+#define FLASH_RUID_CMD 0x4b
+#define FLASH_RUID_DUMMY_BYTES 4
+#define FLASH_RUID_DATA_BYTES 8
+
+// Registers to send a command to the flash directly.
+#define IO_QSPI_SS_CTRL (*(volatile uint32_t *)0x4001800c)
+#define IO_QSPI_SS_CTRL_OUTOVER_BITS 0x300
+#define IO_QSPI_SS_CTRL_OUTOVER_LOW  0x200
+#define IO_QSPI_SS_CTRL_OUTOVER_HIGH 0x300
+#define SSI_SR  (*(volatile uint32_t *)0x18000028)
+#define SSI_DR0 (*(volatile uint32_t *)0x18000060)
+#define SSI_SR_TFNF 0x2
+#define SSI_SR_RFNE 0x8
+
+#define ram_func __attribute__((section(".ramfuncs"), noinline))
+
+// Defined in machine_rp2040_enter_bootloader.go.
+void *rom_func_lookup(uint32_t code);
+
+typedef void (*rom_void_fn)(void);
+typedef void (*rom_flash_erase_fn)(uint32_t addr, size_t count, uint32_t block_size, uint8_t block_cmd);
+typedef void (*rom_flash_prog_fn)(uint32_t addr, const uint8_t *data, size_t count);
+
+typedef struct {
+	rom_void_fn connect_internal_flash;
+	rom_void_fn flash_exit_xip;
+	rom_flash_erase_fn flash_range_erase;
+	rom_flash_prog_fn flash_range_program;
+	rom_void_fn flash_flush_cache;
+} flash_funcs_t;
+
+// Copy of the second stage bootloader, which is used to set up fast XIP again
+// after the flash was written.
+static uint32_t boot2_copyout[64];
+
+static void flash_prepare(flash_funcs_t *funcs) {
+	funcs->connect_internal_flash = (rom_void_fn)rom_func_lookup(ROM_FUNC_CONNECT_INTERNAL_FLASH);
+	funcs->flash_exit_xip = (rom_void_fn)rom_func_lookup(ROM_FUNC_FLASH_EXIT_XIP);
+	funcs->flash_range_erase = (rom_flash_erase_fn)rom_func_lookup(ROM_FUNC_FLASH_RANGE_ERASE);
+	funcs->flash_range_program = (rom_flash_prog_fn)rom_func_lookup(ROM_FUNC_FLASH_RANGE_PROGRAM);
+	funcs->flash_flush_cache = (rom_void_fn)rom_func_lookup(ROM_FUNC_FLASH_FLUSH_CACHE);
+	for (int i = 0; i < 64; i++) {
+		boot2_copyout[i] = ((volatile uint32_t *)XIP_BASE)[i];
+	}
+	__asm__ volatile("" ::: "memory");
+}
+
+static void ram_func flash_enable_xip_via_boot2(void) {
+	((void (*)(void))((uintptr_t)boot2_copyout + 1))();
+}
+
+static void ram_func flash_range_erase_ram(flash_funcs_t *funcs, uint32_t offset, size_t count) {
+	funcs->connect_internal_flash();
+	funcs->flash_exit_xip();
+	funcs->flash_range_erase(offset, count, FLASH_BLOCK_SIZE, FLASH_BLOCK_ERASE_CMD);
+	funcs->flash_flush_cache(); // also removes the CSn IO force
+	flash_enable_xip_via_boot2();
+}
+
+static void ram_func flash_range_program_ram(flash_funcs_t *funcs, uint32_t offset, const uint8_t *data, size_t count) {
+	funcs->connect_internal_flash();
+	funcs->flash_exit_xip();
+	funcs->flash_range_program(offset, data, count);
+	funcs->flash_flush_cache(); // also removes the CSn IO force
+	flash_enable_xip_via_boot2();
+}
+
+static void ram_func flash_cs_force(uint32_t value) {
+	IO_QSPI_SS_CTRL = (IO_QSPI_SS_CTRL & ~IO_QSPI_SS_CTRL_OUTOVER_BITS) | value;
+}
+
+static void ram_func flash_do_cmd_ram(flash_funcs_t *funcs, const uint8_t *txbuf, uint8_t *rxbuf, size_t count) {
+	funcs->connect_internal_flash();
+	funcs->flash_exit_xip();
+	flash_cs_force(IO_QSPI_SS_CTRL_OUTOVER_LOW);
+	size_t tx_remaining = count;
+	size_t rx_remaining = count;
+	// Don't let the RX FIFO (16 entries) overflow.
+	const size_t max_in_flight = 16 - 2;
+	while (tx_remaining || rx_remaining) {
+		uint32_t flags = SSI_SR;
+		if ((flags & SSI_SR_TFNF) && tx_remaining && rx_remaining - tx_remaining < max_in_flight) {
+			SSI_DR0 = *txbuf++;
+			tx_remaining--;
+		}
+		if ((flags & SSI_SR_RFNE) && rx_remaining) {
+			*rxbuf++ = (uint8_t)SSI_DR0;
+			rx_remaining--;
+		}
+	}
+	flash_cs_force(IO_QSPI_SS_CTRL_OUTOVER_HIGH);
+	funcs->flash_flush_cache();
+	flash_enable_xip_via_boot2();
+}
+
+void flash_get_unique_id(uint8_t *id) {
+	uint8_t txbuf[1 + FLASH_RUID_DUMMY_BYTES + FLASH_RUID_DATA_BYTES] = {FLASH_RUID_CMD};
+	uint8_t rxbuf[1 + FLASH_RUID_DUMMY_BYTES + FLASH_RUID_DATA_BYTES];
+	flash_funcs_t funcs;
+	flash_prepare(&funcs);
+	flash_do_cmd_ram(&funcs, txbuf, rxbuf, sizeof(txbuf));
+	for (int i = 0; i < FLASH_RUID_DATA_BYTES; i++) {
+		id[i] = rxbuf[1 + FLASH_RUID_DUMMY_BYTES + i];
+	}
+}
+
+void flash_range_erase(uint32_t offset, size_t count) {
+	flash_funcs_t funcs;
+	flash_prepare(&funcs);
+	flash_range_erase_ram(&funcs, offset, count);
+}
+
+void flash_range_program(uint32_t offset, const uint8_t *data, size_t count) {
+	flash_funcs_t funcs;
+	flash_prepare(&funcs);
+	flash_range_program_ram(&funcs, offset, data, count);
+}
+*/
+import "C"
+
+import (
+	"runtime/interrupt"
+	"unsafe"
+)
+
+// The flash is mapped into the address space at this address (XIP_BASE).
+const xipBase = 0x10000000
+
+// WriteAt writes the given number of bytes to the flash data area.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	data, address, err := f.prepareWrite(p, off)
+	if err != nil {
+		return 0, err
+	}
+
+	// No code may run from flash while it is written, including interrupt
+	// handlers.
+	state := interrupt.Disable()
+	C.flash_range_program(C.uint32_t(address-xipBase), (*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)))
+	interrupt.Restore(state)
+	return len(p), nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 256-byte flash page.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 256
+}
+
+// EraseBlockSize returns the size of a flash sector, which is 4kB.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return 4096
+}
+
+// EraseBlocks erases the given number of sectors.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	state := interrupt.Disable()
+	C.flash_range_erase(C.uint32_t(address-xipBase), C.size_t(len*f.EraseBlockSize()))
+	interrupt.Restore(state)
+	return nil
+}
+
+// The RP2040 itself has no unique ID, but the flash chip on the board has one.
+var deviceID [8]byte
+
+// readDeviceID reads the unique ID of the flash chip into deviceID.
+func readDeviceID() {
+	// The flash can't be used for XIP while the command runs.
+	state := interrupt.Disable()
+	C.flash_get_unique_id((*C.uint8_t)(unsafe.Pointer(&deviceID[0])))
+	interrupt.Restore(state)
+}
@@ -7,6 +7,8 @@ import (
 	"device/rp"
 	"errors"
 	"internal/itoa"
+	"runtime/interrupt"
+	"unsafe"
 )
 
 // I2C on the RP2040.
@@ -46,11 +48,21 @@ type I2CConfig struct {
 	// SDA/SCL Serial Data and clock pins. Refer to datasheet to see
 	// which pins match the desired bus.
 	SDA, SCL Pin
+	// Mode selects controller (the default) or target mode.
+	Mode I2CMode
+	// Address is the 7-bit address of this device in target mode.
+	Address uint16
 }
 
 type I2C struct {
 	Bus           *rp.I2C0_Type
 	restartOnNext bool
+
+	// Target mode state.
+	targetMode    bool
+	targetHandler I2CTargetHandler
+	targetActive  bool
+	targetReading bool
 }
 
 var (
@@ -195,10 +207,24 @@ func (i2c *I2C) init(config I2CConfig) error {
 		return err
 	}
 	i2c.restartOnNext = false
-	// Configure as a fast-mode master with RepStart support, 7-bit addresses
-	i2c.Bus.IC_CON.Set((rp.I2C0_IC_CON_SPEED_FAST << rp.I2C0_IC_CON_SPEED_Pos) |
-		rp.I2C0_IC_CON_MASTER_MODE | rp.I2C0_IC_CON_IC_SLAVE_DISABLE |
-		rp.I2C0_IC_CON_IC_RESTART_EN | rp.I2C0_IC_CON_TX_EMPTY_CTRL) // sets TX_EMPTY_CTRL to enable TX_EMPTY interrupt status
+	i2c.targetMode = config.Mode == I2CModeTarget
+	i2c.targetActive = false
+	if i2c.targetMode {
+		if config.Address >= 0x80 || isReservedI2CAddr(uint8(config.Address)) {
+			return ErrInvalidTgtAddr
+		}
+		// Configure as a target with 7-bit address. Stretch the clock when
+		// the RX FIFO is full instead of dropping bytes, and only report STOP
+		// for transactions addressed to us.
+		i2c.Bus.IC_CON.Set((rp.I2C0_IC_CON_SPEED_FAST << rp.I2C0_IC_CON_SPEED_Pos) |
+			rp.I2C0_IC_CON_RX_FIFO_FULL_HLD_CTRL | rp.I2C0_IC_CON_STOP_DET_IFADDRESSED)
+		i2c.Bus.IC_SAR.Set(uint32(config.Address))
+	} else {
+		// Configure as a fast-mode master with RepStart support, 7-bit addresses
+		i2c.Bus.IC_CON.Set((rp.I2C0_IC_CON_SPEED_FAST << rp.I2C0_IC_CON_SPEED_Pos) |
+			rp.I2C0_IC_CON_MASTER_MODE | rp.I2C0_IC_CON_IC_SLAVE_DISABLE |
+			rp.I2C0_IC_CON_IC_RESTART_EN | rp.I2C0_IC_CON_TX_EMPTY_CTRL) // sets TX_EMPTY_CTRL to enable TX_EMPTY interrupt status
+	}
 
 	// Set FIFO watermarks to 1 to make things simpler. This is encoded by a register value of 0.
 	i2c.Bus.IC_TX_TL.Set(0)
@@ -206,6 +232,16 @@ func (i2c *I2C) init(config I2CConfig) error {
 
 	// Always enable the DREQ signalling -- harmless if DMA isn't listening
 	i2c.Bus.IC_DMA_CR.Set(rp.I2C0_IC_DMA_CR_TDMAE | rp.I2C0_IC_DMA_CR_RDMAE)
+
+	// Interrupts are only used in target mode and for asynchronous
+	// transactions.
+	if i2c.targetMode {
+		i2c.Bus.IC_INTR_MASK.Set(rp.I2C0_IC_INTR_MASK_M_RX_FULL | rp.I2C0_IC_INTR_MASK_M_RD_REQ |
+			rp.I2C0_IC_INTR_MASK_M_TX_ABRT | rp.I2C0_IC_INTR_MASK_M_STOP_DET)
+		i2c.enableInterrupt()
+	} else {
+		i2c.Bus.IC_INTR_MASK.Set(0)
+	}
 	return i2c.SetBaudRate(config.Frequency)
 }
 
@@ -338,24 +374,29 @@ func (i2c *I2C) tx(addr uint8, tx, rx []byte, timeout_us uint64) (err error) {
 			rx[rxCtr] = uint8(i2c.Bus.IC_DATA_CMD.Get())
 		}
 	}
-	// From Pico SDK: A lot of things could have just happened due to the ingenious and
-	// creative design of I2C. Try to figure things out.
 	if abort {
-		switch {
-		case abortReason == 0 || abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_7B_ADDR_NOACK != 0:
-			// No reported errors - seems to happen if there is nothing connected to the bus.
-			// Address byte not acknowledged
-			err = ErrI2CGeneric
-		case abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_TXDATA_NOACK != 0:
-			// Address acknowledged, some data not acknowledged
-			fallthrough
-		default:
-			err = makeI2CAbortError(abortReason)
-		}
+		err = i2cAbortReasonError(abortReason)
 	}
 	return err
 }
 
+// i2cAbortReasonError returns the error for an aborted transfer.
+func i2cAbortReasonError(abortReason uint32) error {
+	// From Pico SDK: A lot of things could have just happened due to the ingenious and
+	// creative design of I2C. Try to figure things out.
+	switch {
+	case abortReason == 0 || abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_7B_ADDR_NOACK != 0:
+		// No reported errors - seems to happen if there is nothing connected to the bus.
+		// Address byte not acknowledged
+		return ErrI2CGeneric
+	case abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_TXDATA_NOACK != 0:
+		// Address acknowledged, some data not acknowledged
+		fallthrough
+	default:
+		return makeI2CAbortError(abortReason)
+	}
+}
+
 // writeAvailable determines non-blocking write space available
 //
 //go:inline
@@ -424,7 +465,222 @@ func u32max(a, b uint32) uint32 {
 	return b
 }
 
-//go:inline
-func isReservedI2CAddr(addr uint8) bool {
-	return (addr&0x78) == 0 || (addr&0x78) == 0x78
+// i2cDMA is the state of asynchronous transactions on an I2C bus. The
+// transaction is written to the TX FIFO by DMA as a list of commands, received
+// bytes are read from the RX FIFO by another DMA channel. The transaction has
+// finished once the STOP condition was sent and all bytes were received.
+type i2cDMA struct {
+	asyncTransfer
+	tx, rx      dmaChannel
+	claimed     bool
+	cmd         []uint16
+	stopped     bool
+	received    bool
+	abortReason uint32
+	aborted     bool
+}
+
+var i2cDMAs [2]i2cDMA
+
+// dma returns the asynchronous transfer state and the TX DREQ of this bus.
+func (i2c *I2C) dma() (*i2cDMA, uint32) {
+	if i2c.Bus == rp.I2C1 {
+		return &i2cDMAs[1], dreqI2C1TX
+	}
+	return &i2cDMAs[0], dreqI2C0TX
+}
+
+// TxAsync starts an I2C transaction using DMA and returns immediately. The w
+// and r buffers are used in the same way as in Tx. Call Wait to wait for the
+// transaction to finish, the buffers must not be used until then.
+func (i2c *I2C) TxAsync(addr uint16, w, r []byte) error {
+	if addr >= 0x80 || isReservedI2CAddr(uint8(addr)) {
+		return ErrInvalidTgtAddr
+	}
+	d, dreqTX := i2c.dma()
+	if !d.claimed {
+		tx, err := claimDMAChannel(nil)
+		if err != nil {
+			return err
+		}
+		rx, err := claimDMAChannel(func() {
+			d.received = true
+			i2c.finishAsync(d)
+		})
+		if err != nil {
+			tx.release()
+			return err
+		}
+		d.tx, d.rx, d.claimed = tx, rx, true
+		i2c.enableInterrupt()
+	}
+	if err := d.begin(w, r); err != nil {
+		return err
+	}
+	n := len(w) + len(r)
+	if n == 0 {
+		d.complete(nil)
+		return nil
+	}
+
+	// Build the command list: the bytes to write followed by one read command
+	// per byte to read. The controller issues a RESTART when switching from
+	// writing to reading, the last command ends the transaction with a STOP.
+	if cap(d.cmd) < n {
+		d.cmd = make([]uint16, n)
+	}
+	cmd := d.cmd[:n]
+	for i, b := range w {
+		cmd[i] = uint16(b)
+	}
+	for i := len(w); i < n; i++ {
+		cmd[i] = rp.I2C0_IC_DATA_CMD_CMD
+	}
+	if i2c.restartOnNext {
+		cmd[0] |= 1 << rp.I2C0_IC_DATA_CMD_RESTART_Pos
+	}
+	cmd[n-1] |= 1 << rp.I2C0_IC_DATA_CMD_STOP_Pos
+
+	if err := i2c.disable(); err != nil {
+		d.complete(err)
+		return nil
+	}
+	i2c.Bus.IC_TAR.Set(uint32(addr))
+	i2c.clearAbortReason()
+	i2c.Bus.IC_CLR_STOP_DET.Get()
+	d.stopped = false
+	d.received = len(r) == 0
+	d.aborted = false
+	d.abortReason = 0
+	i2c.Bus.IC_INTR_MASK.Set(rp.I2C0_IC_INTR_MASK_M_STOP_DET | rp.I2C0_IC_INTR_MASK_M_TX_ABRT)
+	i2c.enable()
+
+	dataCmd := uintptr(unsafe.Pointer(&i2c.Bus.IC_DATA_CMD))
+	if len(r) != 0 {
+		d.rx.start(dataCmd, uintptr(unsafe.Pointer(&r[0])), uint32(len(r)),
+			dmaCtrlIncrWrite|dmaCtrlDataSizeByte|(dreqTX+1)<<dmaCtrlTreqSelPos)
+	}
+	d.tx.start(uintptr(unsafe.Pointer(&cmd[0])), dataCmd, uint32(n),
+		dmaCtrlIncrRead|dmaCtrlDataSizeHalf|dreqTX<<dmaCtrlTreqSelPos)
+	return nil
+}
+
+// Wait waits until the transaction started with TxAsync has finished and
+// returns its result. The calling goroutine is paused in the meantime, so
+// that other goroutines can run.
+func (i2c *I2C) Wait() error {
+	d, _ := i2c.dma()
+	return d.wait()
+}
+
+// handleAsyncInterrupt handles the STOP_DET and TX_ABRT interrupts during an
+// asynchronous transaction.
+func (i2c *I2C) handleAsyncInterrupt() {
+	d, _ := i2c.dma()
+	status := i2c.Bus.IC_INTR_STAT.Get()
+	if status&rp.I2C0_IC_INTR_STAT_R_TX_ABRT != 0 {
+		// The controller flushed the TX FIFO and sends a STOP, so nothing
+		// more will be received.
+		d.abortReason = i2c.getAbortReason()
+		d.aborted = true
+		i2c.clearAbortReason()
+		d.tx.abort()
+		d.rx.abort()
+		d.received = true
+	}
+	if status&rp.I2C0_IC_INTR_STAT_R_STOP_DET != 0 {
+		i2c.Bus.IC_CLR_STOP_DET.Get()
+		d.stopped = true
+	}
+	i2c.finishAsync(d)
+}
+
+// finishAsync completes the asynchronous transaction once the STOP condition
+// has been sent and all bytes have been received.
+func (i2c *I2C) finishAsync(d *i2cDMA) {
+	if !d.stopped || !d.received || !d.inProgress() {
+		return
+	}
+	i2c.Bus.IC_INTR_MASK.Set(0)
+	var err error
+	if d.aborted {
+		err = i2cAbortReasonError(d.abortReason)
+	}
+	d.complete(err)
+}
+
+// enableInterrupt enables the interrupt of this bus, which is used in target
+// mode and for asynchronous transactions.
+func (i2c *I2C) enableInterrupt() {
+	if i2c.Bus == rp.I2C1 {
+		interrupt.New(rp.IRQ_I2C1_IRQ, func(interrupt.Interrupt) {
+			_I2C1.handleInterrupt()
+		}).Enable()
+		irqSet(rp.IRQ_I2C1_IRQ, true)
+	} else {
+		interrupt.New(rp.IRQ_I2C0_IRQ, func(interrupt.Interrupt) {
+			_I2C0.handleInterrupt()
+		}).Enable()
+		irqSet(rp.IRQ_I2C0_IRQ, true)
+	}
+}
+
+func (i2c *I2C) handleInterrupt() {
+	if i2c.targetMode {
+		i2c.handleTargetInterrupt()
+	} else {
+		i2c.handleAsyncInterrupt()
+	}
+}
+
+// SetTargetHandler sets the handler for the events of this bus in target mode.
+func (i2c *I2C) SetTargetHandler(handler I2CTargetHandler) {
+	mask := interrupt.Disable()
+	i2c.targetHandler = handler
+	interrupt.Restore(mask)
+}
+
+// targetEvent passes an event to the target handler.
+func (i2c *I2C) targetEvent(event I2CTargetEvent, b byte) byte {
+	if i2c.targetHandler == nil {
+		return 0xff
+	}
+	return i2c.targetHandler(event, b)
+}
+
+// handleTargetInterrupt turns the interrupts of the controller in target mode
+// into events for the target handler.
+func (i2c *I2C) handleTargetInterrupt() {
+	status := i2c.Bus.IC_INTR_STAT.Get()
+	addr := byte(i2c.Bus.IC_SAR.Get() << 1)
+	if status&rp.I2C0_IC_INTR_STAT_R_TX_ABRT != 0 {
+		// The controller did not read all bytes we offered, which flushed the
+		// TX FIFO.
+		i2c.clearAbortReason()
+	}
+	if status&rp.I2C0_IC_INTR_STAT_R_RX_FULL != 0 {
+		for i2c.readAvailable() != 0 {
+			data := i2c.Bus.IC_DATA_CMD.Get()
+			if data&rp.I2C0_IC_DATA_CMD_FIRST_DATA_BYTE != 0 || !i2c.targetActive || i2c.targetReading {
+				i2c.targetEvent(I2CAddressMatch, addr)
+			}
+			i2c.targetActive, i2c.targetReading = true, false
+			i2c.targetEvent(I2CReceive, byte(data))
+		}
+	}
+	if status&rp.I2C0_IC_INTR_STAT_R_RD_REQ != 0 {
+		if !i2c.targetActive || !i2c.targetReading {
+			i2c.targetEvent(I2CAddressMatch, addr|1)
+		}
+		i2c.targetActive, i2c.targetReading = true, true
+		i2c.Bus.IC_DATA_CMD.Set(uint32(i2c.targetEvent(I2CRequest, 0)))
+		i2c.Bus.IC_CLR_RD_REQ.Get()
+	}
+	if status&rp.I2C0_IC_INTR_STAT_R_STOP_DET != 0 {
+		i2c.Bus.IC_CLR_STOP_DET.Get()
+		if i2c.targetActive {
+			i2c.targetActive = false
+			i2c.targetEvent(I2CStop, 0)
+		}
+	}
 }
@@ -6,6 +6,7 @@ package machine
 import (
 	"device/rp"
 	"errors"
+	"unsafe"
 )
 
 // SPI on the RP2040
@@ -366,3 +367,86 @@ func (spi SPI) txrx(tx, rx []byte) error {
 
 	return nil
 }
+
+// spiDMA is the state of asynchronous transfers on a SPI bus. The rx channel
+// completes last, so the transfer is finished when it is done.
+type spiDMA struct {
+	asyncTransfer
+	tx, rx  dmaChannel
+	claimed bool
+}
+
+var spiDMAs [2]spiDMA
+
+// dma returns the asynchronous transfer state and the TX DREQ of this bus.
+func (spi SPI) dma() (*spiDMA, uint32) {
+	if spi.Bus == rp.SPI1 {
+		return &spiDMAs[1], dreqSPI1TX
+	}
+	return &spiDMAs[0], dreqSPI0TX
+}
+
+// TxAsync starts a SPI transfer using DMA and returns immediately. The w and r
+// buffers are used in the same way as in Tx. Call Wait to wait for the
+// transfer to finish, the buffers must not be used until then.
+func (spi SPI) TxAsync(w, r []byte) error {
+	repeat := len(w) == 1 && len(r) > 1
+	if w != nil && r != nil && len(w) != len(r) && !repeat {
+		return ErrTxInvalidSliceSize
+	}
+	d, dreqTX := spi.dma()
+	if !d.claimed {
+		tx, err := claimDMAChannel(nil)
+		if err != nil {
+			return err
+		}
+		rx, err := claimDMAChannel(func() {
+			d.complete(nil)
+		})
+		if err != nil {
+			tx.release()
+			return err
+		}
+		d.tx, d.rx, d.claimed = tx, rx, true
+	}
+	if err := d.begin(w, r); err != nil {
+		return err
+	}
+	n := len(r)
+	if r == nil {
+		n = len(w)
+	}
+	if n == 0 {
+		d.complete(nil)
+		return nil
+	}
+
+	// Throw away stale data, so that the received bytes line up.
+	for spi.isReadable() {
+		spi.Bus.SSPDR.Get()
+	}
+
+	dr := uintptr(unsafe.Pointer(&spi.Bus.SSPDR))
+	rxAddr, rxCtrl := uintptr(unsafe.Pointer(&dmaDummy)), uint32(0)
+	if r != nil {
+		rxAddr, rxCtrl = uintptr(unsafe.Pointer(&r[0])), dmaCtrlIncrWrite
+	}
+	txAddr, txCtrl := uintptr(unsafe.Pointer(&dmaZero)), uint32(0)
+	if w != nil {
+		txAddr = uintptr(unsafe.Pointer(&w[0]))
+		if !repeat {
+			txCtrl = dmaCtrlIncrRead
+		}
+	}
+	// Start receiving before sending, so that the RX FIFO cannot overflow.
+	d.rx.start(dr, rxAddr, uint32(n), rxCtrl|dmaCtrlDataSizeByte|(dreqTX+1)<<dmaCtrlTreqSelPos)
+	d.tx.start(txAddr, dr, uint32(n), txCtrl|dmaCtrlDataSizeByte|dreqTX<<dmaCtrlTreqSelPos)
+	return nil
+}
+
+// Wait waits until the transfer started with TxAsync has finished. The calling
+// goroutine is paused in the meantime, so that other goroutines can run.
+func (spi SPI) Wait() error {
+	d, _ := spi.dma()
+	return d.wait()
+}
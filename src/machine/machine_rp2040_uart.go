@@ -6,6 +6,7 @@ package machine
 import (
 	"device/rp"
 	"runtime/interrupt"
+	"unsafe"
 )
 
 // UART on the RP2040.
@@ -132,3 +133,54 @@ func (uart *UART) handleInterrupt(interrupt.Interrupt) {
 	}
 	uart.Receive(byte((uart.Bus.UARTDR.Get() & 0xFF)))
 }
+
+// uartDMA is the state of asynchronous writes to a UART.
+type uartDMA struct {
+	asyncTransfer
+	ch      dmaChannel
+	claimed bool
+}
+
+var uartDMAs [2]uartDMA
+
+// dma returns the asynchronous transfer state and the TX DREQ of this UART.
+func (uart *UART) dma() (*uartDMA, uint32) {
+	if uart.Bus == rp.UART1 {
+		return &uartDMAs[1], dreqUART1TX
+	}
+	return &uartDMAs[0], dreqUART0TX
+}
+
+// TxAsync starts writing data to the UART using DMA and returns immediately.
+// Call Wait to wait for the write to finish, data must not be modified until
+// then.
+func (uart *UART) TxAsync(data []byte) error {
+	d, dreq := uart.dma()
+	if !d.claimed {
+		ch, err := claimDMAChannel(func() {
+			d.complete(nil)
+		})
+		if err != nil {
+			return err
+		}
+		d.ch, d.claimed = ch, true
+	}
+	if err := d.begin(data, nil); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		d.complete(nil)
+		return nil
+	}
+	uart.Bus.UARTDMACR.SetBits(rp.UART0_UARTDMACR_TXDMAE)
+	d.ch.start(uintptr(unsafe.Pointer(&data[0])), uintptr(unsafe.Pointer(&uart.Bus.UARTDR)), uint32(len(data)),
+		dmaCtrlIncrRead|dmaCtrlDataSizeByte|dreq<<dmaCtrlTreqSelPos)
+	return nil
+}
+
+// Wait waits until all data passed to TxAsync has been moved into the UART
+// transmit FIFO. The last bytes may still be in the process of being sent.
+func (uart *UART) Wait() error {
+	d, _ := uart.dma()
+	return d.wait()
+}
@@ -21,6 +21,9 @@ var (
 
 // Configure the USB peripheral. The config is here for compatibility with the UART interface.
 func (dev *USBDevice) Configure(config UARTConfig) {
+	// Must be done before the USB interrupt is enabled.
+	initUSBSerial()
+
 	// Reset usb controller
 	resetBlock(rp.RESETS_RESET_USBCTRL)
 	unresetBlockWait(rp.RESETS_RESET_USBCTRL)
@@ -137,6 +140,11 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 func initEndpoint(ep, config uint32) {
 	val := uint32(usbEpControlEnable) | uint32(usbEpControlInterruptPerBuff)
 	offset := ep*2*USBBufferLen + 0x100
+	if config&usb.EndpointIn == 0 && config != usb.ENDPOINT_TYPE_CONTROL {
+		// OUT endpoints use the second buffer of the endpoint, so that an IN
+		// endpoint with the same number can use the first one.
+		offset += USBBufferLen
+	}
 	val |= offset
 
 	switch config {
@@ -237,12 +245,15 @@ func handleEndpointRx(ep uint32) []byte {
 	usbDPSRAM.EPxBufferControl[ep].Out.Set(USBBufferLen & usbBuf0CtrlLenMask)
 	sz := ctrl & usbBuf0CtrlLenMask
 
-	return usbDPSRAM.EPxBuffer[ep].Buffer0[:sz]
+	if ep == 0 {
+		return usbDPSRAM.EPxBuffer[ep].Buffer0[:sz]
+	}
+	return usbDPSRAM.EPxBuffer[ep].Buffer1[:sz]
 }
 
 func handleEndpointRxComplete(ep uint32) {
-	epXdata0[ep] = !epXdata0[ep]
-	if epXdata0[ep] || ep == 0 {
+	epXdata0Out[ep] = !epXdata0Out[ep]
+	if epXdata0Out[ep] || ep == 0 {
 		usbDPSRAM.EPxBufferControl[ep].Out.SetBits(usbBuf0CtrlData1Pid)
 	}
 
@@ -307,6 +318,9 @@ type USBBuffer struct {
 var (
 	usbDPSRAM = (*USBDPSRAM)(unsafe.Pointer(uintptr(0x50100000)))
 	epXdata0  [16]bool
+	// The OUT endpoints keep their own data toggle, as they may share their
+	// number with an IN endpoint.
+	epXdata0Out [16]bool
 )
 
 func (d *USBDPSRAM) setupBytes() []byte {
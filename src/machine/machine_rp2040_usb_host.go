@@ -0,0 +1,308 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/arm"
+	"device/rp"
+	"errors"
+	"machine/usb"
+	"machine/usb/host"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// USB host mode on the RP2040.
+//
+// All transfers go through the single endpoint the controller has in host
+// mode (EPX), one packet at a time, by polling the controller. The interrupt
+// endpoints of the controller, which poll the device in hardware, are not
+// used. Host mode can't be used together with device mode, so the USB serial
+// port must be disabled with -serial=uart or -serial=none.
+
+// USBHostController is the USB controller in host mode. It implements
+// host.Controller.
+type USBHostController struct{}
+
+// USBHost is the USB controller of the RP2040, for use in host mode:
+//
+//	machine.USBHost.Configure()
+//	h := host.New(machine.USBHost)
+var USBHost = &USBHostController{}
+
+var errUSBHostTransfer = errors.New("USB host transfer error")
+
+// Layout of the DPRAM in host mode.
+type usbHostDPSRAM struct {
+	setup              [2]volatile.Register32 // 0x000
+	intEPControl       [15][2]volatile.Register32
+	epxBufferControl   volatile.Register32 // 0x080
+	_                  volatile.Register32
+	intEPBufferControl [15][2]volatile.Register32
+	epxControl         volatile.Register32 // 0x100
+	_                  [31]volatile.Register32
+	epxBuffer          [USBBufferLen]byte // 0x180
+}
+
+var usbHostDPRAM = (*usbHostDPSRAM)(unsafe.Pointer(uintptr(0x50100000)))
+
+// Offset of epxBuffer in the DPRAM.
+const usbHostEPXBufferOffset = 0x180
+
+// Fields of the USB controller registers that are used in host mode.
+const (
+	usbMainCtrlHostNDevice = 0x00000002
+
+	usbSIECtrlStartTrans  = 0x00000001
+	usbSIECtrlSendSetup   = 0x00000002
+	usbSIECtrlSendData    = 0x00000004
+	usbSIECtrlReceiveData = 0x00000008
+	usbSIECtrlStopTrans   = 0x00000010
+	usbSIECtrlSOFEn       = 0x00000200
+	usbSIECtrlKeepAliveEn = 0x00000400
+	usbSIECtrlResetBus    = 0x00002000
+	usbSIECtrlPulldownEn  = 0x00008000
+	usbSIECtrlEP0Int1Buf  = 0x20000000
+
+	usbSIEStatusSpeedMsk      = 0x00000300
+	usbSIEStatusSpeedPos      = 8
+	usbSIEStatusTransComplete = 0x00040000
+	usbSIEStatusCRCError      = 0x01000000
+	usbSIEStatusBitStuffError = 0x02000000
+	usbSIEStatusRxOverflow    = 0x04000000
+	usbSIEStatusRxTimeout     = 0x08000000
+	usbSIEStatusNakRec        = 0x10000000
+	usbSIEStatusStallRec      = 0x20000000
+	usbSIEStatusDataSeqError  = 0x80000000
+
+	usbSIEStatusErrors = usbSIEStatusCRCError | usbSIEStatusBitStuffError | usbSIEStatusRxOverflow | usbSIEStatusDataSeqError
+
+	// Bits that are always set in SIE_CTRL in host mode: send start of
+	// frame packets (or keep alive signals to low speed devices), and pull
+	// down D+ and D- to detect devices.
+	usbSIECtrlHost = usbSIECtrlSOFEn | usbSIECtrlKeepAliveEn | usbSIECtrlPulldownEn | usbSIECtrlEP0Int1Buf
+)
+
+// Timeouts in microseconds.
+const (
+	usbHostTransferTimeout = 500 * 1000 // for a single packet
+	usbHostResetTime       = 50 * 1000  // bus reset
+	usbHostSettleTime      = 100 * 1000 // after connecting, before the reset
+	usbHostRecoveryTime    = 10 * 1000  // after the reset
+	usbHostSetAddressTime  = 2 * 1000   // after SET_ADDRESS
+)
+
+// Configure enables the USB controller in host mode.
+func (c *USBHostController) Configure() {
+	resetBlock(rp.RESETS_RESET_USBCTRL)
+	unresetBlockWait(rp.RESETS_RESET_USBCTRL)
+	usbDPSRAM.clear()
+
+	rp.USBCTRL_REGS.INTE.Set(0)
+	rp.USBCTRL_REGS.USB_MUXING.Set(rp.USBCTRL_REGS_USB_MUXING_TO_PHY | rp.USBCTRL_REGS_USB_MUXING_SOFTCON)
+	rp.USBCTRL_REGS.USB_PWR.Set(rp.USBCTRL_REGS_USB_PWR_VBUS_DETECT | rp.USBCTRL_REGS_USB_PWR_VBUS_DETECT_OVERRIDE_EN)
+	rp.USBCTRL_REGS.MAIN_CTRL.Set(rp.USBCTRL_REGS_MAIN_CTRL_CONTROLLER_EN | usbMainCtrlHostNDevice)
+	rp.USBCTRL_REGS.SIE_CTRL.Set(usbSIECtrlHost)
+}
+
+// Connected returns whether a device is connected to the port.
+func (c *USBHostController) Connected() bool {
+	return rp.USBCTRL_REGS.SIE_STATUS.Get()&usbSIEStatusSpeedMsk != 0
+}
+
+// ResetPort resets the connected device and returns its speed.
+func (c *USBHostController) ResetPort() (host.Speed, error) {
+	if !c.Connected() {
+		return 0, host.ErrNoDevice
+	}
+	usbHostWait(usbHostSettleTime)
+	rp.USBCTRL_REGS.SIE_CTRL.SetBits(usbSIECtrlResetBus)
+	usbHostWait(usbHostResetTime)
+	usbHostWait(usbHostRecoveryTime)
+
+	switch (rp.USBCTRL_REGS.SIE_STATUS.Get() & usbSIEStatusSpeedMsk) >> usbSIEStatusSpeedPos {
+	case 1:
+		return host.SpeedLow, nil
+	case 2:
+		return host.SpeedFull, nil
+	default:
+		return 0, host.ErrNoDevice
+	}
+}
+
+// Control performs a control transfer on endpoint 0 of the device.
+func (c *USBHostController) Control(dev *host.Device, setup usb.Setup, data []byte) (int, error) {
+	usbHostDPRAM.setup[0].Set(uint32(setup.BmRequestType) | uint32(setup.BRequest)<<8 |
+		uint32(setup.WValueL)<<16 | uint32(setup.WValueH)<<24)
+	usbHostDPRAM.setup[1].Set(uint32(setup.WIndex) | uint32(setup.WLength)<<16)
+	c.selectEndpoint(dev, 0, usb.ENDPOINT_TYPE_CONTROL)
+	if err := c.transaction(usbSIECtrlSendSetup, false); err != nil {
+		return 0, err
+	}
+
+	// Data stage, which starts with DATA1.
+	in := setup.BmRequestType&usb.REQUEST_DIRECTION != 0
+	length := int(setup.WLength)
+	if length > len(data) {
+		length = len(data)
+	}
+	maxPacketSize := int(dev.MaxPacketSize0)
+	toggle := true
+	n := 0
+	for n < length {
+		size := length - n
+		if size > maxPacketSize {
+			size = maxPacketSize
+		}
+		m, err := c.packet(in, toggle, data[n:n+size], false)
+		if err != nil {
+			return n, err
+		}
+		n += m
+		toggle = !toggle
+		if m < maxPacketSize {
+			break
+		}
+	}
+
+	// Status stage: a zero length packet with DATA1 in the other direction.
+	if _, err := c.packet(!in, true, nil, false); err != nil {
+		return n, err
+	}
+	if setup.BmRequestType == usb.REQUEST_HOSTTODEVICE && setup.BRequest == usb.SET_ADDRESS {
+		usbHostWait(usbHostSetAddressTime)
+	}
+	return n, nil
+}
+
+// Transfer performs a bulk or interrupt transfer on the endpoint. IN transfers
+// end with a short packet or when data is full, and return host.ErrNAK if the
+// device didn't send anything.
+func (c *USBHostController) Transfer(dev *host.Device, ep *host.Endpoint, data []byte) (int, error) {
+	c.selectEndpoint(dev, ep.Number(), ep.Type)
+	in := ep.In()
+	maxPacketSize := int(ep.MaxPacketSize)
+	if maxPacketSize > USBBufferLen {
+		maxPacketSize = USBBufferLen
+	}
+	n := 0
+	for {
+		size := len(data) - n
+		if size > maxPacketSize {
+			size = maxPacketSize
+		}
+		m, err := c.packet(in, ep.DataToggle, data[n:n+size], in)
+		if err == host.ErrNAK && n != 0 {
+			// The device sent some data, and has no more.
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		ep.DataToggle = !ep.DataToggle
+		n += m
+		if n == len(data) || (in && m < maxPacketSize) {
+			return n, nil
+		}
+	}
+}
+
+// selectEndpoint sets the device address and endpoint of the next transactions.
+func (c *USBHostController) selectEndpoint(dev *host.Device, number, typ uint8) {
+	rp.USBCTRL_REGS.ADDR_ENDP.Set(uint32(dev.Address) | uint32(number)<<16)
+	usbHostDPRAM.epxControl.Set(usbEpControlEnable | usbEpControlInterruptPerBuff |
+		uint32(typ)<<26 | usbHostEPXBufferOffset)
+}
+
+// packet sends or receives a single data packet, and returns the number of
+// bytes that were sent or received. If nak is set, it gives up with
+// host.ErrNAK when the device has nothing to send, instead of retrying.
+func (c *USBHostController) packet(in, data1 bool, data []byte, nak bool) (int, error) {
+	val := uint32(len(data)) | usbBuf0CtrlLast
+	if data1 {
+		val |= usbBuf0CtrlData1Pid
+	}
+	flags := uint32(usbSIECtrlReceiveData)
+	if !in {
+		for i, b := range data {
+			usbHostDPRAM.epxBuffer[i] = b
+		}
+		val |= usbBuf0CtrlFull
+		flags = usbSIECtrlSendData
+	}
+	// The buffer must be complete before it is made available to the
+	// controller, which runs at a different clock.
+	usbHostDPRAM.epxBufferControl.Set(val)
+	usbHostWaitCycles()
+	usbHostDPRAM.epxBufferControl.Set(val | usbBuf0CtrlAvail)
+
+	if err := c.transaction(flags, nak); err != nil {
+		return 0, err
+	}
+	if !in {
+		return len(data), nil
+	}
+	n := int(usbHostDPRAM.epxBufferControl.Get() & usbBuf0CtrlLenMask)
+	if n > len(data) {
+		return 0, errUSBHostTransfer
+	}
+	for i := 0; i < n; i++ {
+		data[i] = usbHostDPRAM.epxBuffer[i]
+	}
+	return n, nil
+}
+
+// transaction starts a transaction on EPX and waits for it to complete.
+func (c *USBHostController) transaction(flags uint32, nak bool) error {
+	status := &rp.USBCTRL_REGS.SIE_STATUS
+	status.Set(usbSIEStatusTransComplete | usbSIEStatusErrors | usbSIEStatusRxTimeout |
+		usbSIEStatusNakRec | usbSIEStatusStallRec)
+	rp.USBCTRL_REGS.BUFF_STATUS.Set(0xffffffff)
+
+	rp.USBCTRL_REGS.SIE_CTRL.Set(usbSIECtrlHost | flags)
+	usbHostWaitCycles()
+	rp.USBCTRL_REGS.SIE_CTRL.Set(usbSIECtrlHost | flags | usbSIECtrlStartTrans)
+
+	deadline := ticks() + usbHostTransferTimeout
+	for {
+		s := status.Get()
+		switch {
+		case s&usbSIEStatusStallRec != 0:
+			return host.ErrStall
+		case s&usbSIEStatusErrors != 0:
+			return errUSBHostTransfer
+		case s&usbSIEStatusTransComplete != 0:
+			return nil
+		case s&usbSIEStatusRxTimeout != 0:
+			return host.ErrTimeout
+		case nak && s&usbSIEStatusNakRec != 0:
+			c.stop()
+			return host.ErrNAK
+		case ticks() > deadline:
+			c.stop()
+			return host.ErrTimeout
+		}
+	}
+}
+
+// stop stops the current transaction, which the controller would otherwise
+// retry while the device answers with NAK.
+func (c *USBHostController) stop() {
+	rp.USBCTRL_REGS.SIE_CTRL.Set(usbSIECtrlHost | usbSIECtrlStopTrans)
+}
+
+// usbHostWait waits for the given number of microseconds.
+func usbHostWait(us uint64) {
+	deadline := ticks() + us
+	for ticks() < deadline {
+	}
+}
+
+// usbHostWaitCycles waits for a few cycles of the (slower) USB clock, so that
+// the controller sees register writes in the right order.
+func usbHostWaitCycles() {
+	for i := 0; i < 12; i++ {
+		arm.Asm("nop")
+	}
+}
@@ -26,3 +26,59 @@ var watchdog = (*watchdogType)(unsafe.Pointer(rp.WATCHDOG))
 func (wd *watchdogType) startTick(cycles uint32) {
 	wd.tick.Set(cycles | rp.WATCHDOG_TICK_ENABLE)
 }
+
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds (about 8.3s).
+//
+// The watchdog counter is 24 bits wide and nominally counts down once per
+// microsecond, but it counts down twice per tick because of erratum
+// RP2040-E1.
+const WatchdogMaxTimeout = rp.WATCHDOG_LOAD_LOAD_Msk / 1000 / 2
+
+// The value the watchdog counter is reset to on each update.
+var watchdogLoadValue uint32
+
+// The watchdog is stopped while it is configured.
+func watchdogConfigure(timeoutMillis uint32) {
+	// Two ticks per microsecond, see erratum RP2040-E1.
+	watchdogLoadValue = timeoutMillis * 1000 * 2
+
+	watchdog.ctrl.ClearBits(rp.WATCHDOG_CTRL_ENABLE)
+
+	// Reset everything except the oscillators when the watchdog fires.
+	rp.PSM.WDSEL.Set(0x0001ffff &^ (rp.PSM_WDSEL_ROSC | rp.PSM_WDSEL_XOSC))
+
+	// Pause the watchdog while a debugger halts the processors.
+	watchdog.ctrl.SetBits(rp.WATCHDOG_CTRL_PAUSE_DBG0 | rp.WATCHDOG_CTRL_PAUSE_DBG1 | rp.WATCHDOG_CTRL_PAUSE_JTAG)
+
+	watchdog.load.Set(watchdogLoadValue)
+}
+
+func watchdogStart() {
+	watchdog.ctrl.SetBits(rp.WATCHDOG_CTRL_ENABLE)
+}
+
+func watchdogUpdate() {
+	watchdog.load.Set(watchdogLoadValue)
+}
+
+func resetReason() ResetCause {
+	reason := watchdog.reason.Get()
+	switch {
+	case reason&rp.WATCHDOG_REASON_TIMER != 0:
+		return ResetCauseWatchdog
+	case reason&rp.WATCHDOG_REASON_FORCE != 0:
+		return ResetCauseSoftware
+	}
+	chipReset := rp.VREG_AND_CHIP_RESET.CHIP_RESET.Get()
+	switch {
+	case chipReset&rp.VREG_AND_CHIP_RESET_CHIP_RESET_HAD_PSM_RESTART != 0:
+		// Reset from the debug port.
+		return ResetCauseSoftware
+	case chipReset&rp.VREG_AND_CHIP_RESET_CHIP_RESET_HAD_RUN != 0:
+		return ResetCauseExternal
+	case chipReset&rp.VREG_AND_CHIP_RESET_CHIP_RESET_HAD_POR != 0:
+		return ResetCausePowerOn
+	}
+	return ResetCauseUnknown
+}
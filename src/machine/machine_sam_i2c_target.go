@@ -0,0 +1,139 @@
+//go:build sam
+// +build sam
+
+package machine
+
+import (
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// I2C target mode on the SAMD21 and SAMD51, using the SERCOM in I2C slave
+// mode. Both chips use the same register layout for this mode.
+
+type sercomI2CSType struct {
+	ctrla    volatile.Register32
+	ctrlb    volatile.Register32
+	ctrlc    volatile.Register32
+	_        [8]byte
+	intenclr volatile.Register8
+	_        byte
+	intenset volatile.Register8
+	_        byte
+	intflag  volatile.Register8
+	_        byte
+	status   volatile.Register16
+	syncbusy volatile.Register32
+	_        [4]byte
+	addr     volatile.Register32
+	data     volatile.Register8
+}
+
+// Fields of the SERCOM I2C slave registers.
+const (
+	sercomI2CSCtrlaENABLE     = 1 << 1
+	sercomI2CSCtrlaModeTarget = 0x4 << 2
+	sercomI2CSCtrlaSDAHOLDPos = 20
+
+	sercomI2CSCtrlbCMDPos  = 16
+	sercomI2CSCtrlbCMDMsk  = 0x3 << sercomI2CSCtrlbCMDPos
+	sercomI2CSCtrlbACKACT  = 1 << 18
+	sercomI2CSCmdReceive   = 0x3 // send ACK/NACK and wait for the next byte
+	sercomI2CSCmdWaitStart = 0x2 // wait for a new start condition
+
+	sercomI2CSIntPREC   = 1 << 0
+	sercomI2CSIntAMATCH = 1 << 1
+	sercomI2CSIntDRDY   = 1 << 2
+
+	sercomI2CSStatusRXNACK = 1 << 2
+	sercomI2CSStatusDIR    = 1 << 3
+
+	sercomI2CSSyncbusyENABLE = 1 << 1
+
+	sercomI2CSAddrPos = 1
+)
+
+// The I2C buses in target mode, by SERCOM number. The SERCOM interrupt is
+// shared with the UART, whose interrupt handler passes it on.
+var sercomI2CTargets [8]*I2C
+
+// The handlers set with SetTargetHandler, by SERCOM number.
+var sercomI2CTargetHandlers [8]I2CTargetHandler
+
+func (i2c *I2C) targetBus() *sercomI2CSType {
+	return (*sercomI2CSType)(unsafe.Pointer(i2c.Bus))
+}
+
+// SetTargetHandler sets the handler for the events of this bus in target mode.
+func (i2c *I2C) SetTargetHandler(handler I2CTargetHandler) {
+	mask := interrupt.Disable()
+	sercomI2CTargetHandlers[i2c.SERCOM] = handler
+	interrupt.Restore(mask)
+}
+
+// configureTarget configures the SERCOM, which has just been reset, as I2C
+// target at the given address.
+func (i2c *I2C) configureTarget(address uint16) error {
+	if address >= 0x80 || isReservedI2CAddr(uint8(address)) {
+		return errI2CInvalidTargetAddress
+	}
+	bus := i2c.targetBus()
+	bus.ctrla.Set(sercomI2CSCtrlaModeTarget | 2<<sercomI2CSCtrlaSDAHOLDPos) // 300-600ns hold time
+	bus.addr.Set(uint32(address) << sercomI2CSAddrPos)
+	bus.intenset.Set(sercomI2CSIntPREC | sercomI2CSIntAMATCH | sercomI2CSIntDRDY)
+	bus.ctrla.SetBits(sercomI2CSCtrlaENABLE)
+	for bus.syncbusy.HasBits(sercomI2CSSyncbusyENABLE) {
+	}
+	sercomI2CTargets[i2c.SERCOM] = i2c
+	i2c.enableTargetInterrupt()
+	return nil
+}
+
+// targetEvent passes an event to the target handler.
+func (i2c *I2C) targetEvent(event I2CTargetEvent, b byte) byte {
+	handler := sercomI2CTargetHandlers[i2c.SERCOM]
+	if handler == nil {
+		return 0xff
+	}
+	return handler(event, b)
+}
+
+// command acknowledges the address or the last byte and executes the given
+// command.
+func (bus *sercomI2CSType) command(cmd uint32) {
+	bus.ctrlb.ReplaceBits(cmd<<sercomI2CSCtrlbCMDPos, sercomI2CSCtrlbCMDMsk|sercomI2CSCtrlbACKACT, 0)
+}
+
+// handleTargetInterrupt turns the interrupt flags of the SERCOM into events
+// for the target handler.
+func (i2c *I2C) handleTargetInterrupt() {
+	bus := i2c.targetBus()
+	flags := bus.intflag.Get()
+	read := bus.status.HasBits(sercomI2CSStatusDIR)
+	if flags&sercomI2CSIntAMATCH != 0 {
+		addr := byte(bus.addr.Get() >> sercomI2CSAddrPos << 1)
+		if read {
+			addr |= 1
+		}
+		i2c.targetEvent(I2CAddressMatch, addr)
+		bus.command(sercomI2CSCmdReceive) // ACK the address, clears AMATCH
+	}
+	if flags&sercomI2CSIntDRDY != 0 {
+		if read {
+			if bus.status.HasBits(sercomI2CSStatusRXNACK) {
+				// The controller does not want more data.
+				bus.command(sercomI2CSCmdWaitStart)
+			} else {
+				bus.data.Set(i2c.targetEvent(I2CRequest, 0)) // clears DRDY
+			}
+		} else {
+			i2c.targetEvent(I2CReceive, bus.data.Get())
+			bus.command(sercomI2CSCmdReceive) // ACK the byte, clears DRDY
+		}
+	}
+	if flags&sercomI2CSIntPREC != 0 {
+		bus.intflag.Set(sercomI2CSIntPREC)
+		i2c.targetEvent(I2CStop, 0)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build sam
+// +build sam
+
+package machine
+
+import (
+	_ "unsafe" // for go:linkname
+)
+
+// Standby stops all clocks except the 32kHz oscillator of the RTC. Peripherals
+// that run from the main clock (SERCOM, TCC, ADC, DAC, I2S, CAN) stop working
+// in that mode, so their Configure methods set standbyBlocked.
+var standbyBlocked bool
+
+// Set during DeepSleep, which uses standby even when peripherals are in use.
+var deepSleeping bool
+
+// standbyAllowed returns whether the scheduler can put the chip in standby
+// instead of idle while all goroutines are sleeping. Outside of DeepSleep,
+// this is only the case when no peripheral that needs the main clock is used:
+// USB must not be connected to a host (plugging it in wakes up the chip) and
+// no pin interrupt may use edge detection.
+//
+//go:linkname standbyAllowed runtime.machineStandbyAllowed
+func standbyAllowed() bool {
+	if deepSleeping {
+		return true
+	}
+	return !standbyBlocked && usbConfiguration == 0 && !eicNeedsClock()
+}
@@ -0,0 +1,22 @@
+//go:build sam
+// +build sam
+
+package machine
+
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds (16384 cycles of the 1.024kHz watchdog clock).
+const WatchdogMaxTimeout = 16384 * 1000 / 1024
+
+// The timeout is rounded up to the next supported value: 8 clock cycles
+// (about 8ms) multiplied by a power of two.
+func watchdogConfigure(timeoutMillis uint32) {
+	cycles := uint64(timeoutMillis) * 1024 / 1000
+
+	// The period is expressed as a power of two, starting at 8 cycles.
+	period := uint8(0)
+	for periodCycles := uint64(8); periodCycles < cycles && period < 0xb; periodCycles <<= 1 {
+		period++
+	}
+
+	watchdogSetPeriod(period)
+}
@@ -0,0 +1,326 @@
+//go:build stm32f4
+// +build stm32f4
+
+package machine
+
+// Peripheral abstraction layer for the bxCAN controllers on the stm32f4.
+
+import (
+	"device/stm32"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// CAN is a bxCAN controller. CAN2 shares the filter banks of CAN1, so CAN1
+// needs to be clocked (configured) to use CAN2.
+type CAN struct {
+	Bus       *stm32.CAN_Type
+	Interrupt interrupt.Interrupt
+}
+
+var (
+	CAN1 = CAN{Bus: stm32.CAN1}
+	CAN2 = CAN{Bus: stm32.CAN2}
+)
+
+// CANConfig holds CAN configuration parameters. TransferRate defaults to
+// 500kbps.
+type CANConfig struct {
+	TransferRate CANTransferRate
+	Tx           Pin
+	Rx           Pin
+}
+
+// bxcanRegs is the register layout of a bxCAN controller, including the
+// transmit mailboxes, receive FIFOs and (on CAN1) the filter banks.
+type bxcanRegs struct {
+	MCR  volatile.Register32
+	MSR  volatile.Register32
+	TSR  volatile.Register32
+	RF0R volatile.Register32
+	RF1R volatile.Register32
+	IER  volatile.Register32
+	ESR  volatile.Register32
+	BTR  volatile.Register32
+	_    [0x160]byte
+	TX   [3]bxcanMailbox
+	RX   [2]bxcanMailbox
+	_    [0x30]byte
+	FMR  volatile.Register32
+	FM1R volatile.Register32
+	_    uint32
+	FS1R volatile.Register32
+	_    uint32
+	FFA1 volatile.Register32
+	_    uint32
+	FA1R volatile.Register32
+	_    [0x20]byte
+	FB   [28][2]volatile.Register32
+}
+
+type bxcanMailbox struct {
+	IR  volatile.Register32
+	DTR volatile.Register32
+	DLR volatile.Register32
+	DHR volatile.Register32
+}
+
+const (
+	bxcanMCR_INRQ   = 1 << 0
+	bxcanMCR_SLEEP  = 1 << 1
+	bxcanMCR_TXFP   = 1 << 2
+	bxcanMCR_ABOM   = 1 << 6
+	bxcanMSR_INAK   = 1 << 0
+	bxcanTSR_TME0   = 1 << 26
+	bxcanRF0R_FMP   = 0x3
+	bxcanRF0R_RFOM  = 1 << 5
+	bxcanIER_FMPIE0 = 1 << 1
+	bxcanESR_EPVF   = 1 << 1
+	bxcanESR_BOFF   = 1 << 2
+	bxcanFMR_FINIT  = 1 << 0
+	bxcanIR_TXRQ    = 1 << 0
+	bxcanIR_RTR     = 1 << 1
+	bxcanIR_IDE     = 1 << 2
+
+	// Filter banks 0-13 are used by CAN1, 14-27 by CAN2.
+	bxcanBanks = 14
+)
+
+func (can *CAN) regs() *bxcanRegs {
+	return (*bxcanRegs)(unsafe.Pointer(can.Bus))
+}
+
+// filterRegs returns the registers of CAN1, which holds the filter banks of
+// both controllers.
+func (can *CAN) filterRegs() *bxcanRegs {
+	return (*bxcanRegs)(unsafe.Pointer(stm32.CAN1))
+}
+
+func (can *CAN) instance() int {
+	if can.Bus == stm32.CAN2 {
+		return 1
+	}
+	return 0
+}
+
+// Configure this CAN peripheral with the given configuration, and start it
+// accepting all frames.
+func (can *CAN) Configure(config CANConfig) error {
+	if config.TransferRate == 0 {
+		config.TransferRate = CANTransferRate500kbps
+	}
+	btr, ok := canBitTiming(CPUFrequency()/4, uint32(config.TransferRate)) // APB1 frequency
+	if !ok {
+		return errCANInvalidTransferRate
+	}
+
+	enableAltFuncClock(unsafe.Pointer(stm32.CAN1))
+	enableAltFuncClock(unsafe.Pointer(can.Bus))
+	config.Tx.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, AF9_CAN1_CAN2_TIM12_13_14)
+	config.Rx.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, AF9_CAN1_CAN2_TIM12_13_14)
+
+	regs := can.regs()
+	regs.MCR.ClearBits(bxcanMCR_SLEEP)
+	regs.MCR.SetBits(bxcanMCR_INRQ)
+	for !regs.MSR.HasBits(bxcanMSR_INAK) {
+	}
+
+	// Send frames in the order they were queued, and leave bus off
+	// automatically.
+	regs.MCR.SetBits(bxcanMCR_TXFP | bxcanMCR_ABOM)
+	regs.BTR.Set(btr)
+
+	if err := can.SetFilters(nil); err != nil {
+		return err
+	}
+
+	regs.MCR.ClearBits(bxcanMCR_INRQ)
+	for regs.MSR.HasBits(bxcanMSR_INAK) {
+	}
+	return nil
+}
+
+// canBitTiming returns the BTR value for the bitrate, with between 8 and 25
+// time quanta per bit and the sample point near 87.5%.
+func canBitTiming(clock, bitrate uint32) (uint32, bool) {
+	for tq := uint32(25); tq >= 8; tq-- {
+		if clock%(bitrate*tq) != 0 {
+			continue
+		}
+		brp := clock / (bitrate * tq)
+		ts1 := tq*7/8 - 1 // the sync segment is one time quantum
+		ts2 := tq - 1 - ts1
+		if brp > 1024 || ts1 > 16 || ts2 < 1 || ts2 > 8 {
+			continue
+		}
+		return (ts2-1)<<20 | (ts1-1)<<16 | (brp - 1), true
+	}
+	return 0, false
+}
+
+// Send queues a frame in a free transmit mailbox, and returns ErrCANTxFull if
+// all three are in use.
+func (can *CAN) Send(frame *CANFrame) error {
+	if frame.FD {
+		return errCANFDNotSupported
+	}
+	if !frame.valid() {
+		return errCANInvalidFrame
+	}
+	regs := can.regs()
+	tsr := regs.TSR.Get()
+	mb := -1
+	for i := 0; i < 3; i++ {
+		if tsr&(bxcanTSR_TME0<<i) != 0 {
+			mb = i
+			break
+		}
+	}
+	if mb < 0 {
+		return ErrCANTxFull
+	}
+
+	var ir uint32
+	if frame.Extended {
+		ir = frame.ID<<3 | bxcanIR_IDE
+	} else {
+		ir = frame.ID << 21
+	}
+	if frame.Remote {
+		ir |= bxcanIR_RTR
+	}
+	var data [8]byte
+	copy(data[:], frame.Payload())
+	tx := &regs.TX[mb]
+	tx.DTR.Set(uint32(frame.Length))
+	tx.DLR.Set(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24)
+	tx.DHR.Set(uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16 | uint32(data[7])<<24)
+	tx.IR.Set(ir | bxcanIR_TXRQ)
+	return nil
+}
+
+// Receive copies the next frame in receive FIFO 0 into frame. It returns
+// false if the FIFO is empty.
+func (can *CAN) Receive(frame *CANFrame) bool {
+	regs := can.regs()
+	if regs.RF0R.Get()&bxcanRF0R_FMP == 0 {
+		return false
+	}
+	rx := &regs.RX[0]
+	ir := rx.IR.Get()
+	frame.Extended = ir&bxcanIR_IDE != 0
+	if frame.Extended {
+		frame.ID = ir >> 3
+	} else {
+		frame.ID = ir >> 21
+	}
+	frame.Remote = ir&bxcanIR_RTR != 0
+	frame.FD = false
+	frame.BRS = false
+	frame.Length = uint8(rx.DTR.Get() & 0xf)
+	if frame.Length > 8 {
+		frame.Length = 8
+	}
+	lo, hi := rx.DLR.Get(), rx.DHR.Get()
+	for i := 0; i < 4; i++ {
+		frame.Data[i] = byte(lo >> (8 * i))
+		frame.Data[i+4] = byte(hi >> (8 * i))
+	}
+
+	// Release the FIFO output mailbox.
+	regs.RF0R.Set(bxcanRF0R_RFOM)
+	return true
+}
+
+var (
+	canRxHandlers [2]func(*CANFrame)
+	canRxFrames   [2]CANFrame
+)
+
+// SetReceiveHandler sets a function that is called from the CAN interrupt for
+// each received frame. A nil handler disables the interrupt, so that frames
+// can be read with Receive.
+func (can *CAN) SetReceiveHandler(handler func(frame *CANFrame)) {
+	i := can.instance()
+	canRxHandlers[i] = handler
+	if handler == nil {
+		can.regs().IER.ClearBits(bxcanIER_FMPIE0)
+		return
+	}
+	if i == 0 {
+		can.Interrupt = interrupt.New(stm32.IRQ_CAN1_RX0, func(interrupt.Interrupt) { CAN1.handleInterrupt() })
+	} else {
+		can.Interrupt = interrupt.New(stm32.IRQ_CAN2_RX0, func(interrupt.Interrupt) { CAN2.handleInterrupt() })
+	}
+	can.regs().IER.SetBits(bxcanIER_FMPIE0)
+	can.Interrupt.SetPriority(0xc0)
+	can.Interrupt.Enable()
+}
+
+func (can *CAN) handleInterrupt() {
+	i := can.instance()
+	for can.Receive(&canRxFrames[i]) {
+		if handler := canRxHandlers[i]; handler != nil {
+			handler(&canRxFrames[i])
+		}
+	}
+}
+
+// SetFilters sets the frames to receive into receive FIFO 0, using one of the
+// 14 filter banks of the controller for each filter. With no filters, all
+// frames are received.
+func (can *CAN) SetFilters(filters []CANFilter) error {
+	if len(filters) > bxcanBanks {
+		return errCANTooManyFilters
+	}
+	regs := can.filterRegs()
+	first := can.instance() * bxcanBanks
+	banks := uint32(1<<bxcanBanks-1) << first
+
+	regs.FMR.SetBits(bxcanFMR_FINIT)
+	// Give the upper half of the banks to CAN2.
+	regs.FMR.ReplaceBits(bxcanBanks, 0x3f, 8)
+	regs.FA1R.ClearBits(banks)
+	// All banks are 32-bit identifier/mask filters for FIFO 0.
+	regs.FM1R.ClearBits(banks)
+	regs.FS1R.SetBits(banks)
+	regs.FFA1.ClearBits(banks)
+
+	if len(filters) == 0 {
+		// A mask of zero accepts every frame.
+		regs.FB[first][0].Set(0)
+		regs.FB[first][1].Set(0)
+		regs.FA1R.SetBits(1 << first)
+	}
+	for i, f := range filters {
+		var id, mask uint32
+		if f.Extended {
+			id, mask = f.ID<<3|bxcanIR_IDE, f.Mask<<3|bxcanIR_IDE
+		} else {
+			id, mask = f.ID<<21, f.Mask<<21|bxcanIR_IDE
+		}
+		regs.FB[first+i][0].Set(id)
+		regs.FB[first+i][1].Set(mask)
+		regs.FA1R.SetBits(1 << (first + i))
+	}
+
+	regs.FMR.ClearBits(bxcanFMR_FINIT)
+	return nil
+}
+
+// Status returns the error state and error counters of the controller.
+func (can *CAN) Status() CANStatus {
+	esr := can.regs().ESR.Get()
+	status := CANStatus{
+		TxErrors: uint8(esr >> 16),
+		RxErrors: uint8(esr >> 24),
+	}
+	switch {
+	case esr&bxcanESR_BOFF != 0:
+		status.State = CANBusOff
+	case esr&bxcanESR_EPVF != 0:
+		status.State = CANErrorPassive
+	}
+	return status
+}
@@ -0,0 +1,19 @@
+//go:build stm32f4 || stm32l4 || stm32wlx
+// +build stm32f4 stm32l4 stm32wlx
+
+package machine
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+var deviceID [12]byte
+
+// readDeviceID reads the 96-bit unique device ID at uidBase into deviceID.
+func readDeviceID() {
+	for i := 0; i < 3; i++ {
+		w := (*volatile.Register32)(unsafe.Pointer(uintptr(uidBase + i*4))).Get()
+		putDeviceIDWord(deviceID[i*4:], w)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build stm32f4 || stm32l4 || stm32wlx
+// +build stm32f4 stm32l4 stm32wlx
+
+package machine
+
+import (
+	"device/stm32"
+	"errors"
+)
+
+var (
+	errFlashProgrammingError = errors.New("flash programming error")
+	errFlashWriteProtected   = errors.New("flash is write protected")
+)
+
+const (
+	flashKey1 = 0x45670123
+	flashKey2 = 0xCDEF89AB
+)
+
+// unlockFlash unlocks the flash control register, so that the flash can be
+// erased and programmed.
+func unlockFlash() {
+	waitWhileFlashBusy()
+	if stm32.FLASH.CR.HasBits(stm32.FLASH_CR_LOCK) {
+		stm32.FLASH.KEYR.Set(flashKey1)
+		stm32.FLASH.KEYR.Set(flashKey2)
+	}
+}
+
+// lockFlash locks the flash control register again.
+func lockFlash() {
+	stm32.FLASH.CR.SetBits(stm32.FLASH_CR_LOCK)
+}
+
+func waitWhileFlashBusy() {
+	for stm32.FLASH.SR.HasBits(stm32.FLASH_SR_BSY) {
+	}
+}
+
+// flushFlashDataCache discards the flash data cache, which may contain stale
+// data after the flash was erased or written.
+func flushFlashDataCache() {
+	if stm32.FLASH.ACR.HasBits(stm32.FLASH_ACR_DCEN) {
+		stm32.FLASH.ACR.ClearBits(stm32.FLASH_ACR_DCEN)
+		stm32.FLASH.ACR.SetBits(stm32.FLASH_ACR_DCRST)
+		stm32.FLASH.ACR.ClearBits(stm32.FLASH_ACR_DCRST)
+		stm32.FLASH.ACR.SetBits(stm32.FLASH_ACR_DCEN)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build stm32
+// +build stm32
+
+package machine
+
+import (
+	"device/stm32"
+)
+
+// The watchdog is the independent watchdog (IWDG) of the chip. It runs from
+// the internal low-speed oscillator (LSI), which is not very accurate: its
+// nominal frequency is 32kHz on most chips (40kHz on the STM32F1, 37kHz on the
+// STM32L0) and it can deviate a lot more than that between chips. Therefore,
+// the timeout is approximate.
+
+// WatchdogMaxTimeout is the longest supported watchdog timeout in
+// milliseconds (4096 cycles of the LSI clock divided by 256).
+const WatchdogMaxTimeout = 4096 * 256 / 32
+
+const (
+	iwdgKeyUnlock = 0x5555
+	iwdgKeyReload = 0xAAAA
+	iwdgKeyStart  = 0xCCCC
+)
+
+func watchdogConfigure(timeoutMillis uint32) {
+	// Pick the smallest prescaler (4 << pr) that allows this timeout with the
+	// 12-bit reload value, for the best resolution.
+	cycles := uint64(timeoutMillis) * 32
+	pr := uint32(0)
+	prescaler := uint64(4)
+	for pr < 6 && cycles > 0x1000*prescaler {
+		pr++
+		prescaler <<= 1
+	}
+	reload := (cycles + prescaler - 1) / prescaler
+	if reload > 0x1000 {
+		reload = 0x1000
+	}
+	if reload == 0 {
+		reload = 1
+	}
+
+	// The prescaler and reload registers are only updated while the LSI is
+	// running.
+	stm32.RCC.CSR.SetBits(stm32.RCC_CSR_LSION)
+	for !stm32.RCC.CSR.HasBits(stm32.RCC_CSR_LSIRDY) {
+	}
+
+	stm32.IWDG.KR.Set(iwdgKeyUnlock)
+	stm32.IWDG.PR.Set(pr)
+	stm32.IWDG.RLR.Set(uint32(reload - 1))
+	for stm32.IWDG.SR.Get() != 0 {
+		// Wait until the prescaler and reload values are updated.
+	}
+	stm32.IWDG.KR.Set(iwdgKeyReload)
+}
+
+func watchdogStart() {
+	stm32.IWDG.KR.Set(iwdgKeyStart)
+}
+
+func watchdogUpdate() {
+	stm32.IWDG.KR.Set(iwdgKeyReload)
+}
+
+// Reset flags in RCC_CSR that are at the same location on all supported chip
+// families. The power-on and brown-out reset flags differ per family, see
+// rccCSRPowerOnReset and rccCSRBrownoutReset.
+const (
+	rccCSRPinReset      = 1 << 26
+	rccCSRSoftwareReset = 1 << 28
+	rccCSRIWDGReset     = 1 << 29
+	rccCSRWWDGReset     = 1 << 30
+)
+
+// resetCause is the cause of the last reset, see readResetReason.
+var resetCause ResetCause
+
+// The reset flags are kept until a power-on reset, so they are read once at
+// startup and then cleared. Otherwise, a reset would also report the causes
+// of all resets before it.
+func init() {
+	resetCause = readResetReason()
+	stm32.RCC.CSR.SetBits(stm32.RCC_CSR_RMVF)
+}
+
+func resetReason() ResetCause {
+	return resetCause
+}
+
+// readResetReason returns the cause of the last reset from the reset flags.
+// The pin reset flag is also set on every other reset, as the chip drives the
+// reset pin low, so it is checked last.
+func readResetReason() ResetCause {
+	csr := stm32.RCC.CSR.Get()
+	switch {
+	case csr&(rccCSRIWDGReset|rccCSRWWDGReset) != 0:
+		return ResetCauseWatchdog
+	case csr&rccCSRSoftwareReset != 0:
+		return ResetCauseSoftware
+	case csr&rccCSRPowerOnReset != 0:
+		return ResetCausePowerOn
+	case csr&rccCSRBrownoutReset != 0:
+		return ResetCauseBrownout
+	case csr&rccCSRPinReset != 0:
+		return ResetCauseExternal
+	}
+	return ResetCauseUnknown
+}
@@ -12,6 +12,12 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. There is no brown-out reset flag.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // PORRSTF
+	rccCSRBrownoutReset = 0
+)
+
 func CPUFrequency() uint32 {
 	return 72000000
 }
@@ -13,6 +13,13 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. The brown-out reset flag is also
+// set on power-on, so the power-on reset flag takes precedence.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // PORRSTF
+	rccCSRBrownoutReset = 1 << 25 // BORRSTF
+)
+
 const (
 	PA0  = portA + 0
 	PA1  = portA + 1
@@ -792,3 +799,6 @@ func (i2c *I2C) getSpeed(config I2CConfig) uint32 {
 		}
 	}
 }
+
+// Address of the 96-bit unique device ID.
+const uidBase = 0x1FFF7A10
@@ -0,0 +1,129 @@
+//go:build stm32f4
+// +build stm32f4
+
+package machine
+
+import (
+	"device/stm32"
+	"encoding/binary"
+	"unsafe"
+)
+
+// The STM32F4 flash is divided in sectors of different sizes: four sectors of
+// 16kB, one of 64kB and the rest are 128kB (per bank on chips with two banks).
+// The flash data area is aligned to 128kB so that it only contains whole
+// 128kB blocks.
+const (
+	flashBase      = 0x08000000
+	flashBank2Base = 0x08100000 // only on chips with 2MB flash
+)
+
+// WriteAt writes the given number of bytes to the flash data area.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	data, address, err := f.prepareWrite(p, off)
+	if err != nil {
+		return 0, err
+	}
+
+	unlockFlash()
+	defer lockFlash()
+
+	// Program 32 bits at a time, which requires a supply voltage of at least
+	// 2.7V.
+	stm32.FLASH.CR.ReplaceBits(2, 3, stm32.FLASH_CR_PSIZE_Pos)
+	stm32.FLASH.CR.SetBits(stm32.FLASH_CR_PG)
+	for i := 0; i < len(data); i += 4 {
+		*(*uint32)(unsafe.Pointer(address)) = binary.LittleEndian.Uint32(data[i : i+4])
+		waitWhileFlashBusy()
+		if err := checkFlashError(); err != nil {
+			stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_PG)
+			return 0, err
+		}
+		address += 4
+	}
+	stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_PG)
+	flushFlashDataCache()
+	return len(p), nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 32-bit word.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 4
+}
+
+// EraseBlockSize returns the size of an erase block: 128kB, the size of most
+// sectors.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return 128 * 1024
+}
+
+// EraseBlocks erases the given number of 128kB blocks.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	unlockFlash()
+	defer lockFlash()
+
+	for i := int64(0); i < len; i++ {
+		var err error
+		if address == flashBank2Base {
+			// The first 128kB of the second bank are split in four 16kB
+			// sectors and one 64kB sector, just like the start of the first
+			// bank.
+			for sector := uint32(12); sector <= 16 && err == nil; sector++ {
+				err = eraseFlashSector(sector)
+			}
+		} else {
+			err = eraseFlashSector(flashSectorNumber(address))
+		}
+		if err != nil {
+			return err
+		}
+		address += uintptr(f.EraseBlockSize())
+	}
+	flushFlashDataCache()
+	return nil
+}
+
+// flashSectorNumber returns the number of the 128kB sector at the given
+// address.
+func flashSectorNumber(address uintptr) uint32 {
+	if address >= flashBank2Base {
+		// Sectors 17 and up are the 128kB sectors in the second bank.
+		return 17 + uint32(address-flashBank2Base-0x20000)/0x20000
+	}
+	// Sectors 5 and up are the 128kB sectors in the first bank.
+	return 5 + uint32(address-flashBase-0x20000)/0x20000
+}
+
+// eraseFlashSector erases a single flash sector.
+func eraseFlashSector(sector uint32) error {
+	// Sectors in the second bank (12 and up) are numbered starting at 16.
+	snb := sector
+	if sector >= 12 {
+		snb = sector + 4
+	}
+	stm32.FLASH.CR.ReplaceBits(snb, 0x1f, stm32.FLASH_CR_SNB_Pos)
+	stm32.FLASH.CR.SetBits(stm32.FLASH_CR_SER)
+	stm32.FLASH.CR.SetBits(stm32.FLASH_CR_STRT)
+	waitWhileFlashBusy()
+	stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_SER)
+	return checkFlashError()
+}
+
+// checkFlashError returns (and clears) the error of the last flash operation.
+func checkFlashError() error {
+	sr := stm32.FLASH.SR.Get()
+	stm32.FLASH.SR.Set(sr) // clear flags
+	switch {
+	case sr&stm32.FLASH_SR_WRPERR != 0:
+		return errFlashWriteProtected
+	case sr&(stm32.FLASH_SR_PGAERR|stm32.FLASH_SR_PGPERR|stm32.FLASH_SR_PGSERR) != 0:
+		return errFlashProgrammingError
+	}
+	return nil
+}
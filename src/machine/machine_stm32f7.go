@@ -12,6 +12,13 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. The brown-out reset flag is also
+// set on power-on, so the power-on reset flag takes precedence.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // PORRSTF
+	rccCSRBrownoutReset = 1 << 25 // BORRSTF
+)
+
 // Alternative peripheral pin functions
 const (
 	AF0_SYSTEM                               = 0
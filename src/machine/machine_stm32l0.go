@@ -10,6 +10,12 @@ import (
 	"runtime/interrupt"
 )
 
+// Reset flags in RCC_CSR, see resetReason. There is no brown-out reset flag.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // PORRSTF
+	rccCSRBrownoutReset = 0
+)
+
 func CPUFrequency() uint32 {
 	return 32000000
 }
@@ -10,6 +10,14 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. There is only a brown-out reset
+// flag (BORRSTF, bit 27), which is set on power-on and on brown-out alike. It
+// is reported as a power-on reset, as that is by far the most common reason.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // BORRSTF
+	rccCSRBrownoutReset = 0
+)
+
 // Peripheral abstraction layer for the stm32l4
 
 const (
@@ -544,3 +552,6 @@ func initRNG() {
 	stm32.RCC.AHB2ENR.SetBits(stm32.RCC_AHB2ENR_RNGEN)
 	stm32.RNG.CR.SetBits(stm32.RNG_CR_RNGEN)
 }
+
+// Address of the 96-bit unique device ID.
+const uidBase = 0x1FFF7590
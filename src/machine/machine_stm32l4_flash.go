@@ -0,0 +1,153 @@
+//go:build stm32l4 || stm32wlx
+// +build stm32l4 stm32wlx
+
+package machine
+
+import (
+	"device/stm32"
+	"encoding/binary"
+	"unsafe"
+)
+
+// The flash of the STM32L4 and STM32WL is divided in pages of equal size
+// (flashPageSize), and is programmed in 64-bit double words. Some chips have
+// two banks of flashBankSize bytes; the page number is relative to the start
+// of the bank.
+
+const flashBase = 0x08000000
+
+// FLASH_CR_BKER: bank selection for page erase, only on dual-bank chips.
+const flashCRBKER = 1 << 11
+
+// flashPageBuffer holds the new contents of a page while it is rewritten by
+// WriteAt.
+var flashPageBuffer [flashPageSize]byte
+
+// WriteAt writes the given number of bytes to the flash data area.
+//
+// Because of error correction, a double word can only be programmed once after
+// it was erased, so a write that doesn't start and end at a double word
+// boundary can't be padded with 0xff bytes like on other chips. Instead, every
+// page it touches is read, erased and programmed again with the new data. This
+// is a lot slower and wears the flash, so writes should be aligned to
+// WriteBlockSize where possible.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (n int, err error) {
+	if err := checkFlashRange(off, int64(len(p)), f.Size(), errFlashCannotWritePastEOF); err != nil {
+		return 0, err
+	}
+	blockSize := f.WriteBlockSize()
+	if off%blockSize == 0 && int64(len(p))%blockSize == 0 {
+		if err := programFlash(FlashDataStart()+uintptr(off), p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	// Unaligned write: rewrite the pages. The flash data area starts at a page
+	// boundary, so page numbers are also erase block numbers.
+	pageSize := f.EraseBlockSize()
+	for n < len(p) {
+		page := (off + int64(n)) / pageSize
+		start := off + int64(n) - page*pageSize
+		address := FlashDataStart() + uintptr(page*pageSize)
+		current := unsafe.Slice((*byte)(unsafe.Pointer(address)), pageSize)
+		copy(flashPageBuffer[:], current)
+		written := copy(flashPageBuffer[start:], p[n:])
+		if err := f.EraseBlocks(page, 1); err != nil {
+			return n, err
+		}
+		if err := programFlash(address, flashPageBuffer[:]); err != nil {
+			return n, err
+		}
+		n += written
+	}
+	return n, nil
+}
+
+// programFlash programs the data, which must be a multiple of 8 bytes long, to
+// the given address, which must be aligned to 8 bytes. Double words that are
+// all 0xff are skipped: they are left erased, so that they can still be
+// programmed later.
+func programFlash(address uintptr, data []byte) error {
+	unlockFlash()
+	defer lockFlash()
+	checkFlashError() // clear errors of a previous operation
+
+	stm32.FLASH.CR.SetBits(stm32.FLASH_CR_PG)
+	for i := 0; i < len(data); i += 8 {
+		lo := binary.LittleEndian.Uint32(data[i : i+4])
+		hi := binary.LittleEndian.Uint32(data[i+4 : i+8])
+		if lo != 0xffffffff || hi != 0xffffffff {
+			// A double word is programmed by writing both words in order.
+			*(*uint32)(unsafe.Pointer(address)) = lo
+			*(*uint32)(unsafe.Pointer(address + 4)) = hi
+			waitWhileFlashBusy()
+			if err := checkFlashError(); err != nil {
+				stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_PG)
+				return err
+			}
+		}
+		address += 8
+	}
+	stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_PG)
+	flushFlashDataCache()
+	return nil
+}
+
+// WriteBlockSize returns the block size in which data can be written to
+// memory: a 64-bit double word. Writes that are not aligned to it are much
+// slower, see WriteAt.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 8
+}
+
+// EraseBlockSize returns the size of a flash page.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return flashPageSize
+}
+
+// EraseBlocks erases the given number of flash pages.
+func (f flashBlockDevice) EraseBlocks(start, len int64) error {
+	address, err := f.checkErase(start, len)
+	if err != nil {
+		return err
+	}
+
+	unlockFlash()
+	defer lockFlash()
+	checkFlashError() // clear errors of a previous operation
+
+	for i := int64(0); i < len; i++ {
+		offset := uint32(address - flashBase)
+		cr := uint32(stm32.FLASH_CR_PER)
+		if flashBankSize != 0 && offset >= flashBankSize {
+			offset -= flashBankSize
+			cr |= flashCRBKER
+		}
+		stm32.FLASH.CR.ClearBits(flashCRBKER)
+		stm32.FLASH.CR.ReplaceBits(offset/flashPageSize, 0xff, stm32.FLASH_CR_PNB_Pos)
+		stm32.FLASH.CR.SetBits(cr)
+		stm32.FLASH.CR.SetBits(stm32.FLASH_CR_STRT)
+		waitWhileFlashBusy()
+		stm32.FLASH.CR.ClearBits(stm32.FLASH_CR_PER | flashCRBKER)
+		if err := checkFlashError(); err != nil {
+			return err
+		}
+		address += uintptr(f.EraseBlockSize())
+	}
+	flushFlashDataCache()
+	return nil
+}
+
+// checkFlashError returns (and clears) the error of the last flash operation.
+func checkFlashError() error {
+	sr := stm32.FLASH.SR.Get()
+	stm32.FLASH.SR.Set(sr) // clear flags
+	switch {
+	case sr&stm32.FLASH_SR_WRPERR != 0:
+		return errFlashWriteProtected
+	case sr&(stm32.FLASH_SR_PROGERR|stm32.FLASH_SR_PGAERR|stm32.FLASH_SR_SIZERR|stm32.FLASH_SR_PGSERR) != 0:
+		return errFlashProgrammingError
+	}
+	return nil
+}
@@ -15,6 +15,12 @@ func CPUFrequency() uint32 {
 const APB1_TIM_FREQ = 80e6 // 80MHz
 const APB2_TIM_FREQ = 80e6 // 80MHz
 
+// Flash layout: 2kB pages, single bank.
+const (
+	flashPageSize = 2048
+	flashBankSize = 0
+)
+
 //---------- I2C related code
 
 // Gets the value for TIMINGR register
@@ -15,6 +15,12 @@ func CPUFrequency() uint32 {
 const APB1_TIM_FREQ = 120e6 // 120MHz
 const APB2_TIM_FREQ = 120e6 // 120MHz
 
+// Flash layout: 4kB pages, two banks of 1MB (dual-bank mode, the default).
+const (
+	flashPageSize = 4096
+	flashBankSize = 1024 * 1024
+)
+
 //---------- I2C related code
 
 // Gets the value for TIMINGR register
@@ -12,6 +12,14 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. There is only a brown-out reset
+// flag (BORRSTF, bit 27), which is set on power-on and on brown-out alike. It
+// is reported as a power-on reset, as that is by far the most common reason.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // BORRSTF
+	rccCSRBrownoutReset = 0
+)
+
 const (
 	AF0_SYSTEM                                = 0
 	AF1_TIM1_2_5_8_LPTIM1                     = 1
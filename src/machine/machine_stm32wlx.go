@@ -13,6 +13,14 @@ import (
 	"unsafe"
 )
 
+// Reset flags in RCC_CSR, see resetReason. There is only a brown-out reset
+// flag (BORRSTF, bit 27), which is set on power-on and on brown-out alike. It
+// is reported as a power-on reset, as that is by far the most common reason.
+const (
+	rccCSRPowerOnReset  = 1 << 27 // BORRSTF
+	rccCSRBrownoutReset = 0
+)
+
 const (
 	AF0_SYSTEM             = 0
 	AF1_TIM1_2_LPTIM1      = 1
@@ -39,6 +47,12 @@ func CPUFrequency() uint32 {
 	return SYSCLK
 }
 
+// Flash layout: 2kB pages, single bank.
+const (
+	flashPageSize = 2048
+	flashBankSize = 0
+)
+
 const (
 	PA0  = portA + 0
 	PA1  = portA + 1
@@ -420,3 +434,6 @@ const (
 	ARR_MAX = 0x10000
 	PSC_MAX = 0x10000
 )
+
+// Address of the 96-bit unique device ID.
+const uidBase = 0x1FFF7590
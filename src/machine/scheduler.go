@@ -0,0 +1,12 @@
+package machine
+
+import (
+	"internal/task"
+	_ "unsafe" // for go:linkname
+)
+
+// resumeTask makes a paused goroutine runnable again. It may be called from an
+// interrupt, to wake up a goroutine waiting for a hardware event.
+//
+//go:linkname resumeTask runtime.resumeTask
+func resumeTask(t *task.Task)
@@ -0,0 +1,89 @@
+//go:build linux && !baremetal
+// +build linux,!baremetal
+
+package sim_test
+
+import (
+	"machine"
+	"testing"
+)
+
+func TestCANFilter(t *testing.T) {
+	filter := machine.CANFilter{ID: 0x120, Mask: 0x7f0}
+	for _, tc := range []struct {
+		frame machine.CANFrame
+		match bool
+	}{
+		{machine.CANFrame{ID: 0x123}, true},
+		{machine.CANFrame{ID: 0x12f}, true},
+		{machine.CANFrame{ID: 0x133}, false},
+		{machine.CANFrame{ID: 0x123, Extended: true}, false},
+	} {
+		if got := filter.Match(&tc.frame); got != tc.match {
+			t.Errorf("match %#x (extended: %v): expected %v, got %v", tc.frame.ID, tc.frame.Extended, tc.match, got)
+		}
+	}
+}
+
+func TestCANDlc(t *testing.T) {
+	for _, tc := range []struct {
+		length, dlc byte
+	}{
+		{0, 0}, {8, 8}, {12, 9}, {16, 10}, {20, 11}, {24, 12}, {32, 13}, {48, 14}, {64, 15},
+	} {
+		if dlc := machine.CANLengthToDlc(tc.length, true); dlc != tc.dlc {
+			t.Errorf("length %d: expected DLC %d, got %d", tc.length, tc.dlc, dlc)
+		}
+		if length := machine.CANDlcToLength(tc.dlc, true); length != tc.length {
+			t.Errorf("DLC %d: expected length %d, got %d", tc.dlc, tc.length, length)
+		}
+	}
+}
+
+// TestCANLoopback sends frames between two sockets on a virtual CAN interface:
+//
+//	ip link add dev vcan0 type vcan
+//	ip link set up vcan0
+func TestCANLoopback(t *testing.T) {
+	tx, rx := machine.CAN0, machine.CAN1
+	if err := tx.Configure(machine.CANConfig{Interface: "vcan0"}); err != nil {
+		t.Skip("vcan0 not available:", err)
+	}
+	if err := rx.Configure(machine.CANConfig{Interface: "vcan0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rx.SetFilters([]machine.CANFilter{{ID: 0x100, Mask: 0x700}, {ID: 0x18000000, Mask: 0x1f000000, Extended: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := []machine.CANFrame{
+		{ID: 0x200, Length: 1},
+		{ID: 0x123, Length: 2, Data: [64]byte{1, 2}},
+		{ID: 0x18daf110, Extended: true, Length: 8, Data: [64]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{ID: 0x101, FD: true, BRS: true, Length: 12},
+	}
+	for i := range frames {
+		if err := tx.Send(&frames[i]); err != nil && !frames[i].FD {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Send(&machine.CANFrame{ID: 0x800}); err == nil {
+		t.Error("expected an error for an invalid standard ID")
+	}
+
+	var frame machine.CANFrame
+	for _, expected := range frames[1:] {
+		if !rx.Receive(&frame) {
+			if expected.FD {
+				break // the interface doesn't support CAN FD
+			}
+			t.Fatalf("frame %#x not received", expected.ID)
+		}
+		if frame.ID != expected.ID || frame.Extended != expected.Extended || frame.FD != expected.FD || string(frame.Payload()) != string(expected.Payload()) {
+			t.Errorf("expected frame %+v, got %+v", expected, frame)
+		}
+	}
+	if rx.Receive(&frame) {
+		t.Errorf("unexpected frame %#x", frame.ID)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim
+
+// This file contains a few simple device models, that behave like many common
+// sensors and memory chips. They can be used directly in tests, or serve as an
+// example to write more specific device models.
+
+// I2CRegisters is an I2C device with 256 8-bit registers, like many sensors
+// and small EEPROMs. The first byte written in a transaction selects the
+// register, the following bytes are written to consecutive registers. Reads
+// return consecutive registers starting at the selected register.
+//
+// This matches machine.I2C.ReadRegister and machine.I2C.WriteRegister.
+type I2CRegisters struct {
+	Registers [256]byte
+
+	// Currently selected register.
+	reg uint8
+}
+
+// Tx implements I2CDevice.
+func (d *I2CRegisters) Tx(w, r []byte) error {
+	if len(w) != 0 {
+		d.reg = w[0]
+		for _, b := range w[1:] {
+			d.Registers[d.reg] = b
+			d.reg++
+		}
+	}
+	for i := range r {
+		r[i] = d.Registers[d.reg]
+		d.reg++
+	}
+	return nil
+}
+
+// SPIRegisters is an SPI device with 128 8-bit registers, using the protocol
+// used by many sensors: the first byte after selecting the device is the
+// register address, with the highest bit set for a read. The following bytes
+// read or write consecutive registers.
+type SPIRegisters struct {
+	Registers [128]byte
+
+	state int // 0: waiting for address, 1: writing, 2: reading
+	reg   uint8
+}
+
+// Select implements SPIDevice.
+func (d *SPIRegisters) Select(selected bool) {
+	d.state = 0
+}
+
+// Transfer implements SPIDevice.
+func (d *SPIRegisters) Transfer(w byte) byte {
+	switch d.state {
+	case 0:
+		d.reg = w & 0x7f
+		d.state = 1
+		if w&0x80 != 0 {
+			d.state = 2
+		}
+		return 0
+	case 1:
+		d.Registers[d.reg] = w
+		d.reg = (d.reg + 1) & 0x7f
+		return 0
+	default:
+		r := d.Registers[d.reg]
+		d.reg = (d.reg + 1) & 0x7f
+		return r
+	}
+}
+
+// SPILoopback is an SPI device that returns every byte it receives, as if the
+// SDO and SDI pins were connected to each other.
+type SPILoopback struct{}
+
+// Select implements SPIDevice.
+func (SPILoopback) Select(selected bool) {}
+
+// Transfer implements SPIDevice.
+func (SPILoopback) Transfer(w byte) byte {
+	return w
+}
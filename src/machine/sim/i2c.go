@@ -0,0 +1,117 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim
+
+import (
+	"errors"
+	"machine"
+	"unsafe"
+)
+
+// I2CDevice is a device model that can be connected to a simulated I2C bus
+// with AddI2CDevice.
+type I2CDevice interface {
+	// Tx is called for each transaction addressed to the device. The w slice
+	// contains the bytes written by the program and r must be filled with the
+	// bytes that the program reads. Returning an error results in a bus error
+	// in the program.
+	Tx(w, r []byte) error
+}
+
+// ErrNoDevice is the error recorded in the event log for transactions to an
+// address without a device.
+var ErrNoDevice = errors.New("no device at address")
+
+type i2cAddress struct {
+	bus  uint8
+	addr uint16
+}
+
+var i2cDevices map[i2cAddress]I2CDevice
+
+// AddI2CDevice connects a device model to the given I2C bus, at the given
+// address. It replaces any device already connected at this address.
+func AddI2CDevice(bus uint8, addr uint16, dev I2CDevice) {
+	if i2cDevices == nil {
+		i2cDevices = make(map[i2cAddress]I2CDevice)
+	}
+	i2cDevices[i2cAddress{bus, addr}] = dev
+}
+
+// RemoveI2CDevice disconnects the device at the given address, after which
+// transactions to this address fail as if the device doesn't acknowledge.
+func RemoveI2CDevice(bus uint8, addr uint16) {
+	delete(i2cDevices, i2cAddress{bus, addr})
+}
+
+//export __tinygo_i2c_configure
+func i2cConfigure(bus uint8, scl, sda machine.Pin) {
+}
+
+//export __tinygo_i2c_tx
+func i2cTransfer(bus uint8, addr uint16, w *byte, wlen int, r *byte, rlen int) int {
+	wbuf := unsafe.Slice(w, wlen)
+	rbuf := unsafe.Slice(r, rlen)
+	event := Event{Kind: I2CTransfer, Bus: bus, Addr: addr, W: append([]byte(nil), wbuf...)}
+	dev := i2cDevices[i2cAddress{bus, addr}]
+	if dev == nil {
+		event.Err = ErrNoDevice
+		logEvent(event)
+		return 1
+	}
+	err := dev.Tx(wbuf, rbuf)
+	event.R = append([]byte(nil), rbuf...)
+	event.Err = err
+	logEvent(event)
+	if err != nil {
+		return 2
+	}
+	return 0
+}
+
+// The target address of each bus that the program configured in target mode.
+var i2cTargets map[uint8]uint16
+
+// I2CControllerTx performs a transaction on the given bus as an external
+// controller would, for testing programs that use the bus in target mode. It
+// writes w to and then reads r from the target at addr, passing the events to
+// the handler set with I2C.SetTargetHandler. It returns ErrNoDevice if the
+// program did not configure the bus as a target at this address.
+func I2CControllerTx(bus uint8, addr uint16, w, r []byte) error {
+	event := Event{Kind: I2CTargetTransfer, Bus: bus, Addr: addr, W: append([]byte(nil), w...)}
+	if target, ok := i2cTargets[bus]; !ok || target != addr {
+		event.Err = ErrNoDevice
+		logEvent(event)
+		return ErrNoDevice
+	}
+	if len(w) != 0 || len(r) == 0 {
+		i2cTargetEvent(bus, machine.I2CAddressMatch, byte(addr<<1))
+		for _, b := range w {
+			i2cTargetEvent(bus, machine.I2CReceive, b)
+		}
+	}
+	if len(r) != 0 {
+		i2cTargetEvent(bus, machine.I2CAddressMatch, byte(addr<<1)|1)
+		for i := range r {
+			r[i] = i2cTargetEvent(bus, machine.I2CRequest, 0)
+		}
+	}
+	i2cTargetEvent(bus, machine.I2CStop, 0)
+	event.R = append([]byte(nil), r...)
+	logEvent(event)
+	return nil
+}
+
+//export __tinygo_i2c_target_configure
+func i2cTargetConfigure(bus uint8, addr uint16) {
+	if i2cTargets == nil {
+		i2cTargets = make(map[uint8]uint16)
+	}
+	i2cTargets[bus] = addr
+}
+
+// Implemented in the machine package, to call the target event handler.
+//
+//export __tinygo_i2c_target_event
+func i2cTargetEvent(bus uint8, event machine.I2CTargetEvent, b byte) byte
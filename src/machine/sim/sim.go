@@ -0,0 +1,275 @@
+//go:build !baremetal
+// +build !baremetal
+
+// Package sim implements a virtual board for the generic machine package, so
+// that code using machine (such as drivers) can be tested on the host.
+//
+// The generic machine package, which is used when not compiling for a
+// microcontroller, calls out to external functions for all hardware access.
+// Importing this package provides those functions: GPIO pins, I2C and SPI
+// buses, UARTs and ADCs are simulated in memory. Tests can connect device
+// models to the I2C and SPI buses, change the level of input pins, feed data
+// to UARTs and check everything the program did using the event log:
+//
+//	sim.AddI2CDevice(0, 0x48, &sim.I2CRegisters{})
+//	machine.I2C0.Configure(machine.I2CConfig{})
+//	machine.I2C0.WriteRegister(0x48, 0x01, []byte{0x60})
+//	for _, event := range sim.Log() {
+//		println(event.String()) // i2c0 0x48 w=0160
+//	}
+//
+// The simulation is not safe for concurrent use: all hardware access and all
+// calls to this package should happen from a single goroutine.
+package sim
+
+import (
+	"encoding/hex"
+	"machine"
+	"strconv"
+)
+
+// EventKind is the kind of an Event.
+type EventKind uint8
+
+const (
+	// The program configured a pin.
+	PinConfigure EventKind = iota
+
+	// The program set the level of an output pin.
+	PinSet
+
+	// The simulation changed the level of an input pin, see SetInput.
+	PinInput
+
+	// An I2C transaction.
+	I2CTransfer
+
+	// One or more bytes transferred over SPI. Consecutive bytes are combined
+	// into one event until the chip select pin changes.
+	SPITransfer
+
+	// The program wrote to a UART.
+	UARTWrite
+
+	// An I2C transaction started by I2CControllerTx, with the program as
+	// target.
+	I2CTargetTransfer
+)
+
+// Event is something that happened on the virtual board. Events are recorded
+// in the log returned by Log.
+type Event struct {
+	Kind EventKind
+
+	// Bus number, for I2C, SPI and UART events.
+	Bus uint8
+
+	// Pin, for GPIO events.
+	Pin machine.Pin
+
+	// Pin mode, for PinConfigure events.
+	Mode machine.PinMode
+
+	// Pin level, for PinSet and PinInput events.
+	Level bool
+
+	// Device address, for I2C events.
+	Addr uint16
+
+	// Bytes written by the program (W) and read by the program (R). For
+	// I2CTargetTransfer events, these are the bytes written and read by the
+	// simulated controller instead.
+	W, R []byte
+
+	// Error returned by the I2C device, if any.
+	Err error
+}
+
+// String returns a short description of the event, useful to compare against
+// in tests. For example:
+//
+//	gpio 3 high
+//	i2c0 0x48 w=01 r=6000
+//	i2c0 target 0x42 w=10 r=ab
+//	spi0 w=9f0000 r=00ef40
+//	uart0 "hello"
+func (e Event) String() string {
+	switch e.Kind {
+	case PinConfigure:
+		return "gpio " + strconv.Itoa(int(e.Pin)) + " mode " + strconv.Itoa(int(e.Mode))
+	case PinSet:
+		return "gpio " + strconv.Itoa(int(e.Pin)) + " " + levelString(e.Level)
+	case PinInput:
+		return "input " + strconv.Itoa(int(e.Pin)) + " " + levelString(e.Level)
+	case I2CTransfer, I2CTargetTransfer:
+		addr := []byte{byte(e.Addr)}
+		if e.Addr > 0xff {
+			// 10-bit address.
+			addr = []byte{byte(e.Addr >> 8), byte(e.Addr)}
+		}
+		s := "i2c" + strconv.Itoa(int(e.Bus))
+		if e.Kind == I2CTargetTransfer {
+			s += " target"
+		}
+		s += " 0x" + hex.EncodeToString(addr)
+		if len(e.W) != 0 {
+			s += " w=" + hex.EncodeToString(e.W)
+		}
+		if len(e.R) != 0 {
+			s += " r=" + hex.EncodeToString(e.R)
+		}
+		if e.Err != nil {
+			s += " err=" + e.Err.Error()
+		}
+		return s
+	case SPITransfer:
+		return "spi" + strconv.Itoa(int(e.Bus)) + " w=" + hex.EncodeToString(e.W) + " r=" + hex.EncodeToString(e.R)
+	case UARTWrite:
+		return "uart" + strconv.Itoa(int(e.Bus)) + " " + strconv.Quote(string(e.W))
+	default:
+		return "unknown event"
+	}
+}
+
+// pinState is the simulated state of a single GPIO pin.
+type pinState struct {
+	configured bool
+	mode       machine.PinMode
+	output     bool // level set by the program
+	input      bool // level set by SetInput
+	inputSet   bool // whether SetInput was called for this pin
+}
+
+var (
+	events []Event
+	pins   map[machine.Pin]*pinState
+	adcs   map[machine.Pin]uint16
+)
+
+// Reset removes all devices, clears the event log and resets all pins, UARTs,
+// ADCs and the watchdog to their initial state. It is typically called at the
+// start of each test.
+func Reset() {
+	events = nil
+	pins = nil
+	adcs = nil
+	i2cDevices = nil
+	i2cTargets = nil
+	spiDevices = nil
+	uarts = nil
+	watchdog = watchdogState{}
+	resetCause = machine.ResetCausePowerOn
+}
+
+// Log returns all events since the start of the program or the last call to
+// Reset or ClearLog.
+func Log() []Event {
+	return events
+}
+
+// ClearLog clears the event log.
+func ClearLog() {
+	events = nil
+}
+
+func logEvent(e Event) {
+	events = append(events, e)
+}
+
+func getPin(pin machine.Pin) *pinState {
+	if pins == nil {
+		pins = make(map[machine.Pin]*pinState)
+	}
+	p := pins[pin]
+	if p == nil {
+		p = &pinState{}
+		pins[pin] = p
+	}
+	return p
+}
+
+// Mode returns the mode the pin was configured with. The second return value
+// is false if the pin was never configured.
+func Mode(pin machine.Pin) (mode machine.PinMode, configured bool) {
+	p := getPin(pin)
+	return p.mode, p.configured
+}
+
+// Level returns the current level of the pin: the level set by the program
+// for output pins and the level set by SetInput (or the pull-up or pull-down
+// state) for input pins.
+func Level(pin machine.Pin) bool {
+	return getPin(pin).level()
+}
+
+func (p *pinState) level() bool {
+	switch {
+	case p.mode == machine.PinOutput:
+		return p.output
+	case p.inputSet:
+		return p.input
+	default:
+		return p.mode == machine.PinInputPullup
+	}
+}
+
+// SetInput sets the level of an input pin, as if it was driven by external
+// hardware (a button, for example). When this changes the level of the pin,
+// the interrupt callback set with Pin.SetInterrupt is called.
+func SetInput(pin machine.Pin, level bool) {
+	p := getPin(pin)
+	old := p.level()
+	p.input = level
+	p.inputSet = true
+	logEvent(Event{Kind: PinInput, Pin: pin, Level: level})
+	if p.level() != old {
+		gpioInterrupt(pin, level)
+	}
+}
+
+// SetADC sets the value that is returned when the program reads the ADC
+// connected to this pin.
+func SetADC(pin machine.Pin, value uint16) {
+	if adcs == nil {
+		adcs = make(map[machine.Pin]uint16)
+	}
+	adcs[pin] = value
+}
+
+// Implemented in the machine package, to call pin change interrupts.
+//
+//export __tinygo_gpio_interrupt
+func gpioInterrupt(pin machine.Pin, level bool)
+
+//export __tinygo_gpio_configure
+func gpioConfigure(pin machine.Pin, config machine.PinConfig) {
+	p := getPin(pin)
+	p.configured = true
+	p.mode = config.Mode
+	logEvent(Event{Kind: PinConfigure, Pin: pin, Mode: config.Mode})
+	spiUpdateSelect(pin)
+}
+
+//export __tinygo_gpio_set
+func gpioSet(pin machine.Pin, value bool) {
+	getPin(pin).output = value
+	logEvent(Event{Kind: PinSet, Pin: pin, Level: value})
+	spiUpdateSelect(pin)
+}
+
+//export __tinygo_gpio_get
+func gpioGet(pin machine.Pin) bool {
+	return getPin(pin).level()
+}
+
+//export __tinygo_adc_read
+func adcRead(pin machine.Pin) uint16 {
+	return adcs[pin]
+}
+
+func levelString(level bool) string {
+	if level {
+		return "high"
+	}
+	return "low"
+}
@@ -0,0 +1,429 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim_test
+
+import (
+	"machine"
+	"machine/sim"
+	"testing"
+)
+
+func logStrings() []string {
+	var s []string
+	for _, e := range sim.Log() {
+		s = append(s, e.String())
+	}
+	return s
+}
+
+func checkLog(t *testing.T, expected ...string) {
+	t.Helper()
+	got := logStrings()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %q", len(expected), len(got), got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %q, got %q", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestGPIO(t *testing.T) {
+	sim.Reset()
+
+	led := machine.Pin(3)
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	led.Set(true)
+	if !sim.Level(led) {
+		t.Error("expected pin to be high")
+	}
+	led.Set(false)
+	if mode, configured := sim.Mode(led); !configured || mode != machine.PinOutput {
+		t.Errorf("unexpected pin mode: %d (configured: %v)", mode, configured)
+	}
+	checkLog(t, "gpio 3 mode 1", "gpio 3 high", "gpio 3 low")
+
+	button := machine.Pin(4)
+	button.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	if !button.Get() {
+		t.Error("expected pull-up pin to be high")
+	}
+	sim.SetInput(button, false)
+	if button.Get() {
+		t.Error("expected input pin to be low")
+	}
+}
+
+func TestPinInterrupt(t *testing.T) {
+	sim.Reset()
+
+	pin := machine.Pin(5)
+	pin.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
+	count := 0
+	err := pin.SetInterrupt(machine.PinRising, func(p machine.Pin) {
+		if p != pin {
+			t.Errorf("interrupt for unexpected pin %d", p)
+		}
+		count++
+	})
+	if err != nil {
+		t.Fatal("SetInterrupt:", err)
+	}
+
+	sim.SetInput(pin, true)  // rising edge
+	sim.SetInput(pin, true)  // no change
+	sim.SetInput(pin, false) // falling edge, ignored
+	sim.SetInput(pin, true)  // rising edge
+	if count != 2 {
+		t.Errorf("expected 2 interrupts, got %d", count)
+	}
+
+	pin.SetInterrupt(0, nil)
+	sim.SetInput(pin, false)
+	sim.SetInput(pin, true)
+	if count != 2 {
+		t.Errorf("interrupt called after it was removed")
+	}
+}
+
+func TestI2C(t *testing.T) {
+	sim.Reset()
+
+	dev := &sim.I2CRegisters{}
+	dev.Registers[0x10] = 0xab
+	dev.Registers[0x11] = 0xcd
+	sim.AddI2CDevice(0, 0x48, dev)
+
+	machine.I2C0.Configure(machine.I2CConfig{})
+	if err := machine.I2C0.WriteRegister(0x48, 0x01, []byte{0x60, 0x70}); err != nil {
+		t.Fatal("WriteRegister:", err)
+	}
+	if dev.Registers[0x01] != 0x60 || dev.Registers[0x02] != 0x70 {
+		t.Errorf("unexpected register values: %#x %#x", dev.Registers[0x01], dev.Registers[0x02])
+	}
+	buf := make([]byte, 2)
+	if err := machine.I2C0.ReadRegister(0x48, 0x10, buf); err != nil {
+		t.Fatal("ReadRegister:", err)
+	}
+	if buf[0] != 0xab || buf[1] != 0xcd {
+		t.Errorf("unexpected data read: %x", buf)
+	}
+
+	// There is no device at this address.
+	if err := machine.I2C0.Tx(0x50, []byte{0}, nil); err == nil {
+		t.Error("expected error for missing device")
+	}
+
+	checkLog(t,
+		"i2c0 0x48 w=016070",
+		"i2c0 0x48 w=10 r=abcd",
+		"i2c0 0x50 w=00 err="+sim.ErrNoDevice.Error(),
+	)
+}
+
+func TestSPI(t *testing.T) {
+	sim.Reset()
+
+	cs := machine.Pin(10)
+	dev := &sim.SPIRegisters{}
+	dev.Registers[0x0f] = 0x33
+	sim.AddSPIDevice(0, cs, dev)
+
+	cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	cs.Set(true)
+	machine.SPI0.Configure(machine.SPIConfig{})
+
+	// Not selected, so the bus returns 0xff.
+	if r, _ := machine.SPI0.Transfer(0x8f); r != 0xff {
+		t.Errorf("expected 0xff without a selected device, got %#x", r)
+	}
+
+	// Read a register.
+	cs.Set(false)
+	machine.SPI0.Transfer(0x8f)
+	r, _ := machine.SPI0.Transfer(0)
+	cs.Set(true)
+	if r != 0x33 {
+		t.Errorf("expected 0x33, got %#x", r)
+	}
+
+	// Write two registers.
+	cs.Set(false)
+	machine.SPI0.Transfer(0x20)
+	machine.SPI0.Transfer(0x01)
+	machine.SPI0.Transfer(0x02)
+	cs.Set(true)
+	if dev.Registers[0x20] != 0x01 || dev.Registers[0x21] != 0x02 {
+		t.Errorf("unexpected register values: %#x %#x", dev.Registers[0x20], dev.Registers[0x21])
+	}
+
+	checkLog(t,
+		"gpio 10 mode 1",
+		"gpio 10 high",
+		"spi0 w=8f r=ff",
+		"gpio 10 low",
+		"spi0 w=8f00 r=0033",
+		"gpio 10 high",
+		"gpio 10 low",
+		"spi0 w=200102 r=000000",
+		"gpio 10 high",
+	)
+}
+
+func TestSPILoopback(t *testing.T) {
+	sim.Reset()
+	sim.AddSPIDevice(1, machine.NoPin, sim.SPILoopback{})
+	spi := machine.SPI{Bus: 1}
+	for _, b := range []byte{0x00, 0x5a, 0xff} {
+		if r, _ := spi.Transfer(b); r != b {
+			t.Errorf("expected %#x, got %#x", b, r)
+		}
+	}
+}
+
+func TestUART(t *testing.T) {
+	sim.Reset()
+
+	uart := machine.UART0
+	uart.Configure(machine.UARTConfig{})
+	uart.Write([]byte("hello"))
+	uart.WriteByte('!')
+	if out := string(sim.UARTOutput(0)); out != "hello!" {
+		t.Errorf("unexpected UART output: %q", out)
+	}
+	checkLog(t, `uart0 "hello"`, `uart0 "!"`)
+
+	if _, err := uart.ReadByte(); err == nil {
+		t.Error("expected error when reading from an empty UART")
+	}
+	sim.UARTInput(0, []byte("abc"))
+	b, err := uart.ReadByte()
+	if err != nil || b != 'a' {
+		t.Errorf("ReadByte: got %q, %v", b, err)
+	}
+	buf := make([]byte, 8)
+	n, _ := uart.Read(buf)
+	if string(buf[:n]) != "bc" {
+		t.Errorf("Read: got %q", buf[:n])
+	}
+}
+
+func TestADC(t *testing.T) {
+	sim.Reset()
+
+	machine.InitADC()
+	adc := machine.ADC{Pin: machine.Pin(26)}
+	adc.Configure(machine.ADCConfig{})
+	if v := adc.Get(); v != 0 {
+		t.Errorf("expected 0 before SetADC, got %d", v)
+	}
+	sim.SetADC(adc.Pin, 0x8000)
+	if v := adc.Get(); v != 0x8000 {
+		t.Errorf("expected 0x8000, got %#x", v)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	sim.Reset()
+
+	if cause := machine.ResetReason(); cause != machine.ResetCausePowerOn {
+		t.Errorf("expected power-on reset, got %s", cause)
+	}
+	sim.SetResetReason(machine.ResetCauseWatchdog)
+	if cause := machine.ResetReason(); cause != machine.ResetCauseWatchdog {
+		t.Errorf("expected watchdog reset, got %s", cause)
+	}
+
+	machine.Watchdog.Configure(machine.WatchdogConfig{TimeoutMillis: 100})
+	if sim.AdvanceWatchdog(1000) {
+		t.Error("watchdog expired before it was started")
+	}
+	machine.Watchdog.Start()
+	if timeout, started := sim.WatchdogTimeout(); timeout != 100 || !started {
+		t.Errorf("unexpected watchdog state: timeout=%d started=%v", timeout, started)
+	}
+	for i := 0; i < 5; i++ {
+		if sim.AdvanceWatchdog(60) {
+			t.Fatal("watchdog expired while it was updated")
+		}
+		machine.Watchdog.Update()
+	}
+	if n := sim.WatchdogUpdates(); n != 5 {
+		t.Errorf("expected 5 updates, got %d", n)
+	}
+	sim.AdvanceWatchdog(60)
+	if !sim.AdvanceWatchdog(60) {
+		t.Error("watchdog did not expire")
+	}
+}
+
+func TestFlash(t *testing.T) {
+	flash := machine.Flash
+	blockSize := flash.EraseBlockSize()
+	if err := flash.EraseBlocks(0, 2); err != nil {
+		t.Fatal("EraseBlocks:", err)
+	}
+
+	// Unaligned writes, across an erase block boundary.
+	data := []byte("hello, flash")
+	off := blockSize - 5
+	if n, err := flash.WriteAt(data, off); err != nil || n != len(data) {
+		t.Fatalf("WriteAt: n=%d err=%v", n, err)
+	}
+	buf := make([]byte, len(data)+2)
+	if _, err := flash.ReadAt(buf, off-1); err != nil {
+		t.Fatal("ReadAt:", err)
+	}
+	if string(buf[1:len(data)+1]) != string(data) || buf[0] != 0xff || buf[len(buf)-1] != 0xff {
+		t.Errorf("unexpected flash contents: %q", buf)
+	}
+
+	// Writing without erasing can only clear bits.
+	flash.WriteAt([]byte{0xf0}, 0)
+	flash.WriteAt([]byte{0x0f}, 0)
+	flash.ReadAt(buf[:1], 0)
+	if buf[0] != 0x00 {
+		t.Errorf("expected 0x00 after two writes, got %#x", buf[0])
+	}
+
+	// Erasing only affects the given blocks.
+	if err := flash.EraseBlocks(0, 1); err != nil {
+		t.Fatal("EraseBlocks:", err)
+	}
+	flash.ReadAt(buf, off-1)
+	if buf[1] != 0xff || string(buf[6:len(data)+1]) != string(data[5:]) {
+		t.Errorf("unexpected flash contents after erase: %q", buf)
+	}
+
+	// Out of range accesses.
+	if _, err := flash.ReadAt(buf, flash.Size()-1); err == nil {
+		t.Error("expected error when reading past the end")
+	}
+	if _, err := flash.WriteAt(buf, -1); err == nil {
+		t.Error("expected error for a negative offset")
+	}
+	if err := flash.EraseBlocks(flash.Size()/blockSize, 1); err == nil {
+		t.Error("expected error when erasing past the end")
+	}
+}
+
+func TestAsync(t *testing.T) {
+	sim.Reset()
+	sim.AddSPIDevice(1, machine.NoPin, sim.SPILoopback{})
+	dev := &sim.I2CRegisters{}
+	dev.Registers[0x20] = 0x42
+	sim.AddI2CDevice(0, 0x48, dev)
+
+	spi := machine.SPI{Bus: 1}
+	w := []byte{1, 2, 3}
+	r := make([]byte, len(w))
+	if err := spi.TxAsync(w, r); err != nil {
+		t.Fatal("SPI TxAsync:", err)
+	}
+	if err := spi.Wait(); err != nil {
+		t.Fatal("SPI Wait:", err)
+	}
+	if string(r) != string(w) {
+		t.Errorf("unexpected SPI data: %x", r)
+	}
+	if err := spi.TxAsync(w, r[:1]); err != machine.ErrTxInvalidSliceSize {
+		t.Errorf("expected ErrTxInvalidSliceSize, got %v", err)
+	}
+
+	buf := make([]byte, 1)
+	if err := machine.I2C0.TxAsync(0x48, []byte{0x20}, buf); err != nil {
+		t.Fatal("I2C TxAsync:", err)
+	}
+	if err := machine.I2C0.Wait(); err != nil || buf[0] != 0x42 {
+		t.Errorf("I2C Wait: got %#x, %v", buf[0], err)
+	}
+	machine.I2C0.TxAsync(0x50, []byte{0}, nil)
+	if err := machine.I2C0.Wait(); err == nil {
+		t.Error("expected error for missing device")
+	}
+
+	uart := machine.UART0
+	if err := uart.TxAsync([]byte("async")); err != nil {
+		t.Fatal("UART TxAsync:", err)
+	}
+	if err := uart.Wait(); err != nil {
+		t.Fatal("UART Wait:", err)
+	}
+	if out := string(sim.UARTOutput(0)); out != "async" {
+		t.Errorf("unexpected UART output: %q", out)
+	}
+}
+
+func TestI2CTarget(t *testing.T) {
+	sim.Reset()
+
+	// A device with 4 registers and a register pointer.
+	var regs [4]byte
+	var pointer byte
+	var events []machine.I2CTargetEvent
+	i2c := &machine.I2C{Bus: 1}
+	i2c.SetTargetHandler(func(event machine.I2CTargetEvent, b byte) byte {
+		events = append(events, event)
+		switch event {
+		case machine.I2CAddressMatch:
+			if b != 0x42<<1 && b != 0x42<<1|1 {
+				t.Errorf("unexpected address byte %#x", b)
+			}
+			if b&1 == 0 {
+				// A write starts with the register pointer.
+				pointer = 0xff
+			}
+		case machine.I2CReceive:
+			if pointer == 0xff {
+				pointer = b % 4
+			} else {
+				regs[pointer] = b
+				pointer = (pointer + 1) % 4
+			}
+		case machine.I2CRequest:
+			b = regs[pointer]
+			pointer = (pointer + 1) % 4
+			return b
+		}
+		return 0
+	})
+	i2c.Configure(machine.I2CConfig{Mode: machine.I2CModeTarget, Address: 0x42})
+
+	if err := sim.I2CControllerTx(1, 0x42, []byte{1, 0xaa, 0xbb}, nil); err != nil {
+		t.Fatal("write:", err)
+	}
+	if regs[1] != 0xaa || regs[2] != 0xbb {
+		t.Errorf("unexpected registers: %x", regs)
+	}
+	expected := []machine.I2CTargetEvent{machine.I2CAddressMatch, machine.I2CReceive, machine.I2CReceive, machine.I2CReceive, machine.I2CStop}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %d, got %d", i, expected[i], events[i])
+		}
+	}
+
+	// Read the registers back, starting at register 1.
+	buf := make([]byte, 3)
+	if err := sim.I2CControllerTx(1, 0x42, []byte{1}, buf); err != nil {
+		t.Fatal("read:", err)
+	}
+	if buf[0] != 0xaa || buf[1] != 0xbb || buf[2] != 0x00 {
+		t.Errorf("unexpected data read: %x", buf)
+	}
+
+	// Nothing responds at other addresses.
+	if err := sim.I2CControllerTx(1, 0x43, []byte{0}, nil); err != sim.ErrNoDevice {
+		t.Errorf("expected ErrNoDevice, got %v", err)
+	}
+
+	checkLog(t,
+		"i2c1 target 0x42 w=01aabb",
+		"i2c1 target 0x42 w=01 r=aabb00",
+		"i2c1 target 0x43 w=00 err="+sim.ErrNoDevice.Error(),
+	)
+}
@@ -0,0 +1,95 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim
+
+import "machine"
+
+// SPIDevice is a device model that can be connected to a simulated SPI bus
+// with AddSPIDevice.
+type SPIDevice interface {
+	// Select is called when the chip select pin of the device changes:
+	// selected is true when the pin is driven low, which starts a new
+	// transaction, and false when it is released again.
+	Select(selected bool)
+
+	// Transfer is called for each byte transferred while the device is
+	// selected. It receives the byte sent by the program and returns the byte
+	// the program receives.
+	Transfer(w byte) byte
+}
+
+// spiDevice is an SPI device connected to a bus.
+type spiDevice struct {
+	bus      uint8
+	cs       machine.Pin
+	dev      SPIDevice
+	selected bool
+}
+
+var spiDevices []*spiDevice
+
+// AddSPIDevice connects a device model to the given SPI bus. The device is
+// selected while the cs pin is configured as an output and driven low. If cs is
+// machine.NoPin, the device is always selected.
+//
+// When no device is selected, transfers on the bus return 0xff.
+func AddSPIDevice(bus uint8, cs machine.Pin, dev SPIDevice) {
+	d := &spiDevice{bus: bus, cs: cs, dev: dev}
+	spiDevices = append(spiDevices, d)
+	d.updateSelect()
+}
+
+// isSelected returns whether the chip select pin of the device is active.
+func (d *spiDevice) isSelected() bool {
+	if d.cs == machine.NoPin {
+		return true
+	}
+	p := getPin(d.cs)
+	return p.mode == machine.PinOutput && !p.output
+}
+
+// updateSelect calls Select on the device when the chip select pin changed.
+func (d *spiDevice) updateSelect() {
+	selected := d.isSelected()
+	if selected != d.selected {
+		d.selected = selected
+		d.dev.Select(selected)
+	}
+}
+
+// spiUpdateSelect is called after the given pin may have changed, to update
+// the devices that use it as chip select pin.
+func spiUpdateSelect(pin machine.Pin) {
+	for _, d := range spiDevices {
+		if d.cs == pin {
+			d.updateSelect()
+		}
+	}
+}
+
+//export __tinygo_spi_configure
+func spiConfigure(bus uint8, sck machine.Pin, sdo machine.Pin, sdi machine.Pin) {
+}
+
+//export __tinygo_spi_transfer
+func spiTransfer(bus uint8, w uint8) uint8 {
+	r := uint8(0xff)
+	for _, d := range spiDevices {
+		if d.bus == bus && d.selected {
+			r = d.dev.Transfer(w)
+			break
+		}
+	}
+
+	// Add the byte to the last event if it is a transfer on the same bus.
+	// Any other event in between (such as a change of a chip select pin)
+	// starts a new transfer.
+	if n := len(events); n != 0 && events[n-1].Kind == SPITransfer && events[n-1].Bus == bus {
+		events[n-1].W = append(events[n-1].W, w)
+		events[n-1].R = append(events[n-1].R, r)
+	} else {
+		logEvent(Event{Kind: SPITransfer, Bus: bus, W: []byte{w}, R: []byte{r}})
+	}
+	return r
+}
@@ -0,0 +1,67 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim
+
+import (
+	"machine"
+	"unsafe"
+)
+
+// uartState is the simulated state of a single UART.
+type uartState struct {
+	rx []byte // data that can be read by the program
+	tx []byte // data written by the program
+}
+
+var uarts map[uint8]*uartState
+
+func getUART(bus uint8) *uartState {
+	if uarts == nil {
+		uarts = make(map[uint8]*uartState)
+	}
+	u := uarts[bus]
+	if u == nil {
+		u = &uartState{}
+		uarts[bus] = u
+	}
+	return u
+}
+
+// UARTInput queues data to be read by the program from the given UART.
+func UARTInput(bus uint8, data []byte) {
+	u := getUART(bus)
+	u.rx = append(u.rx, data...)
+}
+
+// UARTOutput returns all data the program wrote to the given UART since the
+// start of the program, or since the last call to Reset or ClearUARTOutput.
+func UARTOutput(bus uint8) []byte {
+	return getUART(bus).tx
+}
+
+// ClearUARTOutput discards the output of the given UART.
+func ClearUARTOutput(bus uint8) {
+	getUART(bus).tx = nil
+}
+
+//export __tinygo_uart_configure
+func uartConfigure(bus uint8, tx, rx machine.Pin) {
+}
+
+//export __tinygo_uart_read
+func uartRead(bus uint8, buf *byte, bufLen int) int {
+	u := getUART(bus)
+	n := copy(unsafe.Slice(buf, bufLen), u.rx)
+	u.rx = u.rx[n:]
+	return n
+}
+
+//export __tinygo_uart_write
+func uartWrite(bus uint8, buf *byte, bufLen int) int {
+	data := append([]byte(nil), unsafe.Slice(buf, bufLen)...)
+	u := getUART(bus)
+	u.tx = append(u.tx, data...)
+	logEvent(Event{Kind: UARTWrite, Bus: bus, W: data})
+	return bufLen
+}
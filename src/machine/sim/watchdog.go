@@ -0,0 +1,79 @@
+//go:build !baremetal
+// +build !baremetal
+
+package sim
+
+import "machine"
+
+// watchdogState is the simulated state of the watchdog timer.
+type watchdogState struct {
+	timeout uint32 // timeout in milliseconds
+	started bool
+	updates int    // number of calls to Update
+	elapsed uint64 // milliseconds since the last Update
+	expired bool
+}
+
+var (
+	watchdog   watchdogState
+	resetCause = machine.ResetCausePowerOn
+)
+
+// WatchdogTimeout returns the timeout in milliseconds the watchdog was
+// configured with, and whether it was started.
+func WatchdogTimeout() (timeoutMillis uint32, started bool) {
+	return watchdog.timeout, watchdog.started
+}
+
+// WatchdogUpdates returns the number of times the program updated (fed) the
+// watchdog.
+func WatchdogUpdates() int {
+	return watchdog.updates
+}
+
+// AdvanceWatchdog simulates the passing of the given number of milliseconds
+// for the watchdog, and returns whether the watchdog expired since it was
+// started: that is, whether at some point more time passed since the last
+// update than the configured timeout. A real chip would have been reset at
+// that point.
+//
+// Time does not pass for the watchdog otherwise, so that tests are
+// deterministic.
+func AdvanceWatchdog(millis uint32) (expired bool) {
+	if watchdog.started {
+		watchdog.elapsed += uint64(millis)
+		if watchdog.elapsed > uint64(watchdog.timeout) {
+			watchdog.expired = true
+		}
+	}
+	return watchdog.expired
+}
+
+// SetResetReason sets the value returned by machine.ResetReason, to simulate
+// a restart of the program after a reset. The default is
+// machine.ResetCausePowerOn.
+func SetResetReason(cause machine.ResetCause) {
+	resetCause = cause
+}
+
+//export __tinygo_watchdog_configure
+func watchdogConfigure(timeoutMillis uint32) {
+	watchdog.timeout = timeoutMillis
+}
+
+//export __tinygo_watchdog_start
+func watchdogStart() {
+	watchdog.started = true
+	watchdog.elapsed = 0
+}
+
+//export __tinygo_watchdog_update
+func watchdogUpdate() {
+	watchdog.updates++
+	watchdog.elapsed = 0
+}
+
+//export __tinygo_reset_reason
+func resetReason() uint8 {
+	return uint8(resetCause)
+}
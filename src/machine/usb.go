@@ -29,7 +29,74 @@ type Serialer interface {
 
 var usbDescriptor = usb.DescriptorCDC
 
-var usbDescriptorConfig uint8 = usb.DescriptorConfigCDC
+// The USB identity of the device. These are set by the compiler from the
+// usb-* fields of the target or the -usb-* flags, and can also be set with
+// -ldflags=-X. The defaults of the board are used for those that are empty.
+var (
+	usbVendorID     string
+	usbProductID    string
+	usbManufacturer string
+	usbProduct      string
+	usbSerialNumber string
+)
+
+// usbVID returns the USB vendor ID of the device.
+func usbVID() uint16 {
+	if id, ok := usb.ParseID(usbVendorID); ok {
+		return id
+	}
+	return usb_VID
+}
+
+// usbPID returns the USB product ID of the device.
+func usbPID() uint16 {
+	if id, ok := usb.ParseID(usbProductID); ok {
+		return id
+	}
+	return usb_PID
+}
+
+// usbSerialDescriptor is the string descriptor with the serial number that is
+// derived from the unique ID of the chip, see initUSBSerial. The longest ID is
+// 16 bytes, which is formatted as 32 hexadecimal digits.
+var (
+	usbSerialDescriptorBuffer [2 + 32*2]byte
+	usbSerialDescriptor       []byte
+)
+
+// initUSBSerial creates the string descriptor with the serial number of the
+// device. Unless it is set at build time, the serial number is the unique ID of
+// the chip so that hosts can tell devices of the same type apart.
+//
+// It is called by USBDevice.Configure before the USB interrupt is enabled, as
+// the interrupt must not allocate memory to format the serial number. On the
+// RP2040, reading the ID also needs a flash command that stops XIP.
+func initUSBSerial() {
+	if usbSerialNumber != "" || usbSerialDescriptor != nil {
+		return
+	}
+	serial := usb.SerialNumber(DeviceID())
+	if maxChars := (len(usbSerialDescriptorBuffer) - 2) >> 1; len(serial) > maxChars {
+		serial = serial[:maxChars]
+	}
+	usbSerialDescriptor = usbSerialDescriptorBuffer[:(len(serial)<<1)+2]
+	strToUTF16LEDescriptor(serial, usbSerialDescriptor)
+}
+
+// usbConfig is the configuration of the composite device. The functions are
+// added to it by EnableCDC, EnableHID, EnableMIDI and EnableMSC, in the order
+// in which they are called.
+var usbConfig usb.Config
+
+// usbConfigDescriptor is the configuration descriptor of usbConfig. It is
+// updated whenever a function is added, so that the USB interrupt only has to
+// send it.
+var usbConfigDescriptor = usbConfig.Bytes()
+
+// usbStringDescriptor is the buffer in which string descriptors are encoded
+// before they are sent. A descriptor stores its length in a byte, so this is
+// the longest possible string descriptor with an even length.
+var usbStringDescriptor [254]byte
 
 // strToUTF16LEDescriptor converts a utf8 string into a string descriptor
 // note: the following code only converts ascii characters to UTF16LE. In order
@@ -46,6 +113,17 @@ func strToUTF16LEDescriptor(in string, out []byte) {
 	return
 }
 
+// sendStringDescriptor sends a string descriptor with the given string. The
+// string is truncated if it doesn't fit in a string descriptor.
+func sendStringDescriptor(s string, maxLength uint16) {
+	if maxChars := (len(usbStringDescriptor) - 2) >> 1; len(s) > maxChars {
+		s = s[:maxChars]
+	}
+	b := usbStringDescriptor[:(len(s)<<1)+2]
+	strToUTF16LEDescriptor(s, b)
+	sendUSBPacket(0, b, maxLength)
+}
+
 const cdcLineInfoSize = 7
 
 var (
@@ -67,25 +145,15 @@ var (
 var udd_ep_control_cache_buffer [256]uint8
 
 //go:align 4
-var udd_ep_in_cache_buffer [7][64]uint8
+var udd_ep_in_cache_buffer [usb.NumberOfEndpoints][64]uint8
 
 //go:align 4
-var udd_ep_out_cache_buffer [7][64]uint8
+var udd_ep_out_cache_buffer [usb.NumberOfEndpoints][64]uint8
 
 var (
 	usbTxHandler    [usb.NumberOfEndpoints]func()
 	usbRxHandler    [usb.NumberOfEndpoints]func([]byte)
 	usbSetupHandler [usb.NumberOfInterfaces]func(usb.Setup) bool
-
-	endPoints = []uint32{
-		usb.CONTROL_ENDPOINT:  usb.ENDPOINT_TYPE_CONTROL,
-		usb.CDC_ENDPOINT_ACM:  (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn),
-		usb.CDC_ENDPOINT_OUT:  (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut),
-		usb.CDC_ENDPOINT_IN:   (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn),
-		usb.HID_ENDPOINT_IN:   (usb.ENDPOINT_TYPE_DISABLE), // Interrupt In
-		usb.MIDI_ENDPOINT_OUT: (usb.ENDPOINT_TYPE_DISABLE), // Bulk Out
-		usb.MIDI_ENDPOINT_IN:  (usb.ENDPOINT_TYPE_DISABLE), // Bulk In
-	}
 )
 
 // sendDescriptor creates and sends the various USB descriptor types that
@@ -93,20 +161,11 @@ var (
 func sendDescriptor(setup usb.Setup) {
 	switch setup.WValueH {
 	case usb.CONFIGURATION_DESCRIPTOR_TYPE:
-		sendUSBPacket(0, usbDescriptor.Configuration, setup.WLength)
+		sendUSBPacket(0, usbConfigDescriptor, setup.WLength)
 		return
 	case usb.DEVICE_DESCRIPTOR_TYPE:
 		// composite descriptor
-		switch {
-		case (usbDescriptorConfig & usb.DescriptorConfigHID) > 0:
-			usbDescriptor = usb.DescriptorCDCHID
-		case (usbDescriptorConfig & usb.DescriptorConfigMIDI) > 0:
-			usbDescriptor = usb.DescriptorCDCMIDI
-		default:
-			usbDescriptor = usb.DescriptorCDC
-		}
-
-		usbDescriptor.Configure(usb_VID, usb_PID)
+		usbDescriptor.Configure(usbVID(), usbPID())
 		sendUSBPacket(0, usbDescriptor.Device, setup.WLength)
 		return
 
@@ -117,22 +176,31 @@ func sendDescriptor(setup usb.Setup) {
 			sendUSBPacket(0, b, setup.WLength)
 
 		case usb.IPRODUCT:
-			b := make([]byte, (len(usb_STRING_PRODUCT)<<1)+2)
-			strToUTF16LEDescriptor(usb_STRING_PRODUCT, b)
-			sendUSBPacket(0, b, setup.WLength)
+			if usbProduct != "" {
+				sendStringDescriptor(usbProduct, setup.WLength)
+			} else {
+				sendStringDescriptor(usb_STRING_PRODUCT, setup.WLength)
+			}
 
 		case usb.IMANUFACTURER:
-			b := make([]byte, (len(usb_STRING_MANUFACTURER)<<1)+2)
-			strToUTF16LEDescriptor(usb_STRING_MANUFACTURER, b)
-			sendUSBPacket(0, b, setup.WLength)
+			if usbManufacturer != "" {
+				sendStringDescriptor(usbManufacturer, setup.WLength)
+			} else {
+				sendStringDescriptor(usb_STRING_MANUFACTURER, setup.WLength)
+			}
 
 		case usb.ISERIAL:
-			// TODO: allow returning a product serial number
-			SendZlp()
+			if usbSerialNumber != "" {
+				sendStringDescriptor(usbSerialNumber, setup.WLength)
+			} else if len(usbSerialDescriptor) > 2 {
+				sendUSBPacket(0, usbSerialDescriptor, setup.WLength)
+			} else {
+				SendZlp()
+			}
 		}
 		return
 	case usb.HID_REPORT_TYPE:
-		if h, ok := usbDescriptor.HID[setup.WIndex]; ok {
+		if h := usbConfig.HIDReport(setup.WIndex); h != nil {
 			sendUSBPacket(0, h, setup.WLength)
 			return
 		}
@@ -195,8 +263,8 @@ func handleStandardSetup(setup usb.Setup) bool {
 
 	case usb.SET_CONFIGURATION:
 		if setup.BmRequestType&usb.REQUEST_RECIPIENT == usb.REQUEST_DEVICE {
-			for i := 1; i < len(endPoints); i++ {
-				initEndpoint(uint32(i), endPoints[i])
+			for _, ep := range usbConfig.Endpoints() {
+				initEndpoint(uint32(ep.Address&^usb.EndpointIn), uint32(ep.Type)|uint32(ep.Address&usb.EndpointIn))
 			}
 
 			usbConfiguration = setup.WValueL
@@ -223,30 +291,55 @@ func handleStandardSetup(setup usb.Setup) bool {
 	}
 }
 
-func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigCDC
-	endPoints[usb.CDC_ENDPOINT_ACM] = (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn)
-	endPoints[usb.CDC_ENDPOINT_OUT] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut)
-	endPoints[usb.CDC_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn)
-	usbRxHandler[usb.CDC_ENDPOINT_OUT] = rxHandler
-	usbTxHandler[usb.CDC_ENDPOINT_IN] = txHandler
-	usbSetupHandler[usb.CDC_ACM_INTERFACE] = setupHandler // 0x02 (Communications and CDC Control)
-	usbSetupHandler[usb.CDC_DATA_INTERFACE] = nil         // 0x0A (CDC-Data)
+// EnableCDC enables CDC. It is called from the runtime at startup, before the
+// functions enabled by other packages.
+func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) usb.CDCFunction {
+	f, err := usbConfig.AddCDC()
+	if err != nil {
+		panic("usb: cannot add CDC: " + err.Error())
+	}
+	usbConfigDescriptor = usbConfig.Bytes()
+	usbRxHandler[f.Out&^usb.EndpointIn] = rxHandler
+	usbTxHandler[f.In&^usb.EndpointIn] = txHandler
+	usbSetupHandler[f.Interface] = setupHandler // 0x02 (Communications and CDC Control)
+	usbSetupHandler[f.Interface+1] = nil        // 0x0A (CDC-Data)
+	return f
 }
 
 // EnableHID enables HID. This function must be executed from the init().
-func EnableHID(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigHID
-	endPoints[usb.HID_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn)
-	usbTxHandler[usb.HID_ENDPOINT_IN] = txHandler
-	usbSetupHandler[usb.HID_INTERFACE] = setupHandler // 0x03 (HID - Human Interface Device)
+func EnableHID(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) usb.HIDFunction {
+	f, err := usbConfig.AddHID(usb.HIDReportKeyboardMouse)
+	if err != nil {
+		panic("usb: cannot add HID: " + err.Error())
+	}
+	usbConfigDescriptor = usbConfig.Bytes()
+	usbTxHandler[f.In&^usb.EndpointIn] = txHandler
+	usbSetupHandler[f.Interface] = setupHandler // 0x03 (HID - Human Interface Device)
+	return f
 }
 
 // EnableMIDI enables MIDI. This function must be executed from the init().
-func EnableMIDI(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigMIDI
-	endPoints[usb.MIDI_ENDPOINT_OUT] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut)
-	endPoints[usb.MIDI_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn)
-	usbRxHandler[usb.MIDI_ENDPOINT_OUT] = rxHandler
-	usbTxHandler[usb.MIDI_ENDPOINT_IN] = txHandler
+func EnableMIDI(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) usb.MIDIFunction {
+	f, err := usbConfig.AddMIDI()
+	if err != nil {
+		panic("usb: cannot add MIDI: " + err.Error())
+	}
+	usbConfigDescriptor = usbConfig.Bytes()
+	usbRxHandler[f.Out&^usb.EndpointIn] = rxHandler
+	usbTxHandler[f.In&^usb.EndpointIn] = txHandler
+	return f
+}
+
+// EnableMSC enables USB mass storage. This function must be executed from the
+// init().
+func EnableMSC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) usb.MSCFunction {
+	f, err := usbConfig.AddMSC()
+	if err != nil {
+		panic("usb: cannot add MSC: " + err.Error())
+	}
+	usbConfigDescriptor = usbConfig.Bytes()
+	usbRxHandler[f.Out&^usb.EndpointIn] = rxHandler
+	usbTxHandler[f.In&^usb.EndpointIn] = txHandler
+	usbSetupHandler[f.Interface] = setupHandler // 0x08 (Mass Storage)
+	return f
 }
@@ -1,10 +1,7 @@
 package cdc
 
-const (
-	cdcEndpointACM = 1
-	cdcEndpointOut = 2
-	cdcEndpointIn  = 3
-)
+// cdcEndpointIn is the bulk IN endpoint, assigned by machine.EnableCDC.
+var cdcEndpointIn uint32
 
 // New returns USBCDC struct.
 func New() *USBCDC {
@@ -186,5 +186,6 @@ func cdcSetup(setup usb.Setup) bool {
 
 func EnableUSBCDC() {
 	machine.USBCDC = New()
-	machine.EnableCDC(USB.Flush, cdcCallbackRx, cdcSetup)
+	f := machine.EnableCDC(USB.Flush, cdcCallbackRx, cdcSetup)
+	cdcEndpointIn = uint32(f.In &^ usb.EndpointIn)
 }
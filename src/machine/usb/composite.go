@@ -0,0 +1,263 @@
+package usb
+
+import "errors"
+
+var (
+	ErrNoEndpoint  = errors.New("usb: no free endpoint")
+	ErrNoInterface = errors.New("usb: too many interfaces")
+)
+
+// Endpoint is an endpoint used by a configuration.
+type Endpoint struct {
+	Address uint8 // endpoint number, with EndpointIn set for IN endpoints
+	Type    uint8 // ENDPOINT_TYPE_BULK, ENDPOINT_TYPE_INTERRUPT, ...
+}
+
+// Config builds the configuration descriptor of a composite device. Functions
+// such as CDC and HID are added one after another, each taking the next free
+// interface numbers and endpoints. The zero value is an empty configuration.
+//
+// Endpoint numbers are assigned in order. Once all of them are taken, an IN
+// endpoint and an OUT endpoint share a number, as the endpoint number and the
+// direction together identify an endpoint.
+type Config struct {
+	desc       []byte
+	interfaces uint8
+	endpoints  []Endpoint
+	inUsed     uint16 // bitmap of IN endpoint numbers in use
+	outUsed    uint16 // bitmap of OUT endpoint numbers in use
+	hidReports map[uint16][]byte
+}
+
+// Bytes returns the configuration descriptor, including the functions added
+// so far. The returned slice is reused by the next call. It may allocate
+// memory, so it should be called once after adding functions instead of from
+// the USB interrupt.
+func (c *Config) Bytes() []byte {
+	c.init()
+	c.desc[2] = byte(len(c.desc))
+	c.desc[3] = byte(len(c.desc) >> 8)
+	c.desc[4] = c.interfaces
+	return c.desc
+}
+
+// Endpoints returns the endpoints used by the functions added so far.
+func (c *Config) Endpoints() []Endpoint {
+	return c.endpoints
+}
+
+// HIDReport returns the HID report descriptor of the given interface, or nil
+// if it is not a HID interface.
+func (c *Config) HIDReport(iface uint16) []byte {
+	return c.hidReports[iface]
+}
+
+func (c *Config) init() {
+	if len(c.desc) == 0 {
+		c.desc = append(c.desc,
+			9, CONFIGURATION_DESCRIPTOR_TYPE,
+			0, 0, // wTotalLength, set by Bytes
+			0, // bNumInterfaces, set by Bytes
+			1, // bConfigurationValue
+			0, // iConfiguration
+			CONFIG_BUS_POWERED|CONFIG_REMOTE_WAKEUP,
+			50, // 100mA
+		)
+	}
+}
+
+// Append adds descriptors to the configuration descriptor.
+func (c *Config) Append(desc ...byte) {
+	c.init()
+	c.desc = append(c.desc, desc...)
+}
+
+// NewInterface returns the next free interface number.
+func (c *Config) NewInterface() (uint8, error) {
+	if c.interfaces == NumberOfInterfaces {
+		return 0, ErrNoInterface
+	}
+	c.interfaces++
+	return c.interfaces - 1, nil
+}
+
+// NewEndpoint returns the address of a free endpoint of the given type and
+// direction, and adds it to the endpoints of the configuration.
+func (c *Config) NewEndpoint(typ uint8, in bool) (uint8, error) {
+	used, other := &c.outUsed, c.inUsed
+	if in {
+		used, other = &c.inUsed, c.outUsed
+	}
+	number := uint8(0)
+	// Prefer a number that isn't used at all, then one that is only used in
+	// the other direction.
+	for n := uint8(1); n < NumberOfEndpoints; n++ {
+		if (*used|other)&(1<<n) == 0 {
+			number = n
+			break
+		}
+	}
+	if number == 0 {
+		for n := uint8(1); n < NumberOfEndpoints; n++ {
+			if *used&(1<<n) == 0 {
+				number = n
+				break
+			}
+		}
+	}
+	if number == 0 {
+		return 0, ErrNoEndpoint
+	}
+	*used |= 1 << number
+	if in {
+		number |= EndpointIn
+	}
+	c.endpoints = append(c.endpoints, Endpoint{Address: number, Type: typ})
+	return number, nil
+}
+
+// Helpers for the standard descriptors.
+
+func (c *Config) interfaceAssociation(first, count, class, subClass, protocol uint8) {
+	c.Append(8, 0x0b, first, count, class, subClass, protocol, 0)
+}
+
+func (c *Config) interfaceDescriptor(number, endpoints, class, subClass, protocol uint8) {
+	c.Append(9, INTERFACE_DESCRIPTOR_TYPE, number, 0, endpoints, class, subClass, protocol, 0)
+}
+
+func (c *Config) endpointDescriptor(address, typ uint8, size uint16, interval uint8) {
+	c.Append(7, ENDPOINT_DESCRIPTOR_TYPE, address, typ, byte(size), byte(size>>8), interval)
+}
+
+// CDCFunction is a CDC-ACM (serial port) function in a configuration.
+type CDCFunction struct {
+	Interface uint8 // communication interface, the data interface follows it
+	Notify    uint8 // interrupt IN endpoint for notifications
+	Out       uint8 // bulk OUT endpoint
+	In        uint8 // bulk IN endpoint
+}
+
+// AddCDC adds a CDC-ACM function, consisting of a communication interface and
+// a data interface.
+func (c *Config) AddCDC() (f CDCFunction, err error) {
+	if f.Interface, err = c.NewInterface(); err != nil {
+		return
+	}
+	data, err := c.NewInterface()
+	if err != nil {
+		return
+	}
+	if f.Notify, err = c.NewEndpoint(ENDPOINT_TYPE_INTERRUPT, true); err != nil {
+		return
+	}
+	if f.Out, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, false); err != nil {
+		return
+	}
+	if f.In, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, true); err != nil {
+		return
+	}
+	c.interfaceAssociation(f.Interface, 2, DEVICE_CLASS_COMMUNICATIONS, 2, 0)
+	c.interfaceDescriptor(f.Interface, 1, DEVICE_CLASS_COMMUNICATIONS, 2, 0)
+	c.Append(
+		5, 0x24, 0x00, 0x10, 0x01, // header, CDC 1.10
+		4, 0x24, 0x02, 0x06, // abstract control management
+		5, 0x24, 0x06, f.Interface, data, // union
+		5, 0x24, 0x01, 0x01, data, // call management
+	)
+	c.endpointDescriptor(f.Notify, ENDPOINT_TYPE_INTERRUPT, 16, 16)
+	c.interfaceDescriptor(data, 2, 0x0a, 0, 0)
+	c.endpointDescriptor(f.Out, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+	c.endpointDescriptor(f.In, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+	return
+}
+
+// HIDFunction is a HID function in a configuration.
+type HIDFunction struct {
+	Interface uint8
+	In        uint8 // interrupt IN endpoint
+}
+
+// AddHID adds a HID function with the given report descriptor.
+func (c *Config) AddHID(report []byte) (f HIDFunction, err error) {
+	if f.Interface, err = c.NewInterface(); err != nil {
+		return
+	}
+	if f.In, err = c.NewEndpoint(ENDPOINT_TYPE_INTERRUPT, true); err != nil {
+		return
+	}
+	c.interfaceDescriptor(f.Interface, 1, DEVICE_CLASS_HUMAN_INTERFACE, 0, 0)
+	c.Append(9, 0x21, 0x01, 0x01, 0x00, 0x01, HID_REPORT_TYPE, byte(len(report)), byte(len(report)>>8)) // HID 1.01
+	c.endpointDescriptor(f.In, ENDPOINT_TYPE_INTERRUPT, EndpointPacketSize, 1)
+	if c.hidReports == nil {
+		c.hidReports = make(map[uint16][]byte)
+	}
+	c.hidReports[uint16(f.Interface)] = report
+	return
+}
+
+// MIDIFunction is a USB MIDI function in a configuration.
+type MIDIFunction struct {
+	Interface uint8 // audio control interface, the MIDI streaming interface follows it
+	Out       uint8 // bulk OUT endpoint
+	In        uint8 // bulk IN endpoint
+}
+
+// AddMIDI adds a USB MIDI function with one embedded jack in each direction,
+// consisting of an audio control interface and a MIDI streaming interface.
+func (c *Config) AddMIDI() (f MIDIFunction, err error) {
+	if f.Interface, err = c.NewInterface(); err != nil {
+		return
+	}
+	streaming, err := c.NewInterface()
+	if err != nil {
+		return
+	}
+	if f.Out, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, false); err != nil {
+		return
+	}
+	if f.In, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, true); err != nil {
+		return
+	}
+	c.interfaceAssociation(f.Interface, 2, 0x01, 0x01, 0)
+	c.interfaceDescriptor(f.Interface, 0, 0x01, 0x01, 0)
+	c.Append(9, 0x24, 0x01, 0x00, 0x01, 0x09, 0x00, 0x01, streaming) // audio control header
+	c.interfaceDescriptor(streaming, 2, 0x01, 0x03, 0)
+	c.Append(
+		7, 0x24, 0x01, 0x00, 0x01, 0x41, 0x00, // MIDI streaming header
+		6, 0x24, 0x02, 0x01, 0x01, 0x00, // embedded IN jack 1
+		6, 0x24, 0x02, 0x02, 0x02, 0x00, // external IN jack 2
+		9, 0x24, 0x03, 0x01, 0x03, 0x01, 0x02, 0x01, 0x00, // embedded OUT jack 3, from jack 2
+		9, 0x24, 0x03, 0x02, 0x04, 0x01, 0x01, 0x01, 0x00, // external OUT jack 4, from jack 1
+		9, ENDPOINT_DESCRIPTOR_TYPE, f.Out, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0, 0, 0, 0,
+		5, 0x25, 0x01, 0x01, 0x01, // to embedded IN jack 1
+		9, ENDPOINT_DESCRIPTOR_TYPE, f.In, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0, 0, 0, 0,
+		5, 0x25, 0x01, 0x01, 0x03, // from embedded OUT jack 3
+	)
+	return
+}
+
+// MSCFunction is a mass storage function in a configuration.
+type MSCFunction struct {
+	Interface uint8
+	Out       uint8 // bulk OUT endpoint
+	In        uint8 // bulk IN endpoint
+}
+
+// AddMSC adds a mass storage function, using the SCSI transparent command set
+// with the bulk-only transport.
+func (c *Config) AddMSC() (f MSCFunction, err error) {
+	if f.Interface, err = c.NewInterface(); err != nil {
+		return
+	}
+	if f.Out, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, false); err != nil {
+		return
+	}
+	if f.In, err = c.NewEndpoint(ENDPOINT_TYPE_BULK, true); err != nil {
+		return
+	}
+	c.interfaceDescriptor(f.Interface, 2, DEVICE_CLASS_STORAGE, 0x06, 0x50)
+	c.endpointDescriptor(f.Out, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+	c.endpointDescriptor(f.In, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+	return
+}
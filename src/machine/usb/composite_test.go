@@ -0,0 +1,145 @@
+package usb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConfigCDC(t *testing.T) {
+	var c Config
+	f, err := c.AddCDC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != (CDCFunction{Interface: 0, Notify: 0x81, Out: 0x02, In: 0x83}) {
+		t.Errorf("unexpected CDC function: %+v", f)
+	}
+	if got := c.Bytes(); !bytes.Equal(got, DescriptorCDC.Configuration) {
+		t.Errorf("unexpected configuration descriptor:\n% x\nexpected:\n% x", got, DescriptorCDC.Configuration)
+	}
+}
+
+func TestConfigCDCHID(t *testing.T) {
+	var c Config
+	if _, err := c.AddCDC(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := c.AddHID(HIDReportKeyboardMouse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != (HIDFunction{Interface: 2, In: 0x84}) {
+		t.Errorf("unexpected HID function: %+v", f)
+	}
+	if got := c.Bytes(); !bytes.Equal(got, DescriptorCDCHID.Configuration) {
+		t.Errorf("unexpected configuration descriptor:\n% x\nexpected:\n% x", got, DescriptorCDCHID.Configuration)
+	}
+	if !bytes.Equal(c.HIDReport(2), HIDReportKeyboardMouse) {
+		t.Errorf("HID report descriptor not found")
+	}
+	if c.HIDReport(0) != nil {
+		t.Errorf("unexpected HID report descriptor for CDC interface")
+	}
+}
+
+func TestConfigComposite(t *testing.T) {
+	var c Config
+	if _, err := c.AddCDC(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddHID(HIDReportKeyboardMouse); err != nil {
+		t.Fatal(err)
+	}
+	midi, err := c.AddMIDI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if midi != (MIDIFunction{Interface: 3, Out: 0x05, In: 0x86}) {
+		t.Errorf("unexpected MIDI function: %+v", midi)
+	}
+	msc, err := c.AddMSC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// All endpoint numbers are taken by now, so the IN endpoint shares its
+	// number with the first OUT endpoint.
+	if msc != (MSCFunction{Interface: 5, Out: 0x07, In: 0x82}) {
+		t.Errorf("unexpected MSC function: %+v", msc)
+	}
+
+	expected := []byte{
+		0x09, 0x02, 0xdf, 0x00, 0x06, 0x01, 0x00, 0xa0, 0x32,
+		// CDC
+		0x08, 0x0b, 0x00, 0x02, 0x02, 0x02, 0x00, 0x00,
+		0x09, 0x04, 0x00, 0x00, 0x01, 0x02, 0x02, 0x00, 0x00,
+		0x05, 0x24, 0x00, 0x10, 0x01,
+		0x04, 0x24, 0x02, 0x06,
+		0x05, 0x24, 0x06, 0x00, 0x01,
+		0x05, 0x24, 0x01, 0x01, 0x01,
+		0x07, 0x05, 0x81, 0x03, 0x10, 0x00, 0x10,
+		0x09, 0x04, 0x01, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x00,
+		0x07, 0x05, 0x02, 0x02, 0x40, 0x00, 0x00,
+		0x07, 0x05, 0x83, 0x02, 0x40, 0x00, 0x00,
+		// HID
+		0x09, 0x04, 0x02, 0x00, 0x01, 0x03, 0x00, 0x00, 0x00,
+		0x09, 0x21, 0x01, 0x01, 0x00, 0x01, 0x22, 0x65, 0x00,
+		0x07, 0x05, 0x84, 0x03, 0x40, 0x00, 0x01,
+		// MIDI
+		0x08, 0x0b, 0x03, 0x02, 0x01, 0x01, 0x00, 0x00,
+		0x09, 0x04, 0x03, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00,
+		0x09, 0x24, 0x01, 0x00, 0x01, 0x09, 0x00, 0x01, 0x04,
+		0x09, 0x04, 0x04, 0x00, 0x02, 0x01, 0x03, 0x00, 0x00,
+		0x07, 0x24, 0x01, 0x00, 0x01, 0x41, 0x00,
+		0x06, 0x24, 0x02, 0x01, 0x01, 0x00,
+		0x06, 0x24, 0x02, 0x02, 0x02, 0x00,
+		0x09, 0x24, 0x03, 0x01, 0x03, 0x01, 0x02, 0x01, 0x00,
+		0x09, 0x24, 0x03, 0x02, 0x04, 0x01, 0x01, 0x01, 0x00,
+		0x09, 0x05, 0x05, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0x25, 0x01, 0x01, 0x01,
+		0x09, 0x05, 0x86, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0x25, 0x01, 0x01, 0x03,
+		// MSC
+		0x09, 0x04, 0x05, 0x00, 0x02, 0x08, 0x06, 0x50, 0x00,
+		0x07, 0x05, 0x07, 0x02, 0x40, 0x00, 0x00,
+		0x07, 0x05, 0x82, 0x02, 0x40, 0x00, 0x00,
+	}
+	if got := c.Bytes(); !bytes.Equal(got, expected) {
+		t.Errorf("unexpected configuration descriptor:\n% x\nexpected:\n% x", got, expected)
+	}
+
+	endpoints := []Endpoint{
+		{0x81, ENDPOINT_TYPE_INTERRUPT},
+		{0x02, ENDPOINT_TYPE_BULK},
+		{0x83, ENDPOINT_TYPE_BULK},
+		{0x84, ENDPOINT_TYPE_INTERRUPT},
+		{0x05, ENDPOINT_TYPE_BULK},
+		{0x86, ENDPOINT_TYPE_BULK},
+		{0x07, ENDPOINT_TYPE_BULK},
+		{0x82, ENDPOINT_TYPE_BULK},
+	}
+	got := c.Endpoints()
+	if len(got) != len(endpoints) {
+		t.Fatalf("expected %d endpoints, got %d", len(endpoints), len(got))
+	}
+	for i := range endpoints {
+		if got[i] != endpoints[i] {
+			t.Errorf("endpoint %d: expected %+v, got %+v", i, endpoints[i], got[i])
+		}
+	}
+}
+
+func TestConfigNoEndpoint(t *testing.T) {
+	var c Config
+	for i := 1; i < NumberOfEndpoints; i++ {
+		if _, err := c.NewEndpoint(ENDPOINT_TYPE_BULK, true); err != nil {
+			t.Fatalf("endpoint %d: %v", i, err)
+		}
+	}
+	if _, err := c.NewEndpoint(ENDPOINT_TYPE_BULK, true); err != ErrNoEndpoint {
+		t.Errorf("expected ErrNoEndpoint, got %v", err)
+	}
+	// OUT endpoints are still available.
+	if ep, err := c.NewEndpoint(ENDPOINT_TYPE_BULK, false); err != nil || ep != 0x01 {
+		t.Errorf("expected OUT endpoint 0x01, got %#x (%v)", ep, err)
+	}
+}
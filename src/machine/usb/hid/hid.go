@@ -14,8 +14,6 @@ var (
 )
 
 const (
-	hidEndpoint = 4
-
 	usb_SET_REPORT_TYPE = 33
 	usb_SET_IDLE        = 10
 )
@@ -27,11 +25,15 @@ type hidDevicer interface {
 var devices [5]hidDevicer
 var size int
 
+// hidEndpoint is the interrupt IN endpoint, assigned by machine.EnableHID.
+var hidEndpoint uint32
+
 // SetHandler sets the handler. Only the first time it is called, it
 // calls machine.EnableHID for USB configuration
 func SetHandler(d hidDevicer) {
 	if size == 0 {
-		machine.EnableHID(handler, nil, setupHandler)
+		f := machine.EnableHID(handler, nil, setupHandler)
+		hidEndpoint = uint32(f.In &^ usb.EndpointIn)
 	}
 
 	devices[size] = d
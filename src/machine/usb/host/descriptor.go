@@ -0,0 +1,187 @@
+package host
+
+import (
+	"errors"
+	"machine/usb"
+)
+
+var errInvalidDescriptor = errors.New("usb: invalid descriptor")
+
+// DeviceDescriptor is the standard device descriptor of a device.
+type DeviceDescriptor struct {
+	USBVersion        uint16 // BCD, 0x0200 for USB 2.0
+	Class             uint8
+	SubClass          uint8
+	Protocol          uint8
+	MaxPacketSize0    uint8 // maximum packet size of the control endpoint
+	VendorID          uint16
+	ProductID         uint16
+	DeviceVersion     uint16 // BCD
+	Manufacturer      uint8  // string descriptor index, 0 if none
+	Product           uint8  // string descriptor index, 0 if none
+	SerialNumber      uint8  // string descriptor index, 0 if none
+	NumConfigurations uint8
+}
+
+// ParseDeviceDescriptor parses a device descriptor.
+func ParseDeviceDescriptor(b []byte) (DeviceDescriptor, error) {
+	if len(b) < 18 || b[0] < 18 || b[1] != usb.DEVICE_DESCRIPTOR_TYPE {
+		return DeviceDescriptor{}, errInvalidDescriptor
+	}
+	return DeviceDescriptor{
+		USBVersion:        le16(b[2:]),
+		Class:             b[4],
+		SubClass:          b[5],
+		Protocol:          b[6],
+		MaxPacketSize0:    b[7],
+		VendorID:          le16(b[8:]),
+		ProductID:         le16(b[10:]),
+		DeviceVersion:     le16(b[12:]),
+		Manufacturer:      b[14],
+		Product:           b[15],
+		SerialNumber:      b[16],
+		NumConfigurations: b[17],
+	}, nil
+}
+
+// Configuration is a parsed configuration descriptor, with the interfaces and
+// endpoints that follow it.
+type Configuration struct {
+	Value      uint8 // value to select the configuration with SET_CONFIGURATION
+	Attributes uint8
+	MaxPower   uint8 // in units of 2mA
+	Interfaces []Interface
+}
+
+// Interface is an interface (or alternate setting of an interface) in a
+// configuration.
+type Interface struct {
+	Number           uint8
+	AlternateSetting uint8
+	Class            uint8
+	SubClass         uint8
+	Protocol         uint8
+	Endpoints        []Endpoint
+
+	// Class specific descriptors that follow the interface descriptor, such
+	// as the HID descriptor or the CDC functional descriptors.
+	Extra []byte
+}
+
+// Endpoint is an endpoint of an interface.
+type Endpoint struct {
+	Address       uint8 // endpoint number, with usb.EndpointIn set for IN endpoints
+	Type          uint8 // usb.ENDPOINT_TYPE_BULK, usb.ENDPOINT_TYPE_INTERRUPT, ...
+	MaxPacketSize uint16
+	Interval      uint8 // polling interval in ms (frames) of interrupt endpoints
+
+	// DataToggle is the data toggle (DATA0 or DATA1) of the next transfer. It
+	// is maintained by the Controller.
+	DataToggle bool
+}
+
+// In returns whether this is an IN endpoint (device to host).
+func (ep *Endpoint) In() bool {
+	return ep.Address&usb.EndpointIn != 0
+}
+
+// Number returns the endpoint number, without direction.
+func (ep *Endpoint) Number() uint8 {
+	return ep.Address &^ usb.EndpointIn
+}
+
+// ParseConfiguration parses a full configuration descriptor, as returned by
+// GET_DESCRIPTOR with the length from wTotalLength. Descriptors of unknown
+// types are added to the Extra field of the interface they follow, and
+// descriptors before the first interface are skipped.
+func ParseConfiguration(b []byte) (Configuration, error) {
+	if len(b) < 9 || b[0] < 9 || b[1] != usb.CONFIGURATION_DESCRIPTOR_TYPE {
+		return Configuration{}, errInvalidDescriptor
+	}
+	total := int(le16(b[2:]))
+	if total > len(b) || total < int(b[0]) {
+		return Configuration{}, errInvalidDescriptor
+	}
+	config := Configuration{
+		Value:      b[5],
+		Attributes: b[7],
+		MaxPower:   b[8],
+	}
+	var iface *Interface
+	for b = b[b[0]:total]; len(b) != 0; b = b[b[0]:] {
+		if len(b) < 2 || b[0] < 2 || int(b[0]) > len(b) {
+			return Configuration{}, errInvalidDescriptor
+		}
+		desc := b[:b[0]]
+		switch desc[1] {
+		case usb.INTERFACE_DESCRIPTOR_TYPE:
+			if len(desc) < 9 {
+				return Configuration{}, errInvalidDescriptor
+			}
+			config.Interfaces = append(config.Interfaces, Interface{
+				Number:           desc[2],
+				AlternateSetting: desc[3],
+				Class:            desc[5],
+				SubClass:         desc[6],
+				Protocol:         desc[7],
+			})
+			iface = &config.Interfaces[len(config.Interfaces)-1]
+		case usb.ENDPOINT_DESCRIPTOR_TYPE:
+			if len(desc) < 7 {
+				return Configuration{}, errInvalidDescriptor
+			}
+			if iface == nil {
+				continue
+			}
+			iface.Endpoints = append(iface.Endpoints, Endpoint{
+				Address:       desc[2],
+				Type:          desc[3] & 0x03,
+				MaxPacketSize: le16(desc[4:]) & 0x7ff,
+				Interval:      desc[6],
+			})
+		default:
+			// Interface associations, HID descriptors, class specific
+			// descriptors, ...
+			if iface != nil {
+				iface.Extra = append(iface.Extra, desc...)
+			}
+		}
+	}
+	return config, nil
+}
+
+// FindInterface returns the first interface with the given class, subclass
+// and protocol, or nil if there is none. A negative subclass or protocol
+// matches any value.
+func (c *Configuration) FindInterface(class uint8, subClass, protocol int) *Interface {
+	for i := range c.Interfaces {
+		iface := &c.Interfaces[i]
+		if iface.AlternateSetting != 0 || iface.Class != class {
+			continue
+		}
+		if subClass >= 0 && iface.SubClass != uint8(subClass) {
+			continue
+		}
+		if protocol >= 0 && iface.Protocol != uint8(protocol) {
+			continue
+		}
+		return iface
+	}
+	return nil
+}
+
+// FindEndpoint returns the first endpoint of the interface with the given type
+// and direction, or nil if there is none.
+func (iface *Interface) FindEndpoint(typ uint8, in bool) *Endpoint {
+	for i := range iface.Endpoints {
+		ep := &iface.Endpoints[i]
+		if ep.Type == typ && ep.In() == in {
+			return ep
+		}
+	}
+	return nil
+}
+
+func le16(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
@@ -0,0 +1,288 @@
+// Package host implements USB host mode: it enumerates a device attached to
+// the USB port, parses its descriptors and drives it with the class drivers in
+// this package, such as Keyboard and Serial.
+//
+// The package doesn't depend on the hardware. The USB controller of the chip
+// is accessed through the Controller interface, which is implemented by
+// machine.USBHost on chips that support host mode:
+//
+//	machine.USBHost.Configure()
+//	h := host.New(machine.USBHost)
+//	for {
+//		if _, err := h.Enumerate(); err == nil {
+//			break
+//		}
+//		time.Sleep(100 * time.Millisecond)
+//	}
+//	kb, err := host.NewKeyboard(h)
+//
+// Only a single device connected directly to the port is supported, hubs are
+// not.
+package host
+
+import (
+	"errors"
+	"machine/usb"
+)
+
+var (
+	ErrNoDevice    = errors.New("usb: no device connected")
+	ErrStall       = errors.New("usb: endpoint stalled")
+	ErrNAK         = errors.New("usb: no data available")
+	ErrTimeout     = errors.New("usb: transfer timeout")
+	ErrUnsupported = errors.New("usb: device not supported")
+)
+
+// Speed is the speed of a device.
+type Speed uint8
+
+const (
+	SpeedFull Speed = iota // 12Mbit/s
+	SpeedLow               // 1.5Mbit/s
+)
+
+// Controller is a USB controller in host mode, with a single port.
+type Controller interface {
+	// Connected returns whether a device is connected to the port.
+	Connected() bool
+
+	// ResetPort resets the device on the port, including the delays before
+	// and after the reset required by the specification. It returns the
+	// speed of the device, or ErrNoDevice.
+	ResetPort() (Speed, error)
+
+	// Control performs a control transfer on endpoint 0 of the device. The
+	// data stage is sent from data or received into data, depending on the
+	// direction of the request, and its length is returned. It returns
+	// ErrStall if the device doesn't support the request. After SET_ADDRESS,
+	// it waits for the 2ms the device may take to switch to its new address.
+	Control(dev *Device, setup usb.Setup, data []byte) (int, error)
+
+	// Transfer performs a single bulk or interrupt transfer on the endpoint,
+	// which may consist of several packets. It updates the data toggle of
+	// the endpoint. IN transfers return ErrNAK when the device has no data
+	// to send, instead of waiting for it.
+	Transfer(dev *Device, ep *Endpoint, data []byte) (int, error)
+}
+
+// Device is a device connected to the host.
+type Device struct {
+	Address        uint8
+	Speed          Speed
+	MaxPacketSize0 uint8 // maximum packet size of the control endpoint
+	Descriptor     DeviceDescriptor
+	Config         Configuration // the active configuration
+}
+
+// State is the state of enumeration of the device.
+type State uint8
+
+const (
+	StateDisconnected        State = iota // no device connected
+	StateReset                            // device connected, port to be reset
+	StateGetMaxPacketSize                 // reading the first 8 bytes of the device descriptor
+	StateSetAddress                       // assigning the address
+	StateGetDeviceDescriptor              // reading the device descriptor
+	StateGetConfiguration                 // reading the configuration descriptor
+	StateSetConfiguration                 // selecting the configuration
+	StateConfigured                       // ready to be used by the class drivers
+	StateError                            // enumeration failed, until the device is disconnected
+)
+
+// The address assigned to the device.
+const deviceAddress = 1
+
+// Host enumerates the device attached to a controller and gives class drivers
+// access to it.
+type Host struct {
+	ctrl   Controller
+	state  State
+	err    error
+	dev    Device
+	buf    [18]byte
+	config []byte
+}
+
+// New returns a host for the given controller.
+func New(ctrl Controller) *Host {
+	return &Host{ctrl: ctrl}
+}
+
+// State returns the current state of enumeration.
+func (h *Host) State() State {
+	return h.state
+}
+
+// Err returns the reason enumeration failed, in StateError.
+func (h *Host) Err() error {
+	return h.err
+}
+
+// Device returns the connected device, or nil if it hasn't been configured.
+func (h *Host) Device() *Device {
+	if h.state != StateConfigured {
+		return nil
+	}
+	return &h.dev
+}
+
+// Poll runs the next step of enumeration, which is at most one control
+// transfer, and returns the new state. It notices when the device has been
+// disconnected, after which the class drivers of the device can't be used
+// anymore.
+func (h *Host) Poll() State {
+	if !h.ctrl.Connected() {
+		h.state = StateDisconnected
+		h.err = nil
+		h.dev = Device{}
+		return h.state
+	}
+
+	var err error
+	switch h.state {
+	case StateDisconnected:
+		h.state = StateReset
+	case StateReset:
+		var speed Speed
+		if speed, err = h.ctrl.ResetPort(); err == nil {
+			// Until the real value is known, use the smallest maximum
+			// packet size of the control endpoint.
+			h.dev = Device{Speed: speed, MaxPacketSize0: 8}
+			h.state = StateGetMaxPacketSize
+		}
+	case StateGetMaxPacketSize:
+		var n int
+		if n, err = h.getDescriptor(usb.DEVICE_DESCRIPTOR_TYPE, 0, h.buf[:8]); err == nil {
+			if n < 8 || h.buf[7] == 0 {
+				err = errInvalidDescriptor
+				break
+			}
+			h.dev.MaxPacketSize0 = h.buf[7]
+			h.state = StateSetAddress
+		}
+	case StateSetAddress:
+		_, err = h.ctrl.Control(&h.dev, usb.Setup{
+			BmRequestType: usb.REQUEST_HOSTTODEVICE,
+			BRequest:      usb.SET_ADDRESS,
+			WValueL:       deviceAddress,
+		}, nil)
+		if err == nil {
+			h.dev.Address = deviceAddress
+			h.state = StateGetDeviceDescriptor
+		}
+	case StateGetDeviceDescriptor:
+		var n int
+		if n, err = h.getDescriptor(usb.DEVICE_DESCRIPTOR_TYPE, 0, h.buf[:]); err == nil {
+			if h.dev.Descriptor, err = ParseDeviceDescriptor(h.buf[:n]); err == nil {
+				h.state = StateGetConfiguration
+			}
+		}
+	case StateGetConfiguration:
+		// Read the header first, for the total length of the descriptor.
+		var n int
+		if n, err = h.getDescriptor(usb.CONFIGURATION_DESCRIPTOR_TYPE, 0, h.buf[:9]); err != nil {
+			break
+		}
+		if n < 9 {
+			err = errInvalidDescriptor
+			break
+		}
+		total := int(le16(h.buf[2:]))
+		if cap(h.config) < total {
+			h.config = make([]byte, total)
+		}
+		h.config = h.config[:total]
+		if n, err = h.getDescriptor(usb.CONFIGURATION_DESCRIPTOR_TYPE, 0, h.config); err == nil {
+			if h.dev.Config, err = ParseConfiguration(h.config[:n]); err == nil {
+				h.state = StateSetConfiguration
+			}
+		}
+	case StateSetConfiguration:
+		_, err = h.ctrl.Control(&h.dev, usb.Setup{
+			BmRequestType: usb.REQUEST_HOSTTODEVICE,
+			BRequest:      usb.SET_CONFIGURATION,
+			WValueL:       h.dev.Config.Value,
+		}, nil)
+		if err == nil {
+			h.state = StateConfigured
+		}
+	}
+	if err != nil {
+		h.state = StateError
+		h.err = err
+	}
+	return h.state
+}
+
+// Enumerate polls until the connected device has been configured, and returns
+// it. It returns ErrNoDevice if no device is connected, and the reason if
+// enumeration failed. To enumerate the device again after a failure, it has
+// to be disconnected first.
+func (h *Host) Enumerate() (*Device, error) {
+	for {
+		switch h.Poll() {
+		case StateConfigured:
+			return &h.dev, nil
+		case StateError:
+			return nil, h.err
+		case StateDisconnected:
+			return nil, ErrNoDevice
+		}
+	}
+}
+
+// Control performs a control transfer on the configured device.
+func (h *Host) Control(setup usb.Setup, data []byte) (int, error) {
+	if h.state != StateConfigured {
+		return 0, ErrNoDevice
+	}
+	return h.ctrl.Control(&h.dev, setup, data)
+}
+
+// Transfer performs a bulk or interrupt transfer on an endpoint of the
+// configured device.
+func (h *Host) Transfer(ep *Endpoint, data []byte) (int, error) {
+	if h.state != StateConfigured {
+		return 0, ErrNoDevice
+	}
+	return h.ctrl.Transfer(&h.dev, ep, data)
+}
+
+// String returns the string descriptor with the given index, such as the
+// Product field of the device descriptor, in US English. Characters outside
+// of the Basic Multilingual Plane are not supported.
+func (h *Host) String(index uint8) (string, error) {
+	if index == 0 {
+		return "", nil
+	}
+	var buf [255]byte
+	n, err := h.Control(usb.Setup{
+		BmRequestType: usb.REQUEST_DEVICETOHOST,
+		BRequest:      usb.GET_DESCRIPTOR,
+		WValueL:       index,
+		WValueH:       usb.STRING_DESCRIPTOR_TYPE,
+		WIndex:        0x0409, // English (United States)
+		WLength:       uint16(len(buf)),
+	}, buf[:])
+	if err != nil {
+		return "", err
+	}
+	if n < 2 || buf[1] != usb.STRING_DESCRIPTOR_TYPE || int(buf[0]) > n {
+		return "", errInvalidDescriptor
+	}
+	s := make([]rune, 0, (buf[0]-2)/2)
+	for i := 2; i+1 < int(buf[0]); i += 2 {
+		s = append(s, rune(le16(buf[i:])))
+	}
+	return string(s), nil
+}
+
+func (h *Host) getDescriptor(typ, index uint8, data []byte) (int, error) {
+	return h.ctrl.Control(&h.dev, usb.Setup{
+		BmRequestType: usb.REQUEST_DEVICETOHOST,
+		BRequest:      usb.GET_DESCRIPTOR,
+		WValueL:       index,
+		WValueH:       typ,
+		WLength:       uint16(len(data)),
+	}, data)
+}
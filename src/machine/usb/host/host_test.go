@@ -0,0 +1,278 @@
+package host
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"machine/usb"
+)
+
+// transfer is a transfer recorded in a trace file.
+type transfer struct {
+	line     int
+	control  bool
+	setup    usb.Setup
+	endpoint uint8
+	data     []byte
+	err      error
+}
+
+// traceController is a Controller that replays a recorded trace, and checks
+// that the host does the same transfers.
+type traceController struct {
+	t         *testing.T
+	trace     []transfer
+	connected bool
+}
+
+func loadTrace(t *testing.T, name string) *traceController {
+	t.Helper()
+	buf, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &traceController{t: t, connected: true}
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		tr := transfer{line: i + 1}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "C":
+			setup, err := hex.DecodeString(strings.Join(fields[1:9], ""))
+			if err != nil || len(setup) != 8 {
+				t.Fatalf("%s:%d: invalid setup packet", name, i+1)
+			}
+			tr.control = true
+			tr.setup = usb.NewSetup(setup)
+			fields = fields[9:]
+		case "T":
+			ep, err := strconv.ParseUint(fields[1], 16, 8)
+			if err != nil {
+				t.Fatalf("%s:%d: invalid endpoint", name, i+1)
+			}
+			tr.endpoint = uint8(ep)
+			fields = fields[2:]
+		default:
+			t.Fatalf("%s:%d: unknown transfer %q", name, i+1, fields[0])
+		}
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "STALL":
+			tr.err = ErrStall
+		case fields[0] == "NAK":
+			tr.err = ErrNAK
+		case fields[0] == ":":
+			tr.data, err = hex.DecodeString(strings.Join(fields[1:], ""))
+			if err != nil {
+				t.Fatalf("%s:%d: %v", name, i+1, err)
+			}
+		default:
+			t.Fatalf("%s:%d: unexpected %q", name, i+1, fields[0])
+		}
+		c.trace = append(c.trace, tr)
+	}
+	return c
+}
+
+func (c *traceController) next() transfer {
+	c.t.Helper()
+	if len(c.trace) == 0 {
+		c.t.Fatal("transfer after the end of the trace")
+	}
+	tr := c.trace[0]
+	c.trace = c.trace[1:]
+	return tr
+}
+
+// done checks that all transfers of the trace have been done.
+func (c *traceController) done() {
+	c.t.Helper()
+	if len(c.trace) != 0 {
+		c.t.Errorf("line %d: transfer not done", c.trace[0].line)
+	}
+}
+
+func (c *traceController) Connected() bool {
+	return c.connected
+}
+
+func (c *traceController) ResetPort() (Speed, error) {
+	return SpeedFull, nil
+}
+
+func (c *traceController) Control(dev *Device, setup usb.Setup, data []byte) (int, error) {
+	c.t.Helper()
+	tr := c.next()
+	if !tr.control || tr.setup != setup {
+		c.t.Fatalf("line %d: unexpected control transfer %+v", tr.line, setup)
+	}
+	return c.data(tr, setup.BmRequestType&usb.REQUEST_DIRECTION != 0, data)
+}
+
+func (c *traceController) Transfer(dev *Device, ep *Endpoint, data []byte) (int, error) {
+	c.t.Helper()
+	tr := c.next()
+	if tr.control || tr.endpoint != ep.Address {
+		c.t.Fatalf("line %d: unexpected transfer on endpoint %#x", tr.line, ep.Address)
+	}
+	return c.data(tr, ep.In(), data)
+}
+
+func (c *traceController) data(tr transfer, in bool, data []byte) (int, error) {
+	c.t.Helper()
+	if tr.err != nil {
+		return 0, tr.err
+	}
+	if in {
+		if len(tr.data) > len(data) {
+			c.t.Fatalf("line %d: buffer of %d bytes too small", tr.line, len(data))
+		}
+		return copy(data, tr.data), nil
+	}
+	if !bytes.Equal(data, tr.data) {
+		c.t.Fatalf("line %d: sent % x", tr.line, data)
+	}
+	return len(data), nil
+}
+
+func TestEnumerateKeyboard(t *testing.T) {
+	c := loadTrace(t, "keyboard.trace")
+	h := New(c)
+	dev, err := h.Enumerate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Address != 1 || dev.MaxPacketSize0 != 8 {
+		t.Errorf("unexpected address %d or max packet size %d", dev.Address, dev.MaxPacketSize0)
+	}
+	if dev.Descriptor.VendorID != 0x413c || dev.Descriptor.ProductID != 0x2113 || dev.Descriptor.USBVersion != 0x0110 {
+		t.Errorf("unexpected device descriptor: %+v", dev.Descriptor)
+	}
+	if len(dev.Config.Interfaces) != 1 {
+		t.Fatalf("expected 1 interface, got %d", len(dev.Config.Interfaces))
+	}
+	iface := dev.Config.Interfaces[0]
+	if iface.Class != usb.DEVICE_CLASS_HUMAN_INTERFACE || len(iface.Extra) != 9 || len(iface.Endpoints) != 1 {
+		t.Errorf("unexpected interface: %+v", iface)
+	}
+	if ep := iface.Endpoints[0]; ep != (Endpoint{Address: 0x81, Type: usb.ENDPOINT_TYPE_INTERRUPT, MaxPacketSize: 8, Interval: 10}) {
+		t.Errorf("unexpected endpoint: %+v", ep)
+	}
+
+	product, err := h.String(dev.Descriptor.Product)
+	if err != nil || product != "KB216" {
+		t.Errorf("unexpected product string %q (%v)", product, err)
+	}
+
+	kb, err := NewKeyboard(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []KeyEvent
+	for i := 0; i < 4; i++ {
+		if err := kb.Poll(func(e KeyEvent) { events = append(events, e) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []KeyEvent{
+		{Key: 0x04, Pressed: true},
+		{Key: KeyLeftCtrl + 1, Modifiers: ModifierLeftShift, Pressed: true},
+		{Key: 0x05, Modifiers: ModifierLeftShift, Pressed: true},
+		{Key: KeyLeftCtrl + 1, Pressed: false},
+		{Key: 0x04, Pressed: false},
+		{Key: 0x05, Pressed: false},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+	if r := events[0].Rune(); r != 'a' {
+		t.Errorf("expected 'a', got %q", r)
+	}
+	if r := events[2].Rune(); r != 'B' {
+		t.Errorf("expected 'B', got %q", r)
+	}
+	c.done()
+}
+
+func TestEnumerateSerial(t *testing.T) {
+	c := loadTrace(t, "cdc.trace")
+	h := New(c)
+	if _, err := h.Enumerate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeyboard(h); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported for a keyboard, got %v", err)
+	}
+	s, err := NewSerial(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Write([]byte("hello")); n != 5 || err != nil {
+		t.Errorf("unexpected write result %d, %v", n, err)
+	}
+	var buf [16]byte
+	if n, err := s.Read(buf[:]); n != 0 || err != nil {
+		t.Errorf("expected no data, got %d bytes (%v)", n, err)
+	}
+	// Read less than a packet at a time.
+	n, err := s.Read(buf[:2])
+	if err != nil || string(buf[:n]) != "ok" {
+		t.Errorf("unexpected read %q (%v)", buf[:n], err)
+	}
+	n, err = s.Read(buf[:])
+	if err != nil || string(buf[:n]) != "\r\n" {
+		t.Errorf("unexpected read %q (%v)", buf[:n], err)
+	}
+	c.done()
+}
+
+func TestEnumerateError(t *testing.T) {
+	c := loadTrace(t, "keyboard.trace")
+	c.trace = c.trace[:1]
+	c.trace[0].data, c.trace[0].err = nil, ErrStall
+	h := New(c)
+	if _, err := h.Enumerate(); err != ErrStall {
+		t.Fatalf("expected ErrStall, got %v", err)
+	}
+	// The host waits for the device to be disconnected.
+	if state := h.Poll(); state != StateError {
+		t.Errorf("expected StateError, got %d", state)
+	}
+	c.connected = false
+	if state := h.Poll(); state != StateDisconnected || h.Err() != nil {
+		t.Errorf("expected StateDisconnected, got %d (%v)", state, h.Err())
+	}
+	if _, err := h.Enumerate(); err != ErrNoDevice {
+		t.Errorf("expected ErrNoDevice, got %v", err)
+	}
+	if _, err := NewSerial(h); err != ErrNoDevice {
+		t.Errorf("expected ErrNoDevice, got %v", err)
+	}
+}
+
+func TestParseConfigurationErrors(t *testing.T) {
+	for _, b := range [][]byte{
+		{},
+		{0x09, 0x02, 0x09, 0x00}, // too short
+		{0x09, 0x02, 0x20, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32},          // wTotalLength too long
+		{0x09, 0x02, 0x0b, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32, 0, 0},    // zero length descriptor
+		{0x09, 0x02, 0x0c, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32, 3, 4, 0}, // short interface
+		{0x12, 0x01, 0x0c, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32},          // device descriptor
+	} {
+		if _, err := ParseConfiguration(b); err != errInvalidDescriptor {
+			t.Errorf("% x: expected errInvalidDescriptor, got %v", b, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package host
+
+import "machine/usb"
+
+// HID class requests.
+const (
+	hidSetIdle      = 0x0a
+	hidSetProtocol  = 0x0b
+	hidBootProtocol = 0
+)
+
+// Modifier keys, as reported in the first byte of a boot keyboard report.
+const (
+	ModifierLeftCtrl = 1 << iota
+	ModifierLeftShift
+	ModifierLeftAlt
+	ModifierLeftGUI
+	ModifierRightCtrl
+	ModifierRightShift
+	ModifierRightAlt
+	ModifierRightGUI
+)
+
+// KeyLeftCtrl is the usage ID of the first modifier key. The other modifier
+// keys follow it in the same order as the Modifier bits.
+const KeyLeftCtrl = 0xe0
+
+// KeyEvent is a key that was pressed or released on a keyboard.
+type KeyEvent struct {
+	Key       uint8 // usage ID on the HID keyboard page, such as 0x04 for A
+	Modifiers uint8 // modifier keys held down, such as ModifierLeftShift
+	Pressed   bool  // pressed or released
+}
+
+// Characters of the keys 0x04 (A) to 0x38 (slash) on a US keyboard, without and
+// with shift.
+const (
+	keyChars      = "abcdefghijklmnopqrstuvwxyz1234567890\n\x1b\b\t -=[]\\#;'`,./"
+	keyCharsShift = "ABCDEFGHIJKLMNOPQRSTUVWXYZ!@#$%^&*()\n\x1b\b\t _+{}|~:\"~<>?"
+)
+
+// Rune returns the character of the key on a US keyboard, or 0 if the key
+// isn't a character such as the arrow keys.
+func (e KeyEvent) Rune() rune {
+	if e.Key < 0x04 || int(e.Key-0x04) >= len(keyChars) {
+		return 0
+	}
+	if e.Modifiers&(ModifierLeftShift|ModifierRightShift) != 0 {
+		return rune(keyCharsShift[e.Key-0x04])
+	}
+	return rune(keyChars[e.Key-0x04])
+}
+
+// Keyboard is a HID keyboard, driven with the boot protocol that all
+// keyboards support.
+type Keyboard struct {
+	host   *Host
+	ep     *Endpoint
+	report [8]byte
+	prev   [8]byte
+}
+
+// NewKeyboard sets up the keyboard interface of the configured device, and
+// returns ErrUnsupported if it doesn't have one.
+func NewKeyboard(h *Host) (*Keyboard, error) {
+	dev := h.Device()
+	if dev == nil {
+		return nil, ErrNoDevice
+	}
+	iface := dev.Config.FindInterface(usb.DEVICE_CLASS_HUMAN_INTERFACE, 1, 1) // boot interface, keyboard
+	if iface == nil {
+		return nil, ErrUnsupported
+	}
+	ep := iface.FindEndpoint(usb.ENDPOINT_TYPE_INTERRUPT, true)
+	if ep == nil {
+		return nil, ErrUnsupported
+	}
+	_, err := h.Control(usb.Setup{
+		BmRequestType: usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE,
+		BRequest:      hidSetProtocol,
+		WValueL:       hidBootProtocol,
+		WIndex:        uint16(iface.Number),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Only send a report when a key changes. Not all keyboards support this,
+	// which is fine as unchanged reports are ignored.
+	_, err = h.Control(usb.Setup{
+		BmRequestType: usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE,
+		BRequest:      hidSetIdle,
+		WIndex:        uint16(iface.Number),
+	}, nil)
+	if err != nil && err != ErrStall {
+		return nil, err
+	}
+	return &Keyboard{host: h, ep: ep}, nil
+}
+
+// Poll reads a report from the keyboard and calls the handler for each key
+// that was pressed or released since the previous report: first the modifier
+// keys, then the released keys and then the pressed keys. It returns nil
+// without calling the handler if the keyboard has nothing to report. It
+// should be called at least as often as the polling interval of the keyboard,
+// which is usually 10ms.
+func (k *Keyboard) Poll(handler func(KeyEvent)) error {
+	n, err := k.host.Transfer(k.ep, k.report[:])
+	if err == ErrNAK {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if n < 3 || k.report[2] == 0x01 {
+		// Short report, or too many keys pressed at once (phantom state).
+		return nil
+	}
+	for i := n; i < len(k.report); i++ {
+		k.report[i] = 0
+	}
+
+	mods, prevMods := k.report[0], k.prev[0]
+	for i := uint8(0); i < 8; i++ {
+		bit := uint8(1) << i
+		if (mods^prevMods)&bit != 0 {
+			handler(KeyEvent{Key: KeyLeftCtrl + i, Modifiers: mods, Pressed: mods&bit != 0})
+		}
+	}
+	for _, key := range k.prev[2:] {
+		if key != 0 && !containsKey(k.report[2:], key) {
+			handler(KeyEvent{Key: key, Modifiers: mods, Pressed: false})
+		}
+	}
+	for _, key := range k.report[2:] {
+		if key != 0 && !containsKey(k.prev[2:], key) {
+			handler(KeyEvent{Key: key, Modifiers: mods, Pressed: true})
+		}
+	}
+	k.prev = k.report
+	return nil
+}
+
+func containsKey(keys []byte, key byte) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package host
+
+import "machine/usb"
+
+// CDC class requests.
+const (
+	cdcSetLineCoding       = 0x20
+	cdcSetControlLineState = 0x22
+)
+
+// The data interface class of a CDC function.
+const cdcDataInterfaceClass = 0x0a
+
+// Serial is a CDC-ACM serial port, such as a USB to serial converter or
+// another microcontroller running TinyGo.
+type Serial struct {
+	host    *Host
+	iface   uint8
+	in, out *Endpoint
+	buf     [usb.EndpointPacketSize]byte
+	pending []byte
+}
+
+// NewSerial sets up the CDC-ACM function of the configured device at 115200
+// baud, and returns ErrUnsupported if it doesn't have one.
+func NewSerial(h *Host) (*Serial, error) {
+	dev := h.Device()
+	if dev == nil {
+		return nil, ErrNoDevice
+	}
+	comm := dev.Config.FindInterface(usb.DEVICE_CLASS_COMMUNICATIONS, 2, -1) // abstract control model
+	data := dev.Config.FindInterface(cdcDataInterfaceClass, -1, -1)
+	if comm == nil || data == nil {
+		return nil, ErrUnsupported
+	}
+	s := &Serial{
+		host:  h,
+		iface: comm.Number,
+		in:    data.FindEndpoint(usb.ENDPOINT_TYPE_BULK, true),
+		out:   data.FindEndpoint(usb.ENDPOINT_TYPE_BULK, false),
+	}
+	if s.in == nil || s.out == nil || s.in.MaxPacketSize > uint16(len(s.buf)) {
+		return nil, ErrUnsupported
+	}
+	if err := s.SetBaudRate(115200); err != nil {
+		return nil, err
+	}
+	// Set DTR and RTS, which some devices wait for before they send data.
+	_, err := h.Control(usb.Setup{
+		BmRequestType: usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE,
+		BRequest:      cdcSetControlLineState,
+		WValueL:       0x03,
+		WIndex:        uint16(s.iface),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetBaudRate sets the baud rate, with 8 data bits, no parity and one stop
+// bit. This only matters for USB to serial converters.
+func (s *Serial) SetBaudRate(br uint32) error {
+	lineCoding := [7]byte{byte(br), byte(br >> 8), byte(br >> 16), byte(br >> 24), 0, 0, 8}
+	_, err := s.host.Control(usb.Setup{
+		BmRequestType: usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE,
+		BRequest:      cdcSetLineCoding,
+		WIndex:        uint16(s.iface),
+		WLength:       uint16(len(lineCoding)),
+	}, lineCoding[:])
+	return err
+}
+
+// Read reads the data that the device has sent. It doesn't wait for data: it
+// returns 0 bytes if there is none.
+func (s *Serial) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		n, err := s.host.Transfer(s.in, s.buf[:s.in.MaxPacketSize])
+		if err == ErrNAK {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		s.pending = s.buf[:n]
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write sends data to the device.
+func (s *Serial) Write(p []byte) (int, error) {
+	return s.host.Transfer(s.out, p)
+}
@@ -0,0 +1,50 @@
+package usb
+
+// ParseID parses a USB vendor or product ID, as set with the usb-vendor-id and
+// usb-product-id target options. The ID is either hexadecimal with a 0x prefix
+// or decimal. It returns false if the string is empty or not a valid 16-bit
+// number.
+func ParseID(s string) (uint16, bool) {
+	base := uint32(10)
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		base = 16
+		s = s[2:]
+	}
+	if len(s) == 0 {
+		return 0, false
+	}
+	var id uint32
+	for i := 0; i < len(s); i++ {
+		var digit uint32
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digit = uint32(c - '0')
+		case c >= 'a' && c <= 'f':
+			digit = uint32(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			digit = uint32(c-'A') + 10
+		default:
+			return 0, false
+		}
+		if digit >= base {
+			return 0, false
+		}
+		id = id*base + digit
+		if id > 0xffff {
+			return 0, false
+		}
+	}
+	return uint16(id), true
+}
+
+// SerialNumber formats a unique ID of the chip as a serial number string, in
+// upper case hexadecimal as hosts expect it.
+func SerialNumber(id []byte) string {
+	const digits = "0123456789ABCDEF"
+	s := make([]byte, len(id)*2)
+	for i, b := range id {
+		s[i*2] = digits[b>>4]
+		s[i*2+1] = digits[b&0xf]
+	}
+	return string(s)
+}
@@ -0,0 +1,36 @@
+package usb
+
+import "testing"
+
+func TestParseID(t *testing.T) {
+	for _, tc := range []struct {
+		s  string
+		id uint16
+		ok bool
+	}{
+		{"0x2e8a", 0x2e8a, true},
+		{"0X000A", 0x000a, true},
+		{"9114", 9114, true},
+		{"0xffff", 0xffff, true},
+		{"0x10000", 0, false},
+		{"65536", 0, false},
+		{"0x", 0, false},
+		{"", 0, false},
+		{"12ab", 0, false},
+		{"-1", 0, false},
+	} {
+		id, ok := ParseID(tc.s)
+		if id != tc.id || ok != tc.ok {
+			t.Errorf("ParseID(%q) = %#x, %v; expected %#x, %v", tc.s, id, ok, tc.id, tc.ok)
+		}
+	}
+}
+
+func TestSerialNumber(t *testing.T) {
+	if s := SerialNumber([]byte{0xe6, 0x60, 0x38, 0xb7, 0x13, 0x4f, 0x2a, 0x31}); s != "E66038B7134F2A31" {
+		t.Errorf("unexpected serial number %q", s)
+	}
+	if s := SerialNumber(nil); s != "" {
+		t.Errorf("expected empty serial number, got %q", s)
+	}
+}
@@ -2,12 +2,12 @@ package midi
 
 import (
 	"machine"
+	"machine/usb"
 )
 
-const (
-	midiEndpointOut = 5 // from PC
-	midiEndpointIn  = 6 // to PC
-)
+// midiEndpointIn is the bulk IN endpoint (to PC), assigned by
+// machine.EnableMIDI.
+var midiEndpointIn uint32
 
 var Midi *midi
 
@@ -33,7 +33,8 @@ func newMidi() *midi {
 	m := &midi{
 		buf: NewRingBuffer(),
 	}
-	machine.EnableMIDI(m.Handler, m.RxHandler, nil)
+	f := machine.EnableMIDI(m.Handler, m.RxHandler, nil)
+	midiEndpointIn = uint32(f.In &^ usb.EndpointIn)
 	return m
 }
 
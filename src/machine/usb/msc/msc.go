@@ -0,0 +1,376 @@
+// Package msc implements the USB mass storage class, so that a block device
+// such as machine.Flash shows up as a disk on the host. It uses the SCSI
+// transparent command set with the bulk-only transport, which is what hosts
+// expect from USB flash drives:
+//
+//	func init() {
+//		msc.New(machine.Flash)
+//	}
+//
+// The mass storage function is added to the other USB functions of the
+// device, such as the CDC serial port.
+package msc
+
+import (
+	"machine"
+	"machine/usb"
+)
+
+// The size of a logical block as seen by the host.
+const blockSize = 512
+
+// Signatures of the command block wrapper (CBW) and the command status wrapper
+// (CSW) of the bulk-only transport.
+const (
+	cbwSignature = 0x43425355
+	cswSignature = 0x53425355
+	cbwSize      = 31
+	cswSize      = 13
+)
+
+// Class specific requests.
+const (
+	requestReset     = 0xff
+	requestGetMaxLUN = 0xfe
+)
+
+// SCSI commands.
+const (
+	scsiTestUnitReady             = 0x00
+	scsiRequestSense              = 0x03
+	scsiInquiry                   = 0x12
+	scsiModeSense6                = 0x1a
+	scsiStartStopUnit             = 0x1b
+	scsiPreventAllowMediumRemoval = 0x1e
+	scsiReadFormatCapacities      = 0x23
+	scsiReadCapacity10            = 0x25
+	scsiRead10                    = 0x28
+	scsiWrite10                   = 0x2a
+	scsiVerify10                  = 0x2f
+	scsiSynchronizeCache10        = 0x35
+)
+
+// SCSI sense keys and additional sense codes.
+const (
+	senseMediumError    = 0x03
+	senseIllegalRequest = 0x05
+
+	ascInvalidCommand  = 0x20
+	ascLBAOutOfRange   = 0x21
+	ascWriteError      = 0x0c
+	ascUnrecoveredRead = 0x11
+)
+
+// Command status values.
+const (
+	statusPassed = 0
+	statusFailed = 1
+)
+
+type state uint8
+
+const (
+	stateCommand state = iota // waiting for a command
+	stateDataIn               // sending data to the host
+	stateDataOut              // receiving data from the host
+	stateStatus               // sending the status
+)
+
+// MSC is a USB mass storage function backed by a block device.
+type MSC struct {
+	dev    machine.BlockDevice
+	in     uint32
+	blocks uint32
+
+	state  state
+	tag    uint32
+	length uint32 // length of the data phase, as expected by the host
+	total  uint32 // length of the data phase, as processed by the device
+	done   uint32 // bytes sent or received so far
+	status uint8
+	offset int64  // block device offset of READ and WRITE commands, or -1
+	resp   []byte // response of other commands
+
+	sense struct{ key, asc uint8 }
+
+	packet  [usb.EndpointPacketSize]byte
+	respBuf [36]byte
+
+	// Writes go through a cache of whole erase blocks, as the host writes
+	// logical blocks which are usually smaller than erase blocks.
+	cache     []byte
+	cacheAddr int64
+	dirty     bool
+}
+
+var maxLUN = [1]byte{0}
+
+// New adds a mass storage function for the given block device to the USB
+// functions of the device. This function must be executed from the init().
+//
+// The commands of the host are handled in the USB interrupt, including writes
+// to the block device.
+func New(dev machine.BlockDevice) *MSC {
+	m := &MSC{
+		dev:       dev,
+		blocks:    uint32(dev.Size() / blockSize),
+		cacheAddr: -1,
+	}
+	size := dev.EraseBlockSize()
+	if size < blockSize {
+		size = blockSize
+	}
+	m.cache = make([]byte, size)
+	f := machine.EnableMSC(m.txHandler, m.rxHandler, m.setupHandler)
+	m.in = uint32(f.In &^ usb.EndpointIn)
+	return m
+}
+
+func (m *MSC) setupHandler(setup usb.Setup) bool {
+	switch setup.BRequest {
+	case requestGetMaxLUN:
+		machine.SendUSBInPacket(0, maxLUN[:])
+		return true
+	case requestReset:
+		m.flush()
+		m.state = stateCommand
+		machine.SendZlp()
+		return true
+	}
+	return false
+}
+
+// rxHandler is called for each packet received from the host.
+func (m *MSC) rxHandler(b []byte) {
+	switch m.state {
+	case stateCommand:
+		m.command(b)
+	case stateDataOut:
+		m.receive(b)
+	}
+}
+
+// txHandler is called each time a packet has been sent to the host.
+func (m *MSC) txHandler() {
+	switch m.state {
+	case stateDataIn:
+		if m.done < m.total {
+			m.sendData()
+		} else {
+			m.sendStatus()
+		}
+	case stateStatus:
+		m.state = stateCommand
+	}
+}
+
+// command starts executing the command in a command block wrapper.
+func (m *MSC) command(b []byte) {
+	if len(b) != cbwSize || le32(b[0:]) != cbwSignature {
+		// Not a valid command, ignore it.
+		return
+	}
+	m.tag = le32(b[4:])
+	m.length = le32(b[8:])
+	in := b[12]&0x80 != 0
+	m.done = 0
+	m.status = statusPassed
+	m.offset = -1
+	m.resp = nil
+	m.execute(b[15:cbwSize])
+
+	if m.offset < 0 {
+		m.total = uint32(len(m.resp))
+	}
+	if m.total > m.length {
+		m.total = m.length
+	}
+	switch {
+	case m.length == 0:
+		m.sendStatus()
+	case in:
+		m.state = stateDataIn
+		m.sendData()
+	default:
+		m.state = stateDataOut
+	}
+}
+
+// execute runs a SCSI command. Commands that transfer data set either resp
+// or offset and total.
+func (m *MSC) execute(cb []byte) {
+	switch cb[0] {
+	case scsiTestUnitReady, scsiStartStopUnit, scsiPreventAllowMediumRemoval, scsiVerify10:
+		// Nothing to do.
+	case scsiSynchronizeCache10:
+		if m.flush() != nil {
+			m.fail(senseMediumError, ascWriteError)
+		}
+	case scsiRequestSense:
+		r := m.response(18)
+		r[0] = 0x70 // current errors
+		r[2] = m.sense.key
+		r[7] = 10 // additional sense length
+		r[12] = m.sense.asc
+		m.sense.key, m.sense.asc = 0, 0
+	case scsiInquiry:
+		r := m.response(36)
+		r[1] = 0x80 // removable
+		r[2] = 0x04 // SPC-2
+		r[3] = 0x02 // response data format
+		r[4] = 31   // additional length
+		copy(r[8:16], "TinyGo  ")
+		copy(r[16:32], "Mass Storage    ")
+		copy(r[32:36], "1.0 ")
+	case scsiModeSense6:
+		r := m.response(4)
+		r[0] = 3 // mode data length
+	case scsiReadFormatCapacities:
+		r := m.response(12)
+		r[3] = 8 // capacity list length
+		putBE32(r[4:], m.blocks)
+		putBE32(r[8:], blockSize)
+		r[8] = 0x02 // formatted media
+	case scsiReadCapacity10:
+		r := m.response(8)
+		putBE32(r[0:], m.blocks-1)
+		putBE32(r[4:], blockSize)
+	case scsiRead10, scsiWrite10:
+		lba := be32(cb[2:])
+		count := uint32(cb[7])<<8 | uint32(cb[8])
+		if lba > m.blocks || count > m.blocks-lba {
+			m.fail(senseIllegalRequest, ascLBAOutOfRange)
+			return
+		}
+		m.offset = int64(lba) * blockSize
+		m.total = count * blockSize
+	default:
+		m.fail(senseIllegalRequest, ascInvalidCommand)
+	}
+}
+
+// response returns a zeroed buffer for a response of the given length.
+func (m *MSC) response(n int) []byte {
+	m.resp = m.respBuf[:n]
+	for i := range m.resp {
+		m.resp[i] = 0
+	}
+	return m.resp
+}
+
+// fail marks the command as failed, with the given sense data for the
+// REQUEST SENSE command that the host sends next.
+func (m *MSC) fail(key, asc uint8) {
+	m.status = statusFailed
+	m.sense.key, m.sense.asc = key, asc
+	m.resp = nil
+	m.total = 0
+}
+
+// sendData sends the next packet of the data phase. A zero-length packet ends
+// the data phase early, when there is less data than the host expected.
+func (m *MSC) sendData() {
+	n := m.total - m.done
+	if n > usb.EndpointPacketSize {
+		n = usb.EndpointPacketSize
+	}
+	p := m.packet[:n]
+	if m.offset >= 0 {
+		if _, err := m.dev.ReadAt(p, m.offset+int64(m.done)); err != nil {
+			m.fail(senseMediumError, ascUnrecoveredRead)
+		}
+	} else {
+		copy(p, m.resp[m.done:])
+	}
+	m.done += n
+	machine.SendUSBInPacket(m.in, p)
+}
+
+// receive handles a packet of the data phase of a WRITE command.
+func (m *MSC) receive(b []byte) {
+	if m.offset >= 0 && m.done < m.total && m.status == statusPassed {
+		p := b
+		if uint32(len(p)) > m.total-m.done {
+			p = p[:m.total-m.done]
+		}
+		if m.write(p, m.offset+int64(m.done)) != nil {
+			m.fail(senseMediumError, ascWriteError)
+		}
+	}
+	m.done += uint32(len(b))
+	if m.done >= m.length {
+		if m.flush() != nil {
+			m.fail(senseMediumError, ascWriteError)
+		}
+		m.sendStatus()
+	}
+}
+
+// sendStatus sends the command status wrapper, which ends the command.
+func (m *MSC) sendStatus() {
+	residue := m.length - m.total
+	if m.status != statusPassed {
+		residue = m.length
+	}
+	p := m.packet[:cswSize]
+	putLE32(p[0:], cswSignature)
+	putLE32(p[4:], m.tag)
+	putLE32(p[8:], residue)
+	p[12] = m.status
+	m.state = stateStatus
+	machine.SendUSBInPacket(m.in, p)
+}
+
+// write writes data to the block device through the erase block cache.
+func (m *MSC) write(p []byte, off int64) error {
+	size := int64(len(m.cache))
+	for len(p) > 0 {
+		addr := off - off%size
+		if addr != m.cacheAddr {
+			if err := m.flush(); err != nil {
+				return err
+			}
+			if _, err := m.dev.ReadAt(m.cache, addr); err != nil {
+				m.cacheAddr = -1
+				return err
+			}
+			m.cacheAddr = addr
+		}
+		n := copy(m.cache[off-addr:], p)
+		m.dirty = true
+		p = p[n:]
+		off += int64(n)
+	}
+	return nil
+}
+
+// flush writes the erase block cache back to the block device, if it was
+// modified.
+func (m *MSC) flush() error {
+	if !m.dirty {
+		return nil
+	}
+	m.dirty = false
+	eraseSize := m.dev.EraseBlockSize()
+	if err := m.dev.EraseBlocks(m.cacheAddr/eraseSize, int64(len(m.cache))/eraseSize); err != nil {
+		return err
+	}
+	_, err := m.dev.WriteAt(m.cache, m.cacheAddr)
+	return err
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0], b[1], b[2], b[3] = byte(v>>24), byte(v>>16), byte(v>>8), byte(v)
+}
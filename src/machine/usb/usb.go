@@ -64,7 +64,7 @@ const (
 	CONFIG_REMOTE_WAKEUP = 0x20
 
 	// Interface
-	NumberOfInterfaces = 3
+	NumberOfInterfaces = 8
 	CDC_ACM_INTERFACE  = 0 // CDC ACM
 	CDC_DATA_INTERFACE = 1 // CDC Data
 	CDC_FIRST_ENDPOINT = 1
@@ -0,0 +1,137 @@
+//go:build !baremetal || nrf || sam || rp2040 || stm32 || (cortexm && qemu)
+// +build !baremetal nrf sam rp2040 stm32 cortexm,qemu
+
+package machine
+
+// Hardware abstraction layer for the watchdog timer and reset cause.
+//
+// Chips that support it provide the Watchdog variable, the WatchdogMaxTimeout
+// constant and the ResetReason function. A typical use looks like this:
+//
+//	machine.Watchdog.Configure(machine.WatchdogConfig{TimeoutMillis: 1000})
+//	machine.Watchdog.Start()
+//	for {
+//		doWork()
+//		machine.Watchdog.Update()
+//	}
+
+// WatchdogConfig holds the configuration for the watchdog timer.
+type WatchdogConfig struct {
+	// The timeout in milliseconds after which the watchdog resets the chip
+	// when Update is not called. Timeouts longer than WatchdogMaxTimeout are
+	// rounded down to WatchdogMaxTimeout. Some chips only support a few
+	// timeouts, in which case the timeout is rounded up to the next supported
+	// value.
+	TimeoutMillis uint32
+}
+
+// ResetCause is the cause of the last reset of the chip, as returned by
+// ResetReason.
+type ResetCause uint8
+
+const (
+	// The reset cause is not known or not supported on this chip.
+	ResetCauseUnknown ResetCause = iota
+
+	// The chip was powered on.
+	ResetCausePowerOn
+
+	// The supply voltage dropped below the brown-out threshold.
+	ResetCauseBrownout
+
+	// The reset pin was pulled low.
+	ResetCauseExternal
+
+	// The watchdog timer expired.
+	ResetCauseWatchdog
+
+	// The program (or a debugger) requested a reset, for example by setting
+	// SYSRESETREQ on Cortex-M.
+	ResetCauseSoftware
+
+	// The chip woke up from a low power mode in which the CPU state is lost,
+	// such as System OFF on the nRF chips.
+	ResetCauseWakeup
+)
+
+// String returns a human-readable name of the reset cause.
+func (c ResetCause) String() string {
+	switch c {
+	case ResetCausePowerOn:
+		return "power-on"
+	case ResetCauseBrownout:
+		return "brown-out"
+	case ResetCauseExternal:
+		return "external"
+	case ResetCauseWatchdog:
+		return "watchdog"
+	case ResetCauseSoftware:
+		return "software"
+	case ResetCauseWakeup:
+		return "wakeup"
+	default:
+		return "unknown"
+	}
+}
+
+// Watchdog provides access to the hardware watchdog timer of the chip. Once
+// started, it resets the chip when it isn't updated within the configured
+// timeout.
+var Watchdog = &watchdogImpl{}
+
+var _ watchdogTimer = Watchdog
+
+// watchdogTimer is the interface implemented by Watchdog.
+type watchdogTimer interface {
+	Configure(config WatchdogConfig) error
+	Start() error
+	Update()
+}
+
+// The chip specific part of the watchdog is implemented by these functions:
+//
+//	// watchdogConfigure configures (but does not start) the watchdog with a
+//	// timeout of at most WatchdogMaxTimeout.
+//	func watchdogConfigure(timeoutMillis uint32)
+//
+//	// watchdogStart starts the watchdog.
+//	func watchdogStart()
+//
+//	// watchdogUpdate reloads the watchdog counter.
+//	func watchdogUpdate()
+//
+//	// resetReason returns the cause of the last reset.
+//	func resetReason() ResetCause
+
+type watchdogImpl struct{}
+
+// Configure the watchdog. This must be done before calling Start: on most
+// chips the watchdog cannot be reconfigured once it is running.
+func (wd *watchdogImpl) Configure(config WatchdogConfig) error {
+	timeout := config.TimeoutMillis
+	if uint64(timeout) > WatchdogMaxTimeout {
+		timeout = WatchdogMaxTimeout
+	}
+	watchdogConfigure(timeout)
+	return nil
+}
+
+// Start the watchdog. Once started, the watchdog cannot be stopped.
+func (wd *watchdogImpl) Start() error {
+	watchdogStart()
+	return nil
+}
+
+// Update (feed) the watchdog, indicating that the program is healthy.
+func (wd *watchdogImpl) Update() {
+	watchdogUpdate()
+}
+
+// ResetReason returns the cause of the last reset.
+//
+// On chips that keep the reset flags until a power-on reset, the flags are
+// read and cleared at startup, so that they only record the cause of the last
+// reset. If several flags are set anyway, the most specific one is returned.
+func ResetReason() ResetCause {
+	return resetReason()
+}
@@ -5,6 +5,7 @@
 package os
 
 import (
+	"io"
 	"io/fs"
 	"sort"
 )
@@ -38,7 +39,7 @@ func (f *File) Readdir(n int) ([]FileInfo, error) {
 	if f == nil {
 		return nil, ErrInvalid
 	}
-	_, _, infos, err := f.readdir(n, readdirFileInfo)
+	_, _, infos, err := f.readdirMount(n, readdirFileInfo)
 	if infos == nil {
 		// Readdir has historically always returned a non-nil empty slice, never nil,
 		// even on error (except misuse with nil receiver above).
@@ -67,7 +68,7 @@ func (f *File) Readdirnames(n int) (names []string, err error) {
 	if f == nil {
 		return nil, ErrInvalid
 	}
-	names, _, _, err = f.readdir(n, readdirName)
+	names, _, _, err = f.readdirMount(n, readdirName)
 	if names == nil {
 		// Readdirnames has historically always returned a non-nil empty slice, never nil,
 		// even on error (except misuse with nil receiver above).
@@ -95,7 +96,7 @@ func (f *File) ReadDir(n int) ([]DirEntry, error) {
 	if f == nil {
 		return nil, ErrInvalid
 	}
-	_, dirents, _, err := f.readdir(n, readdirDirEntry)
+	_, dirents, _, err := f.readdirMount(n, readdirDirEntry)
 	if dirents == nil {
 		// Match Readdir and Readdirnames: don't return nil slices.
 		dirents = []DirEntry{}
@@ -103,6 +104,56 @@ func (f *File) ReadDir(n int) ([]DirEntry, error) {
 	return dirents, err
 }
 
+// mountDirInfo holds the state of a directory being read from a mounted
+// ReadDirFS. The entries are read all at once, on the first read.
+type mountDirInfo struct {
+	fs      ReadDirFS
+	name    string // path relative to the mount point
+	entries []DirEntry
+	read    bool
+}
+
+// readdirMount is like readdir, but also supports directories that were opened
+// on a mounted filesystem that implements ReadDirFS.
+func (f *File) readdirMount(n int, mode readdirMode) (names []string, dirents []DirEntry, infos []FileInfo, err error) {
+	d := f.mountdir
+	if d == nil {
+		return f.readdir(n, mode)
+	}
+	if !d.read {
+		d.entries, err = d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, nil, nil, &PathError{Op: "readdirent", Path: f.name, Err: err}
+		}
+		d.read = true
+	}
+	entries := d.entries
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, nil, nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.entries = d.entries[len(entries):]
+	for _, entry := range entries {
+		switch mode {
+		case readdirName:
+			names = append(names, entry.Name())
+		case readdirDirEntry:
+			dirents = append(dirents, entry)
+		case readdirFileInfo:
+			info, err := entry.Info()
+			if err != nil {
+				return nil, nil, infos, err
+			}
+			infos = append(infos, info)
+		}
+	}
+	return names, dirents, infos, nil
+}
+
 // testingForceReadDirLstat forces ReadDir to call Lstat, for testing that code path.
 // This can be difficult to provoke on some Unix systems otherwise.
 var testingForceReadDirLstat bool
@@ -0,0 +1,19 @@
+//go:build linux && !baremetal && !wasi
+// +build linux,!baremetal,!wasi
+
+package os
+
+import "syscall"
+
+// sigpipe is implemented in the runtime. It terminates the process with
+// SIGPIPE.
+func sigpipe()
+
+// epipecheck mirrors the upstream behavior for broken pipes: the runtime
+// catches SIGPIPE so that writes to a closed pipe return EPIPE, except for
+// writes to stdout and stderr which kill the process with SIGPIPE.
+func epipecheck(f unixFileHandle, e error) {
+	if e == syscall.EPIPE && (f == 1 || f == 2) {
+		sigpipe()
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !baremetal && !js && (!linux || wasi)
+// +build !baremetal
+// +build !js
+// +build !linux wasi
+
+package os
+
+// epipecheck is a no-op on systems where the runtime doesn't catch SIGPIPE.
+func epipecheck(f unixFileHandle, e error) {
+}
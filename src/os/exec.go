@@ -2,6 +2,7 @@ package os
 
 import (
 	"errors"
+	"internal/itoa"
 	"syscall"
 )
 
@@ -30,45 +31,158 @@ type ProcAttr struct {
 // ErrProcessDone indicates a Process has finished.
 var ErrProcessDone = errors.New("os: process already finished")
 
+// ProcessState stores information about a process, as reported by Wait.
 type ProcessState struct {
+	pid    int                // The process's id.
+	status syscall.WaitStatus // System-dependent status info.
+	rusage interface{}        // System-dependent resource usage, may be nil.
 }
 
-func (p *ProcessState) String() string {
-	return "" // TODO
+// Pid returns the process id of the exited process.
+func (p *ProcessState) Pid() int {
+	return p.pid
+}
+
+// Exited reports whether the program has exited.
+// On Unix systems this reports true if the program exited due to calling exit,
+// but false if the program terminated due to a signal.
+func (p *ProcessState) Exited() bool {
+	return p.status.Exited()
 }
+
+// Success reports whether the program exited successfully,
+// such as with exit status 0 on Unix.
 func (p *ProcessState) Success() bool {
-	return false // TODO
+	return p.status.Exited() && p.status.ExitStatus() == 0
 }
 
 // Sys returns system-dependent exit information about
 // the process. Convert it to the appropriate underlying
 // type, such as syscall.WaitStatus on Unix, to access its contents.
 func (p *ProcessState) Sys() interface{} {
-	return nil // TODO
+	return p.status
+}
+
+// SysUsage returns system-dependent resource usage information about
+// the exited process. Convert it to the appropriate underlying
+// type, such as *syscall.Rusage on Unix, to access its contents.
+// It returns nil when the information is not available.
+func (p *ProcessState) SysUsage() interface{} {
+	return p.rusage
+}
+
+func (p *ProcessState) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	status := p.status
+	res := ""
+	switch {
+	case status.Exited():
+		res = "exit status " + itoa.Itoa(status.ExitStatus())
+	case status.Signaled():
+		res = "signal: " + status.Signal().String()
+	case status.Stopped():
+		res = "stop signal: " + status.StopSignal().String()
+	case status.Continued():
+		res = "continued"
+	}
+	if status.CoreDump() {
+		res += " (core dumped)"
+	}
+	return res
 }
 
 // ExitCode returns the exit code of the exited process, or -1
 // if the process hasn't exited or was terminated by a signal.
 func (p *ProcessState) ExitCode() int {
-	return -1 // TODO
+	// return -1 if the process hasn't started.
+	if p == nil || !p.status.Exited() {
+		return -1
+	}
+	return p.status.ExitStatus()
 }
 
+// Process stores the information about a process created by StartProcess.
 type Process struct {
-	Pid int
+	Pid  int
+	done bool
 }
 
+// StartProcess starts a new process with the program, arguments and attributes
+// specified by name, argv and attr. The argv slice will become os.Args in the
+// new process, so it normally starts with the program name.
+//
+// If the calling goroutine has locked the operating system thread
+// with runtime.LockOSThread and modified any inheritable OS-level
+// thread state (for example, Linux or Plan 9 name spaces), the new
+// process will inherit the caller's thread state.
+//
+// StartProcess is a low-level interface. The os/exec package provides
+// higher-level interfaces.
+//
+// If there is an error, it will be of type *PathError.
 func StartProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
-	return nil, &PathError{"fork/exec", name, ErrNotImplemented}
+	// If there is no SysProcAttr (ie. no Chroot or changed
+	// UID/GID), double-check existence of the directory we want
+	// to chdir into. We can make the error clearer this way.
+	if attr != nil && attr.Sys == nil && attr.Dir != "" {
+		if _, err := Stat(attr.Dir); err != nil {
+			pe := err.(*PathError)
+			pe.Op = "chdir"
+			return nil, pe
+		}
+	}
+	if attr == nil {
+		attr = &ProcAttr{}
+	}
+
+	p, err := startProcess(name, argv, attr)
+	if err != nil {
+		return nil, &PathError{Op: "fork/exec", Path: name, Err: err}
+	}
+	return p, nil
+}
+
+// FindProcess looks for a running process by its pid.
+//
+// The Process it returns can be used to obtain information
+// about the underlying operating system process.
+//
+// On Unix systems, FindProcess always succeeds and returns a Process
+// for the given pid, regardless of whether the process exists.
+func FindProcess(pid int) (*Process, error) {
+	return &Process{Pid: pid}, nil
+}
+
+// Release releases any resources associated with the Process p,
+// rendering it unusable in the future.
+//
+// Release only needs to be called if Wait is not.
+func (p *Process) Release() error {
+	// NOOP for unix.
+	p.Pid = -1
+	return nil
 }
 
+// Wait waits for the Process to exit, and then returns a
+// ProcessState describing its status and an error, if any.
+// Wait releases any resources associated with the Process.
+// On most operating systems, the Process must be a child
+// of the current process or an error will be returned.
 func (p *Process) Wait() (*ProcessState, error) {
-	return nil, ErrNotImplemented
+	return p.wait()
 }
 
+// Kill causes the Process to exit immediately. Kill does not wait until
+// the Process has actually exited. This only kills the Process itself,
+// not any other processes it may have started.
 func (p *Process) Kill() error {
-	return ErrNotImplemented
+	return p.Signal(Kill)
 }
 
+// Signal sends a signal to the Process.
+// Sending Interrupt on Windows is not implemented.
 func (p *Process) Signal(sig Signal) error {
-	return ErrNotImplemented
+	return p.signal(sig)
 }
@@ -0,0 +1,103 @@
+//go:build linux && !baremetal && !wasi
+// +build linux,!baremetal,!wasi
+
+package exec_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	out, err := exec.Command("echo", "hello", "world").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hello world\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestExitError(t *testing.T) {
+	_, err := exec.Command("sh", "-c", "echo oops >&2; exit 7").Output()
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if ee.ExitCode() != 7 {
+		t.Errorf("exit code: got %d, want 7", ee.ExitCode())
+	}
+	if string(ee.Stderr) != "oops\n" {
+		t.Errorf("stderr: got %q", ee.Stderr)
+	}
+	if ee.Error() != "exit status 7" {
+		t.Errorf("error: got %q", ee.Error())
+	}
+}
+
+func TestDirEnv(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "pwd; echo $GREETING")
+	cmd.Dir = os.TempDir()
+	cmd.Env = []string{"GREETING=hi"}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := cmd.Dir + "\nhi\n"; string(out) != want {
+		t.Errorf("output: got %q, want %q", out, want)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	cmd := exec.Command("cat")
+	cmd.Stdin = strings.NewReader("some input")
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "some input" {
+		t.Errorf("output: got %q", buf.String())
+	}
+}
+
+func TestPipes(t *testing.T) {
+	cmd := exec.Command("cat")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(stdin, "through a pipe")
+	stdin.Close()
+	out, err := io.ReadAll(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "through a pipe" {
+		t.Errorf("output: got %q", out)
+	}
+}
+
+func TestLookPathNotFound(t *testing.T) {
+	_, err := exec.LookPath("tinygo-nonexistent-command")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if ee, ok := err.(*exec.Error); !ok || ee.Err != exec.ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+//go:build linux && !baremetal && !wasi
+// +build linux,!baremetal,!wasi
+
+// Portions copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package os
+
+import (
+	"errors"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func startProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
+	// Convert everything the child needs to C strings before forking: the
+	// child may only do raw system calls, it must not allocate.
+	argv0p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	argvp, err := syscall.SlicePtrFromStrings(argv)
+	if err != nil {
+		return nil, err
+	}
+	env := attr.Env
+	if env == nil {
+		env = Environ()
+	}
+	envvp, err := syscall.SlicePtrFromStrings(env)
+	if err != nil {
+		return nil, err
+	}
+	var dirp *byte
+	if attr.Dir != "" {
+		dirp, err = syscall.BytePtrFromString(attr.Dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fds := make([]int, len(attr.Files))
+	for i, f := range attr.Files {
+		fds[i] = -1
+		if f != nil {
+			fds[i] = int(f.Fd())
+		}
+	}
+
+	// The child writes the errno of a failed exec to this pipe. It is closed
+	// automatically (close-on-exec) when the exec succeeds.
+	var p [2]int
+	if err := syscall.Pipe2(p[:], syscall.O_CLOEXEC); err != nil {
+		return nil, err
+	}
+
+	pid, errno := forkAndExecInChild(argv0p, argvp, envvp, dirp, fds, p[1])
+	syscall.Close(p[1])
+	if errno != 0 {
+		syscall.Close(p[0])
+		return nil, errno
+	}
+
+	var childErr uint32
+	buf := (*[4]byte)(unsafe.Pointer(&childErr))[:]
+	n := 0
+	for n < len(buf) {
+		nn, err := syscall.Read(p[0], buf[n:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || nn == 0 {
+			break
+		}
+		n += nn
+	}
+	syscall.Close(p[0])
+	if n == len(buf) {
+		// The exec failed. Reap the child, which exits immediately.
+		var status syscall.WaitStatus
+		for {
+			_, err := syscall.Wait4(pid, &status, 0, nil)
+			if err != syscall.EINTR {
+				break
+			}
+		}
+		return nil, syscall.Errno(childErr)
+	}
+
+	return &Process{Pid: pid}, nil
+}
+
+// forkAndExecInChild forks the current process and executes the given program
+// in the child. In the parent, it returns the pid of the child or the errno of
+// a failed fork.
+//
+// The code between the fork and the exec runs in the child. It must only use
+// raw system calls: the heap and scheduler state of the child are a copy of
+// the parent and must not be touched.
+//
+//go:noinline
+func forkAndExecInChild(argv0 *byte, argv, envv []*byte, dir *byte, fds []int, pipe int) (pid int, err syscall.Errno) {
+	// Declare all variables at top in case any declarations require heap
+	// allocation (and because of the goto below).
+	var (
+		r1     uintptr
+		err1   syscall.Errno
+		nextfd int
+		i      int
+		errnum uint32
+	)
+
+	r1, _, err1 = syscall.RawSyscall6(syscall.SYS_CLONE, uintptr(syscall.SIGCHLD), 0, 0, 0, 0, 0)
+	if err1 != 0 {
+		return 0, err1
+	}
+	if r1 != 0 {
+		// Parent.
+		return int(r1), 0
+	}
+
+	// Child.
+
+	// Pass 1: look for fds[i] < i and move those up above len(fds) so that
+	// pass 2 won't stomp on an fd it needs later.
+	nextfd = len(fds)
+	if pipe < nextfd {
+		_, _, err1 = syscall.RawSyscall(syscall.SYS_DUP3, uintptr(pipe), uintptr(nextfd), syscall.O_CLOEXEC)
+		if err1 != 0 {
+			goto childerror
+		}
+		pipe = nextfd
+		nextfd++
+	}
+	for i = 0; i < len(fds); i++ {
+		if fds[i] >= 0 && fds[i] < i {
+			if nextfd == pipe {
+				nextfd++
+			}
+			_, _, err1 = syscall.RawSyscall(syscall.SYS_DUP3, uintptr(fds[i]), uintptr(nextfd), syscall.O_CLOEXEC)
+			if err1 != 0 {
+				goto childerror
+			}
+			fds[i] = nextfd
+			nextfd++
+		}
+	}
+
+	// Pass 2: dup fds[i] down onto i.
+	for i = 0; i < len(fds); i++ {
+		if fds[i] == -1 {
+			syscall.RawSyscall(syscall.SYS_CLOSE, uintptr(i), 0, 0)
+			continue
+		}
+		if fds[i] == i {
+			// dup3 fails when both file descriptors are the same, so only
+			// clear the close-on-exec flag.
+			_, _, err1 = syscall.RawSyscall(syscall.SYS_FCNTL, uintptr(i), syscall.F_SETFD, 0)
+		} else {
+			// The new file descriptor does not have close-on-exec set.
+			_, _, err1 = syscall.RawSyscall(syscall.SYS_DUP3, uintptr(fds[i]), uintptr(i), 0)
+		}
+		if err1 != 0 {
+			goto childerror
+		}
+	}
+
+	if dir != nil {
+		_, _, err1 = syscall.RawSyscall(syscall.SYS_CHDIR, uintptr(unsafe.Pointer(dir)), 0, 0)
+		if err1 != 0 {
+			goto childerror
+		}
+	}
+
+	_, _, err1 = syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(argv0)),
+		uintptr(unsafe.Pointer(&argv[0])),
+		uintptr(unsafe.Pointer(&envv[0])))
+
+childerror:
+	// Send the error to the parent and exit.
+	errnum = uint32(err1)
+	syscall.RawSyscall(syscall.SYS_WRITE, uintptr(pipe), uintptr(unsafe.Pointer(&errnum)), 4)
+	for {
+		syscall.RawSyscall(syscall.SYS_EXIT, 253, 0, 0)
+	}
+}
+
+func (p *Process) wait() (*ProcessState, error) {
+	if p.Pid == -1 {
+		return nil, syscall.EINVAL
+	}
+	var (
+		status syscall.WaitStatus
+		rusage syscall.Rusage
+		delay  = 50 * time.Microsecond
+	)
+	for {
+		pid, err := syscall.Wait4(p.Pid, &status, syscall.WNOHANG, &rusage)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, NewSyscallError("wait", err)
+		}
+		if pid != 0 {
+			break
+		}
+		// The child is still running. Sleep instead of blocking in wait4, so
+		// that other goroutines (for example those copying the output of
+		// the child) can run in the meantime.
+		time.Sleep(delay)
+		if delay < 10*time.Millisecond {
+			delay *= 2
+		}
+	}
+	p.done = true
+	return &ProcessState{
+		pid:    p.Pid,
+		status: status,
+		rusage: &rusage,
+	}, nil
+}
+
+func (p *Process) signal(sig Signal) error {
+	if p.Pid == -1 {
+		return errors.New("os: process already released")
+	}
+	if p.Pid == 0 {
+		return errors.New("os: process not initialized")
+	}
+	if p.done {
+		return ErrProcessDone
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("os: unsupported signal type")
+	}
+	if err := syscall.Kill(p.Pid, s); err != nil {
+		if err == syscall.ESRCH {
+			return ErrProcessDone
+		}
+		return NewSyscallError("kill", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//go:build linux && !baremetal && !wasi
+// +build linux,!baremetal,!wasi
+
+package os_test
+
+import (
+	"io"
+	. "os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartProcess(t *testing.T) {
+	r, w, err := Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	dir := TempDir()
+	p, err := StartProcess("/bin/sh", []string{"sh", "-c", "pwd; echo $FOO; exit 3"}, &ProcAttr{
+		Dir:   dir,
+		Env:   []string{"FOO=bar"},
+		Files: []*File{nil, w, Stderr},
+	})
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), dir+"\nbar\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+
+	state, err := p.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Exited() || state.Success() {
+		t.Errorf("unexpected state: %s", state)
+	}
+	if code := state.ExitCode(); code != 3 {
+		t.Errorf("exit code: got %d, want 3", code)
+	}
+	if s := state.String(); s != "exit status 3" {
+		t.Errorf("state string: got %q", s)
+	}
+	if pid := state.Pid(); pid != p.Pid {
+		t.Errorf("state pid: got %d, want %d", pid, p.Pid)
+	}
+}
+
+func TestStartProcessNotFound(t *testing.T) {
+	_, err := StartProcess("/nonexistent/program", []string{"program"}, &ProcAttr{})
+	if !IsNotExist(err) {
+		t.Fatalf("expected a not-exist error, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "fork/exec ") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+func TestProcessKill(t *testing.T) {
+	p, err := StartProcess("/bin/sh", []string{"sh", "-c", "sleep 10"}, &ProcAttr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	state, err := p.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := state.Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		t.Errorf("unexpected state: %s", state)
+	}
+	if code := state.ExitCode(); code != -1 {
+		t.Errorf("exit code: got %d, want -1", code)
+	}
+	if err := p.Signal(Interrupt); err != ErrProcessDone {
+		t.Errorf("signal after wait: got %v, want %v", err, ErrProcessDone)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux || baremetal || wasi
+// +build !linux baremetal wasi
+
+package os
+
+func startProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *Process) wait() (*ProcessState, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *Process) signal(sig Signal) error {
+	return ErrNotImplemented
+}
@@ -52,6 +52,33 @@ func fixCount(n int, err error) (int, error) {
 	return n, err
 }
 
+// Rename renames (moves) oldpath to newpath.
+// If newpath already exists and is not a directory, Rename replaces it.
+// OS-specific restrictions may apply when oldpath and newpath are in different directories.
+// If there is an error, it will be of type *LinkError.
+func Rename(oldpath, newpath string) error {
+	oldmount, oldsuffix := findMountPoint(oldpath)
+	newmount, newsuffix := findMountPoint(newpath)
+	if oldmount == nil || newmount == nil {
+		return &LinkError{"rename", oldpath, newpath, ErrNotExist}
+	}
+	if oldmount != newmount {
+		return &LinkError{"rename", oldpath, newpath, errCrossMount}
+	}
+	if isOSMount(oldmount) {
+		return rename(oldpath, newpath)
+	}
+	fs, ok := oldmount.filesystem.(RenameFS)
+	if !ok {
+		return &LinkError{"rename", oldpath, newpath, ErrNotImplemented}
+	}
+	err := fs.Rename(oldsuffix, newsuffix)
+	if err != nil {
+		return &LinkError{"rename", oldpath, newpath, err}
+	}
+	return nil
+}
+
 // Remove removes a file or (empty) directory. If the operation fails, it will
 // return an error of type *PathError.
 func Remove(path string) error {
@@ -78,11 +105,26 @@ func OpenFile(name string, flag int, perm FileMode) (*File, error) {
 	if fs == nil {
 		return nil, &PathError{"open", name, ErrNotExist}
 	}
-	handle, err := fs.OpenFile(suffix, flag, perm)
-	if err != nil {
-		return nil, &PathError{"open", name, err}
+	var f *File
+	if hfs, ok := fs.(OpenHandleFS); ok {
+		handle, err := hfs.OpenHandle(suffix, flag, perm)
+		if err != nil {
+			return nil, &PathError{"open", name, err}
+		}
+		f = &File{&file{handle: handle, name: name}}
+	} else {
+		handle, err := fs.OpenFile(suffix, flag, perm)
+		if err != nil {
+			return nil, &PathError{"open", name, err}
+		}
+		f = NewFile(handle, name)
 	}
-	return NewFile(handle, name), nil
+	if dirfs, ok := fs.(ReadDirFS); ok {
+		// Remember where the file came from, in case it is a directory that
+		// will be read.
+		f.mountdir = &mountDirInfo{fs: dirfs, name: suffix}
+	}
+	return f, nil
 }
 
 // Open opens the file named for reading.
@@ -118,6 +160,7 @@ func (f *File) ReadAt(b []byte, offset int64) (n int, err error) {
 
 	for len(b) > 0 {
 		m, e := f.handle.ReadAt(b, offset)
+		n += m
 		if e != nil {
 			// TODO: want to always wrap, like upstream, but TestReadAtEOF compares against exactly io.EOF?
 			if e != io.EOF {
@@ -127,7 +170,6 @@ func (f *File) ReadAt(b []byte, offset int64) (n int, err error) {
 			}
 			break
 		}
-		n += m
 		b = b[m:]
 		offset += int64(m)
 	}
@@ -196,9 +238,67 @@ func (f *File) Fd() uintptr {
 	return 0
 }
 
-// Truncate is a stub, not yet implemented
+// Stat returns the FileInfo structure describing file.
+// If there is an error, it will be of type *PathError.
+func (f *File) Stat() (FileInfo, error) {
+	if f == nil {
+		return nil, ErrInvalid
+	}
+	if handle, ok := f.handle.(StatFileHandle); ok {
+		info, err := handle.Stat()
+		if err != nil {
+			return nil, &PathError{"stat", f.name, err}
+		}
+		return info, nil
+	}
+	return f.stat()
+}
+
+// Sync commits the current contents of the file to stable storage. It is only
+// implemented for files of mounted filesystems that support it.
+func (f *File) Sync() error {
+	if f == nil {
+		return ErrInvalid
+	}
+	handle, ok := f.handle.(SyncFileHandle)
+	if !ok {
+		return ErrNotImplemented
+	}
+	if err := handle.Sync(); err != nil {
+		return &PathError{"sync", f.name, err}
+	}
+	return nil
+}
+
+// Truncate changes the size of the file. It does not change the I/O offset.
+// If there is an error, it will be of type *PathError. It is only implemented
+// for files of mounted filesystems that support it.
 func (f *File) Truncate(size int64) error {
-	return &PathError{"truncate", f.name, ErrNotImplemented}
+	if f == nil {
+		return ErrInvalid
+	}
+	handle, ok := f.handle.(TruncateFileHandle)
+	if !ok {
+		return &PathError{"truncate", f.name, ErrNotImplemented}
+	}
+	if err := handle.Truncate(size); err != nil {
+		return &PathError{"truncate", f.name, err}
+	}
+	return nil
+}
+
+// Truncate changes the size of the named file.
+// If there is an error, it will be of type *PathError.
+func Truncate(name string, size int64) error {
+	f, err := OpenFile(name, O_WRONLY, 0)
+	if err != nil {
+		return &PathError{"truncate", name, err.(*PathError).Err}
+	}
+	err = f.Truncate(size)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
 }
 
 // LinkError records an error during a link or symlink or rename system call and
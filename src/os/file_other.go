@@ -30,12 +30,13 @@ type stdioFileHandle uint8
 // can overwrite this data, which could cause the finalizer
 // to close the wrong file descriptor.
 type file struct {
-	handle FileHandle
-	name   string
+	handle   FileHandle
+	name     string
+	mountdir *mountDirInfo // nil unless opened on a mounted ReadDirFS
 }
 
 func NewFile(fd uintptr, name string) *File {
-	return &File{&file{stdioFileHandle(fd), name}}
+	return &File{&file{handle: stdioFileHandle(fd), name: name}}
 }
 
 // Read reads up to len(b) bytes from machine.Serial.
@@ -112,6 +113,10 @@ func Readlink(name string) (string, error) {
 	return "", ErrNotImplemented
 }
 
+func rename(oldname, newname string) error {
+	return &LinkError{"rename", oldname, newname, ErrNotImplemented}
+}
+
 func tempDir() string {
 	return "/tmp"
 }
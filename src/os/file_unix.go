@@ -38,13 +38,14 @@ func rename(oldname, newname string) error {
 // can overwrite this data, which could cause the finalizer
 // to close the wrong file descriptor.
 type file struct {
-	handle  FileHandle
-	name    string
-	dirinfo *dirInfo // nil unless directory being read
+	handle   FileHandle
+	name     string
+	dirinfo  *dirInfo      // nil unless directory being read
+	mountdir *mountDirInfo // nil unless opened on a mounted ReadDirFS
 }
 
 func NewFile(fd uintptr, name string) *File {
-	return &File{&file{unixFileHandle(fd), name, nil}}
+	return &File{&file{handle: unixFileHandle(fd), name: name}}
 }
 
 func Pipe() (r *File, w *File, err error) {
@@ -36,12 +36,13 @@ func rename(oldname, newname string) error {
 }
 
 type file struct {
-	handle FileHandle
-	name   string
+	handle   FileHandle
+	name     string
+	mountdir *mountDirInfo // nil unless opened on a mounted ReadDirFS
 }
 
 func NewFile(fd uintptr, name string) *File {
-	return &File{&file{unixFileHandle(fd), name}}
+	return &File{&file{handle: unixFileHandle(fd), name: name}}
 }
 
 func Pipe() (r *File, w *File, err error) {
@@ -1,6 +1,7 @@
 package os
 
 import (
+	"errors"
 	"strings"
 )
 
@@ -59,20 +60,130 @@ type FileHandle interface {
 	Close() (err error)
 }
 
+// The following interfaces may be implemented by a Filesystem to support more
+// operations. The os package checks for them when an operation is done on a
+// path within a mounted filesystem, and returns ErrNotImplemented if the
+// filesystem doesn't implement the needed interface. Like with Filesystem,
+// paths passed to these methods are relative to the mount point and errors
+// should not be wrapped in a *PathError.
+//
+// WARNING: these interfaces are not finalized and may change in a future
+// version.
+
+// OpenHandleFS is a Filesystem that returns its own FileHandle for an open
+// file, instead of a file descriptor. If a filesystem implements it, its
+// OpenHandle method is used instead of OpenFile. The returned FileHandle may
+// implement the optional FileHandle interfaces below. Directories must also be
+// opened by this method, so that they can be read if the filesystem implements
+// ReadDirFS.
+type OpenHandleFS interface {
+	Filesystem
+
+	// OpenHandle opens the named file or directory.
+	OpenHandle(name string, flag int, perm FileMode) (FileHandle, error)
+}
+
+// StatFS is a Filesystem that can describe files by name. It is used by Stat
+// and Lstat.
+type StatFS interface {
+	Filesystem
+
+	// Stat returns a FileInfo describing the named file or directory.
+	Stat(name string) (FileInfo, error)
+}
+
+// ReadDirFS is a Filesystem that can list directories. It is used by ReadDir
+// and by the Readdir, Readdirnames and ReadDir methods of a File.
+type ReadDirFS interface {
+	Filesystem
+
+	// ReadDir reads the named directory and returns all its entries sorted
+	// by filename.
+	ReadDir(name string) ([]DirEntry, error)
+}
+
+// RenameFS is a Filesystem that can rename files and directories. It is used
+// by Rename when both paths are within the same mounted filesystem.
+type RenameFS interface {
+	Filesystem
+
+	// Rename renames (moves) oldname to newname, replacing newname if it
+	// already exists and is not a directory.
+	Rename(oldname, newname string) error
+}
+
+// The following interfaces may be implemented by a FileHandle to support more
+// operations on open files.
+//
+// WARNING: these interfaces are not finalized and may change in a future
+// version.
+
+// StatFileHandle is a FileHandle that can describe the open file. It is used
+// by File.Stat.
+type StatFileHandle interface {
+	FileHandle
+
+	// Stat returns a FileInfo describing the file.
+	Stat() (FileInfo, error)
+}
+
+// SyncFileHandle is a FileHandle that can flush its contents to stable
+// storage. It is used by File.Sync.
+type SyncFileHandle interface {
+	FileHandle
+
+	// Sync commits the current contents of the file to stable storage.
+	Sync() error
+}
+
+// TruncateFileHandle is a FileHandle that can change the size of the file. It
+// is used by File.Truncate and Truncate.
+type TruncateFileHandle interface {
+	FileHandle
+
+	// Truncate changes the size of the file. It does not change the I/O
+	// offset.
+	Truncate(size int64) error
+}
+
+// errCrossMount is returned by Rename when the two paths are on different
+// mounted filesystems.
+var errCrossMount = errors.New("invalid cross-device link")
+
 // findMount returns the appropriate (mounted) filesystem to use for a given
 // filename plus the path relative to that filesystem.
 func findMount(path string) (Filesystem, string) {
+	mount, suffix := findMountPoint(path)
+	if mount == nil {
+		return nil, suffix
+	}
+	return mount.filesystem, suffix
+}
+
+// isOSMount returns whether the given mount point is the filesystem of the
+// operating system, which is always the first mount point if there is one.
+func isOSMount(mount *mountPoint) bool {
+	return isOS && mount == &mounts[0]
+}
+
+// findMountPoint is like findMount, but returns the mount point itself. This
+// makes it possible to check whether two paths are on the same mount point.
+func findMountPoint(path string) (*mountPoint, string) {
 	for i := len(mounts) - 1; i >= 0; i-- {
-		mount := mounts[i]
+		mount := &mounts[i]
 		if strings.HasPrefix(path, mount.prefix) {
-			return mount.filesystem, path[len(mount.prefix)-1:]
+			return mount, path[len(mount.prefix)-1:]
+		}
+		if path == mount.prefix[:len(mount.prefix)-1] && path != "" {
+			// The mount point itself, without trailing slash.
+			return mount, "/"
 		}
 	}
 	if isOS {
 		// Assume that the first entry in the mounts slice is the OS filesystem
 		// at the root of the directory tree. Use it as-is, to support relative
 		// paths.
-		return mounts[0].filesystem, path
+		return &mounts[0], path
 	}
 	return nil, path
 }
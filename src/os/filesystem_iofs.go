@@ -0,0 +1,148 @@
+package os
+
+import (
+	"io"
+	"io/fs"
+	"path"
+)
+
+// NewReadOnlyFS returns a read-only filesystem with the contents of fsys, that
+// can be mounted using Mount. This makes it possible to access files embedded
+// in the program using the os package, for example configuration files that
+// are read by code shared with regular Go programs:
+//
+//	//go:embed etc
+//	var etc embed.FS
+//
+//	func init() {
+//		sub, _ := fs.Sub(etc, "etc")
+//		os.Mount("/etc/", os.NewReadOnlyFS(sub))
+//	}
+//
+// Any attempt to modify the filesystem fails with ErrPermission.
+func NewReadOnlyFS(fsys fs.FS) Filesystem {
+	return &readOnlyFS{fsys}
+}
+
+// readOnlyFS is the filesystem returned by NewReadOnlyFS. It implements
+// OpenHandleFS, StatFS, ReadDirFS and RenameFS (to report ErrPermission).
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+// fsName converts a path relative to the mount point to a path as used by
+// io/fs: without leading slash, and "." for the root directory.
+func fsName(name string) string {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return "."
+	}
+	return name[1:]
+}
+
+// unwrapPathError returns the underlying error of a *PathError, as the os
+// package already wraps errors of mounted filesystems.
+func unwrapPathError(err error) error {
+	if e, ok := err.(*PathError); ok {
+		return e.Err
+	}
+	return err
+}
+
+// OpenFile is not used, files are opened with OpenHandle.
+func (ro *readOnlyFS) OpenFile(name string, flag int, perm FileMode) (uintptr, error) {
+	return 0, ErrNotImplemented
+}
+
+func (ro *readOnlyFS) OpenHandle(name string, flag int, perm FileMode) (FileHandle, error) {
+	if flag&(O_WRONLY|O_RDWR|O_APPEND|O_CREATE|O_TRUNC) != 0 {
+		return nil, ErrPermission
+	}
+	f, err := ro.fsys.Open(fsName(name))
+	if err != nil {
+		return nil, unwrapPathError(err)
+	}
+	return &readOnlyFileHandle{file: f}, nil
+}
+
+func (ro *readOnlyFS) Mkdir(name string, perm FileMode) error {
+	return ErrPermission
+}
+
+func (ro *readOnlyFS) Remove(name string) error {
+	return ErrPermission
+}
+
+func (ro *readOnlyFS) Rename(oldname, newname string) error {
+	return ErrPermission
+}
+
+func (ro *readOnlyFS) Stat(name string) (FileInfo, error) {
+	info, err := fs.Stat(ro.fsys, fsName(name))
+	if err != nil {
+		return nil, unwrapPathError(err)
+	}
+	return info, nil
+}
+
+func (ro *readOnlyFS) ReadDir(name string) ([]DirEntry, error) {
+	entries, err := fs.ReadDir(ro.fsys, fsName(name))
+	if err != nil {
+		return nil, unwrapPathError(err)
+	}
+	return entries, nil
+}
+
+// readOnlyFileHandle is an open file of a readOnlyFS.
+type readOnlyFileHandle struct {
+	file fs.File
+}
+
+func (f *readOnlyFileHandle) Read(b []byte) (n int, err error) {
+	n, err = f.file.Read(b)
+	if err != io.EOF {
+		err = unwrapPathError(err)
+	}
+	return
+}
+
+func (f *readOnlyFileHandle) ReadAt(b []byte, offset int64) (n int, err error) {
+	r, ok := f.file.(io.ReaderAt)
+	if !ok {
+		return 0, ErrNotImplemented
+	}
+	n, err = r.ReadAt(b, offset)
+	if err != io.EOF {
+		err = unwrapPathError(err)
+	}
+	return
+}
+
+func (f *readOnlyFileHandle) Seek(offset int64, whence int) (newoffset int64, err error) {
+	s, ok := f.file.(io.Seeker)
+	if !ok {
+		return 0, ErrNotImplemented
+	}
+	newoffset, err = s.Seek(offset, whence)
+	return newoffset, unwrapPathError(err)
+}
+
+func (f *readOnlyFileHandle) Write(b []byte) (n int, err error) {
+	return 0, ErrPermission
+}
+
+func (f *readOnlyFileHandle) Truncate(size int64) error {
+	return ErrPermission
+}
+
+func (f *readOnlyFileHandle) Close() error {
+	return unwrapPathError(f.file.Close())
+}
+
+func (f *readOnlyFileHandle) Stat() (FileInfo, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil, unwrapPathError(err)
+	}
+	return info, nil
+}
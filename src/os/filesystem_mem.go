@@ -0,0 +1,398 @@
+package os
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors returned by the built-in filesystems.
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// NewMemFS returns a new, empty filesystem that keeps all its files and
+// directories in RAM. It can be mounted using Mount, for example to provide a
+// writable /tmp directory on systems without a filesystem:
+//
+//	os.Mount("/tmp/", os.NewMemFS())
+//
+// The contents of the filesystem are lost when the program exits.
+func NewMemFS() Filesystem {
+	return &memFS{
+		root: &memNode{
+			name:     "/",
+			mode:     ModeDir | 0777,
+			modTime:  time.Now(),
+			children: make(map[string]*memNode),
+		},
+	}
+}
+
+// memFS is the filesystem returned by NewMemFS. It implements OpenHandleFS,
+// StatFS, ReadDirFS and RenameFS.
+type memFS struct {
+	root *memNode
+}
+
+// memNode is a file or directory in a memFS. Directories have a non-nil
+// children map.
+type memNode struct {
+	name     string
+	mode     FileMode
+	modTime  time.Time
+	data     []byte
+	children map[string]*memNode
+}
+
+// info returns a snapshot of the metadata of this node.
+func (n *memNode) info() FileInfo {
+	return &memFileInfo{
+		name:    n.name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+// lookup returns the node for the given path, which is relative to the mount
+// point.
+func (m *memFS) lookup(name string) (*memNode, error) {
+	name = path.Clean("/" + name)
+	node := m.root
+	if name == "/" {
+		return node, nil
+	}
+	for _, part := range strings.Split(name[1:], "/") {
+		if node.children == nil {
+			return nil, errNotDir
+		}
+		child, ok := node.children[part]
+		if !ok {
+			return nil, ErrNotExist
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// lookupParent returns the directory that contains the given path, and the
+// base name of the path.
+func (m *memFS) lookupParent(name string) (*memNode, string, error) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return nil, "", ErrInvalid
+	}
+	dir, base := path.Split(name)
+	parent, err := m.lookup(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if parent.children == nil {
+		return nil, "", errNotDir
+	}
+	return parent, base, nil
+}
+
+// OpenFile is not used, files are opened with OpenHandle.
+func (m *memFS) OpenFile(name string, flag int, perm FileMode) (uintptr, error) {
+	return 0, ErrNotImplemented
+}
+
+func (m *memFS) OpenHandle(name string, flag int, perm FileMode) (FileHandle, error) {
+	parent, base, err := m.lookupParent(name)
+	if err == ErrInvalid {
+		// The root directory.
+		return &memFileHandle{node: m.root, flag: flag}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	node, ok := parent.children[base]
+	if ok && flag&(O_CREATE|O_EXCL) == O_CREATE|O_EXCL {
+		return nil, ErrExist
+	}
+	if !ok {
+		if flag&O_CREATE == 0 {
+			return nil, ErrNotExist
+		}
+		node = &memNode{
+			name:    base,
+			mode:    perm & ModePerm,
+			modTime: time.Now(),
+		}
+		parent.children[base] = node
+		parent.modTime = node.modTime
+	}
+	writable := flag&(O_WRONLY|O_RDWR) != 0
+	if node.children != nil && writable {
+		return nil, errIsDir
+	}
+	if flag&O_TRUNC != 0 && writable {
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	return &memFileHandle{node: node, flag: flag}, nil
+}
+
+func (m *memFS) Mkdir(name string, perm FileMode) error {
+	parent, base, err := m.lookupParent(name)
+	if err == ErrInvalid {
+		return ErrExist // the root directory
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := parent.children[base]; ok {
+		return ErrExist
+	}
+	node := &memNode{
+		name:     base,
+		mode:     ModeDir | perm&ModePerm,
+		modTime:  time.Now(),
+		children: make(map[string]*memNode),
+	}
+	parent.children[base] = node
+	parent.modTime = node.modTime
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	parent, base, err := m.lookupParent(name)
+	if err != nil {
+		return err
+	}
+	node, ok := parent.children[base]
+	if !ok {
+		return ErrNotExist
+	}
+	if len(node.children) != 0 {
+		return errNotEmpty
+	}
+	delete(parent.children, base)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (m *memFS) Stat(name string) (FileInfo, error) {
+	node, err := m.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(), nil
+}
+
+func (m *memFS) ReadDir(name string) ([]DirEntry, error) {
+	node, err := m.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if node.children == nil {
+		return nil, errNotDir
+	}
+	entries := make([]DirEntry, 0, len(node.children))
+	for _, child := range node.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *memFS) Rename(oldname, newname string) error {
+	oldparent, oldbase, err := m.lookupParent(oldname)
+	if err != nil {
+		return err
+	}
+	node, ok := oldparent.children[oldbase]
+	if !ok {
+		return ErrNotExist
+	}
+	newparent, newbase, err := m.lookupParent(newname)
+	if err != nil {
+		return err
+	}
+	if node.children != nil && strings.HasPrefix(path.Clean("/"+newname), path.Clean("/"+oldname)+"/") {
+		// Refuse to move a directory into itself.
+		return ErrInvalid
+	}
+	if target, ok := newparent.children[newbase]; ok {
+		if target == node {
+			return nil
+		}
+		if target.children != nil {
+			if node.children == nil {
+				return errIsDir
+			}
+			if len(target.children) != 0 {
+				return errNotEmpty
+			}
+		} else if node.children != nil {
+			return errNotDir
+		}
+	}
+	delete(oldparent.children, oldbase)
+	node.name = newbase
+	newparent.children[newbase] = node
+	now := time.Now()
+	oldparent.modTime = now
+	newparent.modTime = now
+	return nil
+}
+
+// memFileHandle is an open file (or directory) of a memFS.
+type memFileHandle struct {
+	node   *memNode
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *memFileHandle) Read(b []byte) (n int, err error) {
+	n, err = f.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	if n != 0 && err == io.EOF {
+		// Unlike ReadAt, Read reports the end of the file on the next call.
+		err = nil
+	}
+	return
+}
+
+func (f *memFileHandle) ReadAt(b []byte, offset int64) (n int, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if f.node.children != nil {
+		return 0, errIsDir
+	}
+	if f.flag&O_WRONLY != 0 {
+		return 0, ErrPermission
+	}
+	if offset < 0 {
+		return 0, ErrInvalid
+	}
+	if offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.node.data[offset:])
+	if n < len(b) {
+		// Required by io.ReaderAt.
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFileHandle) Seek(offset int64, whence int) (newoffset int64, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	default:
+		return 0, ErrInvalid
+	}
+	if offset < 0 {
+		return 0, ErrInvalid
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFileHandle) Write(b []byte) (n int, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if f.flag&(O_WRONLY|O_RDWR) == 0 {
+		return 0, ErrPermission
+	}
+	if f.flag&O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.node.data)) {
+		f.node.resize(end)
+	}
+	n = copy(f.node.data[f.offset:], b)
+	f.offset += int64(n)
+	f.node.modTime = time.Now()
+	return n, nil
+}
+
+func (f *memFileHandle) Close() error {
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFileHandle) Stat() (FileInfo, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	return f.node.info(), nil
+}
+
+// Sync does nothing, as the data is never stored anywhere else.
+func (f *memFileHandle) Sync() error {
+	if f.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (f *memFileHandle) Truncate(size int64) error {
+	if f.closed {
+		return ErrClosed
+	}
+	if f.flag&(O_WRONLY|O_RDWR) == 0 {
+		return ErrPermission
+	}
+	if size < 0 {
+		return ErrInvalid
+	}
+	f.node.resize(size)
+	f.node.modTime = time.Now()
+	return nil
+}
+
+// resize changes the size of the file data, filling new space with zeroes.
+func (n *memNode) resize(size int64) {
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size]
+		return
+	}
+	if size <= int64(cap(n.data)) {
+		old := len(n.data)
+		n.data = n.data[:size]
+		for i := old; i < len(n.data); i++ {
+			n.data[i] = 0
+		}
+		return
+	}
+	n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+}
+
+// memFileInfo implements FileInfo for the built-in filesystems.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    FileMode
+	modTime time.Time
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() FileMode     { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
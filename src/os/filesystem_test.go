@@ -0,0 +1,489 @@
+//go:build !baremetal && !js && !windows
+// +build !baremetal,!js,!windows
+
+package os_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	. "os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+// fdFS is a filesystem that only implements the Filesystem interface and
+// returns file descriptors of the operating system, like drivers written
+// before OpenHandleFS was added.
+type fdFS struct {
+	dir string
+}
+
+func (d fdFS) OpenFile(name string, flag int, perm FileMode) (uintptr, error) {
+	fd, err := syscall.Open(filepath.Join(d.dir, name), flag, uint32(perm))
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(fd), nil
+}
+
+func (d fdFS) Mkdir(name string, perm FileMode) error {
+	return ErrNotImplemented
+}
+
+func (d fdFS) Remove(name string) error {
+	return ErrNotImplemented
+}
+
+// readerFS is a filesystem with a single read-only file named "file", that
+// is read using a bytes.Reader.
+type readerFS struct {
+	data string
+}
+
+func (r readerFS) OpenFile(name string, flag int, perm FileMode) (uintptr, error) {
+	return 0, ErrNotImplemented
+}
+
+func (r readerFS) OpenHandle(name string, flag int, perm FileMode) (FileHandle, error) {
+	if name != "/file" {
+		return nil, ErrNotExist
+	}
+	return readerHandle{bytes.NewReader([]byte(r.data))}, nil
+}
+
+func (r readerFS) Mkdir(name string, perm FileMode) error {
+	return ErrPermission
+}
+
+func (r readerFS) Remove(name string) error {
+	return ErrPermission
+}
+
+type readerHandle struct {
+	*bytes.Reader
+}
+
+func (h readerHandle) Write(b []byte) (int, error) {
+	return 0, ErrPermission
+}
+
+func (h readerHandle) Close() error {
+	return nil
+}
+
+var mountCount int
+
+// mountTestFS mounts the given filesystem at a new prefix and returns the mount
+// point. Filesystems cannot be unmounted, so every test uses its own prefix.
+func mountTestFS(fsys Filesystem) string {
+	mountCount++
+	prefix := "/tinygo-test-mount-" + strconv.Itoa(mountCount) + "/"
+	Mount(prefix, fsys)
+	return strings.TrimSuffix(prefix, "/")
+}
+
+// mountMemFS mounts a fresh in-memory filesystem and returns the mount point.
+func mountMemFS() string {
+	return mountTestFS(NewMemFS())
+}
+
+func TestMountFileDescriptors(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	root := mountTestFS(fdFS{dir})
+	data, err := ReadFile(root + "/file")
+	if err != nil || string(data) != "data" {
+		t.Errorf("ReadFile: got %q, %v", data, err)
+	}
+	if _, err := Open(root + "/missing"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Open missing file: expected ErrNotExist, got %v", err)
+	}
+
+	// Optional operations are not available.
+	if _, err := Stat(root + "/file"); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Stat: expected ErrNotImplemented, got %v", err)
+	}
+	if err := Rename(root+"/file", root+"/other"); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Rename: expected ErrNotImplemented, got %v", err)
+	}
+}
+
+func TestMountReadWrite(t *testing.T) {
+	root := mountMemFS()
+	name := root + "/hello.txt"
+	if err := WriteFile(name, []byte("hello, world"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	data, err := ReadFile(name)
+	if err != nil {
+		t.Fatal("ReadFile:", err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("ReadFile: got %q", data)
+	}
+	if _, err := Open(root + "/missing"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Open missing file: expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestMountStat(t *testing.T) {
+	root := mountMemFS()
+	if err := Mkdir(root+"/dir", 0755); err != nil {
+		t.Fatal("Mkdir:", err)
+	}
+	if err := WriteFile(root+"/dir/file", []byte("12345"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	info, err := Stat(root + "/dir")
+	if err != nil {
+		t.Fatal("Stat:", err)
+	}
+	if !info.IsDir() || info.Name() != "dir" {
+		t.Errorf("Stat dir: got name %q, IsDir %v", info.Name(), info.IsDir())
+	}
+	info, err = Lstat(root + "/dir/file")
+	if err != nil {
+		t.Fatal("Lstat:", err)
+	}
+	if info.IsDir() || info.Size() != 5 || info.Mode().Perm() != 0644 {
+		t.Errorf("Lstat file: got size %d, mode %v", info.Size(), info.Mode())
+	}
+
+	_, err = Stat(root + "/missing")
+	if perr, ok := err.(*PathError); !ok || perr.Op != "stat" || !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat missing file: expected *PathError wrapping ErrNotExist, got %#v", err)
+	}
+
+	f, err := Open(root + "/dir/file")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+	info, err = f.Stat()
+	if err != nil {
+		t.Fatal("File.Stat:", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("File.Stat: got size %d", info.Size())
+	}
+}
+
+func TestMountReadDir(t *testing.T) {
+	root := mountMemFS()
+	for _, name := range []string{"c", "a", "b"} {
+		if err := WriteFile(root+"/"+name, nil, 0644); err != nil {
+			t.Fatal("WriteFile:", err)
+		}
+	}
+	if err := Mkdir(root+"/d", 0755); err != nil {
+		t.Fatal("Mkdir:", err)
+	}
+
+	entries, err := ReadDir(root)
+	if err != nil {
+		t.Fatal("ReadDir:", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, ","); got != "a,b,c,d" {
+		t.Errorf("ReadDir: got %s", got)
+	}
+	if !entries[3].IsDir() {
+		t.Errorf("ReadDir: expected d to be a directory")
+	}
+
+	// Read the directory in chunks.
+	f, err := Open(root)
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+	names, err = f.Readdirnames(3)
+	if err != nil || len(names) != 3 {
+		t.Fatalf("Readdirnames(3): got %v, %v", names, err)
+	}
+	infos, err := f.Readdir(3)
+	if err != nil || len(infos) != 1 || infos[0].Name() != "d" {
+		t.Fatalf("Readdir(3): got %v, %v", infos, err)
+	}
+	if _, err := f.ReadDir(1); err != io.EOF {
+		t.Errorf("ReadDir(1) at end of directory: expected io.EOF, got %v", err)
+	}
+}
+
+func TestMountWalk(t *testing.T) {
+	root := mountMemFS()
+	for _, dir := range []string{"/a", "/a/b", "/c"} {
+		if err := Mkdir(root+dir, 0755); err != nil {
+			t.Fatal("Mkdir:", err)
+		}
+	}
+	for _, file := range []string{"/a/b/file1", "/c/file2", "/file3"} {
+		if err := WriteFile(root+file, []byte(file), 0644); err != nil {
+			t.Fatal("WriteFile:", err)
+		}
+	}
+	const expected = "/,/a,/a/b,/a/b/file1,/c,/c/file2,/file3"
+
+	var walked []string
+	err := filepath.Walk(root, func(p string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, "/"+strings.TrimPrefix(strings.TrimPrefix(p, root), "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Walk:", err)
+	}
+	if got := strings.Join(walked, ","); got != expected {
+		t.Errorf("Walk: got %s", got)
+	}
+
+	walked = nil
+	err = filepath.WalkDir(root, func(p string, d DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, "/"+strings.TrimPrefix(strings.TrimPrefix(p, root), "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal("WalkDir:", err)
+	}
+	if got := strings.Join(walked, ","); got != expected {
+		t.Errorf("WalkDir: got %s", got)
+	}
+}
+
+func TestMountRename(t *testing.T) {
+	root := mountMemFS()
+	if err := Mkdir(root+"/dir", 0755); err != nil {
+		t.Fatal("Mkdir:", err)
+	}
+	if err := WriteFile(root+"/dir/old", []byte("data"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	if err := Rename(root+"/dir", root+"/newdir"); err != nil {
+		t.Fatal("Rename dir:", err)
+	}
+	if err := Rename(root+"/newdir/old", root+"/new"); err != nil {
+		t.Fatal("Rename file:", err)
+	}
+	if _, err := Stat(root + "/dir"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat old dir: expected ErrNotExist, got %v", err)
+	}
+	data, err := ReadFile(root + "/new")
+	if err != nil || string(data) != "data" {
+		t.Errorf("ReadFile renamed file: got %q, %v", data, err)
+	}
+
+	err = Rename(root+"/missing", root+"/other")
+	if lerr, ok := err.(*LinkError); !ok || !errors.Is(lerr, ErrNotExist) {
+		t.Errorf("Rename missing file: expected *LinkError wrapping ErrNotExist, got %v", err)
+	}
+
+	// Renaming between different mount points is not possible.
+	other := mountMemFS()
+	if err := Rename(root+"/new", other+"/new"); err == nil {
+		t.Errorf("Rename across mount points: expected an error")
+	}
+}
+
+func TestMountTruncateSync(t *testing.T) {
+	root := mountMemFS()
+	name := root + "/file"
+	if err := WriteFile(name, []byte("0123456789"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	if err := Truncate(name, 4); err != nil {
+		t.Fatal("Truncate:", err)
+	}
+	if data, _ := ReadFile(name); string(data) != "0123" {
+		t.Errorf("after Truncate: got %q", data)
+	}
+
+	f, err := OpenFile(name, O_RDWR, 0)
+	if err != nil {
+		t.Fatal("OpenFile:", err)
+	}
+	defer f.Close()
+	if err := f.Truncate(6); err != nil {
+		t.Fatal("File.Truncate:", err)
+	}
+	if info, _ := f.Stat(); info.Size() != 6 {
+		t.Errorf("after File.Truncate: got size %d", info.Size())
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal("File.Sync:", err)
+	}
+
+	if err := Truncate(root+"/missing", 0); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Truncate missing file: expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestMountRemove(t *testing.T) {
+	root := mountMemFS()
+	if err := Mkdir(root+"/dir", 0755); err != nil {
+		t.Fatal("Mkdir:", err)
+	}
+	if err := WriteFile(root+"/dir/file", nil, 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	if err := Remove(root + "/dir"); err == nil {
+		t.Errorf("Remove non-empty directory: expected an error")
+	}
+	if err := Remove(root + "/dir/file"); err != nil {
+		t.Fatal("Remove file:", err)
+	}
+	if err := Remove(root + "/dir"); err != nil {
+		t.Fatal("Remove dir:", err)
+	}
+	if _, err := Stat(root + "/dir"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat removed dir: expected ErrNotExist, got %v", err)
+	}
+}
+
+// File.ReadAt must count the bytes that the FileHandle returned together with
+// an error, like io.EOF after a short read.
+func TestMountReadAtEOF(t *testing.T) {
+	root := mountTestFS(readerFS{"hello"})
+	f, err := Open(root + "/file")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+	buf := make([]byte, 10)
+	n, err := f.ReadAt(buf, 2)
+	if n != 3 || err != io.EOF || string(buf[:n]) != "llo" {
+		t.Errorf("ReadAt: got %d, %q, %v", n, buf[:n], err)
+	}
+}
+
+func TestNewMemFS(t *testing.T) {
+	root := mountTestFS(NewMemFS())
+	for _, dir := range []string{"/a", "/a/b", "/c"} {
+		if err := Mkdir(root+dir, 0755); err != nil {
+			t.Fatal("Mkdir:", err)
+		}
+	}
+	for _, file := range []string{"/a/b/file1", "/c/file2", "/file3"} {
+		if err := WriteFile(root+file, []byte("contents of "+file), 0644); err != nil {
+			t.Fatal("WriteFile:", err)
+		}
+	}
+	if err := fstest.TestFS(DirFS(root), "a/b/file1", "c/file2", "file3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modify the filesystem.
+	if err := Rename(root+"/c", root+"/a/c"); err != nil {
+		t.Fatal("Rename:", err)
+	}
+	if err := Rename(root+"/a", root+"/a/c/a"); err == nil {
+		t.Error("Rename directory into itself: expected an error")
+	}
+	if err := Truncate(root+"/file3", 3); err != nil {
+		t.Fatal("Truncate:", err)
+	}
+	if data, err := ReadFile(root + "/file3"); err != nil || string(data) != "con" {
+		t.Errorf("ReadFile after Truncate: got %q, %v", data, err)
+	}
+	if err := Remove(root + "/a/c"); err == nil {
+		t.Error("Remove non-empty directory: expected an error")
+	}
+	if err := Remove(root + "/a/c/file2"); err != nil {
+		t.Fatal("Remove:", err)
+	}
+	if err := fstest.TestFS(DirFS(root), "a/b/file1", "a/c", "file3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories can't be written to.
+	if _, err := OpenFile(root+"/a", O_WRONLY, 0); err == nil {
+		t.Error("OpenFile directory for writing: expected an error")
+	}
+	if _, err := OpenFile(root+"/file3", O_CREATE|O_EXCL, 0644); !errors.Is(err, ErrExist) {
+		t.Errorf("OpenFile with O_EXCL: expected ErrExist, got %v", err)
+	}
+}
+
+func TestNewMemFSReadAt(t *testing.T) {
+	root := mountTestFS(NewMemFS())
+	if err := WriteFile(root+"/file", []byte("hello"), 0644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	f, err := Open(root + "/file")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+
+	// A short read at the end of the file must return io.EOF.
+	buf := make([]byte, 4)
+	n, err := f.ReadAt(buf, 2)
+	if n != 3 || err != io.EOF || string(buf[:n]) != "llo" {
+		t.Errorf("ReadAt at end of file: got %d, %q, %v", n, buf[:n], err)
+	}
+	n, err = f.ReadAt(buf, 1)
+	if n != 4 || err != nil || string(buf) != "ello" {
+		t.Errorf("ReadAt: got %d, %q, %v", n, buf[:n], err)
+	}
+
+	// Read returns io.EOF only once there is no more data.
+	n, err = f.Read(make([]byte, 10))
+	if n != 5 || err != nil {
+		t.Errorf("Read: got %d, %v", n, err)
+	}
+	if n, err = f.Read(buf); n != 0 || err != io.EOF {
+		t.Errorf("Read at end of file: got %d, %v", n, err)
+	}
+}
+
+func TestNewReadOnlyFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.txt":    {Data: []byte("key=value\n")},
+		"dir/other.txt": {Data: []byte("other")},
+	}
+	root := mountTestFS(NewReadOnlyFS(fsys))
+
+	data, err := ReadFile(root + "/config.txt")
+	if err != nil || string(data) != "key=value\n" {
+		t.Errorf("ReadFile: got %q, %v", data, err)
+	}
+	entries, err := ReadDir(root)
+	if err != nil || len(entries) != 2 || entries[0].Name() != "config.txt" || !entries[1].IsDir() {
+		t.Errorf("ReadDir: got %v, %v", entries, err)
+	}
+	if _, err := Stat(root + "/missing"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat missing file: expected ErrNotExist, got %v", err)
+	}
+	if err := fstest.TestFS(DirFS(root), "config.txt", "dir/other.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Any modification must fail.
+	if err := WriteFile(root+"/config.txt", nil, 0644); !errors.Is(err, ErrPermission) {
+		t.Errorf("WriteFile: expected ErrPermission, got %v", err)
+	}
+	if err := Mkdir(root+"/newdir", 0755); !errors.Is(err, ErrPermission) {
+		t.Errorf("Mkdir: expected ErrPermission, got %v", err)
+	}
+	if err := Remove(root + "/config.txt"); !errors.Is(err, ErrPermission) {
+		t.Errorf("Remove: expected ErrPermission, got %v", err)
+	}
+	if err := Rename(root+"/config.txt", root+"/new.txt"); !errors.Is(err, ErrPermission) {
+		t.Errorf("Rename: expected ErrPermission, got %v", err)
+	}
+}
@@ -7,6 +7,9 @@ package os
 // Stat returns a FileInfo describing the named file.
 // If there is an error, it will be of type *PathError.
 func Stat(name string) (FileInfo, error) {
+	if info, ok, err := statMount("stat", name); ok {
+		return info, err
+	}
 	return statNolog(name)
 }
 
@@ -15,5 +18,26 @@ func Stat(name string) (FileInfo, error) {
 // describes the symbolic link. Lstat makes no attempt to follow the link.
 // If there is an error, it will be of type *PathError.
 func Lstat(name string) (FileInfo, error) {
+	if info, ok, err := statMount("lstat", name); ok {
+		return info, err
+	}
 	return lstatNolog(name)
 }
+
+// statMount stats the named file if it is on a mounted filesystem (other than
+// the filesystem of the operating system), in which case ok is true.
+func statMount(op, name string) (info FileInfo, ok bool, err error) {
+	mount, suffix := findMountPoint(name)
+	if mount == nil || isOSMount(mount) {
+		return nil, false, nil
+	}
+	fs, isStatFS := mount.filesystem.(StatFS)
+	if !isStatFS {
+		return nil, true, &PathError{op, name, ErrNotImplemented}
+	}
+	info, err = fs.Stat(suffix)
+	if err != nil {
+		return nil, true, &PathError{op, name, err}
+	}
+	return info, true, nil
+}
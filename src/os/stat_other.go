@@ -7,13 +7,8 @@
 
 package os
 
-// Sync is a stub, not yet implemented
-func (f *File) Sync() error {
-	return ErrNotImplemented
-}
-
-// Stat is a stub, not yet implemented
-func (f *File) Stat() (FileInfo, error) {
+// stat is a stub, not yet implemented
+func (f *File) stat() (FileInfo, error) {
 	return nil, ErrNotImplemented
 }
 
@@ -11,14 +11,9 @@ import (
 	"syscall"
 )
 
-// Sync is a stub, not yet implemented
-func (f *File) Sync() error {
-	return ErrNotImplemented
-}
-
-// Stat returns the FileInfo structure describing file.
-// If there is an error, it will be of type *PathError.
-func (f *File) Stat() (FileInfo, error) {
+// stat returns the FileInfo structure describing file, when it is a file of
+// the operating system.
+func (f *File) stat() (FileInfo, error) {
 	var fs fileStat
 	err := ignoringEINTR(func() error {
 		return syscall.Fstat(int(f.handle.(unixFileHandle)), &fs.sys)
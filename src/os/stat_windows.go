@@ -10,14 +10,9 @@ import (
 	"unsafe"
 )
 
-// Sync is a stub, not yet implemented
-func (f *File) Sync() error {
-	return ErrNotImplemented
-}
-
-// Stat returns the FileInfo structure describing file.
-// If there is an error, it will be of type *PathError.
-func (file *File) Stat() (FileInfo, error) {
+// stat returns the FileInfo structure describing file, when it is a file of
+// the operating system.
+func (file *File) stat() (FileInfo, error) {
 	if file == nil {
 		return nil, ErrInvalid
 	}
@@ -196,7 +196,7 @@ func (ch *channel) resumeRX(ok bool) unsafe.Pointer {
 	}
 
 	// push task onto runqueue
-	runqueue.Push(b.t)
+	runqueuePushBack(b.t)
 
 	return dst
 }
@@ -223,7 +223,7 @@ func (ch *channel) resumeTX() unsafe.Pointer {
 	}
 
 	// push task onto runqueue
-	runqueue.Push(b.t)
+	runqueuePushBack(b.t)
 
 	return src
 }
@@ -467,6 +467,8 @@ type chanSelectState struct {
 // This operation will block unless a value is immediately available.
 // May panic if the channel is closed.
 func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList) {
+	schedulerYieldPoint()
+
 	i := interrupt.Disable()
 
 	if ch.trySend(value) {
@@ -502,6 +504,8 @@ func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 // The recieved value is copied into the value pointer.
 // Returns the comma-ok value.
 func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList) bool {
+	schedulerYieldPoint()
+
 	i := interrupt.Disable()
 
 	if rx, ok := ch.tryRecv(value); rx {
@@ -537,6 +541,8 @@ func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 // chanClose closes the given channel. If this channel has a receiver or is
 // empty, it closes the channel. Else, it panics.
 func chanClose(ch *channel) {
+	schedulerYieldPoint()
+
 	if ch == nil {
 		// Not allowed by the language spec.
 		runtimePanic("close of nil channel")
@@ -575,6 +581,8 @@ func chanClose(ch *channel) {
 // TODO: do this in a round-robin fashion (as specified in the Go spec) instead
 // of picking the first one that can proceed.
 func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelBlockedList) (uintptr, bool) {
+	schedulerYieldPoint()
+
 	istate := interrupt.Disable()
 
 	if selected, ok := tryChanSelect(recvbuf, states); selected != ^uintptr(0) {
@@ -0,0 +1,31 @@
+//go:build !baremetal && !js && !nintendoswitch
+// +build !baremetal,!js,!nintendoswitch
+
+package runtime
+
+import "unsafe"
+
+// char *getenv(const char *name);
+//
+//export getenv
+func libc_getenv(name *byte) unsafe.Pointer
+
+// getenv returns the value of the environment variable with the given name, or
+// the empty string if it isn't set. It doesn't allocate memory, so it can be
+// used while initializing the runtime.
+func getenv(name string) string {
+	var buf [64]byte
+	if len(name) >= len(buf) {
+		return ""
+	}
+	copy(buf[:], name)
+	value := libc_getenv(&buf[0])
+	if value == nil {
+		return ""
+	}
+	s := _string{
+		ptr:    (*byte)(value),
+		length: strlen(value),
+	}
+	return *(*string)(unsafe.Pointer(&s))
+}
@@ -267,6 +267,8 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 		return unsafe.Pointer(&zeroSizedAlloc)
 	}
 
+	heapLock()
+
 	gcTotalAlloc += uint64(size)
 	gcMallocs++
 
@@ -351,6 +353,7 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			// Return a pointer to this allocation.
 			pointer := thisAlloc.pointer()
 			memzero(pointer, size)
+			heapUnlock()
 			return pointer
 		}
 	}
@@ -362,7 +365,9 @@ func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
 	}
 
 	ptrAddress := uintptr(ptr)
+	heapLock()
 	endOfTailAddress := blockFromAddr(ptrAddress).findNext().address()
+	heapUnlock()
 
 	// this might be a few bytes longer than the original size of
 	// ptr, because we align to full blocks of size bytesPerBlock
@@ -384,17 +389,23 @@ func free(ptr unsafe.Pointer) {
 
 // GC performs a garbage collection cycle.
 func GC() {
+	heapLock()
 	runGC()
+	heapUnlock()
 }
 
 // runGC performs a garbage colleciton cycle. It is the internal implementation
 // of the runtime.GC() function. The difference is that it returns the number of
 // free bytes in the heap after the GC is finished.
+// The heap must be locked by the caller.
 func runGC() (freeBytes uintptr) {
 	if gcDebug {
 		println("running collection cycle...")
 	}
 
+	// Make sure no other goroutine modifies the heap while it is scanned.
+	gcStopWorld()
+
 	// Mark phase: mark all reachable objects, recursively.
 	markStack()
 	markGlobals()
@@ -434,6 +445,8 @@ func runGC() (freeBytes uintptr) {
 	// the next collection cycle.
 	freeBytes = sweep()
 
+	gcResumeWorld()
+
 	// Show how much has been sweeped, for debugging.
 	if gcDebug {
 		dumpHeap()
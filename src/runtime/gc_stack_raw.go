@@ -1,5 +1,5 @@
-//go:build gc.conservative && !tinygo.wasm
-// +build gc.conservative,!tinygo.wasm
+//go:build gc.conservative && !tinygo.wasm && !scheduler.threads
+// +build gc.conservative,!tinygo.wasm,!scheduler.threads
 
 package runtime
 
@@ -0,0 +1,30 @@
+//go:build gc.conservative && scheduler.threads
+// +build gc.conservative,scheduler.threads
+
+package runtime
+
+import "internal/task"
+
+// markStack marks all root pointers found on the stacks of all goroutines.
+//
+// With the threads scheduler, every goroutine has its own thread stack. All
+// other goroutines have been stopped before this is called, and their
+// registers have been saved on their stacks.
+func markStack() {
+	// Scan the current stack, and all current registers.
+	scanCurrentStack()
+
+	// Scan the stacks of all other goroutines.
+	task.GCScan(markRoots)
+}
+
+//go:export tinygo_scanCurrentStack
+func scanCurrentStack()
+
+//go:export tinygo_scanstack
+func scanstack(sp uintptr) {
+	// Mark current stack.
+	// This function is called by scanCurrentStack, after pushing all registers onto the stack.
+	// Callee-saved registers have been pushed onto stack by tinygo_localscan, so this will scan them too.
+	markRoots(sp, task.StackTop())
+}
@@ -19,6 +19,10 @@ type Interrupt struct {
 // it only once, and must pass constant parameters to it. That means that the
 // interrupt ID must be a Go constant and that the handler must be a simple
 // function: closures are not supported.
+//
+// The handler runs in interrupt context, so it must not allocate memory or
+// block (for example on a channel or a sync.Mutex). Mark the handler
+// //go:noalloc to have the compiler check this.
 func New(id int, handler func(Interrupt)) Interrupt
 
 // handle is used internally, between IR generation and interrupt lowering. The
@@ -1,5 +1,5 @@
-//go:build !baremetal
-// +build !baremetal
+//go:build !baremetal && !scheduler.threads
+// +build !baremetal,!scheduler.threads
 
 package interrupt
 
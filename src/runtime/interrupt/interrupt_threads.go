@@ -0,0 +1,39 @@
+//go:build !baremetal && scheduler.threads
+// +build !baremetal,scheduler.threads
+
+package interrupt
+
+// State represents the previous global interrupt state.
+type State uintptr
+
+//export tinygo_scheduler_lock
+func schedulerLock()
+
+//export tinygo_scheduler_unlock
+func schedulerUnlock()
+
+// Disable disables all interrupts and returns the previous interrupt state. It
+// can be used in a critical section like this:
+//
+//	state := interrupt.Disable()
+//	// critical section
+//	interrupt.Restore(state)
+//
+// Critical sections can be nested. Make sure to call Restore in the same order
+// as you called Disable (this happens naturally with the pattern above).
+//
+// With the threads scheduler there are no interrupts, but goroutines run in
+// parallel. Instead, this locks a global lock that protects the scheduler data
+// structures, such as channels and timers.
+func Disable() (state State) {
+	schedulerLock()
+	return 0
+}
+
+// Restore restores interrupts to what they were before. Give the previous state
+// returned by Disable as a parameter. If interrupts were disabled before
+// calling Disable, this will not re-enable interrupts, allowing for nested
+// cricital sections.
+func Restore(state State) {
+	schedulerUnlock()
+}
@@ -55,3 +55,24 @@ func init() {
 		env = append(env, s[start:])
 	}
 }
+
+// getenv returns the value of the environment variable with the given name, or
+// the empty string if it isn't set. It reads osEnv directly, so that it can be
+// used before the init function above has run.
+func getenv(name string) string {
+	s := osEnv
+	for len(s) != 0 {
+		end := 0
+		for end < len(s) && s[end] != 0 {
+			end++
+		}
+		if end > len(name) && s[:len(name)] == name && s[len(name)] == '=' {
+			return s[len(name)+1 : end]
+		}
+		if end == len(s) {
+			break
+		}
+		s = s[end+1:]
+	}
+	return ""
+}
@@ -49,6 +49,8 @@ func _panic(message interface{}) {
 	printstring("panic: ")
 	printitf(message)
 	printnl()
+	printSchedulerSeed()
+	callPanicHook()
 	abort()
 }
 
@@ -56,6 +58,8 @@ func _panic(message interface{}) {
 func runtimePanic(msg string) {
 	printstring("panic: runtime error: ")
 	println(msg)
+	printSchedulerSeed()
+	callPanicHook()
 	abort()
 }
 
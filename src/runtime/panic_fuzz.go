@@ -0,0 +1,25 @@
+//go:build tinygo.fuzz
+// +build tinygo.fuzz
+
+package runtime
+
+// panicHook is called when the program is about to abort because of a panic.
+// It is used by the testing package to save the input that caused a crash
+// while fuzzing.
+var panicHook func()
+
+// setPanicHook sets the function to call before aborting on a panic.
+//
+//go:linkname setPanicHook testing.setPanicHook
+func setPanicHook(hook func()) {
+	panicHook = hook
+}
+
+// callPanicHook calls the panic hook, if there is one. The hook is cleared
+// first so that a panic inside the hook doesn't result in infinite recursion.
+func callPanicHook() {
+	if hook := panicHook; hook != nil {
+		panicHook = nil
+		hook()
+	}
+}
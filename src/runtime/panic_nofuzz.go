@@ -0,0 +1,8 @@
+//go:build !tinygo.fuzz
+// +build !tinygo.fuzz
+
+package runtime
+
+// callPanicHook does nothing: only test binaries built for fuzzing have a panic
+// hook (see panic_fuzz.go).
+func callPanicHook() {}
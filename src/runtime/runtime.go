@@ -15,11 +15,6 @@ func initAll()
 //go:linkname callMain main.main
 func callMain()
 
-func GOMAXPROCS(n int) int {
-	// Note: setting GOMAXPROCS is ignored.
-	return 1
-}
-
 func GOROOT() string {
 	// TODO: don't hardcode but take the one at compile time.
 	return "/usr/local/go"
@@ -74,16 +69,7 @@ func nanotime() int64 {
 //
 //go:linkname os_sigpipe os.sigpipe
 func os_sigpipe() {
+	// Terminate the process with SIGPIPE if supported, like upstream Go.
+	raiseSigpipe()
 	runtimePanic("too many writes on closed pipe")
 }
-
-// LockOSThread wires the calling goroutine to its current operating system thread.
-// Stub for now
-// Called by go1.18 standard library on windows, see https://github.com/golang/go/issues/49320
-func LockOSThread() {
-}
-
-// UnlockOSThread undoes an earlier call to LockOSThread.
-// Stub for now
-func UnlockOSThread() {
-}
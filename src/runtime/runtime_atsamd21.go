@@ -81,6 +81,7 @@ func initClocks() {
 		(0x6 << sam.SYSCTRL_OSC32K_STARTUP_Pos) |
 		sam.SYSCTRL_OSC32K_EN32K |
 		sam.SYSCTRL_OSC32K_EN1K |
+		sam.SYSCTRL_OSC32K_RUNSTDBY | // keep the RTC running in standby
 		sam.SYSCTRL_OSC32K_ENABLE)
 	// Wait for oscillator stabilization
 	for !sam.SYSCTRL.PCLKSR.HasBits(sam.SYSCTRL_PCLKSR_OSC32KRDY) {
@@ -189,6 +190,7 @@ func initClocks() {
 
 	sam.GCLK.GENCTRL.Set((2 << sam.GCLK_GENCTRL_ID_Pos) |
 		(sam.GCLK_GENCTRL_SRC_OSC32K << sam.GCLK_GENCTRL_SRC_Pos) |
+		sam.GCLK_GENCTRL_RUNSTDBY | // keep the RTC running in standby
 		sam.GCLK_GENCTRL_GENEN)
 	waitForSync()
 
@@ -381,6 +383,18 @@ func initADCClock() {
 	waitForSync()
 }
 
+// machineStandbyAllowed is provided by package machine.
+func machineStandbyAllowed() bool
+
+// waitForEvents sleeps until an interrupt or event happens. It uses standby
+// instead of idle when no peripheral that needs the main clock is in use, or
+// during machine.DeepSleep. Only the RTC and the EIC keep running in standby.
 func waitForEvents() {
+	if machineStandbyAllowed() {
+		arm.SCB.SCR.SetBits(arm.SCB_SCR_SLEEPDEEP)
+		arm.Asm("wfe")
+		arm.SCB.SCR.ClearBits(arm.SCB_SCR_SLEEPDEEP)
+		return
+	}
 	arm.Asm("wfe")
 }
@@ -368,6 +368,25 @@ func initADCClock() {
 		sam.GCLK_PCHCTRL_CHEN)
 }
 
+// machineStandbyAllowed is provided by package machine.
+func machineStandbyAllowed() bool
+
+// waitForEvents sleeps until an interrupt or event happens. It uses standby
+// instead of idle when no peripheral that needs the main clock is in use, or
+// during machine.DeepSleep. The RTC runs from the always-on 32kHz oscillator.
 func waitForEvents() {
+	if machineStandbyAllowed() {
+		setSleepMode(sam.PM_SLEEPCFG_SLEEPMODE_STANDBY)
+		arm.Asm("wfe")
+		setSleepMode(sam.PM_SLEEPCFG_SLEEPMODE_IDLE2)
+		return
+	}
 	arm.Asm("wfe")
 }
+
+func setSleepMode(mode uint8) {
+	sam.PM.SLEEPCFG.Set(mode)
+	// The new mode must be read back before it takes effect.
+	for sam.PM.SLEEPCFG.Get() != mode {
+	}
+}
@@ -24,11 +24,10 @@ func buffered() int {
 	return 0
 }
 
-func sleepWDT(period uint8) {
-	// TODO: use the watchdog timer instead of a busy loop.
-	for i := 0x45; i != 0; i-- {
-		for i := 0xff; i != 0; i-- {
-			avr.Asm("nop")
-		}
-	}
+// sleepCPU waits for an interrupt in idle mode, the lowest power mode in which
+// timer 0 (the monotonic timer) keeps running.
+func sleepCPU() {
+	avr.MCUCR.ReplaceBits((0<<3)|avr.MCUCR_SE, 0x38, 0)
+	avr.Asm("sleep")
+	avr.MCUCR.ClearBits(avr.MCUCR_SE)
 }
@@ -75,11 +75,16 @@ func nanosecondsToTicks(ns int64) timeUnit {
 }
 
 // Sleep this number of ticks of nanoseconds.
+//
+// The CPU sleeps in idle mode (see sleepCPU) until the next interrupt, which is
+// at the latest the next overflow of timer 0. Deeper sleep modes stop timer 0,
+// so they are only used by machine.DeepSleep, which keeps time with the
+// watchdog instead.
 func sleepTicks(d timeUnit) {
 	waitTill := ticks() + d
 	for {
 		// wait for interrupt
-		avr.Asm("sleep")
+		sleepCPU()
 		if waitTill <= ticks() {
 			// done waiting
 			return
@@ -141,6 +146,17 @@ func initMonotonicTimer() {
 	avr.TIMSK0.SetBits(avr.TIMSK0_TOIE0)
 }
 
+// advanceMonotonicTimer adds the time timer 0 was stopped, for example during
+// machine.DeepSleep.
+//
+//go:linkname advanceMonotonicTimer machine.advanceMonotonicTimer
+func advanceMonotonicTimer(ns int64) {
+	mask := interrupt.Disable()
+	ticks := volatile.LoadUint64((*uint64)(unsafe.Pointer(&ticksCount)))
+	volatile.StoreUint64((*uint64)(unsafe.Pointer(&ticksCount)), ticks+uint64(ns))
+	interrupt.Restore(mask)
+}
+
 //go:linkname adjustMonotonicTimer machine.adjustMonotonicTimer
 func adjustMonotonicTimer() {
 	// adjust the nanosecondsInTick using volatile
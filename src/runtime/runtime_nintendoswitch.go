@@ -105,6 +105,11 @@ func abort() {
 	}
 }
 
+// getenv always returns the empty string: there are no environment variables.
+func getenv(name string) string {
+	return ""
+}
+
 //export write
 func write(fd int32, buf *byte, count int) int {
 	// TODO: Proper handling write
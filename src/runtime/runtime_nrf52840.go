@@ -84,7 +84,11 @@ func buffered() int {
 func sleepTicks(d timeUnit) {
 	for d != 0 {
 		ticks := uint32(d) & 0x7fffff // 23 bits (to be on the safe side)
-		rtc_sleep(ticks)
+		if !rtc_sleep(ticks) {
+			// An interrupt may have made a goroutine runnable (for example
+			// one waiting in machine.DeepSleep), so return to the scheduler.
+			return
+		}
 		d -= timeUnit(ticks)
 	}
 }
@@ -135,7 +139,9 @@ func ticks() timeUnit {
 
 var rtc_wakeup volatile.Register8
 
-func rtc_sleep(ticks uint32) {
+// rtc_sleep waits until the given number of RTC ticks has passed. With a
+// scheduler it returns false as soon as another interrupt wakes up the CPU.
+func rtc_sleep(ticks uint32) bool {
 	nrf.RTC1.INTENSET.Set(nrf.RTC_INTENSET_COMPARE0)
 	rtc_wakeup.Set(0)
 	if ticks == 1 {
@@ -147,5 +153,10 @@ func rtc_sleep(ticks uint32) {
 	nrf.RTC1.CC[0].Set((nrf.RTC1.COUNTER.Get() + ticks) & 0x00ffffff)
 	for rtc_wakeup.Get() == 0 {
 		waitForEvents()
+		if hasScheduler && rtc_wakeup.Get() == 0 {
+			nrf.RTC1.INTENCLR.Set(nrf.RTC_INTENSET_COMPARE0)
+			return false
+		}
 	}
+	return true
 }
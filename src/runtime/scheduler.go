@@ -3,7 +3,8 @@ package runtime
 // This file implements the TinyGo scheduler. This scheduler is a very simple
 // cooperative round robin scheduler, with a runqueue that contains a linked
 // list of goroutines (tasks) that should be run next, in order of when they
-// were added to the queue (first-in, first-out). It also contains a sleep queue
+// were added to the queue (first-in, first-out), or in random order when the
+// randomized scheduler is enabled (see scheduler_random.go). It also contains a sleep queue
 // with sleeping goroutines in order of when they should be re-activated.
 //
 // The scheduler is used both for the asyncify based scheduler and for the task
@@ -77,9 +78,21 @@ func Goexit() {
 
 // Add this task to the end of the run queue.
 func runqueuePushBack(t *task.Task) {
+	if threadScheduler {
+		// Every goroutine has its own thread, so wake it up directly.
+		t.Resume()
+		return
+	}
 	runqueue.Push(t)
 }
 
+// resumeTask adds a paused goroutine back to the run queue. It may be called
+// from an interrupt. The machine package uses it to wake up goroutines waiting
+// for a hardware event, such as a completed DMA transfer.
+func resumeTask(t *task.Task) {
+	runqueuePushBack(t)
+}
+
 // Add this task to the sleep queue, assuming its state is set to sleeping.
 func addSleepTask(t *task.Task, duration timeUnit) {
 	if schedulerDebug {
@@ -133,6 +146,7 @@ func addTimer(tim *timerNode) {
 	}
 	tim.next = *q
 	*q = tim
+	timerAdded()
 	interrupt.Restore(mask)
 }
 
@@ -189,7 +203,12 @@ func scheduler() {
 			tn.callback(tn)
 		}
 
-		t := runqueue.Pop()
+		// Wake up the goroutine waiting for a signal, if one has arrived.
+		if hasSignals {
+			checkSignals()
+		}
+
+		t := runqueuePop()
 		if t == nil {
 			if sleepQueue == nil && timerQueue == nil {
 				if asyncScheduler {
@@ -244,7 +263,7 @@ func scheduler() {
 func minSched() {
 	scheduleLog("start nested scheduler")
 	for !schedulerDone {
-		t := runqueue.Pop()
+		t := runqueuePop()
 		if t == nil {
 			break
 		}
@@ -254,8 +273,3 @@ func minSched() {
 	}
 	scheduleLog("stop nested scheduler")
 }
-
-func Gosched() {
-	runqueue.Push(task.Current())
-	task.Pause()
-}
@@ -1,5 +1,5 @@
-//go:build !scheduler.none
-// +build !scheduler.none
+//go:build !scheduler.none && !scheduler.threads
+// +build !scheduler.none,!scheduler.threads
 
 package runtime
 
@@ -21,6 +21,7 @@ func sleep(duration int64) {
 // With a scheduler, init and the main function are invoked in a goroutine before starting the scheduler.
 func run() {
 	initHeap()
+	initRandomScheduler()
 	go func() {
 		initAll()
 		callMain()
@@ -0,0 +1,83 @@
+//go:build !scheduler.threads
+// +build !scheduler.threads
+
+package runtime
+
+// This file contains the parts of the runtime that differ between the
+// cooperative schedulers (none, tasks, asyncify), which run all goroutines on
+// a single thread, and the threads scheduler.
+
+import "internal/task"
+
+// The scheduler in scheduler.go runs all goroutines.
+const threadScheduler = false
+
+func GOMAXPROCS(n int) int {
+	// Note: setting GOMAXPROCS is ignored.
+	return 1
+}
+
+// NumCPU returns the number of logical CPUs usable by the current process.
+//
+// The set of available CPUs is checked by querying the operating system
+// at process startup. Changes to operating system CPU allocation after
+// process startup are not reflected.
+func NumCPU() int {
+	return 1
+}
+
+// Stub for NumGoroutine, does not return the real value
+func NumGoroutine() int {
+	return 1
+}
+
+func Gosched() {
+	runqueue.Push(task.Current())
+	task.Pause()
+}
+
+// LockOSThread wires the calling goroutine to its current operating system thread.
+// Stub for now
+// Called by go1.18 standard library on windows, see https://github.com/golang/go/issues/49320
+func LockOSThread() {
+}
+
+// UnlockOSThread undoes an earlier call to LockOSThread.
+// Stub for now
+func UnlockOSThread() {
+}
+
+// The heap is only accessed by one goroutine at a time, so no locking is
+// needed.
+func heapLock() {
+}
+
+func heapUnlock() {
+}
+
+// All other goroutines are paused while the GC is running, as the GC never
+// yields.
+func gcStopWorld() {
+}
+
+func gcResumeWorld() {
+}
+
+// timerAdded is called when a new timer was added to the timer queue. The
+// scheduler checks the timer queue each time it runs, so nothing needs to be
+// done here.
+func timerAdded() {
+}
+
+// signalNotify is called from the signal handler after a signal has been
+// recorded. The scheduler checks for pending signals, so nothing needs to be
+// done here.
+func signalNotify() {
+}
+
+// signalWait blocks the current goroutine until the scheduler wakes it up
+// because a signal has arrived.
+func signalWait() {
+	signalRecvWaiter = task.Current()
+	task.Pause()
+}
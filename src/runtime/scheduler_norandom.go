@@ -0,0 +1,25 @@
+//go:build !tinygo.test
+// +build !tinygo.test
+
+package runtime
+
+// The randomized scheduler is only included in test binaries (see
+// scheduler_random.go). Everywhere else, the runqueue is used in FIFO order and
+// the yield points in channel operations compile away.
+
+import "internal/task"
+
+func initRandomScheduler() {}
+
+// runqueuePop removes the goroutine at the front of the runqueue, or returns
+// nil if the runqueue is empty.
+//
+//go:inline
+func runqueuePop() *task.Task {
+	return runqueue.Pop()
+}
+
+//go:inline
+func schedulerYieldPoint() {}
+
+func printSchedulerSeed() {}
@@ -0,0 +1,126 @@
+//go:build tinygo.test
+// +build tinygo.test
+
+package runtime
+
+// This file implements the randomized scheduler mode, which helps to find
+// concurrency bugs that don't show up with the regular FIFO order of the
+// cooperative schedulers. It is enabled by setting the TINYGO_SCHEDSEED
+// environment variable to a seed (a positive integer) when running the
+// program. The scheduler then picks a random goroutine from the runqueue
+// instead of the one that has been waiting the longest, and goroutines
+// randomly yield at channel and mutex operations.
+//
+// The random number generator only depends on the seed, so running the same
+// (otherwise deterministic) program with the same seed results in the same
+// schedule. The seed is printed when the program panics, so that a failure can
+// be replayed.
+//
+// Only the cooperative schedulers (tasks and asyncify) support this mode, and
+// only test binaries include it: see scheduler_norandom.go for other programs.
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+)
+
+// schedulerSeed is the seed of the randomized scheduler, or zero when the
+// runqueue is used in FIFO order.
+var schedulerSeed uint64
+
+// State of the random number generator of the randomized scheduler.
+var schedulerRandState uint64
+
+// initRandomScheduler enables the randomized scheduler if TINYGO_SCHEDSEED is
+// set. It must be called before any goroutine is started.
+func initRandomScheduler() {
+	if !hasScheduler || threadScheduler {
+		return
+	}
+	s := getenv("TINYGO_SCHEDSEED")
+	if s == "" {
+		return
+	}
+	var seed uint64
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			seed = 0
+			break
+		}
+		seed = seed*10 + uint64(s[i]-'0')
+	}
+	if seed == 0 {
+		println("runtime: ignoring TINYGO_SCHEDSEED, it must be a positive integer:", s)
+		return
+	}
+	schedulerSeed = seed
+	schedulerRandState = seed
+}
+
+// schedulerRand returns the next pseudo-random number for the randomized
+// scheduler, using the xorshift64* algorithm.
+func schedulerRand() uint32 {
+	x := schedulerRandState
+	x ^= x >> 12
+	x ^= x << 25
+	x ^= x >> 27
+	schedulerRandState = x
+	return uint32((x * 2685821657736338717) >> 32)
+}
+
+// runqueuePop removes the goroutine that should run next from the runqueue,
+// or returns nil if the runqueue is empty. This is the goroutine at the front
+// of the queue, unless the randomized scheduler is enabled.
+func runqueuePop() *task.Task {
+	if schedulerSeed == 0 {
+		return runqueue.Pop()
+	}
+
+	// Move all goroutines to a temporary queue to count them, and then move
+	// them back except for a randomly picked one. This is slow for a long
+	// runqueue, but it doesn't need to allocate memory.
+	mask := interrupt.Disable()
+	var tmp task.Queue
+	n := uint32(0)
+	for t := runqueue.Pop(); t != nil; t = runqueue.Pop() {
+		tmp.Push(t)
+		n++
+	}
+	var picked *task.Task
+	if n != 0 {
+		index := schedulerRand() % n
+		for i := uint32(0); i < n; i++ {
+			t := tmp.Pop()
+			if i == index {
+				picked = t
+			} else {
+				runqueue.Push(t)
+			}
+		}
+	}
+	interrupt.Restore(mask)
+	return picked
+}
+
+// schedulerYieldPoint is called at the start of channel and mutex operations.
+// With the randomized scheduler, it yields to another goroutine half of the
+// time to expose code that depends on a particular order of goroutines.
+//
+//go:linkname schedulerYieldPoint sync.runtime_yieldPoint
+func schedulerYieldPoint() {
+	if schedulerSeed != 0 && schedulerRand()%2 == 0 {
+		Gosched()
+	}
+}
+
+// printSchedulerSeed prints the seed of the randomized scheduler (if enabled)
+// when the program crashes, so that the crash can be reproduced.
+func printSchedulerSeed() {
+	if schedulerSeed != 0 {
+		printstring("scheduler seed: ")
+		printuint64(schedulerSeed)
+		printstring(" (replay with TINYGO_SCHEDSEED=")
+		printuint64(schedulerSeed)
+		printstring(")\n")
+	}
+}
@@ -0,0 +1,208 @@
+//go:build scheduler.threads
+// +build scheduler.threads
+
+package runtime
+
+// This file implements the threads scheduler: every goroutine runs on its own
+// OS thread, so goroutines can run in parallel. At most GOMAXPROCS of them run
+// Go code at the same time, see internal/task. Blocking operations (channels,
+// sync.Mutex, etc) still use task.Pause and task.Resume, which block and wake
+// up the thread of a goroutine. Runtime data structures are protected by a
+// global lock, see runtime/interrupt.
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+	"sync/atomic"
+)
+
+const hasScheduler = true
+
+// Every goroutine runs on its own thread, the scheduler in scheduler.go is not
+// used.
+const threadScheduler = true
+
+// Pause the current goroutine for a given time.
+//
+//go:linkname sleep time.Sleep
+func sleep(duration int64) {
+	if duration <= 0 {
+		return
+	}
+
+	// Sleeping may be interrupted by signals (for example when the GC stops
+	// this thread), so continue sleeping until the time has passed.
+	end := ticks() + nanosecondsToTicks(duration)
+	task.ReleaseProc()
+	for now := ticks(); now < end; now = ticks() {
+		sleepTicks(end - now)
+	}
+	task.AcquireProc()
+}
+
+// run is called by the program entry point to execute the go program.
+// With the threads scheduler, init and the main function run on the main thread.
+func run() {
+	initHeap()
+	task.Init(stackTop)
+	initAll()
+	callMain()
+}
+
+// GOMAXPROCS sets the maximum number of goroutines that can be running Go code
+// simultaneously and returns the previous setting. It defaults to the value of
+// runtime.NumCPU. If n < 1, it does not change the current setting.
+// Goroutines that are blocked in a system call don't count towards the limit.
+func GOMAXPROCS(n int) int {
+	if n > 0 {
+		return task.SetMaxProcs(n)
+	}
+	return task.MaxProcs()
+}
+
+// NumCPU returns the number of logical CPUs usable by the current process.
+//
+// The set of available CPUs is checked by querying the operating system
+// at process startup. Changes to operating system CPU allocation after
+// process startup are not reflected.
+func NumCPU() int {
+	return task.NumCPU()
+}
+
+// NumGoroutine returns the number of goroutines that currently exist.
+func NumGoroutine() int {
+	return task.NumGoroutine()
+}
+
+//export sched_yield
+func sched_yield() int32
+
+func Gosched() {
+	// Give other goroutines that are waiting for a processor a chance to run.
+	task.ReleaseProc()
+	sched_yield()
+	task.AcquireProc()
+}
+
+// LockOSThread wires the calling goroutine to its current operating system
+// thread. The calling goroutine will always execute in that thread, and no
+// other goroutine will execute in it.
+// With this scheduler every goroutine runs on its own thread from start to
+// exit and threads are never reused, so that is always the case and nothing
+// needs to be done.
+func LockOSThread() {
+}
+
+// UnlockOSThread undoes an earlier call to LockOSThread.
+func UnlockOSThread() {
+}
+
+// entersyscall is called by the compiler before a system call (but not a raw
+// system call), which may block for a long time.
+func entersyscall() {
+	task.ReleaseProc()
+}
+
+// exitsyscall is called by the compiler after a system call that started
+// with entersyscall.
+func exitsyscall() {
+	task.AcquireProc()
+}
+
+// heapMutex protects the heap (including GC state) from concurrent access.
+var heapMutex task.Mutex
+
+func heapLock() {
+	heapMutex.Lock()
+}
+
+func heapUnlock() {
+	heapMutex.Unlock()
+}
+
+// gcStopWorld stops all other goroutines before a GC cycle, so that they don't
+// modify the heap while it is being scanned.
+func gcStopWorld() {
+	task.GCStopWorld()
+}
+
+func gcResumeWorld() {
+	task.GCResumeWorld()
+}
+
+var (
+	// timerWakeups is incremented each time a timer is added, to wake up the
+	// timer goroutine. It is used as a futex.
+	timerWakeups uint32
+
+	// timerStarted is true once the timer goroutine has been started.
+	timerStarted bool
+)
+
+// timerAdded is called when a new timer was added to the timer queue, with the
+// scheduler lock held. It starts the timer goroutine when needed and wakes it
+// up so that it can recalculate how long to sleep.
+func timerAdded() {
+	if !timerStarted {
+		timerStarted = true
+		go timerLoop()
+	}
+	atomic.AddUint32(&timerWakeups, 1)
+	task.FutexWake(&timerWakeups, 1)
+}
+
+// timerLoop runs the callbacks of expired timers. It runs in its own
+// goroutine, which is started when the first timer is added.
+func timerLoop() {
+	for {
+		mask := interrupt.Disable()
+		wakeups := atomic.LoadUint32(&timerWakeups)
+		if timerQueue == nil {
+			interrupt.Restore(mask)
+			task.ReleaseProc()
+			task.FutexWait(&timerWakeups, wakeups)
+			task.AcquireProc()
+			continue
+		}
+		now := ticks()
+		if now < timerQueue.whenTicks() {
+			timeLeft := timerQueue.whenTicks() - now
+			interrupt.Restore(mask)
+			task.ReleaseProc()
+			task.FutexWaitTimeout(&timerWakeups, wakeups, ticksToNanoseconds(timeLeft))
+			task.AcquireProc()
+			continue
+		}
+
+		// Pop timer from queue.
+		tn := timerQueue
+		timerQueue = tn.next
+		tn.next = nil
+		interrupt.Restore(mask)
+
+		// Run the callback stored in this timer node.
+		tn.callback(tn)
+	}
+}
+
+// signalWakeups is incremented each time a signal arrives. It is used as a
+// futex by the goroutine waiting in signal_recv.
+var signalWakeups uint32
+
+// signalNotify is called from the signal handler after a signal has been
+// recorded.
+func signalNotify() {
+	atomic.AddUint32(&signalWakeups, 1)
+	task.FutexWake(&signalWakeups, 1)
+}
+
+// signalWait blocks the current goroutine until a signal might have arrived.
+func signalWait() {
+	wakeups := atomic.LoadUint32(&signalWakeups)
+	if anySignalPending() {
+		return
+	}
+	task.ReleaseProc()
+	task.FutexWait(&signalWakeups, wakeups)
+	task.AcquireProc()
+}
@@ -0,0 +1,165 @@
+package runtime
+
+// This file implements the runtime side of the os/signal package. Signals are
+// recorded by a (platform specific) signal handler in the signalPending bitmask
+// and delivered from there to the os/signal package by a goroutine waiting in
+// signal_recv.
+//
+// On platforms without signals (hasSignals is false), signals are never
+// delivered. All signal state is then optimized away.
+
+import (
+	"internal/task"
+	"sync/atomic"
+)
+
+// Maximum signal number (exclusive), the same as numSig in os/signal.
+const numSig = 65
+
+type signalMask [(numSig + 31) / 32]uint32
+
+var (
+	// Signals that have been received but not yet delivered to os/signal.
+	// This mask is modified from the signal handler so must only be accessed
+	// atomically.
+	signalPending signalMask
+
+	// Signals that should be delivered to os/signal (see signal.Notify).
+	// Only read atomically from the signal handler.
+	signalEnabled signalMask
+
+	// Signals that are ignored (see signal.Ignore).
+	signalIgnored signalMask
+
+	// Goroutine blocked in signal_recv waiting for a signal to arrive, or nil.
+	signalRecvWaiter *task.Task
+
+	// Nonzero while os/signal processes a signal returned by signal_recv.
+	// Only accessed atomically, as signal.Stop may run on another thread.
+	signalRecvBusy uint32
+)
+
+func (m *signalMask) has(sig uint32) bool {
+	return atomic.LoadUint32(&m[sig/32])&(1<<(sig%32)) != 0
+}
+
+func (m *signalMask) set(sig uint32) {
+	addr := &m[sig/32]
+	for {
+		old := atomic.LoadUint32(addr)
+		if atomic.CompareAndSwapUint32(addr, old, old|1<<(sig%32)) {
+			return
+		}
+	}
+}
+
+func (m *signalMask) clear(sig uint32) {
+	addr := &m[sig/32]
+	for {
+		old := atomic.LoadUint32(addr)
+		if atomic.CompareAndSwapUint32(addr, old, old&^(1<<(sig%32))) {
+			return
+		}
+	}
+}
+
+// signalReceived records that the given signal was received. It is called from
+// the signal handler so it must be async-signal-safe: it must not allocate or
+// touch the scheduler.
+func signalReceived(sig uint32) {
+	if sig < numSig && signalEnabled.has(sig) {
+		signalPending.set(sig)
+		signalNotify()
+	}
+}
+
+// anySignalPending returns whether there is at least one signal waiting to be
+// delivered to os/signal.
+func anySignalPending() bool {
+	for i := range signalPending {
+		if atomic.LoadUint32(&signalPending[i]) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSignals wakes up the goroutine waiting in signal_recv when a signal has
+// arrived. It is called from the scheduler.
+func checkSignals() {
+	if signalRecvWaiter != nil && anySignalPending() {
+		runqueue.Push(signalRecvWaiter)
+		signalRecvWaiter = nil
+	}
+}
+
+//go:linkname signal_enable os/signal.signal_enable
+func signal_enable(sig uint32) {
+	if sig >= numSig {
+		return
+	}
+	signalIgnored.clear(sig)
+	signalEnabled.set(sig)
+	signalEnable(sig)
+}
+
+//go:linkname signal_disable os/signal.signal_disable
+func signal_disable(sig uint32) {
+	if sig >= numSig {
+		return
+	}
+	signalEnabled.clear(sig)
+	signalDisable(sig)
+}
+
+//go:linkname signal_ignore os/signal.signal_ignore
+func signal_ignore(sig uint32) {
+	if sig >= numSig {
+		return
+	}
+	signalEnabled.clear(sig)
+	signalIgnored.set(sig)
+	signalIgnore(sig)
+}
+
+//go:linkname signal_ignored os/signal.signal_ignored
+func signal_ignored(sig uint32) bool {
+	return sig < numSig && signalIgnored.has(sig)
+}
+
+// signal_recv blocks until a signal arrives and returns its number. It is
+// called in a loop by a goroutine in os/signal.
+//
+//go:linkname signal_recv os/signal.signal_recv
+func signal_recv() uint32 {
+	atomic.StoreUint32(&signalRecvBusy, 0)
+	for {
+		for i := range signalPending {
+			pending := atomic.LoadUint32(&signalPending[i])
+			if pending == 0 {
+				continue
+			}
+			for bit := uint32(0); bit < 32; bit++ {
+				if pending&(1<<bit) != 0 {
+					sig := uint32(i)*32 + bit
+					signalPending.clear(sig)
+					atomic.StoreUint32(&signalRecvBusy, 1)
+					return sig
+				}
+			}
+		}
+
+		// Nothing pending, wait until a signal arrives.
+		signalWait()
+	}
+}
+
+// signalWaitUntilIdle waits until the signal delivery goroutine has processed
+// the last signal it received. It is used by signal.Stop.
+//
+//go:linkname signalWaitUntilIdle os/signal.signalWaitUntilIdle
+func signalWaitUntilIdle() {
+	for hasScheduler && atomic.LoadUint32(&signalRecvBusy) != 0 {
+		Gosched()
+	}
+}
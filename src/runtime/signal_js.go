@@ -0,0 +1,33 @@
+//go:build wasm && !wasi
+// +build wasm,!wasi
+
+package runtime
+
+// Signals are delivered by the JavaScript host. Under Node.js, wasm_exec.js
+// installs process signal listeners that call tinygo_signal_handler.
+// In a browser, signals are never delivered.
+
+const hasSignals = true
+
+//export runtime.signalEnable
+func signalEnable(sig uint32)
+
+//export runtime.signalDisable
+func signalDisable(sig uint32)
+
+//export runtime.signalIgnore
+func signalIgnore(sig uint32)
+
+// Called by the JavaScript host when a signal arrives. The host calls
+// go_scheduler afterwards to deliver the signal.
+//
+//export tinygo_signal_handler
+func tinygo_signal_handler(sig uint32) {
+	signalReceived(sig)
+}
+
+func waitForSignal() {
+}
+
+func raiseSigpipe() {
+}
@@ -0,0 +1,84 @@
+//go:build linux && !baremetal && !nintendoswitch && !wasi
+// +build linux,!baremetal,!nintendoswitch,!wasi
+
+package runtime
+
+import "unsafe"
+
+const hasSignals = true
+
+const (
+	sigPIPE = 13
+	sigCHLD = 17
+)
+
+// The following functions are implemented in lib/runtime-signal.c.
+
+//export tinygo_signal_enable
+func tinygo_signal_enable(sig uint32)
+
+//export tinygo_signal_disable
+func tinygo_signal_disable(sig uint32)
+
+//export tinygo_signal_ignore
+func tinygo_signal_ignore(sig uint32)
+
+//export tinygo_signal_wait
+func tinygo_signal_wait(pending unsafe.Pointer, n uint32)
+
+//export raise
+func libc_raise(sig int32) int32
+
+// Called from the signal handler installed by tinygo_signal_enable.
+//
+//export tinygo_signal_handler
+func tinygo_signal_handler(sig int32) {
+	signalReceived(uint32(sig))
+}
+
+func init() {
+	// Like the upstream runtime, catch SIGPIPE so that writing to a closed
+	// pipe returns EPIPE instead of killing the process. Writes to stdout or
+	// stderr still raise SIGPIPE, see os_sigpipe.
+	// A handler is used instead of SIG_IGN, so that child processes get the
+	// default behavior back on exec.
+	tinygo_signal_enable(sigPIPE)
+
+	// Also like the upstream runtime, install a handler for SIGCHLD even if
+	// the parent process ignored it. An ignored SIGCHLD makes the kernel reap
+	// child processes automatically, so that Process.Wait would fail.
+	// The signal is dropped unless signal.Notify asks for it.
+	tinygo_signal_enable(sigCHLD)
+}
+
+func signalEnable(sig uint32) {
+	tinygo_signal_enable(sig)
+}
+
+func signalDisable(sig uint32) {
+	if sig == sigPIPE || sig == sigCHLD {
+		// Restore the default Go behavior, which is not the same as SIG_DFL
+		// for SIGPIPE and which keeps child processes waitable for SIGCHLD.
+		tinygo_signal_enable(sig)
+		return
+	}
+	tinygo_signal_disable(sig)
+}
+
+func signalIgnore(sig uint32) {
+	tinygo_signal_ignore(sig)
+}
+
+// waitForSignal blocks until a signal has been received. It is called by the
+// scheduler when the only goroutine that can make progress is the one waiting
+// in signal_recv.
+func waitForSignal() {
+	tinygo_signal_wait(unsafe.Pointer(&signalPending), uint32(len(signalPending)))
+}
+
+// raiseSigpipe kills the process with SIGPIPE, bypassing the handler that is
+// installed by default.
+func raiseSigpipe() {
+	tinygo_signal_disable(sigPIPE)
+	libc_raise(sigPIPE)
+}
@@ -0,0 +1,24 @@
+//go:build (!linux && !js) || baremetal || nintendoswitch || wasi
+// +build !linux,!js baremetal nintendoswitch wasi
+
+package runtime
+
+// Signals are not supported on this platform: os/signal can be used but no
+// signal will ever be delivered.
+
+const hasSignals = false
+
+func signalEnable(sig uint32) {
+}
+
+func signalDisable(sig uint32) {
+}
+
+func signalIgnore(sig uint32) {
+}
+
+func waitForSignal() {
+}
+
+func raiseSigpipe() {
+}
@@ -57,3 +57,24 @@ func resetTimer(tim *timer, when int64) bool {
 	startTimer(tim)
 	return removed
 }
+
+// startCallbackTimer calls callback from the scheduler after the given number
+// of nanoseconds, unless the timer is stopped before that. It is used by the
+// machine package, which can't import the time package.
+//
+//go:linkname startCallbackTimer machine.startCallbackTimer
+func startCallbackTimer(ns int64, callback func()) *timer {
+	tim := &timer{when: nanotime() + ns}
+	addTimer(&timerNode{
+		timer: tim,
+		callback: func(*timerNode) {
+			callback()
+		},
+	})
+	return tim
+}
+
+//go:linkname stopCallbackTimer machine.stopCallbackTimer
+func stopCallbackTimer(tim *timer) bool {
+	return removeTimer(tim)
+}
@@ -4,5 +4,11 @@
 package runtime
 
 func waitForEvents() {
+	if hasSignals && signalRecvWaiter != nil {
+		// The only goroutine that can make progress is the one waiting for
+		// signals, so wait for a signal to arrive.
+		waitForSignal()
+		return
+	}
 	runtimePanic("deadlocked: no event source")
 }
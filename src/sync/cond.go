@@ -40,21 +40,27 @@ func (c *Cond) trySignal() bool {
 }
 
 func (c *Cond) Signal() {
+	mask := lockScheduler()
 	c.trySignal()
+	unlockScheduler(mask)
 }
 
 func (c *Cond) Broadcast() {
 	// Signal everything.
+	mask := lockScheduler()
 	for c.trySignal() {
 	}
+	unlockScheduler(mask)
 }
 
 func (c *Cond) Wait() {
 	// Add an earlySignal frame to the stack so we can be signalled while unlocking.
+	mask := lockScheduler()
 	early := earlySignal{
 		next: c.unlocking,
 	}
 	c.unlocking = &early
+	unlockScheduler(mask)
 
 	// Temporarily unlock L.
 	c.L.Unlock()
@@ -63,7 +69,9 @@ func (c *Cond) Wait() {
 	defer c.L.Lock()
 
 	// If we were signaled while unlocking, immediately complete.
+	mask = lockScheduler()
 	if early.signaled {
+		unlockScheduler(mask)
 		return
 	}
 
@@ -80,5 +88,6 @@ func (c *Cond) Wait() {
 
 	// Wait for a signal.
 	c.blocked.Push(task.Current())
+	unlockScheduler(mask)
 	task.Pause()
 }
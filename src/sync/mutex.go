@@ -14,18 +14,27 @@ type Mutex struct {
 func scheduleTask(*task.Task)
 
 func (m *Mutex) Lock() {
+	runtime_yieldPoint()
+
+	mask := lockScheduler()
 	if m.locked {
 		// Push self onto stack of blocked tasks, and wait to be resumed.
 		m.blocked.Push(task.Current())
+		unlockScheduler(mask)
 		task.Pause()
 		return
 	}
 
 	m.locked = true
+	unlockScheduler(mask)
 }
 
 func (m *Mutex) Unlock() {
+	runtime_yieldPoint()
+
+	mask := lockScheduler()
 	if !m.locked {
+		unlockScheduler(mask)
 		panic("sync: unlock of unlocked Mutex")
 	}
 
@@ -35,6 +44,7 @@ func (m *Mutex) Unlock() {
 	} else {
 		m.locked = false
 	}
+	unlockScheduler(mask)
 }
 
 type RWMutex struct {
@@ -58,29 +68,37 @@ const (
 )
 
 func (rw *RWMutex) Lock() {
+	runtime_yieldPoint()
+
+	mask := lockScheduler()
 	if rw.state == 0 {
 		// The mutex is completely unlocked.
 		// Lock without waiting.
 		rw.state = rwMutexStateWLocked
+		unlockScheduler(mask)
 		return
 	}
 
 	// Wait for the lock to be released.
 	rw.waitingWriters.Push(task.Current())
+	unlockScheduler(mask)
 	task.Pause()
 }
 
 func (rw *RWMutex) Unlock() {
+	mask := lockScheduler()
 	switch rw.state {
 	case rwMutexStateWLocked:
 		// This is correct.
 
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		unlockScheduler(mask)
 		panic("sync: unlock of unlocked RWMutex")
 
 	default:
 		// The mutex is read-locked instead of write-locked.
+		unlockScheduler(mask)
 		panic("sync: write-unlock of read-locked RWMutex")
 	}
 
@@ -95,32 +113,42 @@ func (rw *RWMutex) Unlock() {
 		// Nothing is waiting for the lock.
 		rw.state = rwMutexStateUnlocked
 	}
+	unlockScheduler(mask)
 }
 
 func (rw *RWMutex) RLock() {
+	runtime_yieldPoint()
+
+	mask := lockScheduler()
 	if rw.state == rwMutexStateWLocked {
 		// Wait for the write lock to be released.
 		rw.waitingReaders.Push(task.Current())
+		unlockScheduler(mask)
 		task.Pause()
 		return
 	}
 
 	if rw.state == rwMutexMaxReaders {
+		unlockScheduler(mask)
 		panic("sync: too many readers on RWMutex")
 	}
 
 	// Increase the reader count.
 	rw.state++
+	unlockScheduler(mask)
 }
 
 func (rw *RWMutex) RUnlock() {
+	mask := lockScheduler()
 	switch rw.state {
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		unlockScheduler(mask)
 		panic("sync: unlock of unlocked RWMutex")
 
 	case rwMutexStateWLocked:
 		// The mutex is write-locked instead of read-locked.
+		unlockScheduler(mask)
 		panic("sync: read-unlock of write-locked RWMutex")
 	}
 
@@ -131,6 +159,7 @@ func (rw *RWMutex) RUnlock() {
 		// Try to unblock a writer.
 		rw.maybeUnblockWriter()
 	}
+	unlockScheduler(mask)
 }
 
 func (rw *RWMutex) maybeUnblockReaders() bool {
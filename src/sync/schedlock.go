@@ -0,0 +1,17 @@
+//go:build !scheduler.threads
+// +build !scheduler.threads
+
+package sync
+
+import "runtime/interrupt"
+
+// lockScheduler protects the state of the synchronization primitives in this
+// package. With the cooperative schedulers, goroutines are never preempted so
+// nothing needs to be locked.
+func lockScheduler() interrupt.State {
+	return 0
+}
+
+// unlockScheduler undoes lockScheduler.
+func unlockScheduler(state interrupt.State) {
+}
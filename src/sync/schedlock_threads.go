@@ -0,0 +1,18 @@
+//go:build scheduler.threads
+// +build scheduler.threads
+
+package sync
+
+import "runtime/interrupt"
+
+// lockScheduler protects the state of the synchronization primitives in this
+// package. With the threads scheduler, goroutines run in parallel so this
+// takes the global scheduler lock.
+func lockScheduler() interrupt.State {
+	return interrupt.Disable()
+}
+
+// unlockScheduler undoes lockScheduler.
+func unlockScheduler(state interrupt.State) {
+	interrupt.Restore(state)
+}
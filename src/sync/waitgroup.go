@@ -8,9 +8,11 @@ type WaitGroup struct {
 }
 
 func (wg *WaitGroup) Add(delta int) {
+	mask := lockScheduler()
 	if delta > 0 {
 		// Check for overflow.
 		if uint(delta) > (^uint(0))-wg.counter {
+			unlockScheduler(mask)
 			panic("sync: WaitGroup counter overflowed")
 		}
 
@@ -19,6 +21,7 @@ func (wg *WaitGroup) Add(delta int) {
 	} else {
 		// Check for underflow.
 		if uint(-delta) > wg.counter {
+			unlockScheduler(mask)
 			panic("sync: negative WaitGroup counter")
 		}
 
@@ -34,6 +37,7 @@ func (wg *WaitGroup) Add(delta int) {
 			}
 		}
 	}
+	unlockScheduler(mask)
 }
 
 func (wg *WaitGroup) Done() {
@@ -41,13 +45,16 @@ func (wg *WaitGroup) Done() {
 }
 
 func (wg *WaitGroup) Wait() {
+	mask := lockScheduler()
 	if wg.counter == 0 {
 		// Everything already finished.
+		unlockScheduler(mask)
 		return
 	}
 
 	// Push the current goroutine onto the waiter stack.
 	wg.waiters.Push(task.Current())
+	unlockScheduler(mask)
 
 	// Pause until the waiters are awoken by Add/Done.
 	task.Pause()
@@ -0,0 +1,8 @@
+//go:build tinygo.test
+// +build tinygo.test
+
+package sync
+
+// runtime_yieldPoint may switch to another goroutine when the randomized
+// scheduler is enabled. It is implemented in the runtime.
+func runtime_yieldPoint()
@@ -0,0 +1,10 @@
+//go:build !tinygo.test
+// +build !tinygo.test
+
+package sync
+
+// runtime_yieldPoint does nothing: the randomized scheduler is only included in
+// test binaries (see yieldpoint.go).
+//
+//go:inline
+func runtime_yieldPoint() {}
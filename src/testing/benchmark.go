@@ -361,7 +361,7 @@ func runBenchmarks(matchString func(pat, str string) (bool, error), benchmarks [
 		return true
 	}
 	ctx := &benchContext{
-		match: newMatcher(matchString, *matchBenchmarks, "-test.bench"),
+		match: newMatcher(matchString, *matchBenchmarks, "-test.bench", flagSkipRegexp),
 	}
 	var bs []InternalBenchmark
 	for _, Benchmark := range benchmarks {
@@ -394,24 +394,39 @@ func runBenchmarks(matchString func(pat, str string) (bool, error), benchmarks [
 func (b *B) processBench(ctx *benchContext) {
 	benchName := b.name
 
-	if ctx != nil {
-		fmt.Printf("%-*s\t", ctx.maxLen, benchName)
-	}
-	r := b.doBench()
-	if b.failed {
-		// The output could be very long here, but probably isn't.
-		// We print it all, regardless, because we don't want to trim the reason
-		// the benchmark failed.
-		fmt.Printf("--- FAIL: %s\n%s", benchName, "") // b.output)
-		return
-	}
-	if ctx != nil {
-		results := r.String()
+	for i := uint(0); i < flagCount; i++ {
+		// Recompute the running time for all but the first iteration.
+		if i > 0 {
+			b = &B{
+				common: common{
+					name:  b.name,
+					level: b.level,
+				},
+				benchFunc: b.benchFunc,
+				benchTime: b.benchTime,
+				context:   b.context,
+			}
+			b.run1()
+		}
+		if ctx != nil {
+			fmt.Printf("%-*s\t", ctx.maxLen, benchName)
+		}
+		r := b.doBench()
+		if b.failed {
+			// The output could be very long here, but probably isn't.
+			// We print it all, regardless, because we don't want to trim the reason
+			// the benchmark failed.
+			fmt.Printf("--- FAIL: %s\n%s", benchName, "") // b.output)
+			return
+		}
+		if ctx != nil {
+			results := r.String()
 
-		if *benchmarkMemory || b.showAllocResult {
-			results += "\t" + r.MemString()
+			if *benchmarkMemory || b.showAllocResult {
+				results += "\t" + r.MemString()
+			}
+			fmt.Println(results)
 		}
-		fmt.Println(results)
 	}
 }
 
@@ -0,0 +1,81 @@
+package testing
+
+// Code coverage support. The package under test is instrumented by the
+// compiler (see loader/cover.go), which adds an init function that registers
+// the coverage counters of the package here.
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+var (
+	flagCoverProfile string
+	coverMode        string
+	coverPackages    []coverPackage
+)
+
+// coverPackage holds the coverage counters of an instrumented package.
+type coverPackage struct {
+	counters []uint32
+	blocks   []uint32 // file index, start line, start column, end line, end column, number of statements
+	files    []string
+}
+
+// registerCover is called from the init function of an instrumented package.
+func registerCover(mode string, counters []uint32, blocks []uint32, files []string) {
+	coverMode = mode
+	coverPackages = append(coverPackages, coverPackage{
+		counters: counters,
+		blocks:   blocks,
+		files:    files,
+	})
+}
+
+// Coverage reports the current code coverage as a fraction in the range [0, 1].
+// If coverage is not enabled, Coverage returns 0.
+func Coverage() float64 {
+	var total, covered uint64
+	for _, pkg := range coverPackages {
+		for i, count := range pkg.counters {
+			numStmt := uint64(pkg.blocks[i*6+5])
+			total += numStmt
+			if count != 0 {
+				covered += numStmt
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total)
+}
+
+// coverReport prints the coverage percentage and writes the coverage profile,
+// if requested.
+func coverReport() {
+	fmt.Printf("coverage: %.1f%% of statements\n", 100*Coverage())
+	if flagCoverProfile == "" {
+		return
+	}
+	err := writeCoverProfile(flagCoverProfile, coverProfile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "testing: can't write %s: %s\n", flagCoverProfile, err)
+	}
+}
+
+// coverProfile returns the coverage profile in the format used by go test:
+// a mode line followed by one line per block with its position, number of
+// statements and counter.
+func coverProfile() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mode: %s\n", coverMode)
+	for _, pkg := range coverPackages {
+		for i, count := range pkg.counters {
+			block := pkg.blocks[i*6 : i*6+6]
+			fmt.Fprintf(&buf, "%s:%d.%d,%d.%d %d %d\n", pkg.files[block[0]], block[1], block[2], block[3], block[4], block[5], count)
+		}
+	}
+	return buf.Bytes()
+}
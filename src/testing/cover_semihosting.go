@@ -0,0 +1,31 @@
+//go:build cortexm && qemu
+// +build cortexm,qemu
+
+package testing
+
+import (
+	"device/arm"
+	"errors"
+	"unsafe"
+)
+
+// writeCoverProfile writes the coverage profile to a file on the host, using
+// semihosting.
+func writeCoverProfile(path string, data []byte) error {
+	name := append([]byte(path), 0)
+	const modeWrite = 4 // "w" in fopen
+	openArgs := [3]uintptr{uintptr(unsafe.Pointer(&name[0])), modeWrite, uintptr(len(path))}
+	handle := arm.SemihostingCall(arm.SemihostingOpen, uintptr(unsafe.Pointer(&openArgs)))
+	if handle == -1 {
+		return errors.New("semihosting: could not open file")
+	}
+	// The write call returns the number of bytes that were not written.
+	writeArgs := [3]uintptr{uintptr(handle), uintptr(unsafe.Pointer(&data[0])), uintptr(len(data))}
+	notWritten := arm.SemihostingCall(arm.SemihostingWrite, uintptr(unsafe.Pointer(&writeArgs)))
+	closeArgs := [1]uintptr{uintptr(handle)}
+	arm.SemihostingCall(arm.SemihostingClose, uintptr(unsafe.Pointer(&closeArgs)))
+	if notWritten != 0 {
+		return errors.New("semihosting: could not write file")
+	}
+	return nil
+}
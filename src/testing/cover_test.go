@@ -0,0 +1,34 @@
+package testing
+
+func TestCoverProfile(t *T) {
+	// Replace the counters of this package (if it was built with -cover) by
+	// two fake packages.
+	savedMode, savedPackages := coverMode, coverPackages
+	defer func() {
+		coverMode, coverPackages = savedMode, savedPackages
+	}()
+	coverMode, coverPackages = "", nil
+
+	registerCover("count", []uint32{3, 0}, []uint32{
+		0, 5, 20, 8, 2, 2,
+		1, 10, 14, 10, 30, 1,
+	}, []string{"example.com/foo/a.go", "example.com/foo/b.go"})
+	registerCover("count", []uint32{1}, []uint32{
+		0, 3, 13, 4, 2, 1,
+	}, []string{"example.com/bar/c.go"})
+
+	if mode := CoverMode(); mode != "count" {
+		t.Errorf("CoverMode() = %q, want %q", mode, "count")
+	}
+	// 3 of the 4 statements were executed.
+	if coverage := Coverage(); coverage != 0.75 {
+		t.Errorf("Coverage() = %v, want 0.75", coverage)
+	}
+	const expected = "mode: count\n" +
+		"example.com/foo/a.go:5.20,8.2 2 3\n" +
+		"example.com/foo/b.go:10.14,10.30 1 0\n" +
+		"example.com/bar/c.go:3.13,4.2 1 1\n"
+	if profile := string(coverProfile()); profile != expected {
+		t.Errorf("unexpected coverage profile:\n%s\nexpected:\n%s", profile, expected)
+	}
+}
@@ -0,0 +1,117 @@
+package testing
+
+import (
+	"bytes"
+	"reflect"
+	"sync/atomic"
+)
+
+// setFakeTestFlags sets the test flags to their defaults, to run fake tests
+// with runTests independently of the flags this test binary was started with.
+// It returns a function that restores the flags.
+func setFakeTestFlags() (restore func()) {
+	verbose, run, skip, list := flagVerbose, flagRunRegexp, flagSkipRegexp, flagListRegexp
+	count, failFast, parallel := flagCount, flagFailFast, flagParallel
+	failed := atomic.LoadUint32(&numFailed)
+	flagVerbose, flagRunRegexp, flagSkipRegexp, flagListRegexp = false, "", "", ""
+	flagCount, flagFailFast, flagParallel = 1, false, 4
+	return func() {
+		flagVerbose, flagRunRegexp, flagSkipRegexp, flagListRegexp = verbose, run, skip, list
+		flagCount, flagFailFast, flagParallel = count, failFast, parallel
+		atomic.StoreUint32(&numFailed, failed)
+	}
+}
+
+func TestCount(t *T) {
+	defer setFakeTestFlags()()
+	flagCount = 3
+
+	runs := 0
+	ran, ok := runTests(fakeMatchString, []InternalTest{
+		{"TestA", func(t *T) { runs++ }},
+	})
+	if !ran || !ok {
+		t.Errorf("runTests returned ran=%v ok=%v, want both true", ran, ok)
+	}
+	if runs != 3 {
+		t.Errorf("test ran %d times with -test.count=3, want 3", runs)
+	}
+}
+
+func TestFailFast(t *T) {
+	defer setFakeTestFlags()()
+	flagFailFast = true
+	flagCount = 2
+
+	// Count a failure like a failing test would, without printing one.
+	var ran []string
+	runTests(fakeMatchString, []InternalTest{
+		{"TestA", func(t *T) {
+			ran = append(ran, "TestA")
+			atomic.AddUint32(&numFailed, 1)
+		}},
+		{"TestB", func(t *T) { ran = append(ran, "TestB") }},
+	})
+	if !reflect.DeepEqual(ran, []string{"TestA"}) {
+		t.Errorf("ran %v with -test.failfast, want only the first run of TestA", ran)
+	}
+}
+
+func TestShuffle(t *T) {
+	newTests := func() []InternalTest {
+		var tests []InternalTest
+		for _, name := range []string{"A", "B", "C", "D", "E", "F", "G", "H"} {
+			tests = append(tests, InternalTest{Name: name})
+		}
+		return tests
+	}
+	names := func(tests []InternalTest) (names []string) {
+		for _, test := range tests {
+			names = append(names, test.Name)
+		}
+		return names
+	}
+
+	// The same seed must result in the same order.
+	order1 := newTests()
+	shuffleTests(1, order1, nil)
+	order2 := newTests()
+	shuffleTests(1, order2, nil)
+	if !reflect.DeepEqual(names(order1), names(order2)) {
+		t.Errorf("same seed resulted in different orders: %v and %v", names(order1), names(order2))
+	}
+
+	// It must still be the same tests, and some seed must change the order.
+	changed := false
+	for seed := int64(1); seed <= 10; seed++ {
+		tests := newTests()
+		shuffleTests(seed, tests, nil)
+		seen := make(map[string]bool)
+		for _, name := range names(tests) {
+			seen[name] = true
+		}
+		if len(seen) != len(tests) {
+			t.Errorf("seed %d lost tests: %v", seed, names(tests))
+		}
+		changed = changed || !reflect.DeepEqual(names(tests), names(newTests()))
+	}
+	if !changed {
+		t.Error("shuffling didn't change the order of the tests")
+	}
+}
+
+func TestList(t *T) {
+	defer setFakeTestFlags()()
+	flagListRegexp = "Foo"
+	flagSkipRegexp = "FooSkip"
+
+	tests := []InternalTest{{Name: "TestFoo"}, {Name: "TestFooSkip"}, {Name: "TestBar"}}
+	benchmarks := []InternalBenchmark{{Name: "BenchmarkFoo"}, {Name: "BenchmarkBar"}}
+	fuzzTargets := []InternalFuzzTarget{{Name: "FuzzFoo"}}
+	var buf bytes.Buffer
+	listTests(&buf, fakeMatchString, tests, benchmarks, fuzzTargets)
+	const expected = "TestFoo\nBenchmarkFoo\nFuzzFoo\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output of -test.list=Foo -test.skip=FooSkip:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
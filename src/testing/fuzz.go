@@ -2,11 +2,33 @@ package testing
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Fuzzing flags.
+var (
+	flagFuzz             string
+	fuzzDuration         durationOrCountFlag
+	fuzzMinimizeDuration = durationOrCountFlag{d: 60 * time.Second, allowZero: true}
+	fuzzCacheDir         string
+	fuzzWorkDir          string
+)
+
+// initFuzzFlags registers the fuzzing flags. It is called from Init.
+func initFuzzFlags() {
+	flag.StringVar(&flagFuzz, "test.fuzz", "", "run the fuzz test matching `regexp`")
+	flag.Var(&fuzzDuration, "test.fuzztime", "time to spend fuzzing; default is to run indefinitely")
+	flag.Var(&fuzzMinimizeDuration, "test.fuzzminimizetime", "time to spend minimizing a value after finding a failing input")
+	flag.StringVar(&fuzzCacheDir, "test.fuzzcachedir", "", "directory where interesting fuzzing inputs are stored")
+	flag.StringVar(&fuzzWorkDir, "test.fuzzworkdir", "", "directory where a crashing input is stored, to be minimized in a new process (used by tinygo test)")
+}
+
 // InternalFuzzTarget is an internal type but exported because it is
 // cross-package; it is part of the implementation of the "go test" command.
 type InternalFuzzTarget struct {
@@ -30,7 +52,8 @@ type InternalFuzzTarget struct {
 // executing the fuzz target, only (*T) methods can be used. The only *F methods
 // that are allowed in the (*F).Fuzz function are (*F).Failed and (*F).Name.
 type F struct {
-	common
+	*common
+	t           *T
 	fuzzContext *fuzzContext
 	testContext *testContext
 
@@ -102,9 +125,11 @@ var supportedTypes = map[reflect.Type]bool{
 //
 //	f.Fuzz(func(t *testing.T, b []byte, i int) { ... })
 //
-// The following types are allowed: []byte, string, bool, byte, rune, float32,
-// float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64.
-// More types may be supported in the future.
+// TinyGo doesn't support reflect.Value.Call, so only the following signatures
+// are supported (after the *T argument): ([]byte), (string), (bool), (byte),
+// (rune), (int), (int64), (uint32), (uint64), (float64), ([]byte, []byte),
+// (string, string), ([]byte, int), (string, int), ([]byte, bool),
+// (string, bool) and (int, int).
 //
 // ff must not call any *F methods, e.g. (*F).Log, (*F).Error, (*F).Skip. Use
 // the corresponding *T method instead. The only *F methods that are allowed in
@@ -120,11 +145,204 @@ var supportedTypes = map[reflect.Type]bool{
 // (set with -fuzztime), or the test process is interrupted by a signal. F.Fuzz
 // should be called exactly once, unless F.Skip or F.Fail is called beforehand.
 func (f *F) Fuzz(ff interface{}) {
-	f.failed = true
-	f.result.N = 0
-	f.result.T = 0
-	f.result.Error = errors.New("operation not implemented")
-	return
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+	if f.Failed() || f.Skipped() {
+		return
+	}
+	fn, ok := newFuzzFunc(ff)
+	if !ok {
+		f.Fatalf("testing: F.Fuzz function has an unsupported signature %T (see the F.Fuzz documentation)", ff)
+		return
+	}
+
+	// Collect the seed corpus: the values passed to F.Add and the files in
+	// testdata/fuzz/FuzzTestName.
+	for _, entry := range f.corpus {
+		if err := checkCorpus(entry.Values, fn.types); err != nil {
+			f.Fatal(err)
+			return
+		}
+	}
+	testdata, err := readCorpus(filepath.Join(corpusDir, f.name), fn.types)
+	if err != nil {
+		f.Fatal(err)
+		return
+	}
+	corpus := append(f.corpus[:len(f.corpus):len(f.corpus)], testdata...)
+
+	f.inFuzzFn = true
+	defer func() {
+		f.inFuzzFn = false
+	}()
+	if f.fuzzContext.mode == fuzzCoordinator {
+		f.fuzz(fn, corpus)
+		return
+	}
+
+	// Run each seed corpus entry as a subtest, which can be selected with
+	// -run=FuzzTestName/seed#0 for example.
+	for _, entry := range corpus {
+		values := entry.Values
+		f.t.Run(filepath.Base(entry.Path), func(t *T) {
+			fn.call(t, values)
+		})
+	}
+}
+
+// fuzzFunc is a fuzz function with the types of its arguments (without the
+// *T). Because reflect.Value.Call isn't supported, the call function converts
+// the values to the right types instead.
+type fuzzFunc struct {
+	types []reflect.Type
+	call  func(t *T, values []interface{})
+}
+
+// newFuzzFunc returns the fuzzFunc for the function passed to F.Fuzz, or false
+// if the signature isn't supported.
+func newFuzzFunc(ff interface{}) (fuzzFunc, bool) {
+	switch ff := ff.(type) {
+	case func(*T, []byte):
+		return fuzzFunc{typesOf([]byte(nil)), func(t *T, v []interface{}) { ff(t, v[0].([]byte)) }}, true
+	case func(*T, string):
+		return fuzzFunc{typesOf(""), func(t *T, v []interface{}) { ff(t, v[0].(string)) }}, true
+	case func(*T, bool):
+		return fuzzFunc{typesOf(false), func(t *T, v []interface{}) { ff(t, v[0].(bool)) }}, true
+	case func(*T, byte):
+		return fuzzFunc{typesOf(byte(0)), func(t *T, v []interface{}) { ff(t, v[0].(byte)) }}, true
+	case func(*T, rune):
+		return fuzzFunc{typesOf(rune(0)), func(t *T, v []interface{}) { ff(t, v[0].(rune)) }}, true
+	case func(*T, int):
+		return fuzzFunc{typesOf(0), func(t *T, v []interface{}) { ff(t, v[0].(int)) }}, true
+	case func(*T, int64):
+		return fuzzFunc{typesOf(int64(0)), func(t *T, v []interface{}) { ff(t, v[0].(int64)) }}, true
+	case func(*T, uint32):
+		return fuzzFunc{typesOf(uint32(0)), func(t *T, v []interface{}) { ff(t, v[0].(uint32)) }}, true
+	case func(*T, uint64):
+		return fuzzFunc{typesOf(uint64(0)), func(t *T, v []interface{}) { ff(t, v[0].(uint64)) }}, true
+	case func(*T, float64):
+		return fuzzFunc{typesOf(float64(0)), func(t *T, v []interface{}) { ff(t, v[0].(float64)) }}, true
+	case func(*T, []byte, []byte):
+		return fuzzFunc{typesOf([]byte(nil), []byte(nil)), func(t *T, v []interface{}) { ff(t, v[0].([]byte), v[1].([]byte)) }}, true
+	case func(*T, string, string):
+		return fuzzFunc{typesOf("", ""), func(t *T, v []interface{}) { ff(t, v[0].(string), v[1].(string)) }}, true
+	case func(*T, []byte, int):
+		return fuzzFunc{typesOf([]byte(nil), 0), func(t *T, v []interface{}) { ff(t, v[0].([]byte), v[1].(int)) }}, true
+	case func(*T, string, int):
+		return fuzzFunc{typesOf("", 0), func(t *T, v []interface{}) { ff(t, v[0].(string), v[1].(int)) }}, true
+	case func(*T, []byte, bool):
+		return fuzzFunc{typesOf([]byte(nil), false), func(t *T, v []interface{}) { ff(t, v[0].([]byte), v[1].(bool)) }}, true
+	case func(*T, string, bool):
+		return fuzzFunc{typesOf("", false), func(t *T, v []interface{}) { ff(t, v[0].(string), v[1].(bool)) }}, true
+	case func(*T, int, int):
+		return fuzzFunc{typesOf(0, 0), func(t *T, v []interface{}) { ff(t, v[0].(int), v[1].(int)) }}, true
+	}
+	return fuzzFunc{}, false
+}
+
+// typesOf returns the types of the given values.
+func typesOf(values ...interface{}) []reflect.Type {
+	types := make([]reflect.Type, len(values))
+	for i, value := range values {
+		types[i] = reflect.TypeOf(value)
+	}
+	return types
+}
+
+// zeroValues returns the zero value for each argument of the fuzz function,
+// which is used as the starting point when there is no seed corpus.
+func (fn fuzzFunc) zeroValues() []interface{} {
+	values := make([]interface{}, len(fn.types))
+	for i, typ := range fn.types {
+		switch typ {
+		case reflect.TypeOf([]byte(nil)):
+			values[i] = []byte{}
+		case reflect.TypeOf(""):
+			values[i] = ""
+		case reflect.TypeOf(false):
+			values[i] = false
+		case reflect.TypeOf(byte(0)):
+			values[i] = byte(0)
+		case reflect.TypeOf(rune(0)):
+			values[i] = rune(0)
+		case reflect.TypeOf(0):
+			values[i] = 0
+		case reflect.TypeOf(int64(0)):
+			values[i] = int64(0)
+		case reflect.TypeOf(uint32(0)):
+			values[i] = uint32(0)
+		case reflect.TypeOf(uint64(0)):
+			values[i] = uint64(0)
+		case reflect.TypeOf(float64(0)):
+			values[i] = float64(0)
+		}
+	}
+	return values
+}
+
+// fuzzTargetTest returns the test that runs a fuzz test, either with only its
+// seed corpus or (when mode is fuzzCoordinator) with the fuzzing engine.
+func fuzzTargetTest(target InternalFuzzTarget, mode fuzzMode) InternalTest {
+	return InternalTest{
+		Name: target.Name,
+		F: func(t *T) {
+			f := &F{
+				common:      &t.common,
+				t:           t,
+				fuzzContext: &fuzzContext{mode: mode},
+				testContext: t.context,
+			}
+			target.Fn(f)
+		},
+	}
+}
+
+// durationOrCountFlag is a flag like -test.fuzztime, which is either a
+// duration or a number of executions (like 1000x).
+type durationOrCountFlag struct {
+	d         time.Duration
+	n         int64
+	allowZero bool
+}
+
+func (f *durationOrCountFlag) String() string {
+	if f.n > 0 {
+		return fmt.Sprintf("%dx", f.n)
+	}
+	return f.d.String()
+}
+
+func (f *durationOrCountFlag) Set(s string) error {
+	if strings.HasSuffix(s, "x") {
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 0)
+		if err != nil || n < 0 || (!f.allowZero && n == 0) {
+			return errors.New("invalid count")
+		}
+		*f = durationOrCountFlag{n: n, allowZero: f.allowZero}
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 || (!f.allowZero && d == 0) {
+		return errors.New("invalid duration")
+	}
+	*f = durationOrCountFlag{d: d, allowZero: f.allowZero}
+	return nil
+}
+
+// done returns whether the limit has been reached, given the start time and
+// the number of executions so far. A zero limit means no limit.
+func (f *durationOrCountFlag) done(start time.Time, execs int64) bool {
+	if f.n > 0 {
+		return execs >= f.n
+	}
+	return f.d > 0 && time.Since(start) >= f.d
+}
+
+// disabled returns whether the flag was explicitly set to zero.
+func (f *durationOrCountFlag) disabled() bool {
+	return f.allowZero && f.n == 0 && f.d == 0
 }
 
 // fuzzContext holds fields common to all fuzz tests.
@@ -135,6 +353,11 @@ type fuzzContext struct {
 
 type fuzzMode uint8
 
+const (
+	seedCorpusOnly fuzzMode = iota
+	fuzzCoordinator
+)
+
 // fuzzResult contains the results of a fuzz run.
 type fuzzResult struct {
 	N     int           // The number of iterations.
@@ -0,0 +1,299 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file reads corpus files in the same format as the go tool,
+// so that testdata/fuzz directories can be shared between go test and tinygo
+// test. A corpus file looks like this:
+//
+//	go test fuzz v1
+//	[]byte("hello")
+//	int(5)
+//
+// The go tool parses these files with go/parser. That's a rather big
+// dependency for a test binary, so this file contains a small hand-written
+// parser instead that accepts the same (limited) set of expressions.
+
+// corpusFileHeader is the first line of every corpus file.
+const corpusFileHeader = "go test fuzz v1"
+
+// corpusDir is the directory (relative to the package directory) where failing
+// inputs are stored, in a subdirectory per fuzz test.
+const corpusDir = "testdata/fuzz"
+
+// marshalCorpusFile encodes the given values in the corpus file format.
+func marshalCorpusFile(values ...interface{}) []byte {
+	b := bytes.NewBufferString(corpusFileHeader + "\n")
+	for _, value := range values {
+		switch v := value.(type) {
+		case []byte:
+			fmt.Fprintf(b, "[]byte(%q)\n", v)
+		case string:
+			fmt.Fprintf(b, "string(%q)\n", v)
+		case bool:
+			fmt.Fprintf(b, "bool(%v)\n", v)
+		case byte:
+			fmt.Fprintf(b, "byte(%q)\n", v)
+		case rune:
+			// Negative numbers, surrogate halves and values above
+			// unicode.MaxRune can't be written as a rune literal.
+			if utf8.ValidRune(v) {
+				fmt.Fprintf(b, "rune(%q)\n", v)
+			} else {
+				fmt.Fprintf(b, "int32(%d)\n", v)
+			}
+		case int:
+			fmt.Fprintf(b, "int(%d)\n", v)
+		case int8:
+			fmt.Fprintf(b, "int8(%d)\n", v)
+		case int16:
+			fmt.Fprintf(b, "int16(%d)\n", v)
+		case int64:
+			fmt.Fprintf(b, "int64(%d)\n", v)
+		case uint:
+			fmt.Fprintf(b, "uint(%d)\n", v)
+		case uint16:
+			fmt.Fprintf(b, "uint16(%d)\n", v)
+		case uint32:
+			fmt.Fprintf(b, "uint32(%d)\n", v)
+		case uint64:
+			fmt.Fprintf(b, "uint64(%d)\n", v)
+		case float32:
+			if math.IsNaN(float64(v)) {
+				// Keep the exact NaN bits.
+				fmt.Fprintf(b, "math.Float32frombits(0x%x)\n", math.Float32bits(v))
+			} else {
+				fmt.Fprintf(b, "float32(%v)\n", v)
+			}
+		case float64:
+			if math.IsNaN(v) {
+				fmt.Fprintf(b, "math.Float64frombits(0x%x)\n", math.Float64bits(v))
+			} else {
+				fmt.Fprintf(b, "float64(%v)\n", v)
+			}
+		default:
+			panic("testing: unsupported type in corpus entry")
+		}
+	}
+	return b.Bytes()
+}
+
+// unmarshalCorpusFile decodes a corpus file created by marshalCorpusFile (or
+// by the go tool).
+func unmarshalCorpusFile(data []byte) ([]interface{}, error) {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) < 2 {
+		return nil, errors.New("must include version and at least one value")
+	}
+	if version := strings.TrimSpace(string(lines[0])); version != corpusFileHeader {
+		return nil, fmt.Errorf("unknown encoding version: %s", version)
+	}
+	var values []interface{}
+	for _, line := range lines[1:] {
+		line := strings.TrimSpace(string(line))
+		if line == "" {
+			continue
+		}
+		value, err := parseCorpusValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("malformed line %q: %v", line, err)
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("must include version and at least one value")
+	}
+	return values, nil
+}
+
+// parseCorpusValue parses a single line of a corpus file, which is a
+// conversion like int(5) or a call like math.Float64frombits(0x7ff8000000000001).
+func parseCorpusValue(line string) (interface{}, error) {
+	open := strings.IndexByte(line, '(')
+	if open <= 0 || line[len(line)-1] != ')' {
+		return nil, errors.New("expected a call or conversion")
+	}
+	typ := line[:open]
+	arg := strings.TrimSpace(line[open+1 : len(line)-1])
+	if arg == "" {
+		return nil, errors.New("expected one argument")
+	}
+
+	switch typ {
+	case "[]byte", "string":
+		s, err := strconv.Unquote(arg)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "string" {
+			return s, nil
+		}
+		return []byte(s), nil
+	case "bool":
+		return strconv.ParseBool(arg)
+	case "math.Float32frombits", "math.Float64frombits":
+		bits, err := strconv.ParseUint(arg, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "math.Float32frombits" {
+			if bits > math.MaxUint32 {
+				return nil, errors.New("value out of range")
+			}
+			return math.Float32frombits(uint32(bits)), nil
+		}
+		return math.Float64frombits(bits), nil
+	case "float32":
+		f, err := strconv.ParseFloat(arg, 32)
+		return float32(f), err
+	case "float64":
+		return strconv.ParseFloat(arg, 64)
+	}
+
+	// The remaining types are all integers, which may be written as a
+	// character literal.
+	var n int64
+	var u uint64
+	if arg[0] == '\'' {
+		if len(arg) < 3 || arg[len(arg)-1] != '\'' {
+			return nil, errors.New("invalid character literal")
+		}
+		r, _, tail, err := strconv.UnquoteChar(arg[1:len(arg)-1], '\'')
+		if err != nil {
+			return nil, err
+		}
+		if tail != "" {
+			return nil, errors.New("invalid character literal")
+		}
+		n, u = int64(r), uint64(r)
+	} else if strings.HasPrefix(typ, "u") || typ == "byte" {
+		var err error
+		u, err = strconv.ParseUint(arg, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		n = int64(u)
+	} else {
+		var err error
+		n, err = strconv.ParseInt(arg, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		u = uint64(n)
+	}
+
+	switch typ {
+	case "int":
+		if int64(int(n)) != n {
+			break
+		}
+		return int(n), nil
+	case "int8":
+		if n < math.MinInt8 || n > math.MaxInt8 {
+			break
+		}
+		return int8(n), nil
+	case "int16":
+		if n < math.MinInt16 || n > math.MaxInt16 {
+			break
+		}
+		return int16(n), nil
+	case "int32", "rune":
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			break
+		}
+		return int32(n), nil
+	case "int64":
+		return n, nil
+	case "uint":
+		if uint64(uint(u)) != u {
+			break
+		}
+		return uint(u), nil
+	case "uint8", "byte":
+		if u > math.MaxUint8 {
+			break
+		}
+		return uint8(u), nil
+	case "uint16":
+		if u > math.MaxUint16 {
+			break
+		}
+		return uint16(u), nil
+	case "uint32":
+		if u > math.MaxUint32 {
+			break
+		}
+		return uint32(u), nil
+	case "uint64":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil, fmt.Errorf("value out of range for %s", typ)
+}
+
+// checkCorpus verifies that the types of the values match the expected types.
+func checkCorpus(values []interface{}, types []reflect.Type) error {
+	if len(values) != len(types) {
+		return fmt.Errorf("wrong number of values in corpus entry: %d, want %d", len(values), len(types))
+	}
+	for i := range types {
+		if reflect.TypeOf(values[i]) != types[i] {
+			return fmt.Errorf("mismatched types in corpus entry: %v, want %v", reflect.TypeOf(values[i]), types[i])
+		}
+	}
+	return nil
+}
+
+// readCorpus reads all corpus files in the given directory, which must match
+// the given types. A directory that doesn't exist is treated as an empty
+// corpus.
+func readCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading seed corpus from testdata: %v", err)
+	}
+	var corpus []corpusEntry
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		values, err := readCorpusFile(path, types)
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, corpusEntry{Path: path, Values: values})
+	}
+	return corpus, nil
+}
+
+// readCorpusFile reads a single corpus file and checks its types.
+func readCorpusFile(path string, types []reflect.Type) ([]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := unmarshalCorpusFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %q: %v", path, err)
+	}
+	if err := checkCorpus(values, types); err != nil {
+		return nil, fmt.Errorf("%q: %v", path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,471 @@
+//go:build tinygo.fuzz
+// +build tinygo.fuzz
+
+package testing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// This file contains the fuzzing engine. Unlike the go tool, which runs a
+// coordinator process and several worker processes, TinyGo fuzzes in the test
+// process itself. Coverage is collected with 8-bit counters inserted by the
+// compiler (see transform.AddFuzzCoverage), which are registered with the
+// engine through __sanitizer_cov_8bit_counters_init like with libFuzzer.
+//
+// Failures that can be recovered from (t.Error etc. and most panics) are
+// minimized in-process. Crashes that abort the process are written to the
+// work directory by a panic hook, after which tinygo test restarts the test
+// binary to minimize the crashing input.
+
+// fuzzCounters are the coverage counters of the instrumented code. It is nil
+// when the test binary wasn't instrumented.
+var fuzzCounters []uint8
+
+// fuzzStatsInterval is how often progress is printed while fuzzing.
+const fuzzStatsInterval = 3 * time.Second
+
+// setPanicHook sets a function that is called when the program aborts because
+// of a panic. It is implemented in the runtime.
+func setPanicHook(hook func())
+
+// fuzzer is the state of the fuzzing engine while fuzzing a single fuzz test.
+type fuzzer struct {
+	f        *F
+	fn       fuzzFunc
+	corpus   []corpusEntry
+	cacheDir string
+	coverage []uint8 // counter buckets that have been seen before, per counter
+	mutator  fuzzMutator
+
+	start       time.Time
+	lastStats   time.Time
+	execs       int64
+	interesting int
+
+	// Input that is currently being run, to be saved by the panic hook.
+	current []interface{}
+	seeding bool // running the seed corpus, which doesn't need to be saved
+}
+
+// fuzz runs the fuzz engine until it finds a failing input or runs out of
+// time. The seed corpus is used as a starting point.
+func (f *F) fuzz(fn fuzzFunc, seed []corpusEntry) {
+	fz := &fuzzer{
+		f:        f,
+		fn:       fn,
+		coverage: make([]uint8, len(fuzzCounters)),
+		mutator:  fuzzMutator{r: rand.New(rand.NewSource(time.Now().UnixNano()))},
+		start:    time.Now(),
+	}
+	if fuzzCacheDir != "" {
+		fz.cacheDir = filepath.Join(fuzzCacheDir, f.name)
+	}
+	setPanicHook(fz.crashed)
+	defer setPanicHook(nil)
+
+	// Continue minimizing an input that crashed the previous test process.
+	if fuzzWorkDir != "" {
+		crasher := filepath.Join(fuzzWorkDir, "crasher")
+		if values, err := readCorpusFile(crasher, fn.types); err == nil {
+			fz.minimizeCrasher(crasher, values)
+			return
+		}
+	}
+
+	if len(fuzzCounters) == 0 {
+		fmt.Println("fuzz: warning: the test binary is not instrumented for fuzzing, inputs are generated without coverage guidance")
+	}
+
+	// Gather the baseline coverage of the seed corpus and the inputs found
+	// in earlier runs.
+	corpus := seed
+	if fz.cacheDir != "" {
+		cached, err := readCorpus(fz.cacheDir, fn.types)
+		if err != nil {
+			fmt.Printf("fuzz: warning: ignoring cached corpus: %v\n", err)
+		}
+		corpus = append(corpus, cached...)
+	}
+	if len(corpus) == 0 {
+		corpus = append(corpus, corpusEntry{Values: fn.zeroValues()})
+	}
+	fmt.Printf("fuzz: elapsed: 0s, gathering baseline coverage: 0/%d completed\n", len(corpus))
+	fz.seeding = true
+	for _, entry := range corpus {
+		failed, output := fz.exec(entry.Values)
+		if failed {
+			// Seed corpus entries are already stored, so there is nothing
+			// to minimize.
+			fz.seeding = false
+			fz.printStats()
+			f.writeFailure(output, 0)
+			f.writeOutput(fmt.Sprintf("\nfailure while testing seed corpus entry: %s/%s\n", f.name, filepath.Base(entry.Path)))
+			return
+		}
+		fz.updateCoverage()
+		fz.corpus = append(fz.corpus, entry)
+	}
+	fz.seeding = false
+	fmt.Printf("fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed, now fuzzing\n", fz.elapsed(), len(corpus), len(corpus))
+	fz.start = time.Now()
+	fz.execs = 0
+	fz.lastStats = fz.start
+
+	// Mutate inputs from the corpus until an input fails.
+	for !fuzzDuration.done(fz.start, fz.execs) {
+		values := append([]interface{}(nil), fz.corpus[fz.mutator.r.Intn(len(fz.corpus))].Values...)
+		fz.mutator.mutate(values, fz.corpus)
+		if failed, _ := fz.exec(values); failed {
+			fz.printStats()
+			fz.reportFailure(fz.minimize(values))
+			return
+		}
+		if fz.updateCoverage() {
+			fz.interesting++
+			fz.corpus = append(fz.corpus, corpusEntry{Values: values})
+			if fz.cacheDir != "" {
+				writeCorpusFile(fz.cacheDir, values)
+			}
+		}
+		if time.Since(fz.lastStats) >= fuzzStatsInterval {
+			fz.printStats()
+		}
+	}
+	fz.printStats()
+}
+
+// exec runs the fuzz function once with the given values. It returns whether
+// the function failed, and the output of the function (including the panic
+// message if it panicked).
+func (fz *fuzzer) exec(values []interface{}) (failed bool, output string) {
+	for i := range fuzzCounters {
+		fuzzCounters[i] = 0
+	}
+	t := &T{
+		common: common{
+			name:    fz.f.name,
+			level:   fz.f.level,
+			barrier: make(chan bool),
+		},
+		context: fz.f.testContext,
+	}
+	fz.current = values
+	panicValue, panicked := callFuzzFunc(fz.fn, t, values)
+	fz.current = nil
+	fz.execs++
+	output = t.output.String()
+	if panicked {
+		output += fmt.Sprintf("    panic: %v\n", panicValue)
+		return true, output
+	}
+	// Skipping isn't a failure, even though SkipNow currently marks the test
+	// as failed.
+	return t.Failed() && !t.Skipped(), output
+}
+
+// callFuzzFunc calls the fuzz function and recovers from a panic, if there is
+// one.
+func callFuzzFunc(fn fuzzFunc, t *T, values []interface{}) (panicValue interface{}, panicked bool) {
+	panicked = true
+	defer func() {
+		if panicked {
+			panicValue = recover()
+		}
+	}()
+	fn.call(t, values)
+	panicked = false
+	return
+}
+
+// updateCoverage records the coverage of the last run, and returns whether it
+// reached new code (or the same code a new number of times).
+func (fz *fuzzer) updateCoverage() bool {
+	newCoverage := false
+	for i, count := range fuzzCounters {
+		if count == 0 {
+			continue
+		}
+		bucket := counterBucket(count)
+		if fz.coverage[i]&bucket == 0 {
+			fz.coverage[i] |= bucket
+			newCoverage = true
+		}
+	}
+	return newCoverage
+}
+
+// counterBucket returns the bit that represents the counter value, like
+// libFuzzer does: small differences in hit counts are ignored.
+func counterBucket(count uint8) uint8 {
+	switch {
+	case count <= 3:
+		return 1 << (count - 1) // 1, 2 or 4
+	case count < 8:
+		return 1 << 3
+	case count < 16:
+		return 1 << 4
+	case count < 32:
+		return 1 << 5
+	case count < 128:
+		return 1 << 6
+	default:
+		return 1 << 7
+	}
+}
+
+// printStats prints the progress of the fuzzer, like the go tool does.
+func (fz *fuzzer) printStats() {
+	fz.lastStats = time.Now()
+	rate := int64(0)
+	if seconds := time.Since(fz.start).Seconds(); seconds > 0 {
+		rate = int64(float64(fz.execs) / seconds)
+	}
+	fmt.Printf("fuzz: elapsed: %s, execs: %d (%d/sec), new interesting: %d (total: %d)\n", fz.elapsed(), fz.execs, rate, fz.interesting, len(fz.corpus))
+}
+
+// elapsed returns the time spent fuzzing, rounded to seconds.
+func (fz *fuzzer) elapsed() time.Duration {
+	return time.Since(fz.start).Round(time.Second)
+}
+
+// minimize tries to make the failing input smaller (within the limit set by
+// -test.fuzzminimizetime) and returns the smallest input that still fails.
+// Only []byte and string values are minimized.
+func (fz *fuzzer) minimize(values []interface{}) []interface{} {
+	if fuzzMinimizeDuration.disabled() {
+		return values
+	}
+	fmt.Printf("fuzz: elapsed: %s, minimizing\n", fz.elapsed())
+	start := time.Now()
+	var execs int64
+	stop := func() bool {
+		return fuzzMinimizeDuration.done(start, execs)
+	}
+	values = append([]interface{}(nil), values...)
+	for i, value := range values {
+		// Each candidate is a new copy, so that it can be stored by the
+		// panic hook.
+		try := func(candidate interface{}) bool {
+			execs++
+			candidateValues := append([]interface{}(nil), values...)
+			candidateValues[i] = candidate
+			failed, _ := fz.exec(candidateValues)
+			return failed
+		}
+		switch value := value.(type) {
+		case []byte:
+			values[i] = minimizeBytes(value, func(b []byte) bool { return try(b) }, stop)
+		case string:
+			values[i] = string(minimizeBytes([]byte(value), func(b []byte) bool { return try(string(b)) }, stop))
+		}
+	}
+	return values
+}
+
+// minimizeBytes returns the smallest version of b for which try still returns
+// true. The slice passed to try is always a new copy.
+func minimizeBytes(b []byte, try func([]byte) bool, stop func() bool) []byte {
+	// First, try to cut the tail.
+	for n := 1024; n != 0; n /= 2 {
+		for len(b) > n {
+			if stop() {
+				return b
+			}
+			candidate := append([]byte(nil), b[:len(b)-n]...)
+			if !try(candidate) {
+				break
+			}
+			b = candidate
+		}
+	}
+
+	// Then, try to remove each individual byte.
+	for i := 0; i < len(b); i++ {
+		if stop() {
+			return b
+		}
+		candidate := append(append([]byte(nil), b[:i]...), b[i+1:]...)
+		if try(candidate) {
+			b = candidate
+			i-- // try the byte that is now at this index
+		}
+	}
+
+	// Finally, make the input more readable by replacing bytes with
+	// printable characters.
+	const printable = "012789ABCXYZabcxyz !\"#$%&'()*+,."
+	for i := range b {
+		if strings.IndexByte(printable, b[i]) >= 0 {
+			continue
+		}
+		for j := 0; j < len(printable); j++ {
+			if stop() {
+				return b
+			}
+			candidate := append([]byte(nil), b...)
+			candidate[i] = printable[j]
+			if try(candidate) {
+				b = candidate
+				break
+			}
+		}
+	}
+	return b
+}
+
+// reportFailure stores the failing input in testdata and reports the failure
+// as part of the fuzz test.
+func (fz *fuzzer) reportFailure(values []interface{}) {
+	f := fz.f
+	start := time.Now()
+	_, output := fz.exec(values)
+	f.writeFailure(output, time.Since(start))
+	name, err := writeCorpusFile(filepath.Join(corpusDir, f.name), values)
+	if err != nil {
+		f.Errorf("could not write failing input: %v", err)
+		return
+	}
+	f.writeOutput(fmt.Sprintf("\nFailing input written to %s\nTo re-run:\ntinygo test -run=%s/%s\n", filepath.Join(corpusDir, f.name, name), f.name, name))
+}
+
+// writeFailure adds the output of a failing run of the fuzz function to the
+// output of the fuzz test, and marks it as failed.
+func (f *F) writeFailure(output string, duration time.Duration) {
+	f.Fail()
+	f.writeOutput(fmt.Sprintf("--- FAIL: %s (%s)\n", f.name, fmtDuration(duration)) + output)
+}
+
+// writeOutput adds the text to the output of the fuzz test, indented like log
+// messages.
+func (f *F) writeOutput(text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "\n" {
+			f.output.WriteString("\n")
+		} else if line != "" {
+			f.output.WriteString(f.indent + "    " + line)
+		}
+	}
+}
+
+// crashed is the panic hook while fuzzing: it is called when an input crashed
+// the test process in a way that can't be recovered from.
+func (fz *fuzzer) crashed() {
+	if fz.current == nil {
+		return
+	}
+	if fz.seeding {
+		fmt.Println("failure while testing seed corpus entry")
+		return
+	}
+	if fuzzWorkDir != "" {
+		// Let tinygo test minimize the input in a new process.
+		os.WriteFile(filepath.Join(fuzzWorkDir, "crasher"), marshalCorpusFile(fz.current...), 0666)
+		return
+	}
+	name, err := writeCorpusFile(filepath.Join(corpusDir, fz.f.name), fz.current)
+	if err != nil {
+		fmt.Println("could not write failing input:", err)
+		return
+	}
+	fmt.Printf("\nFailing input written to %s\nTo re-run:\ntinygo test -run=%s/%s\n", filepath.Join(corpusDir, fz.f.name, name), fz.f.name, name)
+}
+
+// minimizeCrasher continues minimizing an input that crashed an earlier test
+// process. A candidate that crashes too replaces the crasher file (through the
+// panic hook) and ends this process, after which tinygo test starts another
+// one. When done, the input is stored in testdata and run one last time to
+// show the failure.
+func (fz *fuzzer) minimizeCrasher(crasher string, values []interface{}) {
+	f := fz.f
+	minimized := fz.minimize(values)
+	os.Remove(crasher)
+	name, err := writeCorpusFile(filepath.Join(corpusDir, f.name), minimized)
+	if err != nil {
+		f.Errorf("could not write failing input: %v", err)
+		return
+	}
+
+	// The final input may crash the process again, so print where it is
+	// stored before running it.
+	fmt.Printf("Failing input written to %s\nTo re-run:\ntinygo test -run=%s/%s\n", filepath.Join(corpusDir, f.name, name), f.name, name)
+	setPanicHook(nil)
+	start := time.Now()
+	if failed, output := fz.exec(minimized); failed {
+		f.writeFailure(output, time.Since(start))
+	} else {
+		f.Errorf("crashing input %s/%s does not fail anymore", f.name, name)
+	}
+}
+
+// runFuzzing runs the fuzzing engine on the fuzz test that matches -test.fuzz,
+// if there is one. It returns false if fuzzing found a failing input.
+func runFuzzing(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ok bool) {
+	if flagFuzz == "" {
+		return true
+	}
+	if isBaremetal {
+		matchString = fakeMatchString
+	}
+	var matched []InternalFuzzTarget
+	for _, target := range fuzzTargets {
+		match, err := matchString(flagFuzz, target.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testing: invalid regexp for -test.fuzz: %s\n", err)
+			return false
+		}
+		if match {
+			matched = append(matched, target)
+		}
+	}
+	if len(matched) == 0 {
+		fmt.Fprintln(os.Stderr, "testing: warning: no fuzz tests to fuzz")
+		return true
+	}
+	if len(matched) > 1 {
+		var names []string
+		for _, target := range matched {
+			names = append(names, target.Name)
+		}
+		fmt.Fprintf(os.Stderr, "testing: will not fuzz, -fuzz matches more than one fuzz test: %v\n", names)
+		return false
+	}
+
+	ctx := newTestContext(1, newMatcher(matchString, "", "-test.fuzz", ""))
+	t := &T{
+		common: common{
+			barrier: make(chan bool),
+		},
+		context: ctx,
+	}
+	test := fuzzTargetTest(matched[0], fuzzCoordinator)
+	tRunner(t, func(t *T) {
+		t.Run(test.Name, test.F)
+	})
+	return !t.Failed()
+}
+
+// writeCorpusFile writes the values to a new file in the given directory. The
+// file is named after the hash of its contents, like the go tool does. It
+// returns the name of the file (without the directory).
+func writeCorpusFile(dir string, values []interface{}) (string, error) {
+	data := marshalCorpusFile(values...)
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])[:16]
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0666); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,29 @@
+//go:build tinygo.fuzz
+// +build tinygo.fuzz
+
+package testing
+
+// The fuzzing engine is only included in test binaries built with tinygo test
+// -fuzz. Run these tests with tinygo test -tags=tinygo.fuzz testing.
+
+import "bytes"
+
+func TestMinimizeBytes(t *T) {
+	// The input fails if it contains "bug".
+	try := func(b []byte) bool {
+		return bytes.Contains(b, []byte("bug"))
+	}
+	stop := func() bool { return false }
+	input := []byte("some long input with a bug\x00 somewhere in the middle")
+	if got := minimizeBytes(input, try, stop); string(got) != "bug" {
+		t.Errorf("minimizeBytes: got %q, want %q", got, "bug")
+	}
+
+	// Non-printable bytes are replaced if they don't matter.
+	try = func(b []byte) bool {
+		return len(b) == 2 && b[0] == 0xff
+	}
+	if got := minimizeBytes([]byte{0xff, 0x01}, try, stop); string(got) != "\xff0" {
+		t.Errorf("minimizeBytes: got %q, want %q", got, "\xff0")
+	}
+}
@@ -0,0 +1,201 @@
+//go:build tinygo.fuzz
+// +build tinygo.fuzz
+
+package testing
+
+import (
+	"math"
+	"math/rand"
+)
+
+// maxFuzzInputSize is the maximum size of a []byte or string created by the
+// mutator. Inputs from the corpus may be bigger.
+const maxFuzzInputSize = 64 * 1024
+
+// Values that often trigger edge cases, used by the mutator.
+var interestingInts = []int64{
+	-128, -1, 0, 1, 16, 32, 64, 100, 127, // 8-bit
+	-32768, -129, 128, 255, 256, 512, 1000, 1024, 4096, 32767, // 16-bit
+	-2147483648, -100663046, -32769, 32768, 65535, 65536, 100663045, 2147483647, // 32-bit
+}
+
+// fuzzMutator creates new inputs from existing ones. It works on the typed
+// values (like the go tool), not on the encoded corpus file.
+type fuzzMutator struct {
+	r *rand.Rand
+}
+
+// mutate modifies one or more of the values (in place, values must be a copy).
+// Other corpus entries may be used to splice in data from.
+func (m *fuzzMutator) mutate(values []interface{}, corpus []corpusEntry) {
+	// Apply a few mutations at once, which makes it easier to get past
+	// checks that need multiple changes.
+	n := 1 + m.r.Intn(4)
+	for i := 0; i < n; i++ {
+		index := m.r.Intn(len(values))
+		values[index] = m.mutateValue(values[index], index, corpus)
+	}
+}
+
+// mutateValue returns a mutated copy of the value.
+func (m *fuzzMutator) mutateValue(value interface{}, index int, corpus []corpusEntry) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		var other []byte
+		if len(corpus) != 0 {
+			if b, ok := corpus[m.r.Intn(len(corpus))].Values[index].([]byte); ok {
+				other = b
+			}
+		}
+		return m.mutateBytes(append([]byte(nil), v...), other)
+	case string:
+		var other []byte
+		if len(corpus) != 0 {
+			if s, ok := corpus[m.r.Intn(len(corpus))].Values[index].(string); ok {
+				other = []byte(s)
+			}
+		}
+		return string(m.mutateBytes([]byte(v), other))
+	case bool:
+		return !v
+	case byte:
+		return byte(m.mutateInt(int64(v), math.MaxUint8))
+	case rune:
+		return rune(m.mutateInt(int64(v), math.MaxInt32))
+	case int:
+		return int(m.mutateInt(int64(v), math.MaxInt))
+	case int8:
+		return int8(m.mutateInt(int64(v), math.MaxInt8))
+	case int16:
+		return int16(m.mutateInt(int64(v), math.MaxInt16))
+	case int64:
+		return m.mutateInt(v, math.MaxInt64)
+	case uint:
+		return uint(m.mutateUint(uint64(v), math.MaxUint))
+	case uint16:
+		return uint16(m.mutateUint(uint64(v), math.MaxUint16))
+	case uint32:
+		return uint32(m.mutateUint(uint64(v), math.MaxUint32))
+	case uint64:
+		return m.mutateUint(v, math.MaxUint64)
+	case float32:
+		return float32(m.mutateFloat(float64(v), math.MaxFloat32))
+	case float64:
+		return m.mutateFloat(v, math.MaxFloat64)
+	default:
+		panic("testing: unsupported type to mutate")
+	}
+}
+
+// mutateInt returns a new integer value. Overflow is allowed to wrap around,
+// max is used to pick random values of the right size.
+func (m *fuzzMutator) mutateInt(v, max int64) int64 {
+	switch m.r.Intn(5) {
+	case 0:
+		return v + 1 + m.r.Int63n(16)
+	case 1:
+		return v - 1 - m.r.Int63n(16)
+	case 2:
+		return v ^ 1<<uint(m.r.Intn(64))
+	case 3:
+		return interestingInts[m.r.Intn(len(interestingInts))]
+	default:
+		n := m.r.Int63n(max)
+		if m.r.Intn(2) == 0 {
+			n = -n
+		}
+		return n
+	}
+}
+
+// mutateUint is like mutateInt, but for unsigned integers.
+func (m *fuzzMutator) mutateUint(v, max uint64) uint64 {
+	switch m.r.Intn(5) {
+	case 0:
+		return v + 1 + uint64(m.r.Intn(16))
+	case 1:
+		return v - 1 - uint64(m.r.Intn(16))
+	case 2:
+		return v ^ 1<<uint(m.r.Intn(64))
+	case 3:
+		return uint64(interestingInts[m.r.Intn(len(interestingInts))])
+	default:
+		if max > math.MaxInt64 {
+			return m.r.Uint64()
+		}
+		return uint64(m.r.Int63n(int64(max)))
+	}
+}
+
+// mutateFloat returns a new floating point value.
+func (m *fuzzMutator) mutateFloat(v, max float64) float64 {
+	switch m.r.Intn(5) {
+	case 0:
+		return v + float64(1+m.r.Intn(16))
+	case 1:
+		return v - float64(1+m.r.Intn(16))
+	case 2:
+		return v * float64(1+m.r.Intn(16))
+	case 3:
+		if m.r.Intn(2) == 0 {
+			return -v
+		}
+		return v / float64(1+m.r.Intn(16))
+	default:
+		return (m.r.Float64()*2 - 1) * max
+	}
+}
+
+// mutateBytes modifies the byte slice, possibly growing or shrinking it, and
+// returns the result. The other slice (which may be nil) is used to copy data
+// from another input.
+func (m *fuzzMutator) mutateBytes(b, other []byte) []byte {
+	switch op := m.r.Intn(9); {
+	case op == 0 && len(b) > 0:
+		// Remove a range of bytes.
+		start := m.r.Intn(len(b))
+		end := start + 1 + m.r.Intn(len(b)-start)
+		return append(b[:start], b[end:]...)
+	case op <= 1 && len(b) < maxFuzzInputSize:
+		// Insert random bytes.
+		n := 1 + m.r.Intn(8)
+		pos := m.r.Intn(len(b) + 1)
+		insert := make([]byte, n)
+		m.r.Read(insert)
+		return append(b[:pos], append(insert, b[pos:]...)...)
+	case op == 2 && len(b) > 0 && len(b) < maxFuzzInputSize:
+		// Duplicate a range of bytes.
+		start := m.r.Intn(len(b))
+		end := start + 1 + m.r.Intn(len(b)-start)
+		pos := m.r.Intn(len(b) + 1)
+		chunk := append([]byte(nil), b[start:end]...)
+		return append(b[:pos], append(chunk, b[pos:]...)...)
+	case op == 3 && len(other) > 0 && len(b) < maxFuzzInputSize:
+		// Splice in a part of another input.
+		start := m.r.Intn(len(other))
+		end := start + 1 + m.r.Intn(len(other)-start)
+		pos := m.r.Intn(len(b) + 1)
+		chunk := append([]byte(nil), other[start:end]...)
+		return append(b[:pos], append(chunk, b[pos:]...)...)
+	case len(b) == 0:
+		// All operations below need at least one byte.
+		return append(b, byte(m.r.Intn(256)))
+	case op == 4:
+		// Flip a bit.
+		b[m.r.Intn(len(b))] ^= 1 << uint(m.r.Intn(8))
+	case op == 5:
+		// Set a byte to a random value.
+		b[m.r.Intn(len(b))] = byte(m.r.Intn(256))
+	case op == 6:
+		// Set a byte to an interesting value.
+		b[m.r.Intn(len(b))] = byte(interestingInts[m.r.Intn(9)])
+	case op == 7:
+		// Add or subtract a small value.
+		b[m.r.Intn(len(b))] += byte(m.r.Intn(35) - 17)
+	default:
+		// Swap two bytes.
+		i, j := m.r.Intn(len(b)), m.r.Intn(len(b))
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,29 @@
+//go:build !tinygo.fuzz
+// +build !tinygo.fuzz
+
+package testing
+
+import (
+	"fmt"
+	"os"
+)
+
+// Test binaries that aren't built with tinygo test -fuzz don't include the
+// fuzzing engine (see fuzz_engine.go). They only run the seed corpus of fuzz
+// tests.
+
+// runFuzzing reports an error if fuzzing was requested, as this test binary
+// can't do it.
+func runFuzzing(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ok bool) {
+	if flagFuzz == "" {
+		return true
+	}
+	fmt.Fprintln(os.Stderr, "testing: -test.fuzz is only supported in test binaries built with tinygo test -fuzz")
+	return false
+}
+
+// fuzz is only called for fuzz tests started by runFuzzing, which never
+// happens in this test binary.
+func (f *F) fuzz(fn fuzzFunc, seed []corpusEntry) {
+	panic("testing: fuzzing engine not included")
+}
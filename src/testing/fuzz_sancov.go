@@ -0,0 +1,17 @@
+//go:build tinygo.fuzz && linux && !baremetal && !wasi
+// +build tinygo.fuzz,linux,!baremetal,!wasi
+
+package testing
+
+import "unsafe"
+
+// sanitizerCov8bitCountersInit is called by the module constructor that is
+// added to instrumented test binaries (tinygo test -fuzz), with the range of
+// coverage counters. This is the same interface as used by libFuzzer.
+//
+// This is called before the runtime is initialized, so it must not allocate.
+//
+//export __sanitizer_cov_8bit_counters_init
+func sanitizerCov8bitCountersInit(start, end *uint8) {
+	fuzzCounters = unsafe.Slice(start, uintptr(unsafe.Pointer(end))-uintptr(unsafe.Pointer(start)))
+}
@@ -0,0 +1,99 @@
+package testing
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+)
+
+func TestCorpusFileRoundTrip(t *T) {
+	values := []interface{}{
+		[]byte("hello\x00\xff"),
+		"world\n",
+		true,
+		byte('a'),
+		byte(0x80),
+		rune('☺'),
+		rune(-1),
+		int(-5),
+		int8(math.MinInt8),
+		int16(1234),
+		int64(math.MaxInt64),
+		uint(7),
+		uint16(math.MaxUint16),
+		uint32(42),
+		uint64(math.MaxUint64),
+		float32(1.5),
+		float64(-0.25),
+		math.Inf(1),
+	}
+	data := marshalCorpusFile(values...)
+	decoded, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatalf("could not unmarshal corpus file: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(values, decoded) {
+		t.Errorf("corpus file did not round-trip:\n%s\ngot: %#v", data, decoded)
+	}
+
+	// NaN values must keep their exact bits.
+	nan := math.Float64frombits(0x7ff8000000000001)
+	decoded, err = unmarshalCorpusFile(marshalCorpusFile(nan))
+	if err != nil {
+		t.Fatalf("could not unmarshal NaN: %v", err)
+	}
+	if bits := math.Float64bits(decoded[0].(float64)); bits != 0x7ff8000000000001 {
+		t.Errorf("NaN did not round-trip: got %#x", bits)
+	}
+}
+
+func TestUnmarshalCorpusFile(t *T) {
+	for _, tc := range []struct {
+		data string
+		want []interface{}
+		ok   bool
+	}{
+		{"go test fuzz v1\nint(5)\n", []interface{}{5}, true},
+		{"go test fuzz v1\n[]byte(`raw`)\nbyte('\\x00')\n", []interface{}{[]byte("raw"), byte(0)}, true},
+		{"go test fuzz v1\nuint8(0x10)\nint32(-3)\n", []interface{}{uint8(16), int32(-3)}, true},
+		{"go test fuzz v1\nmath.Float32frombits(0x7fc00001)\n", []interface{}{math.Float32frombits(0x7fc00001)}, true},
+		{"go test fuzz v1\n", nil, false},
+		{"go test fuzz v2\nint(5)\n", nil, false},
+		{"go test fuzz v1\nint8(300)\n", nil, false},
+		{"go test fuzz v1\ncomplex128(1)\n", nil, false},
+		{"go test fuzz v1\nstring(foo)\n", nil, false},
+	} {
+		got, err := unmarshalCorpusFile([]byte(tc.data))
+		if (err == nil) != tc.ok {
+			t.Errorf("unmarshal %q: unexpected error result: %v", tc.data, err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("unmarshal %q: got %#v, want %#v", tc.data, got, tc.want)
+			continue
+		}
+		for i := range got {
+			// Compare the encoding, as NaN doesn't equal itself.
+			if !bytes.Equal(marshalCorpusFile(got[i]), marshalCorpusFile(tc.want[i])) {
+				t.Errorf("unmarshal %q: got %#v, want %#v", tc.data, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestDurationOrCountFlag(t *T) {
+	var f durationOrCountFlag
+	if err := f.Set("100x"); err != nil || f.n != 100 {
+		t.Errorf("Set(100x): got %v, %v", f, err)
+	}
+	if err := f.Set("0x"); err == nil {
+		t.Errorf("Set(0x): expected an error")
+	}
+	f = durationOrCountFlag{allowZero: true}
+	if err := f.Set("0s"); err != nil || !f.disabled() {
+		t.Errorf("Set(0s) with allowZero: got %v, %v", f, err)
+	}
+}
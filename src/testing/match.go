@@ -15,6 +15,7 @@ import (
 // matcher sanitizes, uniques, and filters names of subtests and subbenchmarks.
 type matcher struct {
 	filter    []string
+	skip      []string
 	matchFunc func(pat, str string) (bool, error)
 
 	mu       sync.Mutex
@@ -25,33 +26,42 @@ type matcher struct {
 // eliminate this Mutex.
 var matchMutex sync.Mutex
 
-func newMatcher(matchString func(pat, str string) (bool, error), patterns, name string) *matcher {
+func newMatcher(matchString func(pat, str string) (bool, error), patterns, name, skips string) *matcher {
 	if isBaremetal {
 		// Probably not enough ram to load regexp, substitute something simpler.
 		matchString = fakeMatchString
 	}
 
-	var filter []string
-	if patterns != "" {
-		filter = splitRegexp(patterns)
-		for i, s := range filter {
-			filter[i] = rewrite(s)
-		}
-		// Verify filters before doing any processing.
-		for i, s := range filter {
-			if _, err := matchString(s, "non-empty"); err != nil {
-				fmt.Fprintf(os.Stderr, "testing: invalid regexp for element %d of %s (%q): %s\n", i, name, s, err)
-				os.Exit(1)
-			}
-		}
-	}
+	filter := parseFilter(matchString, patterns, name)
+	skip := parseFilter(matchString, skips, "-test.skip")
 	return &matcher{
 		filter:    filter,
+		skip:      skip,
 		matchFunc: matchString,
 		subNames:  map[string]int64{},
 	}
 }
 
+// parseFilter splits the given pattern into a regexp per level of subtests,
+// and exits when one of them is invalid.
+func parseFilter(matchString func(pat, str string) (bool, error), patterns, name string) []string {
+	if patterns == "" {
+		return nil
+	}
+	filter := splitRegexp(patterns)
+	for i, s := range filter {
+		filter[i] = rewrite(s)
+	}
+	// Verify filters before doing any processing.
+	for i, s := range filter {
+		if _, err := matchString(s, "non-empty"); err != nil {
+			fmt.Fprintf(os.Stderr, "testing: invalid regexp for element %d of %s (%q): %s\n", i, name, s, err)
+			os.Exit(1)
+		}
+	}
+	return filter
+}
+
 func (m *matcher) fullName(c *common, subname string) (name string, ok, partial bool) {
 	name = subname
 
@@ -68,6 +78,9 @@ func (m *matcher) fullName(c *common, subname string) (name string, ok, partial
 	// We check the full array of paths each time to allow for the case that
 	// a pattern contains a '/'.
 	elem := strings.Split(name, "/")
+	if m.skipped(elem) {
+		return name, false, false
+	}
 	for i, s := range elem {
 		if i >= len(m.filter) {
 			break
@@ -79,6 +92,22 @@ func (m *matcher) fullName(c *common, subname string) (name string, ok, partial
 	return name, true, len(elem) < len(m.filter)
 }
 
+// skipped returns whether the test with the given name (split into its levels
+// of subtests) matches -test.skip. Unlike the -test.run filter, all levels of
+// the skip pattern must match: -test.skip=TestFoo/bar does not skip TestFoo
+// itself, only its subtest.
+func (m *matcher) skipped(elem []string) bool {
+	if len(m.skip) == 0 || len(elem) < len(m.skip) {
+		return false
+	}
+	for i, s := range m.skip {
+		if ok, _ := m.matchFunc(s, elem[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func splitRegexp(s string) []string {
 	a := make([]string, 0, strings.Count(s, "/"))
 	cs := 0
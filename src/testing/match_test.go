@@ -129,7 +129,7 @@ func TestMatcher(t *T) {
 	}
 
 	for _, tc := range testCases {
-		m := newMatcher(regexp.MatchString, tc.pattern, "-test.run")
+		m := newMatcher(regexp.MatchString, tc.pattern, "-test.run", "")
 
 		parent := &common{name: tc.parent}
 		if tc.parent != "" {
@@ -142,8 +142,39 @@ func TestMatcher(t *T) {
 	}
 }
 
+func TestMatcherSkip(t *T) {
+	testCases := []struct {
+		pattern, skip string
+		parent, sub   string
+		ok            bool
+	}{
+		{"", "TestBar", "", "TestFoo", true},
+		{"", "TestFoo", "", "TestFoo", false},
+		{"", "TestFoo", "TestFoo", "x", false},
+		{"", "TestFoo/x", "", "TestFoo", true},
+		{"", "TestFoo/x", "TestFoo", "x", false},
+		{"", "TestFoo/x", "TestFoo", "y", true},
+		{"", "/x", "TestBar", "x", false},
+		{"TestFoo", "TestFoo", "", "TestFoo", false},
+		{"TestFoo", "TestBar", "", "TestFoo", true},
+	}
+
+	for _, tc := range testCases {
+		m := newMatcher(regexp.MatchString, tc.pattern, "-test.run", tc.skip)
+
+		parent := &common{name: tc.parent}
+		if tc.parent != "" {
+			parent.level = 1
+		}
+		if n, ok, _ := m.fullName(parent, tc.sub); ok != tc.ok {
+			t.Errorf("for pattern %q and skip %q, fullName(parent=%q, sub=%q) = %q, ok %v; want ok %v",
+				tc.pattern, tc.skip, tc.parent, tc.sub, n, ok, tc.ok)
+		}
+	}
+}
+
 func TestNaming(t *T) {
-	m := newMatcher(regexp.MatchString, "", "")
+	m := newMatcher(regexp.MatchString, "", "", "")
 
 	parent := &common{name: "x", level: 1} // top-level test.
 
@@ -0,0 +1,69 @@
+//go:build !scheduler.none
+// +build !scheduler.none
+
+package testing
+
+import (
+	"fmt"
+	"time"
+)
+
+// runTest runs the test in a new goroutine and waits until it either finished
+// or called Parallel.
+func runTest(t *T, fn func(t *T)) {
+	go tRunner(t, fn)
+	<-t.signal
+}
+
+// Parallel signals that this test is to be run in parallel with (and only with)
+// other parallel tests. When a test is run multiple times due to use of
+// -test.count or -test.cpu, multiple instances of a single test never run in
+// parallel with each other.
+func (t *T) Parallel() {
+	if t.isParallel {
+		panic("testing: t.Parallel called multiple times")
+	}
+	t.isParallel = true
+	if t.parent == nil {
+		// Not a test started with Run (like the fake top-level test).
+		return
+	}
+
+	// We don't want to include the time we spend waiting for serial tests
+	// in the test duration. Record the elapsed time thus far and reset the
+	// timer afterwards.
+	t.duration += time.Since(t.start)
+
+	// Add to the list of tests to be released by the parent.
+	t.parent.mu.Lock()
+	t.parent.sub = append(t.parent.sub, t)
+	if flagVerbose {
+		fmt.Fprintf(&t.parent.output, "=== PAUSE %s\n", t.name)
+	}
+	t.parent.mu.Unlock()
+
+	t.signal <- true   // Release calling test.
+	<-t.parent.barrier // Wait for the parent test to complete.
+	t.context.waitParallel()
+
+	if flagVerbose {
+		t.parent.mu.Lock()
+		fmt.Fprintf(&t.parent.output, "=== CONT  %s\n", t.name)
+		t.parent.mu.Unlock()
+	}
+	t.start = time.Now()
+}
+
+// startAlarm starts an alarm that makes the test binary panic after the given
+// duration (if it is not zero). It returns a function to stop the alarm.
+func startAlarm(d time.Duration) (stop func()) {
+	if d <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(d, func() {
+		panic(fmt.Sprintf("test timed out after %v", d))
+	})
+	return func() {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,28 @@
+//go:build scheduler.none
+// +build scheduler.none
+
+package testing
+
+import "time"
+
+// runTest runs the test. Without a scheduler goroutines can't be started, so
+// tests are always run sequentially.
+func runTest(t *T, fn func(t *T)) {
+	tRunner(t, fn)
+}
+
+// Parallel signals that this test is to be run in parallel with (and only with)
+// other parallel tests. Without a scheduler this has no effect: the test simply
+// continues to run.
+func (t *T) Parallel() {
+	if t.isParallel {
+		panic("testing: t.Parallel called multiple times")
+	}
+	t.isParallel = true
+}
+
+// startAlarm is a no-op: timers need a scheduler. The test may still be stopped
+// after a timeout by tinygo test when it runs in an emulator.
+func startAlarm(d time.Duration) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,118 @@
+//go:build !scheduler.none
+// +build !scheduler.none
+
+package testing
+
+import (
+	"sync"
+	"time"
+)
+
+// runParallelTests runs the given tests like runTests does, with at most
+// maxParallel tests running in parallel. It returns the context of the tests.
+func runParallelTests(maxParallel int, tests []InternalTest) *testContext {
+	defer setFakeTestFlags()()
+	ctx := newTestContext(maxParallel, newMatcher(fakeMatchString, "", "-test.run", ""))
+	t := &T{
+		common: common{
+			barrier: make(chan bool),
+		},
+		context: ctx,
+	}
+	tRunner(t, func(t *T) {
+		for _, test := range tests {
+			t.Run(test.Name, test.F)
+		}
+	})
+	return ctx
+}
+
+// parallelCounter counts how many tests are running at the same time.
+type parallelCounter struct {
+	mu         sync.Mutex
+	runs       int
+	running    int
+	maxRunning int
+}
+
+// run marks a test as running for a short time.
+func (c *parallelCounter) run() {
+	c.mu.Lock()
+	c.runs++
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+}
+
+func TestParallelLimit(t *T) {
+	counter := &parallelCounter{}
+	var tests []InternalTest
+	for _, name := range []string{"TestA", "TestB", "TestC", "TestD", "TestE"} {
+		tests = append(tests, InternalTest{name, func(t *T) {
+			t.Parallel()
+			counter.run()
+		}})
+	}
+	ctx := runParallelTests(2, tests)
+	if counter.runs != 5 {
+		t.Errorf("%d tests ran, want 5", counter.runs)
+	}
+	if counter.maxRunning != 2 {
+		t.Errorf("%d tests ran in parallel with -test.parallel=2, want 2", counter.maxRunning)
+	}
+	if ctx.running != 1 {
+		t.Errorf("%d tests running after all tests finished, want 1 (the main test)", ctx.running)
+	}
+}
+
+func TestParallelSubtests(t *T) {
+	// Parallel tests with parallel subtests: the parent test releases its slot
+	// while waiting for its subtests, which must not happen twice.
+	counter := &parallelCounter{}
+	var mu sync.Mutex
+	subtestsDone := make(map[string]int)
+	cleanedUp := make(map[string]int)
+	var tests []InternalTest
+	for _, name := range []string{"TestA", "TestB", "TestC"} {
+		name := name
+		tests = append(tests, InternalTest{name, func(t *T) {
+			t.Parallel()
+			t.Cleanup(func() {
+				mu.Lock()
+				cleanedUp[name] = subtestsDone[name]
+				mu.Unlock()
+			})
+			for _, sub := range []string{"x", "y", "z"} {
+				t.Run(sub, func(t *T) {
+					t.Parallel()
+					counter.run()
+					mu.Lock()
+					subtestsDone[name]++
+					mu.Unlock()
+				})
+			}
+		}})
+	}
+	ctx := runParallelTests(2, tests)
+	if counter.runs != 9 {
+		t.Errorf("%d subtests ran, want 9", counter.runs)
+	}
+	if counter.maxRunning > 2 {
+		t.Errorf("%d subtests ran in parallel with -test.parallel=2, want at most 2", counter.maxRunning)
+	}
+	for _, name := range []string{"TestA", "TestB", "TestC"} {
+		// The parent test must wait for its parallel subtests.
+		if cleanedUp[name] != 3 {
+			t.Errorf("%s was cleaned up after %d of its 3 subtests finished", name, cleanedUp[name])
+		}
+	}
+	if ctx.running != 1 {
+		t.Errorf("%d tests running after all tests finished, want 1 (the main test)", ctx.running)
+	}
+}
@@ -13,9 +13,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
+	"math/rand"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -23,11 +29,21 @@ import (
 
 // Testing flags.
 var (
-	flagVerbose   bool
-	flagShort     bool
-	flagRunRegexp string
+	flagVerbose    bool
+	flagShort      bool
+	flagRunRegexp  string
+	flagSkipRegexp string
+	flagListRegexp string
+	flagCount      uint
+	flagTimeout    time.Duration
+	flagFailFast   bool
+	flagShuffle    string
+	flagParallel   int
 )
 
+// numFailed is the number of tests that failed, for -test.failfast.
+var numFailed uint32
+
 var initRan bool
 
 // Init registers testing flags. It has no effect if it has already run.
@@ -40,13 +56,23 @@ func Init() {
 	flag.BoolVar(&flagVerbose, "test.v", false, "verbose: print additional output")
 	flag.BoolVar(&flagShort, "test.short", false, "short: run smaller test suite to save time")
 	flag.StringVar(&flagRunRegexp, "test.run", "", "run: regexp of tests to run")
+	flag.StringVar(&flagSkipRegexp, "test.skip", "", "do not list or run tests matching `regexp`")
+	flag.StringVar(&flagListRegexp, "test.list", "", "list tests, examples, and benchmarks matching `regexp` then exit")
+	flag.UintVar(&flagCount, "test.count", 1, "run tests and benchmarks `n` times")
+	flag.DurationVar(&flagTimeout, "test.timeout", 0, "panic test binary after duration `d` (default 0, timeout disabled)")
+	flag.BoolVar(&flagFailFast, "test.failfast", false, "do not start new tests after the first test failure")
+	flag.StringVar(&flagShuffle, "test.shuffle", "off", "randomize the execution order of tests and benchmarks")
+	flag.IntVar(&flagParallel, "test.parallel", runtime.GOMAXPROCS(0), "run at most `n` tests in parallel")
+	flag.StringVar(&flagCoverProfile, "test.coverprofile", "", "write a coverage profile to `file`")
 
 	initBenchmarkFlags()
+	initFuzzFlags()
 }
 
 // common holds the elements common between T and B and
 // captures common methods such as Errorf.
 type common struct {
+	mu       sync.Mutex // guards output, failed, ran and sub
 	output   bytes.Buffer
 	indent   string
 	ran      bool     // Test or benchmark (or one of its subtests) was executed.
@@ -57,6 +83,10 @@ type common struct {
 
 	hasSub bool // TODO: should be atomic
 
+	sub     []*T      // Parallel subtests, which run after the test function returned.
+	signal  chan bool // Receives a value when the test finished or called Parallel.
+	barrier chan bool // Closed when the test function returned, to start parallel subtests.
+
 	parent   *common
 	level    int       // Nesting depth of test or benchmark.
 	name     string    // Name of test or benchmark.
@@ -73,11 +103,10 @@ func Short() bool {
 	return flagShort
 }
 
-// CoverMode reports what the test coverage mode is set to.
-//
-// Test coverage is not supported; this returns the empty string.
+// CoverMode reports what the test coverage mode is set to. The values are
+// "set" or "count", or the empty string when coverage is not enabled.
 func CoverMode() string {
-	return ""
+	return coverMode
 }
 
 // Verbose reports whether the -test.v flag is set.
@@ -88,11 +117,15 @@ func Verbose() bool {
 // flushToParent writes c.output to the parent after first writing the header
 // with the given format and arguments.
 func (c *common) flushToParent(testName, format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.parent == nil {
 		// The fake top-level test doesn't want a FAIL or PASS banner.
 		// Not quite sure how this works upstream.
 		c.output.WriteTo(os.Stdout)
 	} else {
+		c.parent.mu.Lock()
+		defer c.parent.mu.Unlock()
 		fmt.Fprintf(&c.parent.output, format, args...)
 		c.output.WriteTo(&c.parent.output)
 	}
@@ -131,7 +164,8 @@ var _ TB = (*B)(nil)
 // Logs are accumulated during execution and dumped to standard output when done.
 type T struct {
 	common
-	context *testContext // For running tests and subtests.
+	isParallel bool
+	context    *testContext // For running tests and subtests.
 }
 
 // Name returns the name of the running test or benchmark.
@@ -143,17 +177,23 @@ func (c *common) setRan() {
 	if c.parent != nil {
 		c.parent.setRan()
 	}
+	c.mu.Lock()
 	c.ran = true
+	c.mu.Unlock()
 }
 
 // Fail marks the function as having failed but continues execution.
 func (c *common) Fail() {
+	c.mu.Lock()
 	c.failed = true
+	c.mu.Unlock()
 }
 
 // Failed reports whether the function has failed.
 func (c *common) Failed() bool {
+	c.mu.Lock()
 	failed := c.failed
+	c.mu.Unlock()
 	return failed
 }
 
@@ -174,6 +214,8 @@ func (c *common) log(s string) {
 		s = s[:len(s)-1]
 	}
 	lines := strings.Split(s, "\n")
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	// First line.
 	c.output.WriteString(c.indent)
 	c.output.WriteString("    ") // 4 spaces
@@ -346,11 +388,6 @@ func (c *common) runCleanup() {
 	}
 }
 
-// Parallel is not implemented, it is only provided for compatibility.
-func (t *T) Parallel() {
-	// Unimplemented.
-}
-
 // InternalTest is a reference to a test that should be called during a test suite run.
 type InternalTest struct {
 	Name string
@@ -358,19 +395,38 @@ type InternalTest struct {
 }
 
 func tRunner(t *T, fn func(t *T)) {
-	defer func() {
-		t.runCleanup()
-	}()
-
 	// Run the test.
 	t.start = time.Now()
 	fn(t)
 	t.duration += time.Since(t.start) // TODO: capture cleanup time, too.
 
+	// Run the parallel subtests now that the test function returned, and wait
+	// for them to finish.
+	if len(t.sub) > 0 {
+		// Let the subtests use the slot of this test while it is waiting.
+		t.context.release()
+		close(t.barrier)
+		for _, sub := range t.sub {
+			<-sub.signal
+		}
+		if !t.isParallel {
+			// Reacquire the slot of this sequential test.
+			t.context.waitParallel()
+		}
+	} else if t.isParallel {
+		// Only release the slot of this test if it was run as a parallel
+		// test. A parallel test with subtests released it above already.
+		t.context.release()
+	}
+	t.runCleanup()
+
 	t.report() // Report after all subtests have finished.
 	if t.parent != nil && !t.hasSub {
 		t.setRan()
 	}
+	if t.signal != nil {
+		t.signal <- true
+	}
 }
 
 // Run runs f as a subtest of t called name. It waits until the subtest is finished
@@ -378,16 +434,18 @@ func tRunner(t *T, fn func(t *T)) {
 func (t *T) Run(name string, f func(t *T)) bool {
 	t.hasSub = true
 	testName, ok, _ := t.context.match.fullName(&t.common, name)
-	if !ok {
+	if !ok || shouldFailFast() {
 		return true
 	}
 
 	// Create a subtest.
-	sub := T{
+	sub := &T{
 		common: common{
-			name:   testName,
-			parent: &t.common,
-			level:  t.level + 1,
+			name:    testName,
+			parent:  &t.common,
+			level:   t.level + 1,
+			signal:  make(chan bool, 1),
+			barrier: make(chan bool),
 		},
 		context: t.context,
 	}
@@ -395,31 +453,87 @@ func (t *T) Run(name string, f func(t *T)) bool {
 		sub.indent = sub.indent + "    "
 	}
 	if flagVerbose {
+		t.mu.Lock()
 		fmt.Fprintf(&t.output, "=== RUN   %s\n", sub.name)
+		t.mu.Unlock()
 	}
 
-	tRunner(&sub, f)
-	return !sub.failed
+	// Wait until the subtest finished or called Parallel.
+	runTest(sub, f)
+	return !sub.Failed()
+}
+
+// shouldFailFast returns whether no new tests should be started because of
+// -test.failfast.
+func shouldFailFast() bool {
+	return flagFailFast && atomic.LoadUint32(&numFailed) > 0
 }
 
 // testContext holds all fields that are common to all tests. This includes
 // synchronization primitives to run at most *parallel tests.
 type testContext struct {
 	match *matcher
+
+	mu sync.Mutex
+
+	// Channel used to signal tests that are ready to be run in parallel.
+	startParallel chan bool
+
+	// running is the number of tests currently running in parallel.
+	// This does not include tests that are waiting for subtests to complete.
+	running int
+
+	// numWaiting is the number tests waiting to be run in parallel.
+	numWaiting int
+
+	// maxParallel is a copy of the parallel flag.
+	maxParallel int
 }
 
-func newTestContext(m *matcher) *testContext {
+func newTestContext(maxParallel int, m *matcher) *testContext {
 	return &testContext{
-		match: m,
+		match:         m,
+		startParallel: make(chan bool),
+		maxParallel:   maxParallel,
+		running:       1, // Set the count to 1 for the main (sequential) test.
 	}
 }
 
+// waitParallel waits until a parallel test may run.
+func (c *testContext) waitParallel() {
+	c.mu.Lock()
+	if c.running < c.maxParallel {
+		c.running++
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting++
+	c.mu.Unlock()
+	<-c.startParallel
+}
+
+// release marks a running test as finished, starting a waiting parallel test
+// if there is one.
+func (c *testContext) release() {
+	c.mu.Lock()
+	if c.numWaiting == 0 {
+		c.running--
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting--
+	c.mu.Unlock()
+	c.startParallel <- true // Pick a waiting test to be run.
+}
+
 // M is a test suite.
 type M struct {
 	// tests is a list of the test names to execute
 	Tests      []InternalTest
 	Benchmarks []InternalBenchmark
 
+	fuzzTargets []InternalFuzzTarget
+
 	deps testDeps
 
 	// value to pass to os.Exit, the outer test func main
@@ -441,11 +555,47 @@ func (m *M) Run() (code int) {
 		flag.Parse()
 	}
 
-	testRan, testOk := runTests(m.deps.MatchString, m.Tests)
-	if !testRan && *matchBenchmarks == "" {
+	if flagParallel < 1 {
+		fmt.Fprintln(os.Stderr, "testing: -parallel can only be given a positive integer")
+		flag.Usage()
+		m.exitCode = 2
+		return
+	}
+
+	if len(flagListRegexp) != 0 {
+		listTests(os.Stdout, m.deps.MatchString, m.Tests, m.Benchmarks, m.fuzzTargets)
+		m.exitCode = 0
+		return
+	}
+
+	if flagShuffle != "off" {
+		var n int64
+		var err error
+		if flagShuffle == "on" {
+			n = time.Now().UnixNano()
+		} else {
+			n, err = strconv.ParseInt(flagShuffle, 10, 64)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, `testing: -shuffle should be "off", "on", or a valid integer:`, err)
+				m.exitCode = 2
+				return
+			}
+		}
+		fmt.Println("-test.shuffle", n)
+		shuffleTests(n, m.Tests, m.Benchmarks)
+	}
+
+	stopAlarm := startAlarm(flagTimeout)
+	// Fuzz tests run with only their seed corpus, like regular tests.
+	tests := append([]InternalTest(nil), m.Tests...)
+	for _, target := range m.fuzzTargets {
+		tests = append(tests, fuzzTargetTest(target, seedCorpusOnly))
+	}
+	testRan, testOk := runTests(m.deps.MatchString, tests)
+	if !testRan && *matchBenchmarks == "" && flagFuzz == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !runBenchmarks(m.deps.MatchString, m.Benchmarks) {
+	if !testOk || !runFuzzing(m.deps.MatchString, m.fuzzTargets) || !runBenchmarks(m.deps.MatchString, m.Benchmarks) {
 		fmt.Println("FAIL")
 		m.exitCode = 1
 	} else {
@@ -454,25 +604,73 @@ func (m *M) Run() (code int) {
 		}
 		m.exitCode = 0
 	}
+	stopAlarm()
+	if coverMode != "" {
+		coverReport()
+	}
 	return
 }
 
-func runTests(matchString func(pat, str string) (bool, error), tests []InternalTest) (ran, ok bool) {
-	ok = true
+// shuffleTests randomizes the order of the tests and benchmarks for
+// -test.shuffle. The same seed always results in the same order.
+func shuffleTests(seed int64, tests []InternalTest, benchmarks []InternalBenchmark) {
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(tests), func(i, j int) { tests[i], tests[j] = tests[j], tests[i] })
+	rng.Shuffle(len(benchmarks), func(i, j int) { benchmarks[i], benchmarks[j] = benchmarks[j], benchmarks[i] })
+}
 
-	ctx := newTestContext(newMatcher(matchString, flagRunRegexp, "-test.run"))
-	t := &T{
-		context: ctx,
+// listTests prints the names of the tests and benchmarks that match
+// -test.list and don't match -test.skip.
+func listTests(w io.Writer, matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget) {
+	if isBaremetal {
+		matchString = fakeMatchString
 	}
+	if _, err := matchString(flagListRegexp, "non-empty"); err != nil {
+		fmt.Fprintf(os.Stderr, "testing: invalid regexp in -test.list (%q): %s\n", flagListRegexp, err)
+		os.Exit(1)
+	}
+
+	skip := newMatcher(matchString, "", "-test.list", flagSkipRegexp)
+	list := func(name string) {
+		if ok, _ := matchString(flagListRegexp, name); ok && !skip.skipped([]string{name}) {
+			fmt.Fprintln(w, name)
+		}
+	}
+	for _, test := range tests {
+		list(test.Name)
+	}
+	for _, bench := range benchmarks {
+		list(bench.Name)
+	}
+	for _, target := range fuzzTargets {
+		list(target.Name)
+	}
+}
 
-	tRunner(t, func(t *T) {
-		for _, test := range tests {
-			t.Run(test.Name, test.F)
-			ok = ok && !t.Failed()
+func runTests(matchString func(pat, str string) (bool, error), tests []InternalTest) (ran, ok bool) {
+	ok = true
+	for i := uint(0); i < flagCount; i++ {
+		ctx := newTestContext(flagParallel, newMatcher(matchString, flagRunRegexp, "-test.run", flagSkipRegexp))
+		t := &T{
+			common: common{
+				barrier: make(chan bool),
+			},
+			context: ctx,
 		}
-	})
 
-	return t.ran, ok
+		tRunner(t, func(t *T) {
+			for _, test := range tests {
+				t.Run(test.Name, test.F)
+			}
+		})
+
+		ran = ran || t.ran
+		ok = ok && !t.Failed()
+		if shouldFailFast() {
+			break
+		}
+	}
+	return ran, ok
 }
 
 func (t *T) report() {
@@ -480,7 +678,8 @@ func (t *T) report() {
 	format := t.indent + "--- %s: %s (%s)\n"
 	if t.Failed() {
 		if t.parent != nil {
-			t.parent.failed = true
+			t.parent.Fail()
+			atomic.AddUint32(&numFailed, 1)
 		}
 		t.flushToParent(t.name, format, "FAIL", t.name, dstr)
 	} else if flagVerbose {
@@ -518,8 +717,9 @@ type InternalExample struct {
 func MainStart(deps interface{}, tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget, examples []InternalExample) *M {
 	Init()
 	return &M{
-		Tests:      tests,
-		Benchmarks: benchmarks,
-		deps:       deps.(testDeps),
+		Tests:       tests,
+		Benchmarks:  benchmarks,
+		fuzzTargets: fuzzTargets,
+		deps:        deps.(testDeps),
 	}
 }
@@ -15,14 +15,31 @@ import (
 type dwarfCIE struct {
 	bytecode            []byte
 	codeAlignmentFactor uint64
+	dataAlignmentFactor int64
+	addressSize         uint8
+}
+
+// stackPointerRegisters contains the DWARF register number of the stack
+// pointer for each supported architecture.
+var stackPointerRegisters = map[elf.Machine]uint64{
+	elf.EM_ARM:     13, // r13 or sp
+	elf.EM_RISCV:   2,  // x2 or sp
+	elf.EM_XTENSA:  1,  // a1 or sp
+	elf.EM_AVR:     32, // SPH:SPL
+	elf.EM_X86_64:  7,  // rsp
+	elf.EM_AARCH64: 31, // sp
 }
 
 // parseFrames parses all call frame information from a .debug_frame section and
 // provides the passed in symbols map with frame size information.
 func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
-	if f.Class != elf.ELFCLASS32 {
-		// TODO: ELF64
-		return fmt.Errorf("expected ELF32")
+	stackPointer, ok := stackPointerRegisters[f.Machine]
+	if !ok {
+		return fmt.Errorf("unknown architecture: %s", f.Machine)
+	}
+	defaultAddressSize := uint8(4)
+	if f.Class == elf.ELFCLASS64 {
+		defaultAddressSize = 8
 	}
 	cies := make(map[uint32]*dwarfCIE)
 
@@ -31,15 +48,18 @@ func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
 	for {
 		start := len(data) - r.Len()
 		var length uint32
-		err := binary.Read(r, binary.LittleEndian, &length)
+		err := binary.Read(r, f.ByteOrder, &length)
 		if err == io.EOF {
 			return nil
 		}
 		if err != nil {
 			return err
 		}
+		if length == 0xffffffff {
+			return fmt.Errorf("unimplemented: 64-bit DWARF in .debug_frame")
+		}
 		var cie uint32
-		err = binary.Read(r, binary.LittleEndian, &cie)
+		err = binary.Read(r, f.ByteOrder, &cie)
 		if err != nil {
 			return err
 		}
@@ -48,31 +68,49 @@ func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
 			var fields struct {
 				Version      uint8
 				Augmentation uint8
-				AddressSize  uint8
-				SegmentSize  uint8
 			}
-			err = binary.Read(r, binary.LittleEndian, &fields)
+			err = binary.Read(r, f.ByteOrder, &fields)
 			if err != nil {
 				return err
 			}
-			if fields.Version != 4 {
+			if fields.Version != 1 && fields.Version != 3 && fields.Version != 4 {
 				return fmt.Errorf("unimplemented: .debug_frame version %d", fields.Version)
 			}
 			if fields.Augmentation != 0 {
 				return fmt.Errorf("unimplemented: .debug_frame with augmentation")
 			}
-			if fields.SegmentSize != 0 {
-				return fmt.Errorf("unimplemented: .debug_frame with segment size")
+			addressSize := defaultAddressSize
+			if fields.Version == 4 {
+				// Only version 4 includes the address and segment size.
+				var sizes struct {
+					AddressSize uint8
+					SegmentSize uint8
+				}
+				err = binary.Read(r, f.ByteOrder, &sizes)
+				if err != nil {
+					return err
+				}
+				if sizes.SegmentSize != 0 {
+					return fmt.Errorf("unimplemented: .debug_frame with segment size")
+				}
+				addressSize = sizes.AddressSize
+			}
+			if addressSize != 2 && addressSize != 4 && addressSize != 8 {
+				return fmt.Errorf("unimplemented: .debug_frame with address size %d", addressSize)
 			}
 			codeAlignmentFactor, err := readULEB128(r)
 			if err != nil {
 				return err
 			}
-			_, err = readSLEB128(r) // data alignment factor
+			dataAlignmentFactor, err := readSLEB128(r)
 			if err != nil {
 				return err
 			}
-			_, err = readULEB128(r) // return address register
+			if fields.Version == 1 {
+				_, err = r.ReadByte() // return address register
+			} else {
+				_, err = readULEB128(r) // return address register
+			}
 			if err != nil {
 				return err
 			}
@@ -80,27 +118,28 @@ func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
 			bytecode := r.Next(rest)
 			cies[uint32(start)] = &dwarfCIE{
 				codeAlignmentFactor: codeAlignmentFactor,
+				dataAlignmentFactor: dataAlignmentFactor,
+				addressSize:         addressSize,
 				bytecode:            bytecode,
 			}
 		} else {
 			// This is a FDE.
-			var fields struct {
-				InitialLocation uint32
-				AddressRange    uint32
-			}
-			err = binary.Read(r, binary.LittleEndian, &fields)
-			if err != nil {
-				return err
-			}
 			if _, ok := cies[cie]; !ok {
 				return fmt.Errorf("could not find CIE 0x%x in .debug_frame section", cie)
 			}
 			frame := frameInfo{
-				cie:    cies[cie],
-				start:  uint64(fields.InitialLocation),
-				loc:    uint64(fields.InitialLocation),
-				length: uint64(fields.AddressRange),
+				cie:       cies[cie],
+				byteOrder: f.ByteOrder,
 			}
+			frame.start, err = readAddress(r, f.ByteOrder, frame.cie.addressSize)
+			if err != nil {
+				return err
+			}
+			frame.length, err = readAddress(r, f.ByteOrder, frame.cie.addressSize)
+			if err != nil {
+				return err
+			}
+			frame.loc = frame.start
 			rest := (start + int(length) + 4) - (len(data) - r.Len())
 			bytecode := r.Next(rest)
 
@@ -119,24 +158,44 @@ func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
 				return err
 			}
 			var maxFrameSize uint64
+			bounded := true
 			for _, entry := range entries {
-				switch f.Machine {
-				case elf.EM_ARM:
-					if entry.cfaRegister != 13 { // r13 or sp
+				if entry.cfaRegister != stackPointer {
+					switch f.Machine {
+					case elf.EM_ARM:
 						// something other than a stack pointer (on ARM)
 						return fmt.Errorf("%08x..%08x: unknown CFA register number %d", frame.start, frame.start+frame.length, entry.cfaRegister)
+					case elf.EM_AVR:
+						// The CIE emitted by LLVM for AVR doesn't define the
+						// CFA, so the offsets are relative to the (undefined)
+						// register 0. They are still relative to the stack
+						// pointer on function entry.
+						if entry.cfaRegister != 0 {
+							bounded = false
+						}
+					default:
+						// The CFA is relative to a frame pointer, so the stack
+						// pointer may be moved further without this being
+						// described in the CFI (for example, by alloca).
+						bounded = false
 					}
-				default:
-					return fmt.Errorf("unknown architecture: %s", f.Machine)
 				}
 				if entry.cfaOffset > maxFrameSize {
 					maxFrameSize = entry.cfaOffset
 				}
 			}
 			node := symbols[frame.start]
+			if node == nil {
+				// Not the start of a function symbol, for example a local
+				// label in assembly.
+				continue
+			}
 			if node.Size != frame.length {
 				return fmt.Errorf("%s: symtab gives symbol length %d while DWARF gives symbol length %d", node, node.Size, frame.length)
 			}
+			if !bounded {
+				continue
+			}
 			node.FrameSize = maxFrameSize
 			node.FrameSizeType = Bounded
 			if debugPrint {
@@ -146,14 +205,32 @@ func parseFrames(f *elf.File, data []byte, symbols map[uint64]*CallNode) error {
 	}
 }
 
+// readAddress reads a target address of the given size in bytes.
+func readAddress(r *bytes.Buffer, byteOrder binary.ByteOrder, size uint8) (uint64, error) {
+	buf := r.Next(int(size))
+	if len(buf) != int(size) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch size {
+	case 2:
+		return uint64(byteOrder.Uint16(buf)), nil
+	case 4:
+		return uint64(byteOrder.Uint32(buf)), nil
+	default:
+		return byteOrder.Uint64(buf), nil
+	}
+}
+
 // frameInfo contains the state of executing call frame information bytecode.
 type frameInfo struct {
 	cie         *dwarfCIE
+	byteOrder   binary.ByteOrder
 	start       uint64
 	loc         uint64
 	length      uint64
 	cfaRegister uint64
 	cfaOffset   uint64
+	stateStack  []frameInfoLine // for DW_CFA_remember_state
 }
 
 // frameInfoLine represents one line in the frame table (.debug_frame) at one
@@ -193,7 +270,7 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 		// For details on the various opcodes, see:
 		// http://dwarfstd.org/doc/DWARF5.pdf (page 239)
 		highBits := op >> 6 // high order 2 bits
-		lowBits := op & 0x1f
+		lowBits := op & 0x3f
 		switch highBits {
 		case 1: // DW_CFA_advance_loc
 			fi.loc += uint64(lowBits) * fi.cie.codeAlignmentFactor
@@ -224,7 +301,7 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				entries = append(entries, fi.newLine())
 			case 0x03: // DW_CFA_advance_loc2
 				var offset uint16
-				err := binary.Read(r, binary.LittleEndian, &offset)
+				err := binary.Read(r, fi.byteOrder, &offset)
 				if err != nil {
 					return nil, err
 				}
@@ -232,7 +309,7 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				entries = append(entries, fi.newLine())
 			case 0x04: // DW_CFA_advance_loc4
 				var offset uint32
-				err := binary.Read(r, binary.LittleEndian, &offset)
+				err := binary.Read(r, fi.byteOrder, &offset)
 				if err != nil {
 					return nil, err
 				}
@@ -249,6 +326,12 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				if err != nil {
 					return nil, err
 				}
+			case 0x06: // DW_CFA_restore_extended
+				// Same as DW_CFA_restore, with a larger register number.
+				_, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
 			case 0x07: // DW_CFA_undefined
 				// Marks a single register as undefined. This is used to stop
 				// unwinding in tinygo_startTask using:
@@ -258,6 +341,12 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				if err != nil {
 					return nil, err
 				}
+			case 0x08: // DW_CFA_same_value
+				// A register that isn't modified by this function. Ignore it.
+				_, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
 			case 0x09: // DW_CFA_register
 				// Copies a register. Emitted by the machine outliner, for example.
 				// It should be possible to ignore this.
@@ -270,6 +359,17 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				if err != nil {
 					return nil, err
 				}
+			case 0x0a: // DW_CFA_remember_state
+				// Used around an epilogue in the middle of a function.
+				fi.stateStack = append(fi.stateStack, fi.newLine())
+			case 0x0b: // DW_CFA_restore_state
+				if len(fi.stateStack) == 0 {
+					return nil, fmt.Errorf("DW_CFA_restore_state without DW_CFA_remember_state (for address 0x%x)", fi.loc)
+				}
+				state := fi.stateStack[len(fi.stateStack)-1]
+				fi.stateStack = fi.stateStack[:len(fi.stateStack)-1]
+				fi.cfaRegister = state.cfaRegister
+				fi.cfaOffset = state.cfaOffset
 			case 0x0c: // DW_CFA_def_cfa
 				register, err := readULEB128(r)
 				if err != nil {
@@ -281,12 +381,54 @@ func (fi *frameInfo) exec(bytecode []byte) ([]frameInfoLine, error) {
 				}
 				fi.cfaRegister = register
 				fi.cfaOffset = offset
+			case 0x0d: // DW_CFA_def_cfa_register
+				// Usually the switch to a frame pointer.
+				register, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
+				fi.cfaRegister = register
 			case 0x0e: // DW_CFA_def_cfa_offset
 				offset, err := readULEB128(r)
 				if err != nil {
 					return nil, err
 				}
 				fi.cfaOffset = offset
+			case 0x11: // DW_CFA_offset_extended_sf
+				// Same as DW_CFA_offset_extended with a signed offset.
+				_, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
+				_, err = readSLEB128(r)
+				if err != nil {
+					return nil, err
+				}
+			case 0x12: // DW_CFA_def_cfa_sf
+				register, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
+				offset, err := readSLEB128(r)
+				if err != nil {
+					return nil, err
+				}
+				fi.cfaRegister = register
+				fi.cfaOffset = uint64(offset * fi.cie.dataAlignmentFactor)
+			case 0x13: // DW_CFA_def_cfa_offset_sf
+				offset, err := readSLEB128(r)
+				if err != nil {
+					return nil, err
+				}
+				fi.cfaOffset = uint64(offset * fi.cie.dataAlignmentFactor)
+			case 0x2d: // DW_CFA_AARCH64_negate_ra_state (DW_CFA_GNU_window_save)
+				// Pointer authentication state on AArch64, no operands.
+			case 0x2e: // DW_CFA_GNU_args_size
+				// Size of the arguments pushed on the stack (x86), ignore.
+				_, err := readULEB128(r)
+				if err != nil {
+					return nil, err
+				}
 			default:
 				return nil, fmt.Errorf("could not decode .debug_frame bytecode op 0x%x (for address 0x%x)", op, fi.loc)
 			}
@@ -4,7 +4,6 @@ package stacksize
 
 import (
 	"debug/elf"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
@@ -131,26 +130,27 @@ func CallGraph(f *elf.File, callsIndirectFunction []string) (map[string][]*CallN
 		return symbolList[i].Address < symbolList[j].Address
 	})
 
-	// Load relocations and construct the call graph.
+	// Load relocations and construct the call graph. Only relocations in code
+	// are of interest: relocations in data (for example function pointers in
+	// a vtable) are not calls.
+	foundRelocations := false
 	for _, section := range f.Sections {
-		if section.Type != elf.SHT_REL {
+		if section.Type != elf.SHT_REL && section.Type != elf.SHT_RELA {
 			continue
 		}
-		if section.Entsize != 8 {
-			// Assume ELF32, this should be fixed.
-			return nil, fmt.Errorf("only ELF32 is supported at this time")
+		if int(section.Info) >= len(f.Sections) || f.Sections[section.Info].Flags&elf.SHF_EXECINSTR == 0 {
+			continue
 		}
-		data, err := section.Data()
+		relocations, err := readRelocations(f, section)
 		if err != nil {
 			return nil, err
 		}
-		for i := uint64(0); i < section.Size/section.Entsize; i++ {
-			offset := binary.LittleEndian.Uint32(data[i*section.Entsize:])
-			info := binary.LittleEndian.Uint32(data[i*section.Entsize+4:])
-			if elf.R_SYM32(info) == 0 {
+		foundRelocations = true
+		for _, reloc := range relocations {
+			if reloc.symbol == 0 || int(reloc.symbol) > len(elfSymbols) {
 				continue
 			}
-			elfSymbol := elfSymbols[elf.R_SYM32(info)-1]
+			elfSymbol := elfSymbols[reloc.symbol-1]
 			if elf.ST_TYPE(elfSymbol.Info) != elf.STT_FUNC {
 				continue
 			}
@@ -159,47 +159,30 @@ func CallGraph(f *elf.File, callsIndirectFunction []string) (map[string][]*CallN
 				address = address &^ 1
 			}
 			childSym := symbols[address]
-			switch f.Machine {
-			case elf.EM_ARM:
-				relocType := elf.R_ARM(elf.R_TYPE32(info))
-				parentSym := findSymbol(symbolList, uint64(offset))
-				if debugPrint {
-					fmt.Fprintf(os.Stderr, "found relocation %-24s at %s (0x%x) to %s (0x%x)\n", relocType, parentSym, offset, childSym, childSym.Address)
-				}
-				isCall := true
-				switch relocType {
-				case elf.R_ARM_THM_PC22: // actually R_ARM_THM_CALL
-					// used for bl calls
-				case elf.R_ARM_THM_JUMP24:
-					// used for b.w jumps
-					isCall = parentSym != childSym
-				case elf.R_ARM_THM_JUMP11:
-					// used for b.n jumps
-					isCall = parentSym != childSym
-				case elf.R_ARM_THM_MOVW_ABS_NC, elf.R_ARM_THM_MOVT_ABS:
-					// used for getting a function pointer
-					isCall = false
-				case elf.R_ARM_ABS32:
-					// when compiling with -Oz (minsize), used for calling
-					isCall = true
-				default:
-					return nil, fmt.Errorf("unknown relocation: %s", relocType)
-				}
-				if isCall {
-					if parentSym != nil {
-						parentSym.Children = append(parentSym.Children, childSym)
-					}
+			parentSym := findSymbol(symbolList, reloc.offset)
+			isCall, err := isCallRelocation(f.Machine, reloc.typ, parentSym, childSym)
+			if err != nil {
+				return nil, err
+			}
+			if debugPrint {
+				fmt.Fprintf(os.Stderr, "found relocation %-24d at %s (0x%x) to %s (0x%x), call: %v\n", reloc.typ, parentSym, reloc.offset, childSym, childSym.Address, isCall)
+			}
+			if isCall {
+				if parentSym != nil {
+					parentSym.Children = append(parentSym.Children, childSym)
 				}
-			default:
-				return nil, fmt.Errorf("unknown architecture: %s", f.Machine)
 			}
 		}
 	}
+	if !foundRelocations {
+		return nil, errors.New("no relocations found in code sections, binary was linked without --emit-relocs")
+	}
 
 	// Set fixed frame size information, depending on the architecture.
+	var knownFrameSizes map[string]uint64
 	switch f.Machine {
 	case elf.EM_ARM:
-		knownFrameSizes := map[string]uint64{
+		knownFrameSizes = map[string]uint64{
 			// implemented with assembly in compiler-rt
 			"__aeabi_idivmod":  3 * 4, // 3 registers on thumb1 but 1 register on thumb2
 			"__aeabi_uidivmod": 3 * 4, // 3 registers on thumb1 but 1 register on thumb2
@@ -221,14 +204,20 @@ func CallGraph(f *elf.File, callsIndirectFunction []string) (map[string][]*CallN
 			"__aeabi_fcmpge":   2 * 4,
 			"__aeabi_fcmpgt":   2 * 4,
 		}
-		for name, size := range knownFrameSizes {
-			if sym, ok := symbolNames[name]; ok {
-				if len(sym) > 1 {
-					return nil, fmt.Errorf("expected zero or one occurence of the symbol %s, found %d", name, len(sym))
-				}
-				sym[0].FrameSize = size
-				sym[0].FrameSizeType = Bounded
+	case elf.EM_RISCV:
+		knownFrameSizes = map[string]uint64{
+			// implemented with assembly in compiler-rt, leaf functions
+			"__mulsi3": 0,
+			"__muldi3": 0,
+		}
+	}
+	for name, size := range knownFrameSizes {
+		if sym, ok := symbolNames[name]; ok {
+			if len(sym) > 1 {
+				return nil, fmt.Errorf("expected zero or one occurence of the symbol %s, found %d", name, len(sym))
 			}
+			sym[0].FrameSize = size
+			sym[0].FrameSizeType = Bounded
 		}
 	}
 
@@ -258,6 +247,195 @@ func CallGraph(f *elf.File, callsIndirectFunction []string) (map[string][]*CallN
 	return symbolNames, nil
 }
 
+// relocation is a single ELF relocation, independent of the ELF class and
+// whether it is a REL or RELA relocation.
+type relocation struct {
+	offset uint64 // address of the relocated instruction or data
+	symbol uint32 // symbol index, zero if there is no symbol
+	typ    uint32 // architecture specific relocation type
+}
+
+// readRelocations reads all relocations from a SHT_REL or SHT_RELA section.
+// The addend (if any) is not needed to construct the call graph and ignored.
+func readRelocations(f *elf.File, section *elf.Section) ([]relocation, error) {
+	var entsize uint64
+	switch {
+	case f.Class == elf.ELFCLASS32 && section.Type == elf.SHT_REL:
+		entsize = 8
+	case f.Class == elf.ELFCLASS32 && section.Type == elf.SHT_RELA:
+		entsize = 12
+	case f.Class == elf.ELFCLASS64 && section.Type == elf.SHT_REL:
+		entsize = 16
+	case f.Class == elf.ELFCLASS64 && section.Type == elf.SHT_RELA:
+		entsize = 24
+	}
+	if section.Entsize != entsize {
+		return nil, fmt.Errorf("%s: unexpected relocation entry size %d", section.Name, section.Entsize)
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, err
+	}
+	relocations := make([]relocation, len(data)/int(entsize))
+	for i := range relocations {
+		entry := data[uint64(i)*entsize:]
+		if f.Class == elf.ELFCLASS32 {
+			info := f.ByteOrder.Uint32(entry[4:])
+			relocations[i] = relocation{
+				offset: uint64(f.ByteOrder.Uint32(entry)),
+				symbol: elf.R_SYM32(info),
+				typ:    elf.R_TYPE32(info),
+			}
+		} else {
+			info := f.ByteOrder.Uint64(entry[8:])
+			relocations[i] = relocation{
+				offset: f.ByteOrder.Uint64(entry),
+				symbol: elf.R_SYM64(info),
+				typ:    elf.R_TYPE64(info),
+			}
+		}
+	}
+	return relocations, nil
+}
+
+// Relocation types for architectures that are not (fully) described in the
+// debug/elf package.
+const (
+	// AVR, see elf32-avr.h in binutils.
+	rAVR32       = 1
+	rAVR7PCREL   = 2
+	rAVR13PCREL  = 3
+	rAVR16       = 4
+	rAVR16PM     = 5
+	rAVRLO8LDI   = 6
+	rAVRHI8LDI   = 7
+	rAVRLO8LDIPM = 12
+	rAVRHI8LDIPM = 13
+	rAVRCALL     = 18
+	rAVRLO8LDIGS = 24
+	rAVRHI8LDIGS = 25
+
+	// Xtensa, see xtensa.h in binutils.
+	rXtensa32          = 1
+	rXtensaOP0         = 8
+	rXtensaASMExpand   = 11
+	rXtensaASMSimplify = 12
+	rXtensaDiff8       = 17
+	rXtensaDiff16      = 18
+	rXtensaDiff32      = 19
+	rXtensaSlot0Op     = 20
+)
+
+// isCallRelocation returns whether the given relocation (from parent to child)
+// could be a call. Some relocations can be used both for calls and for jumps
+// within the same function, jumps are only calls if they target another
+// function (tail calls). When in doubt, a relocation is treated as a call, as
+// that can only make the computed stack size larger.
+func isCallRelocation(machine elf.Machine, typ uint32, parent, child *CallNode) (bool, error) {
+	switch machine {
+	case elf.EM_ARM:
+		switch elf.R_ARM(typ) {
+		case elf.R_ARM_THM_PC22: // actually R_ARM_THM_CALL
+			// used for bl calls
+			return true, nil
+		case elf.R_ARM_THM_JUMP24:
+			// used for b.w jumps
+			return parent != child, nil
+		case elf.R_ARM_THM_JUMP11:
+			// used for b.n jumps
+			return parent != child, nil
+		case elf.R_ARM_THM_MOVW_ABS_NC, elf.R_ARM_THM_MOVT_ABS:
+			// used for getting a function pointer
+			return false, nil
+		case elf.R_ARM_ABS32:
+			// when compiling with -Oz (minsize), used for calling
+			return true, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %s", elf.R_ARM(typ))
+	case elf.EM_RISCV:
+		switch elf.R_RISCV(typ) {
+		case elf.R_RISCV_CALL, elf.R_RISCV_CALL_PLT:
+			// auipc+jalr pair, used for call and tail
+			return true, nil
+		case elf.R_RISCV_JAL, elf.R_RISCV_RVC_JUMP:
+			// used for jal, j, c.jal and c.j (the latter two also for jumps
+			// within a function)
+			return parent != child, nil
+		case elf.R_RISCV_BRANCH, elf.R_RISCV_RVC_BRANCH:
+			// conditional branches within a function
+			return false, nil
+		case elf.R_RISCV_HI20, elf.R_RISCV_LO12_I, elf.R_RISCV_LO12_S,
+			elf.R_RISCV_PCREL_HI20, elf.R_RISCV_PCREL_LO12_I, elf.R_RISCV_PCREL_LO12_S,
+			elf.R_RISCV_GOT_HI20, elf.R_RISCV_32, elf.R_RISCV_64:
+			// used for getting a function pointer
+			return false, nil
+		case elf.R_RISCV_RELAX, elf.R_RISCV_ALIGN:
+			// linker hints
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %s", elf.R_RISCV(typ))
+	case elf.EM_XTENSA:
+		switch typ {
+		case rXtensaSlot0Op, rXtensaOP0, rXtensaASMExpand:
+			// used for call0/call4/call8/call12 and for jumps, calls through
+			// a literal use rXtensa32 instead
+			return true, nil
+		case rXtensa32:
+			// literal pool entry, usually loaded with l32r for a callx8
+			return true, nil
+		case rXtensaASMSimplify, rXtensaDiff8, rXtensaDiff16, rXtensaDiff32:
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %d", typ)
+	case elf.EM_AVR:
+		switch typ {
+		case rAVRCALL, rAVR13PCREL:
+			// used for call, jmp, rcall and rjmp (jumps within a function
+			// use local labels)
+			return true, nil
+		case rAVR7PCREL:
+			// conditional branches within a function
+			return false, nil
+		case rAVR16PM, rAVRLO8LDIPM, rAVRHI8LDIPM, rAVRLO8LDIGS, rAVRHI8LDIGS,
+			rAVRLO8LDI, rAVRHI8LDI, rAVR16, rAVR32:
+			// used for getting a function pointer
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %d", typ)
+	case elf.EM_X86_64:
+		switch elf.R_X86_64(typ) {
+		case elf.R_X86_64_PLT32, elf.R_X86_64_PC32:
+			// used for call and jmp, and for lea of a function pointer
+			return true, nil
+		case elf.R_X86_64_64, elf.R_X86_64_32, elf.R_X86_64_32S,
+			elf.R_X86_64_GOTPCREL, elf.R_X86_64_GOTPCRELX, elf.R_X86_64_REX_GOTPCRELX:
+			// used for getting a function pointer
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %s", elf.R_X86_64(typ))
+	case elf.EM_AARCH64:
+		switch elf.R_AARCH64(typ) {
+		case elf.R_AARCH64_CALL26:
+			// used for bl calls
+			return true, nil
+		case elf.R_AARCH64_JUMP26:
+			// used for b jumps
+			return parent != child, nil
+		case elf.R_AARCH64_CONDBR19, elf.R_AARCH64_TSTBR14:
+			// conditional branches within a function
+			return false, nil
+		case elf.R_AARCH64_ADR_PREL_PG_HI21, elf.R_AARCH64_ADR_PREL_PG_HI21_NC, elf.R_AARCH64_ADD_ABS_LO12_NC,
+			elf.R_AARCH64_ADR_GOT_PAGE, elf.R_AARCH64_LD64_GOT_LO12_NC, elf.R_AARCH64_ADR_PREL_LO21,
+			elf.R_AARCH64_ABS64, elf.R_AARCH64_ABS32, elf.R_AARCH64_PREL32:
+			// used for getting a function pointer
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown relocation: %s", elf.R_AARCH64(typ))
+	default:
+		return false, fmt.Errorf("unknown architecture: %s", machine)
+	}
+}
+
 // findSymbol determines in which symbol the given address lies.
 func findSymbol(symbolList []*CallNode, address uint64) *CallNode {
 	// TODO: binary search
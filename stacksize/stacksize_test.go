@@ -0,0 +1,148 @@
+package stacksize
+
+import (
+	"debug/elf"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The ELF files in testdata are assembled from the .S files next to them, see
+// the header of each file for how. norelocs.elf is riscv.elf without the
+// relocations in .text:
+//
+//	llvm-objcopy --remove-section=.rela.text riscv.elf norelocs.elf
+
+type stackSizeTest struct {
+	name      string
+	children  []string
+	stackSize uint64
+	sizeType  SizeType
+}
+
+func TestCallGraph(t *testing.T) {
+	for _, tc := range []struct {
+		file  string
+		tests []stackSizeTest
+	}{
+		{"arm.elf", []stackSizeTest{
+			{"main", []string{"leaf", "tailcall"}, 8 + 8 + 32, Bounded},
+			{"leaf", nil, 32, Bounded},
+			{"tailcall", []string{"leaf"}, 8 + 32, Bounded},
+			{"funcptr", nil, 0, Bounded},
+			{"recursive", []string{"recursive"}, 0, Recursive},
+		}},
+		{"riscv.elf", []stackSizeTest{
+			{"main", []string{"leaf", "tailcall"}, 16 + 8 + 32, Bounded},
+			{"leaf", nil, 32, Bounded},
+			{"tailcall", []string{"leaf"}, 8 + 32, Bounded},
+			{"funcptr", nil, 0, Bounded},
+			{"recursive", []string{"recursive"}, 0, Recursive},
+			{"jumpself", nil, 0, Bounded},
+			{"framepointer", nil, 0, Unknown},
+		}},
+		{"avr.elf", []stackSizeTest{
+			{"main", []string{"leaf", "tailcall"}, 2 + 1 + 4, Bounded},
+			{"leaf", nil, 4, Bounded},
+			{"tailcall", []string{"leaf"}, 1 + 4, Bounded},
+			{"funcptr", nil, 0, Bounded},
+			{"recursive", []string{"recursive"}, 0, Recursive},
+		}},
+		{"amd64.elf", []stackSizeTest{
+			// The CFA includes the return address on x86.
+			{"main", []string{"leaf", "tailcall"}, 16 + 16 + 32, Bounded},
+			{"leaf", nil, 32, Bounded},
+			{"tailcall", []string{"leaf"}, 16 + 32, Bounded},
+			{"funcptr", nil, 8, Bounded},
+			{"recursive", []string{"recursive"}, 0, Recursive},
+			{"framepointer", nil, 0, Unknown},
+		}},
+		{"aarch64.elf", []stackSizeTest{
+			{"main", []string{"leaf", "tailcall"}, 16 + 16 + 32, Bounded},
+			{"leaf", nil, 32, Bounded},
+			{"tailcall", []string{"leaf"}, 16 + 32, Bounded},
+			{"funcptr", nil, 0, Bounded},
+			{"recursive", []string{"recursive"}, 0, Recursive},
+			{"framepointer", nil, 0, Unknown},
+		}},
+	} {
+		tc := tc
+		t.Run(tc.file, func(t *testing.T) {
+			f, err := elf.Open(filepath.Join("testdata", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			graph, err := CallGraph(f, nil)
+			if err != nil {
+				t.Fatal("could not build call graph:", err)
+			}
+			for _, test := range tc.tests {
+				if len(graph[test.name]) != 1 {
+					t.Errorf("%s: expected one function, got %d", test.name, len(graph[test.name]))
+					continue
+				}
+				node := graph[test.name][0]
+				var children []string
+				seen := map[string]bool{}
+				for _, child := range node.Children {
+					if !seen[child.Names[0]] {
+						seen[child.Names[0]] = true
+						children = append(children, child.Names[0])
+					}
+				}
+				sort.Strings(children)
+				if !equalStrings(children, test.children) {
+					t.Errorf("%s: expected children %v, got %v", test.name, test.children, children)
+				}
+				stackSize, sizeType, _ := node.StackSize()
+				if sizeType != test.sizeType {
+					t.Errorf("%s: expected stack size type %s, got %s", test.name, test.sizeType, sizeType)
+				} else if sizeType == Bounded && stackSize != test.stackSize {
+					t.Errorf("%s: expected stack size %d, got %d", test.name, test.stackSize, stackSize)
+				}
+			}
+		})
+	}
+}
+
+func TestCallGraphIndirect(t *testing.T) {
+	f, err := elf.Open("testdata/riscv.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	graph, err := CallGraph(f, []string{"tailcall"})
+	if err != nil {
+		t.Fatal("could not build call graph:", err)
+	}
+	_, sizeType, missing := graph["main"][0].StackSize()
+	if sizeType != IndirectCall || missing != graph["tailcall"][0] {
+		t.Errorf("expected an indirect call in tailcall, got %s in %s", sizeType, missing)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCallGraphNoRelocations(t *testing.T) {
+	f, err := elf.Open("testdata/norelocs.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = CallGraph(f, nil)
+	if err == nil || !strings.Contains(err.Error(), "--emit-relocs") {
+		t.Errorf("expected an error about missing relocations, got %v", err)
+	}
+}
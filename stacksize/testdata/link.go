@@ -0,0 +1,226 @@
+//go:build ignore
+// +build ignore
+
+// This program turns an object file into something that looks like a linked
+// executable as produced with --emit-relocs, for use as a test fixture. There
+// is no suitable linker for all architectures, and the test only needs a
+// single .text section at a non-zero address. It does the following:
+//   - sets the address of the .text section
+//   - adds this address to all symbols and code relocations in .text
+//   - applies the relocations in .debug_frame
+//
+// Usage: go run link.go input.o output.elf
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Address at which the .text section is "linked".
+const textAddress = 0x1000
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: go run link.go input.o output.elf")
+		os.Exit(1)
+	}
+	err := link(os.Args[1], os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func link(input, output string) error {
+	f, err := elf.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	bo := f.ByteOrder
+	is64 := f.Class == elf.ELFCLASS64
+
+	// Read the section headers directly, as we need to modify them.
+	var shoff, shentsize uint64
+	if is64 {
+		shoff = bo.Uint64(buf[0x28:])
+		shentsize = uint64(bo.Uint16(buf[0x3a:]))
+	} else {
+		shoff = uint64(bo.Uint32(buf[0x20:]))
+		shentsize = uint64(bo.Uint16(buf[0x2e:]))
+	}
+	header := func(index int) []byte {
+		return buf[shoff+uint64(index)*shentsize:]
+	}
+
+	// Change the file type to ET_EXEC.
+	bo.PutUint16(buf[0x10:], uint16(elf.ET_EXEC))
+
+	// Set the .text address.
+	textIndex := -1
+	for i, section := range f.Sections {
+		if section.Name == ".text" {
+			textIndex = i
+		}
+	}
+	if textIndex < 0 {
+		return fmt.Errorf("no .text section")
+	}
+	if is64 {
+		bo.PutUint64(header(textIndex)[0x10:], textAddress)
+	} else {
+		bo.PutUint32(header(textIndex)[0x0c:], textAddress)
+	}
+
+	// Move all symbols in .text.
+	symtab := f.SectionByType(elf.SHT_SYMTAB)
+	symbols, err := f.Symbols()
+	if err != nil {
+		return err
+	}
+	values := make([]uint64, len(symbols)+1)
+	for i, sym := range symbols {
+		values[i+1] = sym.Value
+		if int(sym.Section) != textIndex {
+			continue
+		}
+		values[i+1] += textAddress
+		entry := buf[symtab.Offset+uint64(i+1)*symtab.Entsize:]
+		if is64 {
+			bo.PutUint64(entry[8:], values[i+1])
+		} else {
+			bo.PutUint32(entry[4:], uint32(values[i+1]))
+		}
+	}
+
+	for _, section := range f.Sections {
+		if section.Type != elf.SHT_REL && section.Type != elf.SHT_RELA {
+			continue
+		}
+		target := f.Sections[section.Info]
+		for i := uint64(0); i < section.Size/section.Entsize; i++ {
+			entry := buf[section.Offset+i*section.Entsize:]
+			var offset, addend uint64
+			var sym, typ uint32
+			if is64 {
+				offset = bo.Uint64(entry)
+				info := bo.Uint64(entry[8:])
+				sym, typ = elf.R_SYM64(info), elf.R_TYPE64(info)
+				if section.Type == elf.SHT_RELA {
+					addend = bo.Uint64(entry[16:])
+				}
+			} else {
+				offset = uint64(bo.Uint32(entry))
+				info := bo.Uint32(entry[4:])
+				sym, typ = elf.R_SYM32(info), elf.R_TYPE32(info)
+				if section.Type == elf.SHT_RELA {
+					addend = uint64(int64(int32(bo.Uint32(entry[8:]))))
+				}
+			}
+			if int(section.Info) == textIndex {
+				// Code relocation: keep it, but at the new address.
+				if is64 {
+					bo.PutUint64(entry, offset+textAddress)
+				} else {
+					bo.PutUint32(entry, uint32(offset+textAddress))
+				}
+				continue
+			}
+			if target.Name != ".debug_frame" {
+				continue
+			}
+			err := apply(f.Machine, bo, buf[target.Offset+offset:], typ, values[sym]+addend, section.Type == elf.SHT_REL)
+			if err != nil {
+				return fmt.Errorf("%s+0x%x: %w", target.Name, offset, err)
+			}
+		}
+	}
+
+	return os.WriteFile(output, buf, 0o644)
+}
+
+// apply applies a single data relocation with value S+A. For REL relocations,
+// the addend is stored in place.
+func apply(machine elf.Machine, bo binary.ByteOrder, data []byte, typ uint32, value uint64, rel bool) error {
+	var size int
+	op := "set"
+	switch {
+	case machine == elf.EM_ARM && elf.R_ARM(typ) == elf.R_ARM_ABS32,
+		machine == elf.EM_RISCV && elf.R_RISCV(typ) == elf.R_RISCV_32,
+		machine == elf.EM_X86_64 && elf.R_X86_64(typ) == elf.R_X86_64_32,
+		machine == elf.EM_AARCH64 && elf.R_AARCH64(typ) == elf.R_AARCH64_ABS32,
+		machine == elf.EM_AVR && typ == 1: // R_AVR_32
+		size = 4
+	case machine == elf.EM_RISCV && elf.R_RISCV(typ) == elf.R_RISCV_64,
+		machine == elf.EM_X86_64 && elf.R_X86_64(typ) == elf.R_X86_64_64,
+		machine == elf.EM_AARCH64 && elf.R_AARCH64(typ) == elf.R_AARCH64_ABS64:
+		size = 8
+	case machine == elf.EM_AVR && typ == 4: // R_AVR_16
+		size = 2
+	case machine == elf.EM_RISCV:
+		switch elf.R_RISCV(typ) {
+		case elf.R_RISCV_ADD8, elf.R_RISCV_ADD16, elf.R_RISCV_ADD32, elf.R_RISCV_ADD64:
+			op = "add"
+		case elf.R_RISCV_SUB6, elf.R_RISCV_SUB8, elf.R_RISCV_SUB16, elf.R_RISCV_SUB32, elf.R_RISCV_SUB64:
+			op = "sub"
+		case elf.R_RISCV_SET6, elf.R_RISCV_SET8, elf.R_RISCV_SET16, elf.R_RISCV_SET32:
+		default:
+			return fmt.Errorf("unknown relocation %s", elf.R_RISCV(typ))
+		}
+		switch elf.R_RISCV(typ) {
+		case elf.R_RISCV_SUB6, elf.R_RISCV_SET6:
+			size = -6
+		case elf.R_RISCV_ADD8, elf.R_RISCV_SUB8, elf.R_RISCV_SET8:
+			size = 1
+		case elf.R_RISCV_ADD16, elf.R_RISCV_SUB16, elf.R_RISCV_SET16:
+			size = 2
+		case elf.R_RISCV_ADD32, elf.R_RISCV_SUB32, elf.R_RISCV_SET32:
+			size = 4
+		case elf.R_RISCV_ADD64, elf.R_RISCV_SUB64:
+			size = 8
+		}
+	default:
+		return fmt.Errorf("unknown relocation type %d", typ)
+	}
+
+	var old uint64
+	switch size {
+	case -6, 1:
+		old = uint64(data[0])
+	case 2:
+		old = uint64(bo.Uint16(data))
+	case 4:
+		old = uint64(bo.Uint32(data))
+	case 8:
+		old = bo.Uint64(data)
+	}
+	if rel {
+		value += old
+	}
+	switch op {
+	case "add":
+		value = old + value
+	case "sub":
+		value = old - value
+	}
+	switch size {
+	case -6:
+		data[0] = data[0]&^0x3f | byte(value)&0x3f
+	case 1:
+		data[0] = byte(value)
+	case 2:
+		bo.PutUint16(data, uint16(value))
+	case 4:
+		bo.PutUint32(data, uint32(value))
+	case 8:
+		bo.PutUint64(data, value)
+	}
+	return nil
+}
@@ -136,12 +136,34 @@
 		constructor() {
 			this._callbackTimeouts = new Map();
 			this._nextCallbackTimeoutID = 1;
+			this._signalListeners = new Map();
 
 			const mem = () => {
 				// The buffer may change when requesting more memory.
 				return new DataView(this._inst.exports.memory.buffer);
 			}
 
+			// Signal numbers as defined in the syscall package for js/wasm.
+			const signalNames = {1: "SIGCHLD", 2: "SIGINT", 5: "SIGQUIT", 6: "SIGTERM", 12: "SIGPIPE"};
+
+			// Replace the process listener for the given signal. Signals are
+			// only supported in Node.js.
+			const setSignalListener = (sig, listener) => {
+				const name = signalNames[sig];
+				if (!name || !global.process || !process.on) {
+					return;
+				}
+				const old = this._signalListeners.get(sig);
+				if (old) {
+					process.off(name, old);
+					this._signalListeners.delete(sig);
+				}
+				if (listener) {
+					process.on(name, listener);
+					this._signalListeners.set(sig, listener);
+				}
+			}
+
 			const setInt64 = (addr, v) => {
 				mem().setUint32(addr + 0, v, true);
 				mem().setUint32(addr + 4, Math.floor(v / 4294967296), true);
@@ -306,6 +328,28 @@
 						setTimeout(this._inst.exports.go_scheduler, timeout);
 					},
 
+					// func signalEnable(sig uint32)
+					"runtime.signalEnable": (sig) => {
+						setSignalListener(sig, () => {
+							if (this.exited) {
+								return;
+							}
+							this._inst.exports.tinygo_signal_handler(sig);
+							this._inst.exports.go_scheduler();
+						});
+					},
+
+					// func signalDisable(sig uint32)
+					"runtime.signalDisable": (sig) => {
+						setSignalListener(sig, null);
+					},
+
+					// func signalIgnore(sig uint32)
+					"runtime.signalIgnore": (sig) => {
+						// Keep Node.js from handling the signal.
+						setSignalListener(sig, () => {});
+					},
+
 					// func finalizeRef(v ref)
 					"syscall/js.finalizeRef": (sp) => {
 						// Note: TinyGo does not support finalizers so this should never be
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	// Signals for which Notify was called are delivered to the channel.
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	select {
+	case sig := <-c:
+		println("got signal:", sig.String())
+	case <-time.After(time.Second):
+		println("timeout waiting for signal")
+	}
+	signal.Stop(c)
+
+	// Ignored signals don't kill the process.
+	signal.Ignore(syscall.SIGUSR2)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+	println("ignored:", signal.Ignored(syscall.SIGUSR2))
+
+	// SIGCHLD is delivered when a child process exits, and the child can still
+	// be waited for.
+	signal.Notify(c, syscall.SIGCHLD)
+	err := exec.Command("true").Run()
+	println("child exited:", err == nil)
+	select {
+	case sig := <-c:
+		println("got signal:", sig.String())
+	case <-time.After(time.Second):
+		println("timeout waiting for signal")
+	}
+	signal.Stop(c)
+
+	// Writing to a closed pipe returns an error instead of killing the
+	// process.
+	r, w, err := os.Pipe()
+	if err != nil {
+		println("could not create pipe:", err.Error())
+		return
+	}
+	r.Close()
+	_, err = w.Write([]byte("data"))
+	println("write to closed pipe:", err != nil)
+}
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+func main() {
+	// Sleep a few times, to check that the CPU wakes up again every time.
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+			println("slept too short:", int64(elapsed))
+		}
+		println("woke up", i)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+// This test is run with -scheduler=threads. It checks that goroutines really
+// run in parallel and that the runtime (channels, sync, the GC) is safe to use
+// from multiple threads at the same time.
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	println("NumCPU > 0:", runtime.NumCPU() > 0)
+	println("GOMAXPROCS == NumCPU:", runtime.GOMAXPROCS(0) == runtime.NumCPU())
+
+	// A goroutine that spins without yielding must not block the main
+	// goroutine, as long as there is a processor for both of them.
+	prevProcs := runtime.GOMAXPROCS(2)
+	var stop uint32
+	go func() {
+		for atomic.LoadUint32(&stop) == 0 {
+		}
+		atomic.StoreUint32(&stop, 2)
+	}()
+	time.Sleep(time.Millisecond)
+	atomic.StoreUint32(&stop, 1)
+	for atomic.LoadUint32(&stop) != 2 {
+		runtime.Gosched()
+	}
+	println("spinning goroutine stopped")
+
+	// GOMAXPROCS limits the number of goroutines that run at the same time.
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			// Keep the processor for a while, without blocking.
+			start := time.Now()
+			for time.Since(start) < 2*time.Millisecond {
+			}
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	println("GOMAXPROCS limits parallelism:", maxRunning >= 1 && maxRunning <= 2)
+	runtime.GOMAXPROCS(prevProcs)
+
+	// Many goroutines incrementing shared counters.
+	const numWorkers = 8
+	const numIncrements = 10000
+	var mu sync.Mutex
+	var counter int
+	var atomicCounter int64
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numIncrements; j++ {
+				mu.Lock()
+				counter++
+				mu.Unlock()
+				atomic.AddInt64(&atomicCounter, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	println("mutex counter:", counter)
+	println("atomic counter:", atomicCounter)
+
+	// Channels between parallel goroutines.
+	results := make(chan int)
+	for i := 0; i < numWorkers; i++ {
+		go func(n int) {
+			sum := 0
+			for j := 0; j <= n*1000; j++ {
+				sum += j
+			}
+			results <- sum
+		}(i)
+	}
+	total := 0
+	for i := 0; i < numWorkers; i++ {
+		total += <-results
+	}
+	println("channel total:", total)
+
+	// Allocate from many goroutines at once, forcing GC cycles while other
+	// goroutines are running.
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			var list *node
+			for j := 0; j < 2000; j++ {
+				if j%500 == 0 {
+					list = nil
+				}
+				list = &node{value: j, next: list, data: make([]byte, 128)}
+			}
+			sum := 0
+			for ; list != nil; list = list.next {
+				sum += list.value
+			}
+			if sum != 874750 {
+				println("unexpected sum:", n, sum)
+			}
+		}(i)
+	}
+	runtime.GC()
+	wg.Wait()
+	println("GC stress done")
+}
+
+type node struct {
+	value int
+	next  *node
+	data  []byte
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testEvent is a single event in the output of `tinygo test -json`, in the
+// same format as `go test -json` (see `go doc test2json`).
+type testEvent struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+}
+
+var (
+	// Result line of a test, like "--- PASS: TestFoo (0.00s)".
+	testResultRegexp = regexp.MustCompile(`^--- (PASS|FAIL|SKIP|BENCH): (\S+)(?: \(([0-9.]+)s\))?`)
+
+	// Result line of a benchmark, like "BenchmarkFoo  1000  1234 ns/op".
+	benchResultRegexp = regexp.MustCompile(`^(Benchmark\S*)\s+[0-9]+\s+[0-9.]+ ns/op`)
+)
+
+// testJSONWriter converts the verbose output of a test binary (written to it)
+// into a stream of JSON test events, like `go tool test2json`. The pkg field
+// must be set before the first write.
+type testJSONWriter struct {
+	out      io.Writer
+	pkg      string
+	line     []byte   // incomplete line
+	running  []string // tests that started but didn't report a result yet
+	reported string   // test that most recently reported a result
+	started  bool
+	err      error
+}
+
+// Write implements io.Writer. It emits events for each complete line.
+func (w *testJSONWriter) Write(data []byte) (int, error) {
+	w.line = append(w.line, data...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.handleLine(string(w.line[:i+1]))
+		w.line = w.line[i+1:]
+	}
+	return len(data), w.err
+}
+
+// finish emits the remaining output, the summary line (like "ok  \tpkg\t0.1s")
+// and the final event for the package, with an action of "pass", "fail" or
+// "skip".
+func (w *testJSONWriter) finish(action, summary string, elapsed time.Duration) error {
+	if len(w.line) != 0 {
+		w.handleLine(string(w.line) + "\n")
+		w.line = nil
+	}
+	if action == "fail" {
+		// Tests that were still running when the test binary exited (for
+		// example because of a panic) failed too.
+		for i := len(w.running) - 1; i >= 0; i-- {
+			w.emit(testEvent{Action: "fail", Test: w.running[i]})
+		}
+		w.running = nil
+	}
+	w.emit(testEvent{Action: "output", Output: summary})
+	event := testEvent{Action: action}
+	if action != "skip" {
+		seconds := elapsed.Seconds()
+		event.Elapsed = &seconds
+	}
+	w.emit(event)
+	return w.err
+}
+
+// handleLine emits the events for a single line of test output.
+func (w *testJSONWriter) handleLine(line string) {
+	trimmed := strings.TrimLeft(line, " ")
+	for _, prefix := range []struct{ text, action string }{
+		{"=== RUN   ", "run"},
+		{"=== PAUSE ", "pause"},
+		{"=== CONT  ", "cont"},
+	} {
+		if !strings.HasPrefix(trimmed, prefix.text) {
+			continue
+		}
+		name := strings.TrimSpace(trimmed[len(prefix.text):])
+		if prefix.action == "run" {
+			w.running = append(w.running, name)
+		}
+		w.emit(testEvent{Action: prefix.action, Test: name})
+		w.emit(testEvent{Action: "output", Test: name, Output: line})
+		return
+	}
+
+	if match := testResultRegexp.FindStringSubmatch(trimmed); match != nil {
+		name := match[2]
+		w.emit(testEvent{Action: "output", Test: name, Output: line})
+		event := testEvent{Action: strings.ToLower(match[1]), Test: name}
+		if seconds, err := strconv.ParseFloat(match[3], 64); err == nil {
+			event.Elapsed = &seconds
+		}
+		w.emit(event)
+		for i := len(w.running) - 1; i >= 0; i-- {
+			if w.running[i] == name {
+				w.running = append(w.running[:i], w.running[i+1:]...)
+				break
+			}
+		}
+		w.reported = name
+		return
+	}
+
+	if match := benchResultRegexp.FindStringSubmatch(line); match != nil {
+		w.emit(testEvent{Action: "output", Test: match[1], Output: line})
+		w.emit(testEvent{Action: "bench", Test: match[1]})
+		return
+	}
+
+	// Regular output. Indented lines following a result line are the log
+	// output of that test, other lines belong to the test that is running.
+	test := ""
+	if trimmed != line && w.reported != "" {
+		test = w.reported
+	} else {
+		w.reported = ""
+		if len(w.running) != 0 {
+			test = w.running[len(w.running)-1]
+		}
+	}
+	w.emit(testEvent{Action: "output", Test: test, Output: line})
+}
+
+// emit writes a single event as a line of JSON, preceded by a "start" event
+// for the package if this is the first event.
+func (w *testJSONWriter) emit(event testEvent) {
+	if w.err != nil {
+		return
+	}
+	if !w.started {
+		w.started = true
+		w.emit(testEvent{Action: "start"})
+	}
+	now := time.Now()
+	event.Time = &now
+	event.Package = w.pkg
+	data, err := json.Marshal(event)
+	if err != nil {
+		w.err = err
+		return
+	}
+	_, w.err = w.out.Write(append(data, '\n'))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &testJSONWriter{out: &buf, pkg: "example.com/foo"}
+	w.Write([]byte("=== RUN   TestA\n--- PASS: TestA (0.00s)\n=== RUN   TestA/b\n"))
+	w.Write([]byte("    --- FAIL: TestA/b (0.01s)\n        foo_test.go:10: oops\n"))
+	w.Write([]byte("=== RUN   TestPanic\npanic: bad\n"))
+	w.Write([]byte("BenchmarkFoo   \t1000\t  123 ns/op\nexit status 2"))
+	if err := w.finish("fail", "FAIL\texample.com/foo\t0.100s\n", 100*time.Millisecond); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := []string{
+		"start  ",
+		"run TestA ",
+		"output TestA === RUN   TestA",
+		"output TestA --- PASS: TestA (0.00s)",
+		"pass TestA ",
+		"run TestA/b ",
+		"output TestA/b === RUN   TestA/b",
+		"output TestA/b     --- FAIL: TestA/b (0.01s)",
+		"fail TestA/b ",
+		"output TestA/b         foo_test.go:10: oops",
+		"run TestPanic ",
+		"output TestPanic === RUN   TestPanic",
+		"output TestPanic panic: bad",
+		"output BenchmarkFoo BenchmarkFoo   \t1000\t  123 ns/op",
+		"bench BenchmarkFoo ",
+		"output TestPanic exit status 2",
+		"fail TestPanic ",
+		"output  FAIL\texample.com/foo\t0.100s",
+		"fail  ",
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d events, got %d:\n%s", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var event testEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("could not parse event %q: %v", line, err)
+		}
+		if event.Package != "example.com/foo" || event.Time == nil {
+			t.Errorf("event %d: missing package or time: %s", i, line)
+		}
+		got := event.Action + " " + event.Test + " " + strings.TrimSuffix(event.Output, "\n")
+		if got != expected[i] {
+			t.Errorf("event %d: expected %q, got %q", i, expected[i], got)
+		}
+	}
+}
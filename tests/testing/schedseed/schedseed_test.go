@@ -0,0 +1,28 @@
+package schedseed_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestInterleaving prints the order in which goroutines took a mutex, which
+// depends on the seed of the randomized scheduler.
+func TestInterleaving(t *testing.T) {
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	fmt.Println("order:", order)
+}
@@ -0,0 +1,135 @@
+package transform
+
+import (
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// Packages that are not instrumented for fuzzing, because their coverage is
+// not interesting or (in the case of the runtime) changes with every input. This
+// is the same list as used by the go tool, plus the TinyGo-specific internal
+// packages.
+var fuzzNoInstrument = []string{
+	"context",
+	"internal/fuzz",
+	"internal/task",
+	"reflect",
+	"runtime",
+	"sync",
+	"sync/atomic",
+	"syscall",
+	"testing",
+	"time",
+}
+
+// AddFuzzCoverage instruments all functions (except those in the packages
+// above) with coverage counters for fuzzing, in the same way as the
+// inline-8bit-counters mode of LLVM SanitizerCoverage: every basic block
+// increments its own 8-bit counter. The counters are registered with the
+// fuzzing engine by calling __sanitizer_cov_8bit_counters_init from a module
+// constructor, which is the interface used by libFuzzer.
+func AddFuzzCoverage(mod llvm.Module) {
+	ctx := mod.Context()
+
+	// Collect all basic blocks to instrument.
+	var blocks []llvm.BasicBlock
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() || !shouldInstrumentForFuzzing(fn.Name()) {
+			continue
+		}
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			blocks = append(blocks, bb)
+		}
+	}
+	if len(blocks) == 0 {
+		return
+	}
+
+	// Create the counters, one byte per basic block.
+	i8Type := ctx.Int8Type()
+	i32Type := ctx.Int32Type()
+	countersType := llvm.ArrayType(i8Type, len(blocks))
+	counters := llvm.AddGlobal(mod, countersType, "__sancov_gen_.counters")
+	counters.SetInitializer(llvm.ConstNull(countersType))
+	counters.SetLinkage(llvm.PrivateLinkage)
+	counters.SetAlignment(1)
+
+	// Increment the counter at the start of each basic block.
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	for i, bb := range blocks {
+		insertionPoint := bb.FirstInstruction()
+		for !insertionPoint.IsAPHINode().IsNil() {
+			insertionPoint = llvm.NextInstruction(insertionPoint)
+		}
+		builder.SetInsertPointBefore(insertionPoint)
+		counter := llvm.ConstInBoundsGEP(counters, []llvm.Value{
+			llvm.ConstInt(i32Type, 0, false),
+			llvm.ConstInt(i32Type, uint64(i), false),
+		})
+		value := builder.CreateLoad(counter, "")
+		value = builder.CreateAdd(value, llvm.ConstInt(i8Type, 1, false), "")
+		builder.CreateStore(value, counter)
+	}
+
+	// Register the counters from a module constructor.
+	i8ptrType := llvm.PointerType(i8Type, 0)
+	initType := llvm.FunctionType(ctx.VoidType(), []llvm.Type{i8ptrType, i8ptrType}, false)
+	initFn := mod.NamedFunction("__sanitizer_cov_8bit_counters_init")
+	if initFn.IsNil() {
+		initFn = llvm.AddFunction(mod, "__sanitizer_cov_8bit_counters_init", initType)
+	}
+	ctorType := llvm.FunctionType(ctx.VoidType(), nil, false)
+	ctor := llvm.AddFunction(mod, "sancov.module_ctor_8bit_counters", ctorType)
+	ctor.SetLinkage(llvm.InternalLinkage)
+	builder.SetInsertPointAtEnd(ctx.AddBasicBlock(ctor, "entry"))
+	start := llvm.ConstBitCast(counters, i8ptrType)
+	end := llvm.ConstInBoundsGEP(start, []llvm.Value{llvm.ConstInt(i32Type, uint64(len(blocks)), false)})
+	builder.CreateCall(initFn, []llvm.Value{start, end}, "")
+	builder.CreateRetVoid()
+	appendToGlobalCtors(mod, ctor, 2)
+}
+
+// shouldInstrumentForFuzzing returns whether the function with the given name
+// should get coverage counters.
+func shouldInstrumentForFuzzing(name string) bool {
+	if strings.HasPrefix(name, "__sanitizer_") {
+		// The fuzzing engine itself.
+		return false
+	}
+	// Strip the receiver, like in (*sync.Mutex).Lock.
+	name = strings.TrimPrefix(name, "(")
+	name = strings.TrimPrefix(name, "*")
+	for _, pkg := range fuzzNoInstrument {
+		if strings.HasPrefix(name, pkg+".") || strings.HasPrefix(name, pkg+"$") {
+			return false
+		}
+	}
+	return true
+}
+
+// appendToGlobalCtors adds the given function to llvm.global_ctors, so that it
+// is called (with the given priority) before the program starts.
+func appendToGlobalCtors(mod llvm.Module, fn llvm.Value, priority int) {
+	ctx := mod.Context()
+	entry := ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(ctx.Int32Type(), uint64(priority), false),
+		fn,
+		llvm.ConstNull(llvm.PointerType(ctx.Int8Type(), 0)),
+	}, false)
+	var entries []llvm.Value
+	if ctors := mod.NamedGlobal("llvm.global_ctors"); !ctors.IsNil() {
+		// Keep the existing constructors.
+		initializer := ctors.Initializer()
+		for i := 0; i < initializer.Type().ArrayLength(); i++ {
+			entries = append(entries, llvm.ConstExtractValue(initializer, []uint32{uint32(i)}))
+		}
+		ctors.EraseFromParentAsGlobal()
+	}
+	entries = append(entries, entry)
+	initializer := llvm.ConstArray(entry.Type(), entries)
+	ctors := llvm.AddGlobal(mod, initializer.Type(), "llvm.global_ctors")
+	ctors.SetInitializer(initializer)
+	ctors.SetLinkage(llvm.AppendingLinkage)
+}
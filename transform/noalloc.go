@@ -0,0 +1,144 @@
+package transform
+
+// This file implements a check that functions marked //go:noalloc don't
+// allocate memory or block. Doing either in an interrupt corrupts the heap or
+// the scheduler state, which usually only shows up as a hard to debug crash,
+// so interrupt handlers can be marked //go:noalloc to catch this at compile
+// time.
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// noallocForbidden lists the runtime functions that may not be reached from a
+// noalloc function, with a description of what they do.
+var noallocForbidden = map[string]string{
+	"runtime.alloc":       "allocate memory",
+	"internal/task.Pause": "block", // used by channel operations, time.Sleep, sync.Mutex etc
+}
+
+// pinNoAllocRoot makes sure the function is not inlined before CheckNoAlloc
+// runs: once inlined into its callers and removed, there is nothing left to
+// check. The noinline attribute is removed again by unpinNoAllocRoots.
+func pinNoAllocRoot(fn llvm.Value) {
+	noinline := llvm.AttributeKindID("noinline")
+	if !fn.GetEnumFunctionAttribute(noinline).IsNil() {
+		// Already marked //go:noinline.
+		return
+	}
+	ctx := fn.GlobalParent().Context()
+	fn.AddFunctionAttr(ctx.CreateEnumAttribute(noinline, 0))
+	fn.AddFunctionAttr(ctx.CreateStringAttribute("tinygo-noalloc-pinned", ""))
+}
+
+// pinNoAllocRoots pins all functions marked //go:noalloc, see pinNoAllocRoot.
+func pinNoAllocRoots(mod llvm.Module) {
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.GetStringAttributeAtIndex(-1, "tinygo-noalloc").IsNil() {
+			pinNoAllocRoot(fn)
+		}
+	}
+}
+
+// unpinNoAllocRoots allows the functions pinned by pinNoAllocRoot to be
+// inlined again, after CheckNoAlloc has run.
+func unpinNoAllocRoots(mod llvm.Module) {
+	noinline := llvm.AttributeKindID("noinline")
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.GetStringAttributeAtIndex(-1, "tinygo-noalloc-pinned").IsNil() {
+			continue
+		}
+		fn.RemoveEnumFunctionAttribute(noinline)
+		fn.RemoveStringAttributeAtIndex(-1, "tinygo-noalloc-pinned")
+	}
+}
+
+// CheckNoAlloc checks that functions marked //go:noalloc can't reach a heap
+// allocation or a call that blocks (such as a channel send or
+// sync.Mutex.Lock), and returns an error with the call chain for each that
+// does. When optimizing, it must be run after OptimizeAllocs, so
+// that allocations that were moved to the stack are not reported. Without
+// optimizations, all allocations are reported.
+//
+// Only direct calls are followed: calls through a function pointer or an
+// interface that could not be devirtualized are not checked.
+func CheckNoAlloc(mod llvm.Module) []error {
+	var errs []error
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		if fn.GetStringAttributeAtIndex(-1, "tinygo-noalloc").IsNil() {
+			continue
+		}
+		chain, what := findForbiddenCall(fn)
+		if chain == nil {
+			continue
+		}
+		msg := fmt.Sprintf("function marked //go:noalloc %s may %s: %s", fn.Name(), what, formatCallChain(fn, chain))
+		errs = append(errs, errorAt(chain[0], msg))
+	}
+	return errs
+}
+
+// findForbiddenCall searches (breadth-first, to find the shortest path) for a
+// call from fn to one of the functions in noallocForbidden. It returns the call
+// instructions that lead to it and what the called function does, or nil if
+// there is no such call.
+func findForbiddenCall(fn llvm.Value) ([]llvm.Value, string) {
+	// For each visited function, the call that first reached it.
+	reachedBy := map[llvm.Value]llvm.Value{fn: {}}
+	worklist := []llvm.Value{fn}
+	for len(worklist) != 0 {
+		caller := worklist[0]
+		worklist = worklist[1:]
+		for bb := caller.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				if inst.IsACallInst().IsNil() && inst.IsAInvokeInst().IsNil() {
+					continue
+				}
+				callee := inst.CalledValue()
+				if callee.IsAFunction().IsNil() {
+					// Indirect call or inline assembly.
+					continue
+				}
+				if _, ok := reachedBy[callee]; ok {
+					continue
+				}
+				reachedBy[callee] = inst
+				if what, ok := noallocForbidden[callee.Name()]; ok {
+					// Walk back to construct the call chain.
+					var chain []llvm.Value
+					for call := inst; !call.IsNil(); call = reachedBy[call.InstructionParent().Parent()] {
+						chain = append([]llvm.Value{call}, chain...)
+					}
+					return chain, what
+				}
+				if !callee.IsDeclaration() {
+					worklist = append(worklist, callee)
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// formatCallChain returns a human readable call chain, like:
+//
+//	main.handler -> main.foo (main.go:12) -> runtime.alloc (main.go:30)
+func formatCallChain(fn llvm.Value, chain []llvm.Value) string {
+	parts := []string{fn.Name()}
+	for _, call := range chain {
+		part := call.CalledValue().Name()
+		if pos := getPosition(call); pos.IsValid() {
+			part += " (" + filepath.Base(pos.Filename) + ":" + strconv.Itoa(pos.Line) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " -> ")
+}
@@ -0,0 +1,44 @@
+package transform_test
+
+import (
+	"go/scanner"
+	"os"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestCheckNoAlloc(t *testing.T) {
+	t.Parallel()
+
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/noalloc.ll")
+	os.Stat("testdata/noalloc.ll") // make sure this file is tracked by `go test` caching
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+	defer mod.Dispose()
+
+	var msgs []string
+	for _, err := range transform.CheckNoAlloc(mod) {
+		msgs = append(msgs, err.(scanner.Error).Msg)
+	}
+	expected := []string{
+		"function marked //go:noalloc main.allocates may allocate memory: main.allocates -> main.helper -> runtime.alloc",
+		"function marked //go:noalloc main.blocks may block: main.blocks -> (*sync.Mutex).Lock -> internal/task.Pause",
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %q", len(expected), len(msgs), msgs)
+	}
+	for i, msg := range msgs {
+		if msg != expected[i] {
+			t.Errorf("unexpected error:\nexpected: %s\nactual:   %s", expected[i], msg)
+		}
+	}
+}
@@ -72,6 +72,10 @@ func Optimize(mod llvm.Module, config *compileopts.Config, optLevel, sizeLevel i
 		fn.SetLinkage(llvm.ExternalLinkage)
 	}
 
+	// Record which functions CheckNoAlloc needs to check, before any of them
+	// can be inlined.
+	pinNoAllocRoots(mod)
+
 	if config.PanicStrategy() == "trap" {
 		ReplacePanicsWithTrap(mod) // -panic=trap
 	}
@@ -120,6 +124,7 @@ func Optimize(mod llvm.Module, config *compileopts.Config, optLevel, sizeLevel i
 		OptimizeAllocs(mod, config.Options.PrintAllocs, func(pos token.Position, msg string) {
 			fmt.Fprintln(os.Stderr, pos.String()+": "+msg)
 		})
+
 		OptimizeStringToBytes(mod)
 		OptimizeStringEqual(mod)
 
@@ -142,6 +147,13 @@ func Optimize(mod llvm.Module, config *compileopts.Config, optLevel, sizeLevel i
 		goPasses.Run(mod)
 	}
 
+	// Now that heap allocations have been moved to the stack where possible,
+	// check that //go:noalloc functions don't allocate or block.
+	if errs := CheckNoAlloc(mod); len(errs) > 0 {
+		return errs
+	}
+	unpinNoAllocRoots(mod)
+
 	if config.Scheduler() == "none" {
 		// Check for any goroutine starts.
 		if start := mod.NamedFunction("internal/task.start"); !start.IsNil() && len(getUses(start)) > 0 {
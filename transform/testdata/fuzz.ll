@@ -0,0 +1,36 @@
+target datalayout = "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64-unknown-linux"
+
+declare void @runtime.printint(i64)
+
+; The runtime is not instrumented.
+define void @runtime.foo(i64 %x) {
+entry:
+  call void @runtime.printint(i64 %x)
+  ret void
+}
+
+define i64 @main.max(i64 %a, i64 %b) {
+entry:
+  %cmp = icmp sgt i64 %a, %b
+  br i1 %cmp, label %if.then, label %if.done
+
+if.then:
+  br label %if.done
+
+if.done:
+  %result = phi i64 [ %a, %if.then ], [ %b, %entry ]
+  ret i64 %result
+}
+
+define void @"(*main.T).Method"(i64 %x) {
+entry:
+  call void @runtime.foo(i64 %x)
+  ret void
+}
+
+; The function that receives the counters isn't instrumented either.
+define void @__sanitizer_cov_8bit_counters_init(i8* %start, i8* %end) {
+entry:
+  ret void
+}
@@ -0,0 +1,55 @@
+target datalayout = "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64-unknown-linux"
+
+@__sancov_gen_.counters = private global [4 x i8] zeroinitializer, align 1
+@llvm.global_ctors = appending global [1 x { i32, void ()*, i8* }] [{ i32, void ()*, i8* } { i32 2, void ()* @sancov.module_ctor_8bit_counters, i8* null }]
+
+declare void @runtime.printint(i64)
+
+define void @runtime.foo(i64 %x) {
+entry:
+  call void @runtime.printint(i64 %x)
+  ret void
+}
+
+define i64 @main.max(i64 %a, i64 %b) {
+entry:
+  %0 = load i8, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 0), align 1
+  %1 = add i8 %0, 1
+  store i8 %1, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 0), align 1
+  %cmp = icmp sgt i64 %a, %b
+  br i1 %cmp, label %if.then, label %if.done
+
+if.then:                                          ; preds = %entry
+  %2 = load i8, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 1), align 1
+  %3 = add i8 %2, 1
+  store i8 %3, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 1), align 1
+  br label %if.done
+
+if.done:                                          ; preds = %if.then, %entry
+  %result = phi i64 [ %a, %if.then ], [ %b, %entry ]
+  %4 = load i8, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 2), align 1
+  %5 = add i8 %4, 1
+  store i8 %5, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 2), align 1
+  ret i64 %result
+}
+
+define void @"(*main.T).Method"(i64 %x) {
+entry:
+  %0 = load i8, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 3), align 1
+  %1 = add i8 %0, 1
+  store i8 %1, i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 3), align 1
+  call void @runtime.foo(i64 %x)
+  ret void
+}
+
+define void @__sanitizer_cov_8bit_counters_init(i8* %start, i8* %end) {
+entry:
+  ret void
+}
+
+define internal void @sancov.module_ctor_8bit_counters() {
+entry:
+  call void @__sanitizer_cov_8bit_counters_init(i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i32 0, i32 0), i8* getelementptr inbounds ([4 x i8], [4 x i8]* @__sancov_gen_.counters, i64 1, i32 0))
+  ret void
+}
@@ -0,0 +1,53 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128"
+target triple = "wasm32-unknown-wasi"
+
+declare i8* @runtime.alloc(i32, i8*, i8*)
+
+declare void @"internal/task.Pause"(i8*)
+
+; Allocates in a called function.
+define void @main.allocates(i8* %context) #0 {
+  call void @main.helper(i8* undef)
+  ret void
+}
+
+define internal void @main.helper(i8* %context) {
+  %1 = call i8* @runtime.alloc(i32 4, i8* null, i8* undef)
+  ret void
+}
+
+; Blocks, by locking a mutex.
+define void @main.blocks(i8* %context) #0 {
+  call void @"(*sync.Mutex).Lock"(i8* undef)
+  ret void
+}
+
+define internal void @"(*sync.Mutex).Lock"(i8* %context) {
+  call void @"internal/task.Pause"(i8* undef)
+  ret void
+}
+
+; Doesn't allocate. Indirect calls are not checked.
+define void @main.pure(i8* %context, void (i8*)* %fn) #0 {
+  call void @main.leaf(i8* undef)
+  call void %fn(i8* undef)
+  ret void
+}
+
+define internal void @main.leaf(i8* %context) {
+  ret void
+}
+
+; Not checked, not even for interrupt handlers: these are only checked when
+; marked //go:noalloc.
+define void @main.unchecked(i8* %context) {
+  call void @main.helper(i8* undef)
+  ret void
+}
+
+define internal void @main.handler(i32 %num, i8* %context) {
+  call void @main.helper(i8* undef)
+  ret void
+}
+
+attributes #0 = { "tinygo-noalloc" }